@@ -0,0 +1,251 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+var errDeleteConfirm = errors.New("Confirm with the user name, {\"confirm\": \"<name>\"}")
+
+// userExport is everything GET /v1/users/me/export hands out.
+type userExport struct {
+	Account         userResponse               `json:"account"`
+	Identities      []userIdentityResponse     `json:"identities"`
+	ApiKeys         []apiKeyResponse           `json:"api_keys"`
+	Feeds           []database.Feed            `json:"feeds"`
+	Follows         []database.FeedFollow      `json:"follows"`
+	Bookmarks       []bookmarkResponse         `json:"bookmarks"`
+	ReadingProgress []database.ReadingProgress `json:"reading_progress"`
+	FeedNotes       []exportedFeedNote         `json:"feed_notes"`
+	Webhooks        []webhookResponse          `json:"webhooks"`
+	// NewsletterAddresses and Newsletters are the address of the user for
+	// newsletters and the emails received at it.
+	NewsletterAddresses []database.NewsletterAddress `json:"newsletter_addresses"`
+	Newsletters         []database.Post              `json:"newsletters"`
+}
+
+type exportedFeedNote struct {
+	feedNoteResponse
+	FeedID uuid.UUID `json:"feed_id"`
+}
+
+func exportUser(ctx context.Context, q *database.Queries, user database.User) (userExport, error) {
+	export := userExport{Account: newUserResponse(user)}
+
+	identities, err := q.GetUserIdentities(ctx, user.ID)
+	if err != nil {
+		return export, err
+	}
+	export.Identities = make([]userIdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		export.Identities = append(export.Identities, newUserIdentityResponse(identity.Provider, identity.Email))
+	}
+
+	apiKeys, err := q.GetUserApiKeys(ctx, user.ID)
+	if err != nil {
+		return export, err
+	}
+	export.ApiKeys = make([]apiKeyResponse, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		export.ApiKeys = append(export.ApiKeys, newApiKeyResponse(apiKey))
+	}
+
+	if export.Feeds, err = q.GetUserFeeds(ctx, user.ID); err != nil {
+		return export, err
+	}
+	if export.Follows, err = q.GetUserFeedFollows(ctx, user.ID); err != nil {
+		return export, err
+	}
+
+	bookmarks, err := q.GetUserBookmarks(ctx, user.ID)
+	if err != nil {
+		return export, err
+	}
+	export.Bookmarks = make([]bookmarkResponse, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		export.Bookmarks = append(export.Bookmarks, newBookmarkResponse(bookmark))
+	}
+
+	if export.ReadingProgress, err = q.GetUserReadingProgress(ctx, user.ID); err != nil {
+		return export, err
+	}
+
+	notes, err := q.GetUserFeedNotes(ctx, user.ID)
+	if err != nil {
+		return export, err
+	}
+	export.FeedNotes = make([]exportedFeedNote, 0, len(notes))
+	for _, note := range notes {
+		export.FeedNotes = append(export.FeedNotes, exportedFeedNote{newFeedNoteResponse(note), note.FeedID})
+	}
+
+	webhooks, err := q.GetUserWebhooks(ctx, user.ID)
+	if err != nil {
+		return export, err
+	}
+	export.Webhooks = make([]webhookResponse, 0, len(webhooks))
+	for _, hook := range webhooks {
+		export.Webhooks = append(export.Webhooks, newWebhookResponse(hook, false))
+	}
+
+	export.NewsletterAddresses = []database.NewsletterAddress{}
+	address, err := q.GetNewsletterAddressByUser(ctx, user.ID)
+	if err == nil {
+		export.NewsletterAddresses = append(export.NewsletterAddresses, address)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return export, err
+	}
+	if export.Newsletters, err = q.GetUserNewsletters(ctx, user.ID); err != nil {
+		return export, err
+	}
+
+	return export, nil
+}
+
+// writeExportZip writes each part of the export as its own JSON file.
+func writeExportZip(w http.ResponseWriter, export userExport) error {
+	parts := []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", export.Account},
+		{"identities.json", export.Identities},
+		{"api_keys.json", export.ApiKeys},
+		{"feeds.json", export.Feeds},
+		{"follows.json", export.Follows},
+		{"bookmarks.json", export.Bookmarks},
+		{"reading_progress.json", export.ReadingProgress},
+		{"feed_notes.json", export.FeedNotes},
+		{"webhooks.json", export.Webhooks},
+		{"newsletter_addresses.json", export.NewsletterAddresses},
+		{"newsletters.json", export.Newsletters},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(part.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+/*
+Endpoint: GET /v1/users/me/export?format=
+
+# This endpoint requires an API key
+
+Or an access token. Exports everything stored about the user: the account,
+linked logins, API keys without the keys, the feeds they added, follows,
+bookmarks, reading progress, feed notes they wrote, webhooks without their
+secrets, and their newsletter address with the emails received at it. format is json, the default, for one JSON document, or zip for a zip
+with a JSON file for each.
+*/
+func getUserExportHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "zip" {
+			respondWithError(w, 400, "Invalid format, expected json or zip")
+			return
+		}
+
+		context := r.Context()
+		var export userExport
+		// one transaction, so the parts agree with each other
+		err := database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			var err error
+			export, err = exportUser(context, q, user)
+			return err
+		})
+		if err != nil {
+			httpLog.Error("Error exporting user", "err", err)
+			respondWithError(w, 500, "Error exporting user")
+			return
+		}
+
+		filename := "blogator-" + user.Name + "-" + time.Now().Format("2006-01-02")
+		if format != "zip" {
+			w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+			respondWithJSON(w, 200, export)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		if err := writeExportZip(w, export); err != nil {
+			httpLog.Error("Error writing export", "err", err)
+		}
+	}
+}
+
+/*
+Endpoint: DELETE /v1/users/me
+
+# This endpoint requires an API key
+
+Or an access token. Deletes the user and everything stored about them, see
+GET /v1/users/me/export to keep a copy first. Feeds they added that others
+follow stay, they are handed to the follower who followed first, without the
+credentials the user set for them. Their newsletter address and the emails
+received at it are deleted. Needs the user name to confirm:
+
+	{
+		"confirm": "jane"
+	}
+*/
+func deleteUserHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type DeleteRequest struct {
+			Confirm string `json:"confirm"`
+		}
+
+		var req DeleteRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Confirm != user.Name {
+			respondWithError(w, 400, errDeleteConfirm.Error())
+			return
+		}
+
+		context := r.Context()
+		err := database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			feedIDs, err := q.HandOverUserFeeds(context, user.ID)
+			if err != nil {
+				return err
+			}
+			for _, feedID := range feedIDs {
+				if _, err := q.DeleteFeedCredentials(context, feedID); err != nil {
+					return err
+				}
+			}
+
+			if err := q.DeleteUser(context, user.ID); err != nil {
+				return err
+			}
+			// after the user, deleting their follows and reads logs changes
+			return q.DeleteUserSyncChanges(context, user.ID)
+		})
+		if err != nil {
+			httpLog.Error("Error deleting user", "err", err)
+			respondWithError(w, 500, "Error deleting user")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/metrics"
+)
+
+// adminLargestFeedTransfers is how many feeds the metrics list by size.
+const adminLargestFeedTransfers = 10
+
+// adminHandler guards operator endpoints with the ADMIN_TOKEN, sent as
+// "Authorization: AdminToken <token>". Without an ADMIN_TOKEN they don't exist.
+func (cfg *apiConfig) adminHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AdminToken == "" {
+			respondWithError(w, 404, "Not found")
+			return
+		}
+
+		scheme, credential, err := parseAuthorization(r.Header.Get("Authorization"))
+		if err != nil || scheme != "AdminToken" || subtle.ConstantTimeCompare([]byte(credential), []byte(cfg.AdminToken)) != 1 {
+			respondWithError(w, 401, "Unauthorized")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+/*
+Endpoint: GET /v1/admin/metrics
+
+# This is an admin endpoint
+
+Returns latency percentiles per route, measured against the SLO target, the
+latest queries slower than the slow query threshold, and how many bytes feed
+fetches transferred against their decoded size, with the feeds that were
+largest on their last fetch.
+*/
+func getAdminMetricsHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type FeedTransfer struct {
+			FeedID          uuid.UUID `json:"feed_id"`
+			Url             string    `json:"url"`
+			TransferBytes   int64     `json:"transfer_bytes"`
+			BodyBytes       int64     `json:"body_bytes"`
+			ContentEncoding string    `json:"content_encoding"`
+		}
+		type FeedTransfers struct {
+			Fetches       int64          `json:"fetches"`
+			TransferBytes int64          `json:"transfer_bytes"`
+			BodyBytes     int64          `json:"body_bytes"`
+			Largest       []FeedTransfer `json:"largest"`
+		}
+		type MetricsResponse struct {
+			SLOTargetMs   int64                `json:"slo_target_ms"`
+			Routes        []metrics.RouteStats `json:"routes"`
+			SlowQueries   []metrics.SlowQuery  `json:"slow_queries"`
+			FeedTransfers FeedTransfers        `json:"feed_transfers"`
+		}
+
+		context := r.Context()
+		totals, err := apiConfig.DB.GetFeedTransferTotals(context)
+		if err != nil {
+			httpLog.Error("Error getting feed transfer totals", "err", err)
+			respondWithError(w, 500, "Error getting metrics")
+			return
+		}
+		largest, err := apiConfig.DB.GetLargestFeedTransfers(context, adminLargestFeedTransfers)
+		if err != nil {
+			httpLog.Error("Error getting largest feed transfers", "err", err)
+			respondWithError(w, 500, "Error getting metrics")
+			return
+		}
+
+		transfers := FeedTransfers{
+			Fetches:       totals.Fetches,
+			TransferBytes: totals.TransferBytes,
+			BodyBytes:     totals.BodyBytes,
+			Largest:       make([]FeedTransfer, 0, len(largest)),
+		}
+		for _, feed := range largest {
+			transfers.Largest = append(transfers.Largest, FeedTransfer{
+				FeedID:          feed.ID,
+				Url:             feed.Url,
+				TransferBytes:   feed.LastTransferBytes,
+				BodyBytes:       feed.LastBodyBytes,
+				ContentEncoding: feed.LastContentEncoding,
+			})
+		}
+
+		respondWithJSON(w, 200, MetricsResponse{
+			SLOTargetMs:   apiConfig.Routes.Target().Milliseconds(),
+			Routes:        apiConfig.Routes.Snapshot(),
+			SlowQueries:   apiConfig.SlowQueries.Snapshot(),
+			FeedTransfers: transfers,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// normalizeAllowedCIDRs validates the ranges an API key is restricted to.
+// Plain addresses are accepted and turned into single host ranges.
+func normalizeAllowedCIDRs(cidrs []string) ([]string, error) {
+	normalized := []string{}
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid CIDR: %s", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR: %s", cidr)
+		}
+
+		normalized = append(normalized, network.String())
+	}
+
+	return normalized, nil
+}
+
+// requestAllowedFrom reports whether the request comes from one of the
+// ranges. An empty list means the key can be used from anywhere.
+func requestAllowedFrom(r *http.Request, cidrs []string) bool {
+	return addrAllowedFrom(r.RemoteAddr, cidrs)
+}
+
+// addrAllowedFrom is requestAllowedFrom for a remote address.
+func addrAllowedFrom(remoteAddr string, cidrs []string) bool {
+	if len(cidrs) == 0 {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
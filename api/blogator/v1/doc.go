@@ -0,0 +1,5 @@
+// Package blogatorv1 holds the protobuf messages and gRPC stubs of the
+// aggregator's gRPC API, generated from blogator.proto.
+package blogatorv1
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative blogator.proto
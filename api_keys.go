@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+var apiKeyScopes = []string{scopeRead, scopePostsWrite, scopeFeedsAdmin}
+
+// authenticateApiKey resolves the user of an API key, their own key with nil
+// scopes or one of their scoped keys. Unknown keys are sql.ErrNoRows.
+func (cfg *apiConfig) authenticateApiKey(ctx context.Context, key string) (database.User, []string, error) {
+	keyHash := hashToken(key)
+	user, err := cfg.DB.GetUserByApiKeyHash(ctx, keyHash)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user, nil, err
+	}
+
+	apiKey, err := cfg.DB.GetApiKeyByHash(ctx, keyHash)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	user, err = cfg.DB.GetUserByID(ctx, apiKey.UserID)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	scopes := apiKey.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return user, scopes, nil
+}
+
+type apiKeyResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	Key       string    `json:"key,omitempty"`
+}
+
+func newApiKeyResponse(apiKey database.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:        apiKey.ID,
+		CreatedAt: apiKey.CreatedAt,
+		Name:      apiKey.Name,
+		Scopes:    apiKey.Scopes,
+	}
+}
+
+/*
+Endpoint: POST /v1/api_keys
+
+# This endpoint requires an API key
+
+Creates an extra API key limited to scopes, e.g. a read-only key for a
+dashboard widget:
+
+	{
+		"name": "dashboard",
+		"scopes": ["read"]
+	}
+
+The scopes are:
+
+	read         everything that only reads
+	posts:write  read state, bookmarks and POST /v1/batch
+	feeds:admin  adding, changing and following feeds
+
+The write scopes can read as well. Everything else, like webhooks or the
+account, needs the user's own key. The key is only returned here, it is used
+like the user's own one: Authorization: ApiKey <key>.
+*/
+func postApiKeyHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ApiKeyRequest struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+
+		var req ApiKeyRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		var v validation
+		v.require("name", req.Name, "Name is required")
+		if len(req.Scopes) == 0 {
+			v.add("scopes", "At least one scope is required, one of "+strings.Join(apiKeyScopes, ", "))
+		}
+		for _, scope := range req.Scopes {
+			if !slices.Contains(apiKeyScopes, scope) {
+				v.add("scopes", "Unknown scope: "+scope)
+			}
+		}
+		if v.respond(w) {
+			return
+		}
+
+		key, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating api key", "err", err)
+			respondWithError(w, 500, "Error creating api key")
+			return
+		}
+
+		context := r.Context()
+		apiKey, err := apiConfig.DB.CreateApiKey(context, database.CreateApiKeyParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UserID:    user.ID,
+			Name:      req.Name,
+			KeyHash:   hashToken(key),
+			Scopes:    req.Scopes,
+		})
+		if err != nil {
+			httpLog.Error("Error creating api key", "err", err)
+			respondWithError(w, 500, "Error creating api key")
+			return
+		}
+
+		resp := newApiKeyResponse(apiKey)
+		resp.Key = key
+		respondCreated(w, "/v1/api_keys/"+apiKey.ID.String(), resp)
+	}
+}
+
+/*
+Endpoint: GET /v1/api_keys
+
+# This is an authenticated endpoint
+
+Lists the scoped API keys of the user, without the keys themselves.
+*/
+func getApiKeysHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		apiKeys, err := apiConfig.DB.GetUserApiKeys(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting api keys", "err", err)
+			respondWithError(w, 500, "Error getting api keys")
+			return
+		}
+
+		resp := make([]apiKeyResponse, 0, len(apiKeys))
+		for _, apiKey := range apiKeys {
+			resp = append(resp, newApiKeyResponse(apiKey))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/api_keys/{api_key_id}
+
+# This is an authenticated endpoint
+
+Revokes a scoped API key.
+*/
+func deleteApiKeyHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		apiKeyID, err := uuid.Parse(chi.URLParam(r, "api_key_id"))
+		if err != nil {
+			respondWithError(w, 400, "Invalid api key id")
+			return
+		}
+
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteApiKey(context, database.DeleteApiKeyParams{
+			ID:     apiKeyID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting api key", "err", err)
+			respondWithError(w, 500, "Error deleting api key")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "api_key_not_found", "Api key not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
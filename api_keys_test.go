@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestUnknownApiKeyUnauthorized(t *testing.T) {
+	_, db := newFakeDB(t)
+	apiConfig := apiConfig{DB: db}
+	handler := apiConfig.authedHandler(func(w http.ResponseWriter, r *http.Request, user database.User) {
+		t.Error("the handler ran without a user")
+	})
+
+	for _, auth := range []string{"", "ApiKey unknown", "ApiKey"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/me/feed_token", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		handler(w, r)
+
+		if w.Code != 401 {
+			t.Errorf("Authorization %q: status = %d, want 401: %s", auth, w.Code, w.Body)
+		}
+		if got := w.Header().Get("WWW-Authenticate"); !strings.Contains(got, "ApiKey") {
+			t.Errorf("Authorization %q: WWW-Authenticate = %q", auth, got)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// batchMaxOperations bounds the operations of one POST /v1/batch.
+const batchMaxOperations = 500
+
+type batchOperation struct {
+	Op     string    `json:"op"`
+	PostID uuid.UUID `json:"post_id"`
+	FeedID uuid.UUID `json:"feed_id"`
+}
+
+type batchResult struct {
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchEvent is a webhook event of an operation, sent once the batch is
+// committed.
+type batchEvent struct {
+	Type string
+	Data interface{}
+}
+
+/*
+Endpoint: POST /v1/batch
+
+# This is an authenticated endpoint
+
+Runs many small operations in one transaction, so a sync client can send what
+it did offline in one request:
+
+	[
+		{"op": "read", "post_id": "..."},
+		{"op": "star", "post_id": "..."},
+		{"op": "follow", "feed_id": "..."},
+		{"op": "unfollow", "feed_id": "..."}
+	]
+
+read marks the post read like PUT /v1/posts/{post_id}/progress with 100
+percent, star bookmarks it. Up to 500 operations are accepted. The response
+has a result for each operation, in order, with the status the single
+endpoint would answer with:
+
+	[{"status": 200}, {"status": 409, "error": "Post is already bookmarked"}, ...]
+
+follow and unfollow need the feeds:admin scope with a scoped API key, see
+POST /v1/api_keys. An operation failing that way doesn't undo the others. When the database
+fails the whole batch is rolled back and the response is a 500.
+*/
+func postBatchHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		var ops []batchOperation
+		if !decodeJSONBody(w, r, &ops) {
+			return
+		}
+		if len(ops) > batchMaxOperations {
+			respondWithError(w, 400, "Too many operations, expected up to "+strconv.Itoa(batchMaxOperations))
+			return
+		}
+
+		scopes := requestScopes(r)
+		context := r.Context()
+		var results []batchResult
+		var events []batchEvent
+		err := database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			results = make([]batchResult, 0, len(ops))
+			for _, op := range ops {
+				result, event, err := runBatchOperation(context, q, user.ID, scopes, op)
+				if err != nil {
+					return err
+				}
+				results = append(results, result)
+				if event != nil {
+					events = append(events, *event)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			httpLog.Error("Error running batch", "err", err)
+			respondWithError(w, 500, "Error running batch")
+			return
+		}
+
+		for _, event := range events {
+			dispatchUserEvent(context, apiConfig, user.ID, event.Type, event.Data)
+		}
+
+		respondWithJSON(w, 200, results)
+	}
+}
+
+// runBatchOperation runs one operation of a batch. Errors are database
+// errors that fail the whole batch, everything else is in the result.
+func runBatchOperation(ctx context.Context, q *database.Queries, userID uuid.UUID, scopes []string, op batchOperation) (batchResult, *batchEvent, error) {
+	if (op.Op == "follow" || op.Op == "unfollow") && !scopesAllow(scopes, http.MethodPost, scopeFeedsAdmin) {
+		return batchResult{Status: 403, Error: "Insufficient scope"}, nil, nil
+	}
+
+	switch op.Op {
+	case "read", "star":
+		post, err := q.GetPostByID(ctx, op.PostID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return batchResult{Status: 404, Error: "Post not found"}, nil, nil
+		}
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+
+		if op.Op == "read" {
+			_, err = q.UpsertReadingProgress(ctx, database.UpsertReadingProgressParams{
+				UserID:    userID,
+				PostID:    post.ID,
+				UpdatedAt: time.Now(),
+				Percent:   100,
+			})
+			if err != nil {
+				return batchResult{}, nil, err
+			}
+			return batchResult{Status: 200}, nil, nil
+		}
+
+		follows, err := followsFeed(ctx, q, userID, post.FeedID)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		if !follows {
+			return batchResult{Status: 404, Error: "Post not found"}, nil, nil
+		}
+
+		_, err = q.CreateBookmark(ctx, database.CreateBookmarkParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			UserID:      userID,
+			PostID:      uuid.NullUUID{UUID: post.ID, Valid: true},
+			Url:         post.Url,
+			Title:       post.Title,
+			Description: post.Description,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return batchResult{Status: 409, Error: errAlreadyBookmarked.Error()}, nil, nil
+		}
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		return batchResult{Status: 200}, &batchEvent{eventPostBookmarked, post}, nil
+
+	case "follow":
+		_, err := q.GetVisibleFeedByID(ctx, database.GetVisibleFeedByIDParams{
+			ID:     op.FeedID,
+			UserID: userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return batchResult{Status: 404, Error: "Feed not found"}, nil, nil
+		}
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+
+		feedFollow, created, err := followFeed(ctx, q, userID, op.FeedID)
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		if !created {
+			return batchResult{Status: 200}, nil, nil
+		}
+		return batchResult{Status: 200}, &batchEvent{eventFollowCreated, feedFollow}, nil
+
+	case "unfollow":
+		deleted, err := q.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
+			UserID: userID,
+			FeedID: op.FeedID,
+		})
+		if err != nil {
+			return batchResult{}, nil, err
+		}
+		if deleted == 0 {
+			return batchResult{Status: 404, Error: "Feed follow not found"}, nil, nil
+		}
+		return batchResult{Status: 200}, &batchEvent{eventFollowDeleted, struct {
+			UserID uuid.UUID `json:"user_id"`
+			FeedID uuid.UUID `json:"feed_id"`
+		}{userID, op.FeedID}}, nil
+	}
+
+	return batchResult{Status: 400, Error: "Unknown op, expected read, star, follow or unfollow"}, nil, nil
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const (
+	bookmarksImportMaxBody = 10 << 20
+	bookmarkPreviewTimeout = 10 * time.Second
+	bookmarkPreviewMaxBody = 1 << 20
+)
+
+var errAlreadyBookmarked = errors.New("Post is already bookmarked")
+
+type bookmarkResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	PostID      *uuid.UUID `json:"post_id"`
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Folder      string     `json:"folder"`
+}
+
+func newBookmarkResponse(bookmark database.Bookmark) bookmarkResponse {
+	resp := bookmarkResponse{
+		ID:          bookmark.ID,
+		CreatedAt:   bookmark.CreatedAt,
+		URL:         bookmark.Url,
+		Title:       bookmark.Title,
+		Description: bookmark.Description,
+		Folder:      bookmark.Folder,
+	}
+	if bookmark.PostID.Valid {
+		resp.PostID = &bookmark.PostID.UUID
+	}
+
+	return resp
+}
+
+/*
+Endpoint: POST /v1/bookmarks
+
+# This is an authenticated endpoint
+
+Bookmarks a post of a feed the user follows. Bookmarking the same post twice
+returns 409.
+*/
+func postBookmarkHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type BookmarkRequest struct {
+			PostID uuid.UUID `json:"post_id"`
+		}
+
+		var req BookmarkRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		post, err := apiConfig.DB.GetPostByID(context, req.PostID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "post_not_found", "Post not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting post", "err", err)
+			respondWithError(w, 500, "Error creating bookmark")
+			return
+		}
+		follows, err := followsFeed(context, apiConfig.DB, user.ID, post.FeedID)
+		if err != nil {
+			httpLog.Error("Error getting feed follows", "err", err)
+			respondWithError(w, 500, "Error creating bookmark")
+			return
+		}
+		if !follows {
+			// the same as a missing post, to not tell which exist
+			respondWithErrorCode(w, 404, "post_not_found", "Post not found")
+			return
+		}
+
+		bookmark, err := bookmarkPost(context, apiConfig, user.ID, post)
+		if errors.Is(err, errAlreadyBookmarked) {
+			respondWithError(w, 409, err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error creating bookmark", "err", err)
+			respondWithError(w, 500, "Error creating bookmark")
+			return
+		}
+
+		respondCreated(w, "/v1/bookmarks/"+bookmark.ID.String(), newBookmarkResponse(bookmark))
+	}
+}
+
+// bookmarkPost bookmarks the post for the user and tells their webhooks.
+func bookmarkPost(ctx context.Context, apiConfig apiConfig, userID uuid.UUID, post database.Post) (database.Bookmark, error) {
+	bookmark, err := apiConfig.DB.CreateBookmark(ctx, database.CreateBookmarkParams{
+		ID:          uuid.New(),
+		CreatedAt:   time.Now(),
+		UserID:      userID,
+		PostID:      uuid.NullUUID{UUID: post.ID, Valid: true},
+		Url:         post.Url,
+		Title:       post.Title,
+		Description: post.Description,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Bookmark{}, errAlreadyBookmarked
+	}
+	if err != nil {
+		return database.Bookmark{}, err
+	}
+
+	dispatchUserEvent(ctx, apiConfig, userID, eventPostBookmarked, post)
+
+	return bookmark, nil
+}
+
+/*
+Endpoint: GET /v1/bookmarks
+
+# This is an authenticated endpoint
+
+Lists the bookmarks of the user, newest first.
+*/
+func getBookmarksHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		bookmarks, err := apiConfig.DB.GetUserBookmarks(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting bookmarks", "err", err)
+			respondWithError(w, 500, "Error getting bookmarks")
+			return
+		}
+
+		resp := make([]bookmarkResponse, 0, len(bookmarks))
+		for _, bookmark := range bookmarks {
+			resp = append(resp, newBookmarkResponse(bookmark))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/bookmarks/{bookmark_id}
+
+# This is an authenticated endpoint
+*/
+func deleteBookmarkHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		bookmarkID, err := uuid.Parse(chi.URLParam(r, "bookmark_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid bookmark_id")
+			return
+		}
+
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteBookmark(context, database.DeleteBookmarkParams{ID: bookmarkID, UserID: user.ID})
+		if err != nil {
+			httpLog.Error("Error deleting bookmark", "err", err)
+			respondWithError(w, 500, "Error deleting bookmark")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "bookmark_not_found", "Bookmark not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: POST /v1/bookmarks/import?folder=
+
+# This is an authenticated endpoint
+
+Imports a bookmarks file as exported by browsers (the Netscape bookmark
+format), either as the request body or as the "file" field of a multipart
+form. Every folder parameter selects a folder to import, given as its path
+like "Bookmarks bar/Blogs", subfolders included. Without one the whole file
+is imported. Urls that are already bookmarked are skipped.
+
+Titles and descriptions are completed from the pages themselves in the
+background after the response.
+*/
+func importBookmarksHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ImportResponse struct {
+			Imported int      `json:"imported"`
+			Skipped  int      `json:"skipped"`
+			Folders  []string `json:"folders"`
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, bookmarksImportMaxBody)
+		file, err := bookmarksUpload(r)
+		if err != nil {
+			respondWithError(w, 400, "Error reading bookmarks file")
+			return
+		}
+
+		entries, err := parseNetscapeBookmarks(file)
+		if err != nil {
+			respondWithError(w, 400, "Error parsing bookmarks file")
+			return
+		}
+
+		folders := r.URL.Query()["folder"]
+		resp := ImportResponse{Folders: []string{}}
+		var imported []database.Bookmark
+
+		context := r.Context()
+		for _, entry := range entries {
+			if !slices.Contains(resp.Folders, entry.Folder) {
+				resp.Folders = append(resp.Folders, entry.Folder)
+			}
+			if len(folders) > 0 && !inBookmarkFolders(entry.Folder, folders) {
+				continue
+			}
+
+			bookmarkURL, err := normalizeFeedURL(entry.URL)
+			if err != nil {
+				resp.Skipped++
+				continue
+			}
+
+			bookmark, err := apiConfig.DB.CreateBookmark(context, database.CreateBookmarkParams{
+				ID:          uuid.New(),
+				CreatedAt:   time.Now(),
+				UserID:      user.ID,
+				Url:         bookmarkURL,
+				Title:       entry.Title,
+				Description: entry.Description,
+				Folder:      entry.Folder,
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				resp.Skipped++
+				continue
+			}
+			if err != nil {
+				httpLog.Error("Error importing bookmark", "err", err)
+				respondWithError(w, 500, "Error importing bookmarks")
+				return
+			}
+
+			imported = append(imported, bookmark)
+			resp.Imported++
+		}
+
+		go fetchBookmarkPreviews(apiConfig, imported)
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+// bookmarksUpload returns the uploaded file, sent either as the "file" field
+// of a multipart form or as the plain request body.
+func bookmarksUpload(r *http.Request) (io.Reader, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// bookmarkEntry is a single link of a bookmarks file.
+type bookmarkEntry struct {
+	URL         string
+	Title       string
+	Description string
+	// Folder is the path of folder names leading to the link, joined with "/".
+	Folder string
+}
+
+// parseNetscapeBookmarks reads the <DL>/<DT> tree browsers export bookmarks
+// as. Folders are <H3> headings followed by a nested <DL> of their content.
+func parseNetscapeBookmarks(r io.Reader) ([]bookmarkEntry, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []bookmarkEntry
+	doc.Find("dl a[href]").Each(func(_ int, link *goquery.Selection) {
+		var path []string
+		link.ParentsFiltered("dl").Each(func(_ int, list *goquery.Selection) {
+			if heading := list.PrevAllFiltered("h3").First(); heading.Length() > 0 {
+				path = append([]string{strings.TrimSpace(heading.Text())}, path...)
+			}
+		})
+
+		href, _ := link.Attr("href")
+		entries = append(entries, bookmarkEntry{
+			URL:         href,
+			Title:       strings.TrimSpace(link.Text()),
+			Description: strings.TrimSpace(link.Parent().NextFiltered("dd").Text()),
+			Folder:      strings.Join(path, "/"),
+		})
+	})
+
+	return entries, nil
+}
+
+// inBookmarkFolders reports whether folder is one of folders or inside one.
+func inBookmarkFolders(folder string, folders []string) bool {
+	for _, f := range folders {
+		f = strings.Trim(f, "/")
+		if folder == f || strings.HasPrefix(folder, f+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchBookmarkPreviews fills in titles and descriptions of freshly imported
+// bookmarks from the pages they point to, one page at a time.
+func fetchBookmarkPreviews(apiConfig apiConfig, bookmarks []database.Bookmark) {
+	for _, bookmark := range bookmarks {
+		ctx, cancel := context.WithTimeout(context.Background(), bookmarkPreviewTimeout)
+		title, description, err := fetchPagePreview(ctx, bookmark.Url)
+		cancel()
+		if err != nil {
+			fetcherLog.Error("Error fetching preview", "bookmark_url", bookmark.Url, "err", err)
+			continue
+		}
+
+		if description == "" {
+			description = bookmark.Description
+		}
+		err = apiConfig.DB.UpdateBookmarkPreview(context.Background(), database.UpdateBookmarkPreviewParams{
+			ID:          bookmark.ID,
+			Title:       title,
+			Description: description,
+		})
+		if err != nil {
+			fetcherLog.Error("Error saving preview", "bookmark_url", bookmark.Url, "err", err)
+		}
+	}
+}
+
+// fetchPagePreview reads the title and description of an html page.
+func fetchPagePreview(ctx context.Context, pageURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	// the head of the page is all that's needed, so a truncated page is fine
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, bookmarkPreviewMaxBody))
+	if err != nil {
+		return "", "", err
+	}
+
+	metadata := extractMetadata(doc, resp.Request.URL)
+	return metadata.Title, metadata.Description, nil
+}
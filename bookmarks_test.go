@@ -0,0 +1,35 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestBookmarkPostOfUnfollowedFeed(t *testing.T) {
+	bob := database.User{ID: uuid.New()}
+	post := database.Post{ID: uuid.New(), FeedID: uuid.New(), Title: "Private", Url: "https://example.com/post"}
+
+	fake, db := newFakeDB(t)
+	fake.on("GetPostByID", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{postRow(post)}}
+	})
+	apiConfig := apiConfig{DB: db}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/bookmarks", strings.NewReader(`{"post_id": "`+post.ID.String()+`"}`))
+	r.Header.Set("Content-Type", "application/json")
+	asUser(bob, postBookmarkHandler(apiConfig))(w, r)
+
+	if w.Code != 404 {
+		t.Errorf("status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if fake.ran("CreateBookmark") {
+		t.Error("bob bookmarked a post of a feed they don't follow")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+
+	// xmlDeclEncoding is the encoding attribute of an XML declaration, the
+	// value in the second group.
+	xmlDeclEncoding = regexp.MustCompile(`^(\s*<\?xml[^>]*?\sencoding\s*=\s*["'])([A-Za-z0-9._:-]+)(["'])`)
+)
+
+// feedBodyToUTF8 transcodes a feed to UTF-8 before it's parsed. The charset
+// is taken from a byte order mark, then the charset of the Content-Type, then
+// the XML declaration, the precedence of RFC 7303. Bodies without any that
+// aren't valid UTF-8 are taken as windows-1252, the usual charset of old feeds
+// that don't declare one. The XML declaration of a transcoded body is changed
+// to UTF-8, so the parser doesn't decode it a second time.
+//
+// Bodies in an unknown charset are returned as they are.
+func feedBodyToUTF8(body []byte, contentType string) []byte {
+	switch {
+	case bytes.HasPrefix(body, utf8BOM):
+		return body[len(utf8BOM):]
+	case bytes.HasPrefix(body, utf16LEBOM):
+		return transcodeFeedBody(body[len(utf16LEBOM):], "utf-16le")
+	case bytes.HasPrefix(body, utf16BEBOM):
+		return transcodeFeedBody(body[len(utf16BEBOM):], "utf-16be")
+	}
+
+	label := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		label = params["charset"]
+	}
+	if label == "" {
+		if m := xmlDeclEncoding.FindSubmatch(body); m != nil {
+			label = string(m[2])
+		}
+	}
+	if label == "" {
+		if utf8.Valid(body) {
+			return body
+		}
+		label = "windows-1252"
+	}
+
+	return transcodeFeedBody(body, label)
+}
+
+func transcodeFeedBody(body []byte, label string) []byte {
+	enc, name := charset.Lookup(strings.TrimSpace(label))
+	if enc == nil {
+		return body
+	}
+	if name == "utf-8" {
+		return utf8DeclaredBody(body)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+
+	return utf8DeclaredBody(decoded)
+}
+
+// utf8DeclaredBody changes the encoding of the XML declaration, if there is
+// one, to UTF-8.
+func utf8DeclaredBody(body []byte) []byte {
+	return xmlDeclEncoding.ReplaceAll(body, []byte("${1}UTF-8${3}"))
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+/*
+Chat notifications post new posts to a Slack incoming webhook or a Discord
+webhook. A notification covers one feed, or all feeds its user follows, and
+can be narrowed down to posts mentioning one of its keywords.
+
+New posts are queued and sent every CHAT_NOTIFICATION_INTERVAL, one message
+per notification, so a feed publishing its whole archive at once makes one
+message instead of a flood.
+*/
+
+const (
+	chatProviderSlack   = "slack"
+	chatProviderDiscord = "discord"
+
+	// chatBatchMax is how many posts one message lists, the rest are counted
+	chatBatchMax = 10
+	// chatMessageMax is the length of a message Discord still takes
+	chatMessageMax     = 2000
+	chatTemplateMax    = 1000
+	chatKeywordsMax    = 20
+	chatDefaultSlack   = "<{{.URL}}|{{.Title}}> ({{.Feed}})"
+	chatDefaultDiscord = "[{{.Title}}](<{{.URL}}>) ({{.Feed}})"
+)
+
+// chatPost is what the template of a notification renders for every post.
+type chatPost struct {
+	Title       string
+	URL         string
+	Feed        string
+	Author      string
+	PublishedAt time.Time
+}
+
+type chatNotificationResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Provider   string     `json:"provider"`
+	WebhookURL string     `json:"webhook_url"`
+	FeedID     *uuid.UUID `json:"feed_id"`
+	Keywords   []string   `json:"keywords"`
+	Template   string     `json:"template"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+func newChatNotificationResponse(notification database.ChatNotification) chatNotificationResponse {
+	resp := chatNotificationResponse{
+		ID:         notification.ID,
+		CreatedAt:  notification.CreatedAt,
+		Provider:   notification.Provider,
+		WebhookURL: notification.WebhookUrl,
+		Keywords:   notification.Keywords,
+		Template:   chatTemplate(notification),
+		LastError:  notification.LastError.String,
+	}
+	if notification.FeedID.Valid {
+		resp.FeedID = &notification.FeedID.UUID
+	}
+	if notification.LastSentAt.Valid {
+		resp.LastSentAt = &notification.LastSentAt.Time
+	}
+
+	return resp
+}
+
+// validateChatWebhookURL only takes the webhook urls of the provider, so
+// notifications can't be pointed at anything else.
+func validateChatWebhookURL(provider, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" {
+		return errors.New("Invalid webhook URL")
+	}
+
+	switch provider {
+	case chatProviderSlack:
+		if u.Host != "hooks.slack.com" || !strings.HasPrefix(u.Path, "/services/") {
+			return errors.New("Not a Slack incoming webhook URL")
+		}
+	case chatProviderDiscord:
+		if (u.Host != "discord.com" && u.Host != "discordapp.com") || !strings.HasPrefix(u.Path, "/api/webhooks/") {
+			return errors.New("Not a Discord webhook URL")
+		}
+	default:
+		return fmt.Errorf("Unknown provider: %s", provider)
+	}
+
+	return nil
+}
+
+// chatTemplate is the template of the notification, or the default of its
+// provider.
+func chatTemplate(notification database.ChatNotification) string {
+	if notification.Template != "" {
+		return notification.Template
+	}
+	if notification.Provider == chatProviderDiscord {
+		return chatDefaultDiscord
+	}
+	return chatDefaultSlack
+}
+
+// parseChatTemplate checks the template by rendering a post with it.
+func parseChatTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("chat").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid template: %w", err)
+	}
+
+	sample := chatPost{Title: "Title", URL: "https://example.com/", Feed: "Feed", PublishedAt: time.Now()}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("Invalid template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// chatNotificationMatches tells whether the post has one of the keywords of
+// the notification in its title, description or categories. Notifications
+// without keywords take every post.
+func chatNotificationMatches(notification database.ChatNotification, post database.Post) bool {
+	if len(notification.Keywords) == 0 {
+		return true
+	}
+
+	text := strings.ToLower(post.Title + "\n" + post.Description + "\n" + strings.Join(post.Categories, "\n"))
+	for _, keyword := range notification.Keywords {
+		if strings.Contains(text, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
+
+// queueChatNotifications queues the new posts of the feed for the chat
+// notifications they match.
+func queueChatNotifications(ctx context.Context, apiConfig apiConfig, feedID uuid.UUID, posts []database.Post) {
+	if len(posts) == 0 {
+		return
+	}
+
+	notifications, err := apiConfig.DB.GetChatNotificationsForFeed(ctx, feedID)
+	if err != nil {
+		notificationsLog.Error("Error getting chat notifications", "err", err)
+		return
+	}
+
+	for _, notification := range notifications {
+		for _, post := range posts {
+			if !chatNotificationMatches(notification, post) {
+				continue
+			}
+
+			err := apiConfig.DB.QueueChatNotificationPost(ctx, database.QueueChatNotificationPostParams{
+				ChatNotificationID: notification.ID,
+				PostID:             post.ID,
+				CreatedAt:          time.Now(),
+			})
+			if err != nil {
+				notificationsLog.Error("Error queueing chat notification", "err", err)
+			}
+		}
+	}
+}
+
+// sendChatNotifications sends a message for every notification with queued
+// posts. Instances sending at the same time don't send a post twice, claiming
+// the posts deletes them from the queue.
+func sendChatNotifications(ctx context.Context, apiConfig apiConfig) {
+	notifications, err := apiConfig.DB.GetQueuedChatNotifications(ctx)
+	if err != nil {
+		notificationsLog.Error("Error getting chat notifications", "err", err)
+		return
+	}
+
+	for _, notification := range notifications {
+		posts, err := apiConfig.DB.ClaimChatNotificationPosts(ctx, notification.ID)
+		if err != nil {
+			notificationsLog.Error("Error claiming chat notification posts", "err", err)
+			continue
+		}
+		if len(posts) == 0 {
+			continue
+		}
+
+		var lastError sql.NullString
+		err = sendChatMessage(ctx, notification, posts)
+		if err != nil {
+			notificationsLog.Error("Error sending chat notification", "notification_id", notification.ID, "err", err)
+			lastError = sql.NullString{String: err.Error(), Valid: true}
+		}
+
+		err = apiConfig.DB.FinishChatNotification(ctx, database.FinishChatNotificationParams{
+			ID:        notification.ID,
+			LastError: lastError,
+		})
+		if err != nil {
+			notificationsLog.Error("Error recording chat notification", "err", err)
+		}
+	}
+}
+
+// chatMessage renders the posts with the template of the notification, a
+// line each, listing at most chatBatchMax of them.
+func chatMessage(notification database.ChatNotification, posts []database.ClaimChatNotificationPostsRow) (string, error) {
+	tmpl, err := parseChatTemplate(chatTemplate(notification))
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	if len(posts) > 1 {
+		lines = append(lines, fmt.Sprintf("%d new posts", len(posts)))
+	}
+	length := 0
+	for i, post := range posts {
+		var line bytes.Buffer
+		err := tmpl.Execute(&line, chatPost{
+			Title:       chatEscape(notification.Provider, post.Title),
+			URL:         post.Url,
+			Feed:        chatEscape(notification.Provider, post.FeedName),
+			Author:      chatEscape(notification.Provider, post.Author),
+			PublishedAt: post.PublishedAt.Time,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		// keeping room for the line counting the rest
+		if i == chatBatchMax || length+line.Len() > chatMessageMax-100 {
+			lines = append(lines, fmt.Sprintf("…and %d more", len(posts)-i))
+			break
+		}
+		length += line.Len() + 1
+		lines = append(lines, line.String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// chatEscape keeps titles from being read as markup. Slack wants &, < and >
+// escaped, Discord markdown can't be escaped wholesale, so the characters
+// that would break a link are.
+func chatEscape(provider, text string) string {
+	if provider == chatProviderSlack {
+		return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	}
+	return strings.NewReplacer("[", "\\[", "]", "\\]").Replace(text)
+}
+
+func sendChatMessage(ctx context.Context, notification database.ChatNotification, posts []database.ClaimChatNotificationPostsRow) error {
+	text, err := chatMessage(notification, posts)
+	if err != nil {
+		return err
+	}
+
+	var body interface{}
+	if notification.Provider == chatProviderDiscord {
+		// no @everyone from a post title
+		body = map[string]interface{}{"content": text, "allowed_mentions": map[string][]string{"parse": {}}}
+	} else {
+		body = map[string]interface{}{"text": text}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.WebhookUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+/*
+Endpoint: POST /v1/chat_notifications
+
+# This is an authenticated endpoint
+
+Posts new posts to a Slack or Discord channel. "provider" is "slack" or
+"discord", "webhook_url" the incoming webhook of the channel. "feed_id" limits
+the notification to a feed, leaving it out covers every feed the user
+follows. "keywords" limits it to posts mentioning one of them.
+
+"template" renders a post as a line of the message, as a Go text/template
+with .Title, .URL, .Feed, .Author and .PublishedAt. Left out, it's a link to
+the post in the markup of the provider.
+
+The user needs a verified email address, otherwise the response is a 403.
+*/
+func postChatNotificationHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ChatNotificationRequest struct {
+			Provider   string     `json:"provider"`
+			WebhookURL string     `json:"webhook_url"`
+			FeedID     *uuid.UUID `json:"feed_id"`
+			Keywords   []string   `json:"keywords"`
+			Template   string     `json:"template"`
+		}
+
+		if !requireVerifiedEmail(w, user) {
+			return
+		}
+
+		var req ChatNotificationRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		var v validation
+		if req.Provider != chatProviderSlack && req.Provider != chatProviderDiscord {
+			v.add("provider", fmt.Sprintf("Unknown provider: %s", req.Provider))
+		} else {
+			v.check("webhook_url", validateChatWebhookURL(req.Provider, req.WebhookURL))
+		}
+
+		keywords := []string{}
+		for _, keyword := range req.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+		if len(keywords) > chatKeywordsMax {
+			v.add("keywords", fmt.Sprintf("At most %d keywords", chatKeywordsMax))
+		}
+
+		if len(req.Template) > chatTemplateMax {
+			v.add("template", fmt.Sprintf("Template longer than %d characters", chatTemplateMax))
+		} else if req.Template != "" {
+			_, err := parseChatTemplate(req.Template)
+			v.check("template", err)
+		}
+		if v.respond(w) {
+			return
+		}
+
+		context := r.Context()
+		var feedID uuid.NullUUID
+		if req.FeedID != nil {
+			feed, err := apiConfig.DB.GetFeedByID(context, *req.FeedID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				httpLog.Error("Error getting feed", "err", err)
+				respondWithError(w, 500, "Error creating chat notification")
+				return
+			}
+			follows, err := followsFeed(context, apiConfig.DB, user.ID, *req.FeedID)
+			if err != nil {
+				httpLog.Error("Error getting feed follows", "err", err)
+				respondWithError(w, 500, "Error creating chat notification")
+				return
+			}
+			if feed.UserID != user.ID && !follows {
+				respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+				return
+			}
+			feedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+		}
+
+		notification, err := apiConfig.DB.CreateChatNotification(context, database.CreateChatNotificationParams{
+			ID:         uuid.New(),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+			UserID:     user.ID,
+			Provider:   req.Provider,
+			WebhookUrl: req.WebhookURL,
+			FeedID:     feedID,
+			Keywords:   keywords,
+			Template:   req.Template,
+		})
+		if err != nil {
+			httpLog.Error("Error creating chat notification", "err", err)
+			respondWithError(w, 500, "Error creating chat notification")
+			return
+		}
+
+		respondCreated(w, "/v1/chat_notifications/"+notification.ID.String(), newChatNotificationResponse(notification))
+	}
+}
+
+/*
+Endpoint: GET /v1/chat_notifications
+
+# This is an authenticated endpoint
+
+Lists the chat notifications of the user, with when each last sent a message
+and the error it got, if any.
+*/
+func getChatNotificationsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		notifications, err := apiConfig.DB.GetUserChatNotifications(r.Context(), user.ID)
+		if err != nil {
+			httpLog.Error("Error getting chat notifications", "err", err)
+			respondWithError(w, 500, "Error getting chat notifications")
+			return
+		}
+
+		resp := []chatNotificationResponse{}
+		for _, notification := range notifications {
+			resp = append(resp, newChatNotificationResponse(notification))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/chat_notifications/{chat_notification_id}
+
+# This is an authenticated endpoint
+
+Deletes the chat notification along with the posts it had queued.
+*/
+func deleteChatNotificationHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		notificationID, err := uuid.Parse(chi.URLParam(r, "chat_notification_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid chat_notification_id")
+			return
+		}
+
+		deleted, err := apiConfig.DB.DeleteChatNotification(r.Context(), database.DeleteChatNotificationParams{
+			ID:     notificationID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting chat notification", "err", err)
+			respondWithError(w, 500, "Error deleting chat notification")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "chat_notification_not_found", "Chat notification not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
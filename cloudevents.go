@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookFormatJSON        = "json"
+	webhookFormatCloudEvents = "cloudevents"
+
+	contentTypeJSON        = "application/json"
+	contentTypeCloudEvents = "application/cloudevents+json"
+
+	// cloudEventTypePrefix namespaces our event types, e.g. blogator.post.created
+	cloudEventTypePrefix = "blogator."
+)
+
+var webhookFormats = []string{webhookFormatJSON, webhookFormatCloudEvents}
+
+// cloudEvent is the structured JSON envelope of CloudEvents 1.0.
+type cloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              uuid.UUID   `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+func newCloudEvent(source string, event webhookEvent) cloudEvent {
+	return cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              event.ID,
+		Source:          source,
+		Type:            cloudEventTypePrefix + event.Type,
+		Time:            event.CreatedAt,
+		DataContentType: contentTypeJSON,
+		Data:            event.Data,
+	}
+}
+
+// encodeEvent renders the event in the given webhook format and returns the
+// content type to send it with.
+func encodeEvent(apiConfig apiConfig, format string, event webhookEvent) ([]byte, string, error) {
+	switch format {
+	case webhookFormatJSON:
+		payload, err := json.Marshal(event)
+		return payload, contentTypeJSON, err
+	case webhookFormatCloudEvents:
+		payload, err := json.Marshal(newCloudEvent(apiConfig.BaseURL, event))
+		return payload, contentTypeCloudEvents, err
+	default:
+		return nil, "", fmt.Errorf("unknown event format: %s", format)
+	}
+}
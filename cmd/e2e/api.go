@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// user is a user created by a step together with the api key to act as it.
+type user struct {
+	Name   string
+	ApiKey string
+}
+
+// apiFeed is the part of a feed the steps look at.
+type apiFeed struct {
+	ID   string
+	Name string
+	Url  string
+}
+
+// statusError is a response with another status than the step expected.
+type statusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+type apiClient struct {
+	base string
+	http *http.Client
+}
+
+func (c *apiClient) createUser(ctx context.Context, name string) (user, error) {
+	var resp struct {
+		Name   string
+		Apikey string
+	}
+	err := c.do(ctx, user{}, http.MethodPost, "/users", map[string]string{"name": name}, http.StatusCreated, &resp)
+	if err != nil {
+		return user{}, err
+	}
+
+	return user{Name: resp.Name, ApiKey: resp.Apikey}, nil
+}
+
+// do sends the request as u (anonymously when u has no api key), checks that
+// it is answered with the status want and decodes the response into out.
+func (c *apiClient) do(ctx context.Context, u user, method, path string, body interface{}, want int, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if u.ApiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+u.ApiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %w", method, path, &statusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(msg))})
+	}
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
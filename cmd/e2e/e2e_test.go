@@ -0,0 +1,30 @@
+//go:build e2e
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestE2E walks through the steps like the command does, against postgres in
+// docker or the empty database in E2E_DB.
+func TestE2E(t *testing.T) {
+	cfg := defaultConfig()
+	// tests run in the directory of their package
+	cfg.pkg = "../.."
+	cfg.db = os.Getenv("E2E_DB")
+	if cfg.db == "" {
+		if _, err := docker(t.Context(), "info"); err != nil {
+			t.Skipf("docker isn't available and E2E_DB is empty: %v", err)
+		}
+	}
+
+	failed, err := run(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed > 0 {
+		t.Fatalf("%d steps failed", failed)
+	}
+}
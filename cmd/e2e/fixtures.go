@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fixtureServer serves the feeds the steps add:
+//
+//	/feed.xml  an RSS feed, answering If-None-Match with 304
+//	/blog      an html page linking /feed.xml, for discovery
+//	/news      an html page with articles and no feed, for the scraper
+type fixtureServer struct {
+	base string
+	// epoch keeps the dates of the posts the same across fetches
+	epoch time.Time
+
+	mu    sync.Mutex
+	posts int
+	// notModified counts the conditional requests answered with 304
+	notModified int
+}
+
+const (
+	fixtureInitialPosts = 3
+	fixtureNewsArticles = 2
+)
+
+func newFixtureServer(base string) *fixtureServer {
+	return &fixtureServer{base: base, epoch: time.Now().UTC().Truncate(time.Hour), posts: fixtureInitialPosts}
+}
+
+// Publish adds a post to /feed.xml.
+func (s *fixtureServer) Publish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.posts++
+}
+
+func (s *fixtureServer) NotModified() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notModified
+}
+
+func (s *fixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/feed.xml":
+		s.serveFeed(w, r)
+	case "/blog":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!doctype html>
+<html><head><title>Fixture blog</title>
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+</head><body><h1>Fixture blog</h1></body></html>
+`)
+	case "/news":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!doctype html>
+<html><head><title>Fixture news</title></head><body>
+`)
+		for i := 0; i < fixtureNewsArticles; i++ {
+			fmt.Fprintf(w, `<article><h2><a href="/news/%d">News %d</a></h2><time datetime="%s">yesterday</time></article>
+`, i, i, s.epoch.Add(-time.Duration(i+1)*24*time.Hour).Format(time.RFC3339))
+		}
+		fmt.Fprint(w, "</body></html>\n")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *fixtureServer) serveFeed(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	posts := s.posts
+	etag := `"` + strconv.Itoa(posts) + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		s.notModified++
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>Fixture feed</title>
+<link>%s/blog</link>
+<description>Fixture feed of the e2e run</description>
+`, html.EscapeString(s.base))
+
+	for i := posts - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, `<item>
+<title>Post %d</title>
+<link>%s/posts/%d</link>
+<guid>%s/posts/%d</guid>
+<description>Fixture post %d</description>
+<pubDate>%s</pubDate>
+</item>
+`, i, html.EscapeString(s.base), i, html.EscapeString(s.base), i, i, s.epoch.Add(-time.Duration(100-i)*time.Hour).Format(time.RFC1123Z))
+	}
+	b.WriteString("</channel></rss>\n")
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	w.Header().Set("ETag", etag)
+	fmt.Fprint(w, b.String())
+}
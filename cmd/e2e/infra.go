@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/migrate"
+	"github.com/halfdan87/boot-go-blog-aggregator/sql/schema"
+)
+
+// postgres is a throwaway postgres container.
+type postgres struct {
+	ID  string
+	URL string
+}
+
+func startPostgres(ctx context.Context, image string) (*postgres, error) {
+	out, err := docker(ctx, "run", "--detach", "--rm",
+		"--env", "POSTGRES_PASSWORD=e2e",
+		"--env", "POSTGRES_DB=e2e",
+		"--publish", "127.0.0.1::5432",
+		image)
+	if err != nil {
+		return nil, fmt.Errorf("starting postgres: %w", err)
+	}
+	pg := &postgres{ID: out}
+
+	addr, err := docker(ctx, "port", pg.ID, "5432/tcp")
+	if err != nil {
+		pg.Stop()
+		return nil, fmt.Errorf("finding the port of postgres: %w", err)
+	}
+	// one line per address family, the first is enough
+	addr, _, _ = strings.Cut(addr, "\n")
+	pg.URL = "postgres://postgres:e2e@" + addr + "/e2e?sslmode=disable"
+
+	log.Printf("Started postgres %.12s on %s", pg.ID, addr)
+	return pg, nil
+}
+
+func (pg *postgres) Stop() {
+	if _, err := docker(context.Background(), "rm", "--force", pg.ID); err != nil {
+		log.Printf("Error removing postgres %.12s: %v", pg.ID, err)
+	}
+}
+
+func docker(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// waitForDatabase pings db until it answers, postgres takes a few seconds to
+// accept connections after its container started.
+func waitForDatabase(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("database not up after %s: %w", timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// migrateDatabase applies the migrations the way MIGRATE_ON_START does.
+func migrateDatabase(ctx context.Context, db *sql.DB) error {
+	migrations, err := migrate.Load(schema.Migrations)
+	if err != nil {
+		return err
+	}
+	pending, err := migrate.Pending(ctx, db, migrations)
+	if err != nil {
+		return err
+	}
+	for _, migration := range pending {
+		if err := migrate.Apply(ctx, db, migration); err != nil {
+			return fmt.Errorf("%d %s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	log.Printf("Applied %d migrations", len(pending))
+	return nil
+}
+
+// server is the aggregator under test, running as a child process.
+type server struct {
+	URL    string
+	cmd    *exec.Cmd
+	log    *os.File
+	exited chan error
+}
+
+// startServer builds the server into dir and starts it against dbURL. It runs
+// in dir, where an empty .env keeps the .env of the repository out.
+func startServer(ctx context.Context, cfg config, dir, dbURL string) (*server, error) {
+	binary := filepath.Join(dir, "aggregator")
+	build := exec.CommandContext(ctx, "go", "build", "-o", binary, cfg.pkg)
+	build.Stdout = os.Stderr
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return nil, fmt.Errorf("building the server: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".env"), nil, 0o644); err != nil {
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(dir, "server.log"))
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(binary)
+	cmd.Dir = dir
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.Env = append(os.Environ(),
+		"PORT="+port,
+		"DB_CONNECTION_STRING="+dbURL,
+		"MIGRATE_ON_START=false",
+		// the fixture feeds are served from localhost
+		"FETCH_ALLOW_PRIVATE_NETWORKS=true",
+		"FETCH_HOST_MIN_INTERVAL=0",
+		"STORAGE_BACKEND=local",
+		"STORAGE_LOCAL_DIR="+filepath.Join(dir, "storage"),
+	)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	srv := &server{URL: "http://127.0.0.1:" + port, cmd: cmd, log: logFile, exited: make(chan error, 1)}
+	go func() {
+		srv.exited <- cmd.Wait()
+	}()
+
+	if err := srv.waitReady(cfg.startTimeout); err != nil {
+		srv.Stop()
+		return nil, fmt.Errorf("%w, see %s", err, logFile.Name())
+	}
+
+	log.Printf("Started server on %s", srv.URL)
+	return srv, nil
+}
+
+func (s *server) waitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-s.exited:
+			s.exited <- err
+			return fmt.Errorf("server exited: %v", err)
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		resp, err := http.Get(s.URL + "/v1/healthz")
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("server not ready after %s", timeout)
+}
+
+// Stop shuts the server down gracefully, or kills it when that takes too long.
+func (s *server) Stop() {
+	s.cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-s.exited:
+	case <-time.After(10 * time.Second):
+		s.cmd.Process.Kill()
+		<-s.exited
+	}
+	s.log.Close()
+}
+
+func freePort() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	return port, err
+}
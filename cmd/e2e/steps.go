@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type step struct {
+	name string
+	run  func(ctx context.Context, e *env) error
+}
+
+// steps run in order, each builds on the ones before it.
+var steps = []step{
+	{"sign up", signUp},
+	{"add a feed", addFeed},
+	{"discover a known feed from its page", discoverFeed},
+	{"scrape the feed", scrapeFeed},
+	{"refetch an unchanged feed", refetchUnchanged},
+	{"pick up a new post", pickUpNewPost},
+	{"scrape a page without a feed", scrapePage},
+	{"read the fetch log", readFetchLog},
+	{"unfollow", unfollow},
+}
+
+func signUp(ctx context.Context, e *env) error {
+	var err error
+	if e.alice, err = e.api.createUser(ctx, "e2e_alice"); err != nil {
+		return err
+	}
+	if e.bob, err = e.api.createUser(ctx, "e2e_bob"); err != nil {
+		return err
+	}
+
+	return e.expectCount(ctx, 2, "SELECT count(*) FROM users")
+}
+
+func addFeed(ctx context.Context, e *env) error {
+	var feed apiFeed
+	err := e.api.do(ctx, e.alice, http.MethodPost, "/feeds", map[string]string{"url": e.fixtures.base + "/feed.xml"}, http.StatusCreated, &feed)
+	if err != nil {
+		return err
+	}
+	if feed.Name != "Fixture feed" {
+		return fmt.Errorf("feed is named %q, want the title of the feed", feed.Name)
+	}
+	e.feedID = feed.ID
+
+	if err := e.expectCount(ctx, 1, "SELECT count(*) FROM feeds"); err != nil {
+		return err
+	}
+	return e.expectCount(ctx, 1, "SELECT count(*) FROM feed_follows WHERE feed_id = $1", e.feedID)
+}
+
+func discoverFeed(ctx context.Context, e *env) error {
+	var feed apiFeed
+	err := e.api.do(ctx, e.bob, http.MethodPost, "/feeds", map[string]string{"url": e.fixtures.base + "/blog"}, http.StatusCreated, &feed)
+	if err != nil {
+		return err
+	}
+	if feed.ID != e.feedID {
+		return fmt.Errorf("the page led to feed %s, want the known feed %s", feed.ID, e.feedID)
+	}
+
+	if err := e.expectCount(ctx, 1, "SELECT count(*) FROM feeds"); err != nil {
+		return err
+	}
+	return e.expectCount(ctx, 2, "SELECT count(*) FROM feed_follows WHERE feed_id = $1", e.feedID)
+}
+
+func scrapeFeed(ctx context.Context, e *env) error {
+	if err := e.api.refresh(ctx, e.alice, e.feedID, e.cfg.scrapeTimeout); err != nil {
+		return err
+	}
+	if err := e.expectCount(ctx, fixtureInitialPosts, "SELECT count(*) FROM posts WHERE feed_id = $1", e.feedID); err != nil {
+		return err
+	}
+
+	// bob follows the same feed and sees the same posts
+	var posts []struct{ Title string }
+	if err := e.api.do(ctx, e.bob, http.MethodGet, "/posts", nil, http.StatusOK, &posts); err != nil {
+		return err
+	}
+	if len(posts) != fixtureInitialPosts {
+		return fmt.Errorf("bob sees %d posts, want %d", len(posts), fixtureInitialPosts)
+	}
+
+	return e.expectCount(ctx, 1, "SELECT count(*) FROM feeds WHERE id = $1 AND last_fetched_at IS NOT NULL AND etag IS NOT NULL", e.feedID)
+}
+
+func refetchUnchanged(ctx context.Context, e *env) error {
+	before := e.fixtures.NotModified()
+	if err := e.api.refresh(ctx, e.bob, e.feedID, e.cfg.scrapeTimeout); err != nil {
+		return err
+	}
+	if e.fixtures.NotModified() == before {
+		return errors.New("the refetch didn't send the etag of the last fetch")
+	}
+
+	return e.expectCount(ctx, fixtureInitialPosts, "SELECT count(*) FROM posts WHERE feed_id = $1", e.feedID)
+}
+
+func pickUpNewPost(ctx context.Context, e *env) error {
+	e.fixtures.Publish()
+	if err := e.api.refresh(ctx, e.alice, e.feedID, e.cfg.scrapeTimeout); err != nil {
+		return err
+	}
+
+	if err := e.expectCount(ctx, fixtureInitialPosts+1, "SELECT count(*) FROM posts WHERE feed_id = $1", e.feedID); err != nil {
+		return err
+	}
+	return e.expectCount(ctx, fixtureInitialPosts+1, "SELECT count(DISTINCT guid) FROM posts WHERE feed_id = $1", e.feedID)
+}
+
+func scrapePage(ctx context.Context, e *env) error {
+	var feed apiFeed
+	err := e.api.do(ctx, e.alice, http.MethodPost, "/feeds", map[string]interface{}{
+		"url": e.fixtures.base + "/news",
+		"scraper": map[string]string{
+			"item": "article",
+			"link": "h2 a",
+			"date": "time",
+		},
+	}, http.StatusCreated, &feed)
+	if err != nil {
+		return err
+	}
+	e.scrapedID = feed.ID
+
+	if err := e.expectCount(ctx, 1, "SELECT count(*) FROM feed_scrapers WHERE feed_id = $1", e.scrapedID); err != nil {
+		return err
+	}
+	if err := e.api.refresh(ctx, e.alice, e.scrapedID, e.cfg.scrapeTimeout); err != nil {
+		return err
+	}
+
+	// the dates come from the datetime of the <time> elements
+	return e.expectCount(ctx, fixtureNewsArticles, "SELECT count(*) FROM posts WHERE feed_id = $1 AND published_at < now() - interval '23 hours'", e.scrapedID)
+}
+
+func readFetchLog(ctx context.Context, e *env) error {
+	var entries []struct {
+		StatusCode *int `json:"status_code"`
+	}
+	if err := e.api.do(ctx, e.alice, http.MethodGet, "/feeds/"+e.feedID+"/fetch_log", nil, http.StatusOK, &entries); err != nil {
+		return err
+	}
+	if len(entries) < 3 {
+		return fmt.Errorf("fetch log has %d entries, want at least the 3 refreshes", len(entries))
+	}
+
+	var stored int
+	err := e.db.QueryRowContext(ctx, "SELECT count(*) FROM fetch_logs WHERE feed_id = $1", e.feedID).Scan(&stored)
+	if err != nil {
+		return err
+	}
+	// the scheduler may have fetched the feed since
+	if stored < len(entries) {
+		return fmt.Errorf("fetch log lists %d entries, the database has %d", len(entries), stored)
+	}
+	return nil
+}
+
+func unfollow(ctx context.Context, e *env) error {
+	if err := e.api.do(ctx, e.bob, http.MethodDelete, "/feed_follows/"+e.feedID, nil, http.StatusNoContent, nil); err != nil {
+		return err
+	}
+
+	var follows []struct{ FeedID string }
+	if err := e.api.do(ctx, e.bob, http.MethodGet, "/feed_follows", nil, http.StatusOK, &follows); err != nil {
+		return err
+	}
+	if len(follows) != 0 {
+		return fmt.Errorf("bob still follows %d feeds", len(follows))
+	}
+
+	// the feed and its posts stay for alice
+	if err := e.expectCount(ctx, 1, "SELECT count(*) FROM feed_follows WHERE feed_id = $1", e.feedID); err != nil {
+		return err
+	}
+	return e.expectCount(ctx, fixtureInitialPosts+1, "SELECT count(*) FROM posts WHERE feed_id = $1", e.feedID)
+}
+
+// expectCount runs a count query and compares its result with want.
+func (e *env) expectCount(ctx context.Context, want int, query string, args ...interface{}) error {
+	var got int
+	err := e.db.QueryRowContext(ctx, query, args...).Scan(&got)
+	if err != nil {
+		return fmt.Errorf("%s: %w", query, err)
+	}
+	if got != want {
+		return fmt.Errorf("%s: got %d, want %d", query, got, want)
+	}
+	return nil
+}
+
+// refresh fetches the feed through the api. Refreshes are rate limited and
+// collide with the scheduler fetching the same feed, both are retried.
+func (c *apiClient) refresh(ctx context.Context, u user, feedID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.do(ctx, u, http.MethodPost, "/feeds/"+feedID+"/refresh", nil, http.StatusOK, nil)
+		var statusErr *statusError
+		if !errors.As(err, &statusErr) || (statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode != http.StatusConflict) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("refresh still refused after %s: %w", timeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// feedServer serves synthetic RSS feeds under /feeds/{n}.xml and remembers
+// how often each of them was requested.
+type feedServer struct {
+	runID string
+	posts int
+
+	mu   sync.Mutex
+	hits map[int]int
+}
+
+func newFeedServer(runID string, posts int) *feedServer {
+	return &feedServer{runID: runID, posts: posts, hits: map[int]int{}}
+}
+
+func (s *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, "/feeds/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(name, ".xml"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.hits[n]++
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/rss+xml")
+	fmt.Fprint(w, s.render(r.Host, n))
+}
+
+// render builds feed n. Post urls carry the run id, so a run that gets the
+// feed urls of an earlier one still brings new posts.
+func (s *feedServer) render(host string, n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel>
+<title>loadgen %s feed %d</title>
+<link>http://%s/feeds/%d.xml</link>
+<description>Synthetic feed</description>
+`, s.runID, n, html.EscapeString(host), n)
+
+	now := time.Now()
+	for i := 0; i < s.posts; i++ {
+		fmt.Fprintf(&b, `<item>
+<title>Post %d of feed %d</title>
+<link>http://%s/posts/%s/%d/%d</link>
+<description>Synthetic post %d</description>
+<pubDate>%s</pubDate>
+</item>
+`, i, n, html.EscapeString(host), s.runID, n, i, i, now.Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+	}
+
+	b.WriteString("</channel></rss>\n")
+	return b.String()
+}
+
+// Hits returns how many times every feed was requested so far.
+func (s *feedServer) Hits() map[int]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hits := make(map[int]int, len(s.hits))
+	for n, count := range s.hits {
+		hits[n] = count
+	}
+	return hits
+}
@@ -0,0 +1,301 @@
+/*
+Command loadgen fills a test instance of the aggregator with synthetic users,
+feeds and posts and measures how it copes.
+
+It serves the synthetic feeds itself, so the instance must be able to reach
+-listen and must accept feeds on private addresses:
+
+	FETCH_ALLOW_PRIVATE_NETWORKS=true FETCH_HOST_MIN_INTERVAL=0 ./boot-go-blog-aggregator
+	go run ./cmd/loadgen -target http://localhost:8080/v1 -users 20 -feeds 5 -posts 50
+
+Three numbers are reported: how long creating users and feeds took, the scrape
+cycle (from the last feed created until every post is visible through
+GET /v1/posts) and the throughput of the read endpoints under -concurrency
+parallel clients for -duration.
+
+Never point it at a production instance, the users and feeds it creates are
+not cleaned up.
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type config struct {
+	target        string
+	listen        string
+	feedBaseURL   string
+	users         int
+	feeds         int
+	posts         int
+	concurrency   int
+	duration      time.Duration
+	scrapeTimeout time.Duration
+}
+
+// synthUser is a user created by this run together with the api key to act as it.
+type synthUser struct {
+	Name   string
+	ApiKey string
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.target, "target", "http://localhost:8080/v1", "base url of the instance under test")
+	flag.StringVar(&cfg.listen, "listen", "127.0.0.1:0", "address to serve the synthetic feeds on")
+	flag.StringVar(&cfg.feedBaseURL, "feed-base-url", "", "url the instance reaches the synthetic feeds under (default http://<listen>)")
+	flag.IntVar(&cfg.users, "users", 10, "number of users to create")
+	flag.IntVar(&cfg.feeds, "feeds", 5, "number of feeds to create per user")
+	flag.IntVar(&cfg.posts, "posts", 20, "number of posts in every feed")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "number of parallel clients")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long to measure the read endpoints")
+	flag.DurationVar(&cfg.scrapeTimeout, "scrape-timeout", 10*time.Minute, "how long to wait for the instance to scrape all feeds")
+	flag.Parse()
+
+	if cfg.users < 1 || cfg.feeds < 1 || cfg.posts < 1 || cfg.concurrency < 1 {
+		log.Fatal("-users, -feeds, -posts and -concurrency must be at least 1")
+	}
+
+	err := run(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, cfg config) error {
+	runID := strconv.FormatInt(time.Now().Unix(), 36)
+	feeds := newFeedServer(runID, cfg.posts)
+
+	listener, err := net.Listen("tcp", cfg.listen)
+	if err != nil {
+		return err
+	}
+	go http.Serve(listener, feeds)
+
+	if cfg.feedBaseURL == "" {
+		cfg.feedBaseURL = "http://" + listener.Addr().String()
+	}
+	log.Printf("Run %s, serving feeds on %s", runID, cfg.feedBaseURL)
+
+	client := &apiClient{base: cfg.target, http: &http.Client{Timeout: 30 * time.Second}}
+	setup := newStats()
+
+	start := time.Now()
+	users := make([]synthUser, cfg.users)
+	err = forEach(cfg.users, cfg.concurrency, func(i int) error {
+		var err error
+		users[i], err = client.createUser(ctx, setup, fmt.Sprintf("loadgen_%s_%d", runID, i))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("creating users: %w", err)
+	}
+	log.Printf("Created %d users in %s", cfg.users, time.Since(start).Round(time.Millisecond))
+
+	start = time.Now()
+	err = forEach(cfg.users*cfg.feeds, cfg.concurrency, func(i int) error {
+		url := fmt.Sprintf("%s/feeds/%d.xml", cfg.feedBaseURL, i)
+		return client.createFeed(ctx, setup, users[i/cfg.feeds], url)
+	})
+	if err != nil {
+		return fmt.Errorf("creating feeds: %w", err)
+	}
+	feedsCreated := time.Now()
+	log.Printf("Created %d feeds in %s", cfg.users*cfg.feeds, feedsCreated.Sub(start).Round(time.Millisecond))
+
+	err = waitForPosts(ctx, client, users, cfg.feeds*cfg.posts, cfg.scrapeTimeout)
+	if err != nil {
+		return err
+	}
+	fetches := 0
+	for _, count := range feeds.Hits() {
+		fetches += count
+	}
+	log.Printf("Scrape cycle took %s (%d feed requests)", time.Since(feedsCreated).Round(time.Millisecond), fetches)
+
+	fmt.Println("\nsetup")
+	setup.Print(os.Stdout, time.Since(start))
+
+	reads := newStats()
+	elapsed := measureReads(ctx, client, reads, users, cfg.concurrency, cfg.duration)
+	fmt.Printf("\nreads, %d clients for %s\n", cfg.concurrency, cfg.duration)
+	reads.Print(os.Stdout, elapsed)
+
+	return nil
+}
+
+// waitForPosts polls the timeline of every user until it holds want posts.
+func waitForPosts(ctx context.Context, client *apiClient, users []synthUser, want int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	pending := users
+	for len(pending) > 0 {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d users still miss posts after %s", len(pending), timeout)
+		}
+
+		var next []synthUser
+		for _, user := range pending {
+			var posts []json.RawMessage
+			err := client.do(ctx, nil, "GET /posts", user, http.MethodGet, "/posts", nil, &posts)
+			if err != nil {
+				return err
+			}
+			if len(posts) < want {
+				next = append(next, user)
+			}
+		}
+
+		pending = next
+		if len(pending) > 0 {
+			time.Sleep(time.Second)
+		}
+	}
+
+	return nil
+}
+
+// measureReads keeps concurrency clients busy with the read endpoints for
+// duration and returns how long they actually ran.
+func measureReads(ctx context.Context, client *apiClient, reads *stats, users []synthUser, concurrency int, duration time.Duration) time.Duration {
+	endpoints := []string{"/posts", "/feeds", "/feed_follows"}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				user := users[rand.Intn(len(users))]
+				path := endpoints[rand.Intn(len(endpoints))]
+				err := client.do(ctx, reads, "GET "+path, user, http.MethodGet, path, nil, nil)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("GET %s: %v", path, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return time.Since(start)
+}
+
+// forEach calls fn for 0..n-1 from at most concurrency goroutines and returns
+// the first error.
+func forEach(n, concurrency int, fn func(i int) error) error {
+	indexes := make(chan int)
+	errs := make(chan error, n)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
+
+type apiClient struct {
+	base string
+	http *http.Client
+}
+
+func (c *apiClient) createUser(ctx context.Context, s *stats, name string) (synthUser, error) {
+	var resp struct {
+		Name   string
+		Apikey string
+	}
+	err := c.do(ctx, s, "POST /users", synthUser{}, http.MethodPost, "/users", map[string]string{"name": name}, &resp)
+	if err != nil {
+		return synthUser{}, err
+	}
+
+	return synthUser{Name: resp.Name, ApiKey: resp.Apikey}, nil
+}
+
+func (c *apiClient) createFeed(ctx context.Context, s *stats, user synthUser, url string) error {
+	return c.do(ctx, s, "POST /feeds", user, http.MethodPost, "/feeds", map[string]string{"url": url}, nil)
+}
+
+// do sends the request as user (anonymously when the user has no api key),
+// records its latency under endpoint in s and decodes the response into out.
+func (c *apiClient) do(ctx context.Context, s *stats, endpoint string, user synthUser, method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user.ApiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+user.ApiKey)
+	}
+
+	start := time.Now()
+	resp, err := c.http.Do(req)
+	if err == nil {
+		err = decodeResponse(resp, out)
+	}
+	if s != nil && !errors.Is(err, context.DeadlineExceeded) {
+		s.Record(endpoint, time.Since(start), err)
+	}
+
+	return err
+}
+
+func decodeResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
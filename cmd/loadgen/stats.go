@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats collects request latencies per endpoint.
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newStats() *stats {
+	return &stats{latencies: map[string][]time.Duration{}, errors: map[string]int{}}
+}
+
+func (s *stats) Record(endpoint string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.errors[endpoint]++
+		return
+	}
+	s.latencies[endpoint] = append(s.latencies[endpoint], latency)
+}
+
+// Print writes one line per endpoint with its throughput over elapsed and
+// its latency percentiles.
+func (s *stats) Print(w io.Writer, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var endpoints []string
+	for endpoint := range s.latencies {
+		endpoints = append(endpoints, endpoint)
+	}
+	for endpoint := range s.errors {
+		if _, ok := s.latencies[endpoint]; !ok {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintf(w, "%-20s %8s %8s %10s %10s %10s %10s\n", "endpoint", "ok", "errors", "req/s", "p50", "p95", "p99")
+	for _, endpoint := range endpoints {
+		latencies := s.latencies[endpoint]
+		slices.Sort(latencies)
+		fmt.Fprintf(w, "%-20s %8d %8d %10.1f %10s %10s %10s\n",
+			endpoint,
+			len(latencies),
+			s.errors[endpoint],
+			float64(len(latencies))/elapsed.Seconds(),
+			percentile(latencies, 50),
+			percentile(latencies, 95),
+			percentile(latencies, 99),
+		)
+	}
+}
+
+// percentile expects sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := (len(sorted)*p + 99) / 100
+	return sorted[max(i-1, 0)].Round(time.Microsecond)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// client talks to the aggregator API as the user owning apiKey.
+type client struct {
+	base   string
+	apiKey string
+	http   *http.Client
+}
+
+func newClient(base, apiKey string) *client {
+	return &client{base: base, apiKey: apiKey, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+type feed struct {
+	ID   uuid.UUID
+	Name string
+	Url  string
+}
+
+type post struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description string
+	PublishedAt struct {
+		Time  time.Time
+		Valid bool
+	}
+	FeedID uuid.UUID
+	// Name is the name of the feed the post belongs to.
+	Name string
+}
+
+// subscriptions returns the feeds the user follows, sorted by name.
+func (c *client) subscriptions() ([]feed, error) {
+	var follows []struct{ FeedID uuid.UUID }
+	err := c.do(http.MethodGet, "/feed_follows", nil, &follows)
+	if err != nil {
+		return nil, err
+	}
+
+	var feeds []feed
+	err = c.do(http.MethodGet, "/feeds", nil, &feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	followed := map[uuid.UUID]bool{}
+	for _, follow := range follows {
+		followed[follow.FeedID] = true
+	}
+
+	var subscribed []feed
+	for _, f := range feeds {
+		if followed[f.ID] {
+			subscribed = append(subscribed, f)
+		}
+	}
+	sort.Slice(subscribed, func(i, j int) bool { return subscribed[i].Name < subscribed[j].Name })
+
+	return subscribed, nil
+}
+
+// timeline returns the posts of the user, newest first.
+func (c *client) timeline() ([]post, error) {
+	var posts []post
+	err := c.do(http.MethodGet, "/posts", nil, &posts)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].PublishedAt.Time.After(posts[j].PublishedAt.Time)
+	})
+
+	return posts, nil
+}
+
+func (c *client) bookmark(postID uuid.UUID) error {
+	return c.do(http.MethodPost, "/bookmarks", map[string]uuid.UUID{"post_id": postID}, nil)
+}
+
+func (c *client) do(method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.base+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "ApiKey "+c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, apiErr.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type feedRecord struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	URL  string    `json:"url"`
+}
+
+type postRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Feed        string     `json:"feed"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	PublishedAt *time.Time `json:"published_at"`
+	Read        bool       `json:"read"`
+}
+
+type bookmarkRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	Folder    string    `json:"folder"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const commandsUsage = `Commands:
+  feeds       list the followed feeds
+  posts       list the posts of the timeline
+  bookmarks   list the bookmarks
+
+Every command takes:
+  --output table|json   output format (default table)
+  --template TEXT       Go template executed for every item, e.g. '{{.Title}}'
+`
+
+// runCommand runs one of the list commands instead of the interactive reader.
+func runCommand(c *client, state *readState, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no command given\n\n%s", commandsUsage)
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	output := flags.String("output", "table", "output format, table or json")
+	tmpl := flags.String("template", "", "Go template executed for every item")
+	feedID := flags.String("feed", "", "posts: only list posts of this feed id")
+	unread := flags.Bool("unread", false, "posts: only list unread posts")
+
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("unknown output %q, must be table or json", *output)
+	}
+
+	// records is what --output json prints, item(i) is what --template sees
+	// and row(i) what the table shows for the i-th of n items.
+	var records any
+	var n int
+	var item func(i int) any
+	var columns []string
+	var row func(i int) []string
+
+	switch args[0] {
+	case "feeds":
+		feeds, err := c.subscriptions()
+		if err != nil {
+			return err
+		}
+		list := make([]feedRecord, 0, len(feeds))
+		for _, f := range feeds {
+			list = append(list, feedRecord{ID: f.ID, Name: f.Name, URL: f.Url})
+		}
+		records, n, columns = list, len(list), []string{"ID", "NAME", "URL"}
+		item = func(i int) any { return list[i] }
+		row = func(i int) []string { return []string{list[i].ID.String(), list[i].Name, list[i].URL} }
+
+	case "posts":
+		posts, err := c.timeline()
+		if err != nil {
+			return err
+		}
+		list := make([]postRecord, 0, len(posts))
+		for _, p := range posts {
+			if *feedID != "" && p.FeedID.String() != *feedID {
+				continue
+			}
+			if *unread && state.IsRead(p.ID) {
+				continue
+			}
+			record := postRecord{ID: p.ID, Title: p.Title, URL: p.Url, Feed: p.Name, FeedID: p.FeedID, Read: state.IsRead(p.ID)}
+			if p.PublishedAt.Valid {
+				record.PublishedAt = &p.PublishedAt.Time
+			}
+			list = append(list, record)
+		}
+		records, n, columns = list, len(list), []string{"PUBLISHED", "TITLE", "FEED", "URL"}
+		item = func(i int) any { return list[i] }
+		row = func(i int) []string {
+			published := ""
+			if list[i].PublishedAt != nil {
+				published = list[i].PublishedAt.Format("2006-01-02")
+			}
+			return []string{published, list[i].Title, list[i].Feed, list[i].URL}
+		}
+
+	case "bookmarks":
+		list := []bookmarkRecord{}
+		err := c.do(http.MethodGet, "/bookmarks", nil, &list)
+		if err != nil {
+			return err
+		}
+		records, n, columns = list, len(list), []string{"CREATED", "TITLE", "FOLDER", "URL"}
+		item = func(i int) any { return list[i] }
+		row = func(i int) []string {
+			return []string{list[i].CreatedAt.Format("2006-01-02"), list[i].Title, list[i].Folder, list[i].URL}
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", args[0], commandsUsage)
+	}
+
+	switch {
+	case *tmpl != "":
+		return writeTemplate(w, *tmpl, n, item)
+	case *output == "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	default:
+		return writeTable(w, columns, n, row)
+	}
+}
+
+// writeTemplate executes text for every item, ending each with a newline
+// unless the template already does.
+func writeTemplate(w io.Writer, text string, n int, item func(i int) any) error {
+	t, err := template.New("output").Parse(text)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		var b strings.Builder
+		err = t.Execute(&b, item(i))
+		if err != nil {
+			return err
+		}
+		line := b.String()
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+		_, err = io.WriteString(w, line)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeTable(w io.Writer, columns []string, n int, row func(i int) []string) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, strings.Join(columns, "\t"))
+	for i := 0; i < n; i++ {
+		fmt.Fprintln(table, strings.Join(row(i), "\t"))
+	}
+
+	return table.Flush()
+}
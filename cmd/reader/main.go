@@ -0,0 +1,83 @@
+/*
+Command reader is a terminal client for the aggregator.
+
+	BLOGATOR_API_KEY=... go run ./cmd/reader -api http://localhost:8080/v1
+
+The sidebar lists the followed feeds, the timeline the posts of the selected
+one, or of all of them. Keys:
+
+	tab        switch between sidebar and timeline
+	j/k, ↑/↓   move
+	enter      show the posts of the selected feed
+	r          mark the post read or unread
+	u          show only unread posts, or all
+	b          bookmark the post
+	o          open the post in the browser and mark it read
+	R          reload
+	q          quit
+
+Which posts were read is remembered in the user config directory, the API
+doesn't keep track of it.
+
+Given a command, the reader prints a list instead and exits, for scripts:
+
+	reader posts --unread --output json | jq -r '.[].url'
+	reader feeds --template '{{.Name}}: {{.URL}}'
+
+The commands are feeds, posts and bookmarks. Each takes --output table|json
+and --template, a Go template executed for every item; posts also takes
+--feed <feed id> and --unread.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func main() {
+	api := flag.String("api", "http://localhost:8080/v1", "base url of the API")
+	apiKey := flag.String("key", "", "api key of the user (default $BLOGATOR_API_KEY)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), "\n"+commandsUsage)
+	}
+	flag.Parse()
+
+	if *apiKey == "" {
+		*apiKey = os.Getenv("BLOGATOR_API_KEY")
+	}
+	if *apiKey == "" {
+		log.Fatal("An api key is required, pass -key or set BLOGATOR_API_KEY")
+	}
+
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	state, err := loadReadState(filepath.Join(configDir, "blogator", "read.json"))
+	if err != nil {
+		log.Fatalf("Error loading read state: %v", err)
+	}
+
+	client := newClient(*api, *apiKey)
+	if flag.NArg() > 0 {
+		err = runCommand(client, state, flag.Args(), os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	program := tea.NewProgram(newModel(client, state), tea.WithAltScreen())
+	_, err = program.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/google/uuid"
+)
+
+const sidebarWidth = 30
+
+var (
+	paneStyle     = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	focusedStyle  = paneStyle.BorderForeground(lipgloss.Color("63"))
+	cursorStyle   = lipgloss.NewStyle().Reverse(true)
+	readStyle     = lipgloss.NewStyle().Faint(true)
+	selectedStyle = lipgloss.NewStyle().Bold(true)
+	statusStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+type focus int
+
+const (
+	focusSidebar focus = iota
+	focusTimeline
+)
+
+type loadedMsg struct {
+	feeds []feed
+	posts []post
+	err   error
+}
+
+type statusMsg string
+
+type model struct {
+	client *client
+	state  *readState
+
+	feeds []feed
+	posts []post
+
+	focus focus
+	// feedCursor and feedSelected index the sidebar, 0 is "All feeds" and
+	// i+1 is feeds[i].
+	feedCursor   int
+	feedSelected int
+	postCursor   int
+	unreadOnly   bool
+
+	width  int
+	height int
+	status string
+}
+
+func newModel(client *client, state *readState) model {
+	return model{client: client, state: state, focus: focusTimeline, status: "Loading..."}
+}
+
+func (m model) Init() tea.Cmd {
+	return m.load
+}
+
+func (m model) load() tea.Msg {
+	feeds, err := m.client.subscriptions()
+	if err != nil {
+		return loadedMsg{err: err}
+	}
+	posts, err := m.client.timeline()
+	return loadedMsg{feeds: feeds, posts: posts, err: err}
+}
+
+// visiblePosts are the posts of the selected feed, without the read ones in
+// unread mode.
+func (m model) visiblePosts() []post {
+	var visible []post
+	for _, p := range m.posts {
+		if m.feedSelected > 0 && p.FeedID != m.feeds[m.feedSelected-1].ID {
+			continue
+		}
+		if m.unreadOnly && m.state.IsRead(p.ID) {
+			continue
+		}
+		visible = append(visible, p)
+	}
+
+	return visible
+}
+
+func (m model) currentPost() (post, bool) {
+	visible := m.visiblePosts()
+	if m.postCursor < 0 || m.postCursor >= len(visible) {
+		return post{}, false
+	}
+
+	return visible[m.postCursor], true
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case loadedMsg:
+		if msg.err != nil {
+			m.status = "Error: " + msg.err.Error()
+			return m, nil
+		}
+		m.feeds, m.posts = msg.feeds, msg.posts
+		m.feedCursor = min(m.feedCursor, len(m.feeds))
+		m.feedSelected = min(m.feedSelected, len(m.feeds))
+		m.postCursor = 0
+		m.status = fmt.Sprintf("%d feeds, %d posts", len(m.feeds), len(m.posts))
+		return m, nil
+
+	case statusMsg:
+		m.status = string(msg)
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	return m, nil
+}
+
+func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+
+	case "tab":
+		if m.focus == focusSidebar {
+			m.focus = focusTimeline
+		} else {
+			m.focus = focusSidebar
+		}
+
+	case "j", "down":
+		if m.focus == focusSidebar {
+			m.feedCursor = min(m.feedCursor+1, len(m.feeds))
+		} else {
+			m.postCursor = min(m.postCursor+1, max(len(m.visiblePosts())-1, 0))
+		}
+
+	case "k", "up":
+		if m.focus == focusSidebar {
+			m.feedCursor = max(m.feedCursor-1, 0)
+		} else {
+			m.postCursor = max(m.postCursor-1, 0)
+		}
+
+	case "enter":
+		if m.focus == focusSidebar {
+			m.feedSelected = m.feedCursor
+			m.postCursor = 0
+			m.focus = focusTimeline
+		}
+
+	case "u":
+		m.unreadOnly = !m.unreadOnly
+		m.postCursor = 0
+
+	case "R":
+		m.status = "Loading..."
+		return m, m.load
+
+	case "r":
+		if p, ok := m.currentPost(); ok {
+			m.status = m.setRead(p.ID, !m.state.IsRead(p.ID))
+		}
+
+	case "b":
+		if p, ok := m.currentPost(); ok {
+			return m, m.bookmark(p)
+		}
+
+	case "o":
+		if p, ok := m.currentPost(); ok {
+			m.status = m.setRead(p.ID, true)
+			return m, openInBrowser(p.Url)
+		}
+	}
+
+	return m, nil
+}
+
+// setRead updates the read state right away, View reads it too. It returns
+// the status to show.
+func (m model) setRead(id uuid.UUID, read bool) string {
+	err := m.state.Set(id, read)
+	if err != nil {
+		return "Error saving read state: " + err.Error()
+	}
+	if read {
+		return "Marked as read"
+	}
+	return "Marked as unread"
+}
+
+func (m model) bookmark(p post) tea.Cmd {
+	return func() tea.Msg {
+		err := m.client.bookmark(p.ID)
+		if err != nil {
+			return statusMsg("Error bookmarking: " + err.Error())
+		}
+		return statusMsg("Bookmarked " + p.Title)
+	}
+}
+
+func openInBrowser(url string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", url)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+		default:
+			cmd = exec.Command("xdg-open", url)
+		}
+
+		err := cmd.Start()
+		if err != nil {
+			return statusMsg("Error opening browser: " + err.Error())
+		}
+		go cmd.Wait()
+		return statusMsg("Opened " + url)
+	}
+}
+
+func (m model) View() string {
+	if m.width == 0 {
+		return m.status
+	}
+
+	// two lines of border per pane and one status line
+	rows := max(m.height-3, 1)
+
+	sidebar := m.sidebarView(rows)
+	timeline := m.timelineView(rows, max(m.width-sidebarWidth-8, 10))
+
+	sidebarPane, timelinePane := paneStyle, focusedStyle
+	if m.focus == focusSidebar {
+		sidebarPane, timelinePane = focusedStyle, paneStyle
+	}
+
+	mode := "all"
+	if m.unreadOnly {
+		mode = "unread"
+	}
+
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top,
+			sidebarPane.Width(sidebarWidth).Height(rows).Render(sidebar),
+			timelinePane.Width(max(m.width-sidebarWidth-4, 10)).Height(rows).Render(timeline),
+		),
+		statusStyle.Render(fmt.Sprintf("[%s] %s", mode, m.status)),
+	)
+}
+
+func (m model) sidebarView(rows int) string {
+	names := []string{"All feeds"}
+	for _, f := range m.feeds {
+		names = append(names, f.Name)
+	}
+
+	var lines []string
+	start, end := window(len(names), m.feedCursor, rows)
+	for i := start; i < end; i++ {
+		name := truncate(names[i], sidebarWidth-2)
+		if i == m.feedSelected {
+			name = selectedStyle.Render(name)
+		}
+		if i == m.feedCursor && m.focus == focusSidebar {
+			name = cursorStyle.Render(name)
+		}
+		lines = append(lines, name)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (m model) timelineView(rows, width int) string {
+	visible := m.visiblePosts()
+	if len(visible) == 0 {
+		return "No posts"
+	}
+
+	var lines []string
+	start, end := window(len(visible), m.postCursor, rows)
+	for i := start; i < end; i++ {
+		p := visible[i]
+		date := "          "
+		if p.PublishedAt.Valid {
+			date = p.PublishedAt.Time.Format("2006-01-02")
+		}
+		line := truncate(fmt.Sprintf("%s  %s  %s", date, p.Title, p.Name), width)
+
+		if m.state.IsRead(p.ID) {
+			line = readStyle.Render(line)
+		}
+		if i == m.postCursor && m.focus == focusTimeline {
+			line = cursorStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// window returns the range of at most rows of n items that keeps the cursor
+// in view.
+func window(n, cursor, rows int) (int, int) {
+	start := 0
+	if cursor >= rows {
+		start = cursor - rows + 1
+	}
+
+	return start, min(start+rows, n)
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 1 {
+		return string(runes[:width])
+	}
+
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// readState remembers which posts were read. The API has no read state, so
+// it is kept in a file on this machine.
+type readState struct {
+	path string
+	read map[uuid.UUID]bool
+}
+
+func loadReadState(path string) (*readState, error) {
+	state := &readState{path: path, read: map[uuid.UUID]bool{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uuid.UUID
+	err = json.Unmarshal(data, &ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		state.read[id] = true
+	}
+
+	return state, nil
+}
+
+func (s *readState) IsRead(id uuid.UUID) bool {
+	return s.read[id]
+}
+
+// Set marks the post read or unread and saves the state.
+func (s *readState) Set(id uuid.UUID, read bool) error {
+	if read {
+		s.read[id] = true
+	} else {
+		delete(s.read, id)
+	}
+
+	ids := make([]uuid.UUID, 0, len(s.read))
+	for id := range s.read {
+		ids = append(ids, id)
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(s.path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, data, 0o644)
+}
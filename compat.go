@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+Field names of responses are in transition. Many responses carry rows of the
+database as they are, with Go field names like "CreatedAt" and nullable
+columns as {"String": "...", "Valid": true}. The v2 names are snake_case like
+the rest of the API, with nullable columns as a plain value or null:
+
+	legacy  {"ID": "...", "LastFetchedAt": {"Time": "...", "Valid": true}}
+	v2      {"id": "...", "last_fetched_at": "..."}
+
+API_FIELD_NAMES sets what the server sends: legacy (the default), both, or v2.
+With both every legacy field is sent along with its v2 twin, so clients can
+move over field by field. A client can ask for a mode with the API-Field-Names
+request header, the mode used is sent back in the same header.
+*/
+
+const fieldNamesHeader = "API-Field-Names"
+
+const (
+	fieldNamesLegacy = "legacy"
+	fieldNamesBoth   = "both"
+	fieldNamesV2     = "v2"
+)
+
+var errInvalidFieldNames = errors.New("API_FIELD_NAMES must be legacy, both or v2")
+
+func parseFieldNames(value string) (string, error) {
+	switch value {
+	case "":
+		return fieldNamesLegacy, nil
+	case fieldNamesLegacy, fieldNamesBoth, fieldNamesV2:
+		return value, nil
+	}
+	return "", errInvalidFieldNames
+}
+
+// fieldNamesFromEnv reads API_FIELD_NAMES.
+func fieldNamesFromEnv() (string, error) {
+	return parseFieldNames(os.Getenv("API_FIELD_NAMES"))
+}
+
+// fieldNamesMiddleware rewrites the field names of JSON and NDJSON responses
+// to the mode the client asked for, or the default of the server.
+func fieldNamesMiddleware(defaultMode string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mode := defaultMode
+			if requested := r.Header.Get(fieldNamesHeader); requested != "" {
+				if parsed, err := parseFieldNames(requested); err == nil {
+					mode = parsed
+				}
+			}
+
+			w.Header().Add("Vary", fieldNamesHeader)
+			w.Header().Set(fieldNamesHeader, mode)
+			if mode == fieldNamesLegacy {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rw := &fieldNamesWriter{ResponseWriter: w, mode: mode}
+			next.ServeHTTP(rw, r)
+			rw.finish()
+		})
+	}
+}
+
+// fieldNamesWriter buffers JSON bodies to rewrite them once complete, and
+// rewrites NDJSON line by line so streams keep streaming.
+type fieldNamesWriter struct {
+	http.ResponseWriter
+	mode        string
+	status      int
+	wroteHeader bool
+	kind        string
+	buf         bytes.Buffer
+}
+
+func (w *fieldNamesWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "application/json"):
+		w.kind = "json"
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		w.kind = "ndjson"
+		w.ResponseWriter.WriteHeader(status)
+	default:
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *fieldNamesWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch w.kind {
+	case "json":
+		return w.buf.Write(b)
+	case "ndjson":
+		w.buf.Write(b)
+		for {
+			line, err := w.buf.ReadBytes('\n')
+			if err != nil {
+				// keep the partial line for the next write
+				rest := append([]byte(nil), line...)
+				w.buf.Reset()
+				w.buf.Write(rest)
+				return len(b), nil
+			}
+			if _, err := w.ResponseWriter.Write(rewriteFieldNames(line, w.mode)); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *fieldNamesWriter) Flush() {
+	if w.kind == "json" {
+		return
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets websockets upgrade through the wrapper.
+func (w *fieldNamesWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
+
+func (w *fieldNamesWriter) finish() {
+	switch w.kind {
+	case "json":
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(rewriteFieldNames(w.buf.Bytes(), w.mode))
+	case "ndjson":
+		if w.buf.Len() > 0 {
+			w.ResponseWriter.Write(rewriteFieldNames(w.buf.Bytes(), w.mode))
+		}
+	}
+}
+
+// rewriteFieldNames rewrites a JSON document, or returns it as it is when it
+// doesn't parse.
+func rewriteFieldNames(body []byte, mode string) []byte {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return body
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return body
+	}
+
+	rewritten, err := json.Marshal(renameFields(doc, mode))
+	if err != nil {
+		return body
+	}
+	if bytes.HasSuffix(body, []byte("\n")) {
+		rewritten = append(rewritten, '\n')
+	}
+	return rewritten
+}
+
+// legacyFieldName matches the Go field names to rename. Keys with other
+// characters, like the names of custom headers, are data and left alone.
+var legacyFieldName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+func renameFields(value interface{}, mode string) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		renamed := make([]interface{}, len(v))
+		for i := range v {
+			renamed[i] = renameFields(v[i], mode)
+		}
+		return renamed
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			if !legacyFieldName.MatchString(key) {
+				renamed[key] = renameFields(field, mode)
+				continue
+			}
+
+			if mode == fieldNamesBoth {
+				if _, ok := nullableValue(field); ok {
+					renamed[key] = field
+				} else {
+					renamed[key] = renameFields(field, mode)
+				}
+			}
+			name := snakeCase(key)
+			if _, taken := v[name]; !taken {
+				if inner, ok := nullableValue(field); ok {
+					field = inner
+				}
+				renamed[name] = renameFields(field, mode)
+			}
+		}
+		return renamed
+	}
+	return value
+}
+
+// nullableValue unwraps {"String": "x", "Valid": true} and the like, as sql
+// and uuid nullable types marshal, into "x", or nil when not valid. ok is false
+// for anything else.
+func nullableValue(value interface{}) (inner interface{}, ok bool) {
+	wrapper, ok := value.(map[string]interface{})
+	if !ok || len(wrapper) != 2 {
+		return nil, false
+	}
+	valid, ok := wrapper["Valid"].(bool)
+	if !ok {
+		return nil, false
+	}
+
+	for _, name := range []string{"String", "Time", "Int16", "Int32", "Int64", "Float64", "Bool", "Byte", "UUID", "V"} {
+		inner, ok := wrapper[name]
+		if !ok {
+			continue
+		}
+		if !valid {
+			return nil, true
+		}
+		return inner, true
+	}
+	return nil, false
+}
+
+// snakeCase turns a Go field name into its v2 name: ID is id, UserID is
+// user_id, AllowedCidrs is allowed_cidrs.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// routeDeprecation describes a route that is going away.
+type routeDeprecation struct {
+	// Since is when the route was deprecated, Sunset when it stops working.
+	Since  time.Time
+	Sunset time.Time
+	// Successor is the route to use instead.
+	Successor string
+}
+
+// deprecatedHandler marks the responses of handler with the Deprecation,
+// Sunset and Link headers (RFC 9745 and RFC 8594), so clients and their
+// logs notice before the route is gone.
+func deprecatedHandler(deprecation routeDeprecation, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.Since.Unix()))
+		w.Header().Set("Sunset", deprecation.Sunset.UTC().Format(http.TimeFormat))
+		if deprecation.Successor != "" {
+			w.Header().Add("Link", "<"+deprecation.Successor+">; rel=\"successor-version\"")
+		}
+
+		handler(w, r)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"golang.org/x/net/html"
+)
+
+const (
+	contentFetchTimeout = 15 * time.Second
+	contentMaxBody      = 5 << 20
+
+	// paragraphs shorter than this are mostly captions, bylines and buttons
+	contentMinParagraph = 25
+)
+
+var errNoArticle = errors.New("no article found on the page")
+
+// contentNoise are elements that never belong to the article itself.
+const contentNoise = "script, style, noscript, iframe, form, nav, header, footer, aside, button, svg"
+
+// contentAllowed are the elements kept in extracted html, everything else is
+// unwrapped to its children.
+var contentAllowed = map[string]bool{
+	"p": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "blockquote": true, "pre": true, "code": true,
+	"em": true, "strong": true, "a": true, "img": true, "figure": true, "figcaption": true, "br": true,
+}
+
+// contentAttributes are the attributes kept on the allowed elements.
+var contentAttributes = map[string]bool{"href": true, "src": true, "alt": true}
+
+// pageMetadata is what a page says about itself in its Open Graph and
+// Twitter Card tags, falling back to the plain html equivalents.
+type pageMetadata struct {
+	Title        string
+	Description  string
+	Image        string
+	SiteName     string
+	CanonicalURL string
+}
+
+func extractMetadata(doc *goquery.Document, base *url.URL) pageMetadata {
+	meta := func(selectors ...string) string {
+		for _, selector := range selectors {
+			content, _ := doc.Find(selector).First().Attr("content")
+			if content = strings.TrimSpace(content); content != "" {
+				return content
+			}
+		}
+		return ""
+	}
+	absolute := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return ""
+		}
+		return u.String()
+	}
+
+	metadata := pageMetadata{
+		Title:       meta(`meta[property="og:title"]`, `meta[name="twitter:title"]`),
+		Description: meta(`meta[property="og:description"]`, `meta[name="twitter:description"]`, `meta[name="description"]`),
+		Image:       absolute(meta(`meta[property="og:image"]`, `meta[name="twitter:image"]`, `meta[name="twitter:image:src"]`)),
+		SiteName:    meta(`meta[property="og:site_name"]`),
+	}
+	if metadata.Title == "" {
+		metadata.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+
+	canonical, _ := doc.Find(`link[rel="canonical"]`).First().Attr("href")
+	if canonical = strings.TrimSpace(canonical); canonical == "" {
+		canonical = meta(`meta[property="og:url"]`)
+	}
+	metadata.CanonicalURL = absolute(canonical)
+
+	return metadata
+}
+
+// extractArticle finds the main text of an html page the way readability
+// does: every paragraph scores points for its parent and, half of them, for
+// its grandparent, and the best scoring element is taken as the article.
+// An <article> element with enough text wins outright. Links and images are
+// made absolute against base. The noise around the article is removed from
+// doc on the way.
+func extractArticle(doc *goquery.Document, base *url.URL) (string, string, error) {
+	doc.Find(contentNoise).Remove()
+
+	var best *goquery.Selection
+	if article := doc.Find("article").First(); article.Length() > 0 && len(strings.TrimSpace(article.Text())) > 500 {
+		best = article
+	} else {
+		best = bestContentCandidate(doc)
+	}
+	if best == nil {
+		return "", "", errNoArticle
+	}
+
+	cleanContent(best, base)
+	text := strings.Join(strings.Fields(best.Text()), " ")
+	if text == "" {
+		return "", "", errNoArticle
+	}
+
+	content, err := best.Html()
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.TrimSpace(content), text, nil
+}
+
+func bestContentCandidate(doc *goquery.Document) *goquery.Selection {
+	scores := map[*html.Node]float64{}
+	doc.Find("p, pre, td").Each(func(_ int, p *goquery.Selection) {
+		text := strings.TrimSpace(p.Text())
+		if len(text) < contentMinParagraph {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		if parent := p.Parent(); parent.Length() > 0 {
+			scores[parent.Get(0)] += score
+			if grandparent := parent.Parent(); grandparent.Length() > 0 {
+				scores[grandparent.Get(0)] += score / 2
+			}
+		}
+	})
+
+	var best *html.Node
+	var bestScore float64
+	for node, score := range scores {
+		// lots of links means navigation or a list of teasers, not an article
+		candidate := goquery.NewDocumentFromNode(node).Selection
+		score *= 1 - linkDensity(candidate)
+		if best == nil || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	return goquery.NewDocumentFromNode(best).Selection
+}
+
+func linkDensity(s *goquery.Selection) float64 {
+	textLength := len(strings.TrimSpace(s.Text()))
+	if textLength == 0 {
+		return 1
+	}
+
+	linkLength := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		linkLength += len(strings.TrimSpace(a.Text()))
+	})
+
+	return float64(linkLength) / float64(textLength)
+}
+
+// cleanContent strips the article down to contentAllowed elements and
+// contentAttributes.
+func cleanContent(s *goquery.Selection, base *url.URL) {
+	s.Find("*").Each(func(_ int, el *goquery.Selection) {
+		node := el.Get(0)
+		if !contentAllowed[node.Data] {
+			el.Contents().Unwrap()
+			return
+		}
+
+		attrs := node.Attr[:0]
+		for _, attr := range node.Attr {
+			if !contentAttributes[attr.Key] {
+				continue
+			}
+			if attr.Key == "href" || attr.Key == "src" {
+				ref, err := base.Parse(strings.TrimSpace(attr.Val))
+				if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") {
+					continue
+				}
+				attr.Val = ref.String()
+			}
+			attrs = append(attrs, attr)
+		}
+		node.Attr = attrs
+	})
+}
+
+// fetchPostContent downloads the page of the post, extracts its article and
+// stores it, together with the preview metadata of the page.
+func fetchPostContent(ctx context.Context, apiConfig apiConfig, post database.Post) (database.PostContent, error) {
+	ctx, cancel := context.WithTimeout(ctx, contentFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, post.Url, nil)
+	if err != nil {
+		return database.PostContent{}, err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return database.PostContent{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return database.PostContent{}, &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, contentMaxBody))
+	if err != nil {
+		return database.PostContent{}, err
+	}
+
+	metadata := extractMetadata(doc, resp.Request.URL)
+	err = apiConfig.DB.UpdatePostMetadata(ctx, database.UpdatePostMetadataParams{
+		ID:               post.ID,
+		ImageUrl:         sql.NullString{String: metadata.Image, Valid: metadata.Image != ""},
+		SiteName:         sql.NullString{String: metadata.SiteName, Valid: metadata.SiteName != ""},
+		PageCanonicalUrl: sql.NullString{String: metadata.CanonicalURL, Valid: metadata.CanonicalURL != ""},
+	})
+	if err != nil {
+		return database.PostContent{}, err
+	}
+
+	content, text, err := extractArticle(doc, resp.Request.URL)
+	if err != nil {
+		return database.PostContent{}, err
+	}
+
+	return apiConfig.DB.UpsertPostContent(ctx, database.UpsertPostContentParams{
+		PostID:    post.ID,
+		CreatedAt: time.Now(),
+		Html:      content,
+		Text:      text,
+	})
+}
+
+// queuePostContents extracts the articles of new posts in the background when
+// FETCH_FULL_CONTENT is enabled. At most as many feeds as there are workers
+// have their posts extracted at once, the posts of the rest are skipped and
+// extracted when they are first read instead.
+func (f *fetcher) queuePostContents(posts []database.Post) {
+	select {
+	case f.contentSlots <- struct{}{}:
+	default:
+		fetcherLog.Warn("Skipping content extraction, too many running", "posts", len(posts))
+		return
+	}
+
+	go func() {
+		defer func() { <-f.contentSlots }()
+		f.fetchPostContents(posts)
+	}()
+}
+
+// fetchPostContents extracts the articles of the posts one after the other,
+// keeping to the request interval of their hosts.
+func (f *fetcher) fetchPostContents(posts []database.Post) {
+	for _, post := range posts {
+		err := f.hosts.Wait(f.jobsCtx, post.Url)
+		if err != nil {
+			return
+		}
+
+		_, err = fetchPostContent(f.jobsCtx, f.apiConfig, post)
+		if err != nil {
+			fetcherLog.Error("Error extracting content", "post_url", post.Url, "err", err)
+		}
+	}
+}
+
+/*
+Endpoint: GET /v1/posts/{post_id}/content?full=true
+
+# This is an authenticated endpoint
+
+Returns the content of the post, which has to be in a feed the user follows.
+By default that is the summary from the
+feed. With full=true it is the article extracted from the page of the post,
+which is fetched on the first request unless the server already did so after
+the fetch (FETCH_FULL_CONTENT=true). Images point at the image proxy when it
+is enabled. progress is how far the user read into it, null if they never
+opened it.
+*/
+func getPostContentHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ContentResponse struct {
+			PostID   uuid.UUID                `json:"post_id"`
+			Full     bool                     `json:"full"`
+			HTML     string                   `json:"html"`
+			Text     string                   `json:"text,omitempty"`
+			Progress *readingProgressResponse `json:"progress"`
+		}
+
+		postID, err := uuid.Parse(chi.URLParam(r, "post_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid post_id")
+			return
+		}
+
+		context := r.Context()
+		post, err := apiConfig.DB.GetPostByID(context, postID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "post_not_found", "Post not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting post", "err", err)
+			respondWithError(w, 500, "Error getting post")
+			return
+		}
+		follows, err := followsFeed(context, apiConfig.DB, user.ID, post.FeedID)
+		if err != nil {
+			httpLog.Error("Error getting feed follows", "err", err)
+			respondWithError(w, 500, "Error getting post")
+			return
+		}
+		if !follows {
+			// the same as a missing post, to not tell which exist
+			respondWithErrorCode(w, 404, "post_not_found", "Post not found")
+			return
+		}
+
+		progress, err := userReadingProgress(context, apiConfig.DB, user.ID, post.ID)
+		if err != nil {
+			httpLog.Error("Error getting reading progress", "err", err)
+			respondWithError(w, 500, "Error getting post")
+			return
+		}
+
+		if r.URL.Query().Get("full") != "true" {
+			respondWithJSON(w, 200, ContentResponse{PostID: post.ID, HTML: proxyImages(apiConfig, post.Description), Progress: progress})
+			return
+		}
+
+		content, err := apiConfig.DB.GetPostContent(context, post.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			content, err = fetchPostContent(context, apiConfig, post)
+			if err != nil {
+				httpLog.Error("Error extracting content", "post_url", post.Url, "err", err)
+				respondWithError(w, 502, "Error extracting content")
+				return
+			}
+		}
+		if err != nil {
+			httpLog.Error("Error getting post content", "err", err)
+			respondWithError(w, 500, "Error getting post")
+			return
+		}
+
+		respondWithJSON(w, 200, ContentResponse{PostID: post.ID, Full: true, HTML: proxyImages(apiConfig, content.Html), Text: content.Text, Progress: progress})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestGetPostContentOfUnfollowedFeed(t *testing.T) {
+	alice := database.User{ID: uuid.New()}
+	bob := database.User{ID: uuid.New()}
+	post := database.Post{ID: uuid.New(), FeedID: uuid.New(), Title: "Post", Url: "https://example.com/post", Description: "summary"}
+
+	// alice follows the feed of the post, bob doesn't
+	fake, db := newFakeDB(t)
+	fake.on("GetPostByID", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{postRow(post)}}
+	})
+	fake.on("GetUserFeedFollows", func(args []driver.Value) fakeResult {
+		if args[0] == alice.ID.String() {
+			return fakeResult{rows: followRows(alice.ID, post.FeedID)}
+		}
+		return fakeResult{}
+	})
+	apiConfig := apiConfig{DB: db}
+
+	getAs := func(user database.User, query string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Get("/v1/posts/{post_id}/content", asUser(user, getPostContentHandler(apiConfig)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/posts/"+post.ID.String()+"/content"+query, nil))
+		return w
+	}
+
+	if w := getAs(bob, ""); w.Code != 404 {
+		t.Errorf("bob: status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if w := getAs(bob, "?full=true"); w.Code != 404 {
+		t.Errorf("bob, full: status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if w := getAs(alice, ""); w.Code != 200 {
+		t.Errorf("alice: status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if fake.ran("GetPostContent") {
+		t.Error("the content was looked up for bob")
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+
+	// emailRateInterval and emailRateBurst bound the mails a user can ask
+	// for, verification links and password resets each.
+	emailRateInterval = time.Minute
+	emailRateBurst    = 3
+)
+
+var errEmailNotVerified = errors.New("Verify your email address first")
+
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("Invalid email address")
+	}
+
+	return nil
+}
+
+func isEmailVerified(user database.User) bool {
+	return user.Email.Valid && user.EmailVerifiedAt.Valid
+}
+
+// requireVerifiedEmail responds with a 403 unless the user verified their
+// email. Notifications that leave the server need it, so a throwaway account
+// can't point them at someone else.
+func requireVerifiedEmail(w http.ResponseWriter, user database.User) bool {
+	if isEmailVerified(user) {
+		return true
+	}
+	respondWithErrorCode(w, 403, "email_not_verified", errEmailNotVerified.Error())
+	return false
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+// sendEmailVerification creates a fresh token for the user's current email
+// and mails the verification link to it.
+func sendEmailVerification(ctx context.Context, apiConfig apiConfig, user database.User) error {
+	if !user.Email.Valid {
+		return errors.New("user has no email")
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = apiConfig.DB.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email.String,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := apiConfig.BaseURL + "/v1/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nplease confirm your email address by opening this link:\n\n%s\n\nThe link expires in 24 hours.\n", user.Name, link)
+	return apiConfig.Mailer.Send(user.Email.String, "Confirm your email address", body)
+}
+
+/*
+Endpoint: GET /v1/verify?token=
+
+Confirms the email address the token was sent to.
+*/
+func verifyEmailHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			respondWithError(w, 400, "Missing token")
+			return
+		}
+
+		context := r.Context()
+		verification, err := apiConfig.DB.GetEmailVerification(context, hashToken(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 404, "Invalid token")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting email verification", "err", err)
+			respondWithError(w, 500, "Error verifying email")
+			return
+		}
+
+		if time.Now().After(verification.ExpiresAt) {
+			respondWithError(w, 410, "Token expired")
+			return
+		}
+
+		err = apiConfig.DB.MarkUserEmailVerified(context, database.MarkUserEmailVerifiedParams{
+			ID:    verification.UserID,
+			Email: sql.NullString{String: verification.Email, Valid: true},
+		})
+		if err != nil {
+			httpLog.Error("Error marking email verified", "err", err)
+			respondWithError(w, 500, "Error verifying email")
+			return
+		}
+
+		err = apiConfig.DB.DeleteUserEmailVerifications(context, verification.UserID)
+		if err != nil {
+			httpLog.Error("Error deleting email verifications", "err", err)
+		}
+
+		respondWithJSON(w, 200, map[string]string{"status": "verified"})
+	}
+}
+
+/*
+Endpoint: POST /v1/users/verify/resend
+
+# This is an authenticated endpoint
+
+Sends a new verification link to the user's email address. A few go out at
+once, then one a minute.
+*/
+func resendEmailVerificationHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	limiter := newUserLimiter(emailRateInterval, emailRateBurst)
+
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if !user.Email.Valid {
+			respondWithError(w, 400, "User has no email")
+			return
+		}
+		if isEmailVerified(user) {
+			respondWithError(w, 409, "Email is already verified")
+			return
+		}
+		if !limiter.Allow(user.ID) {
+			w.Header().Set("Retry-After", "60")
+			respondWithError(w, 429, "Too many verification mails, try again later")
+			return
+		}
+
+		context := r.Context()
+		err := sendEmailVerification(context, apiConfig, user)
+		if err != nil {
+			httpLog.Error("Error sending email verification", "err", err)
+			respondWithError(w, 500, "Error sending email verification")
+			return
+		}
+
+		respondWithJSON(w, 202, map[string]string{"status": "sent"})
+	}
+}
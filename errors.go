@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// apiError is the body of every error response:
+//
+//	{
+//		"error": {
+//			"code": "feed_not_found",
+//			"message": "Feed not found"
+//		}
+//	}
+//
+// code is for programs and doesn't change, message is for people and may.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Field is the field of the request body that is wrong, if it is one.
+	Field string `json:"field,omitempty"`
+	// Fields are all fields that are wrong, when a request fails validation.
+	Fields []fieldError `json:"fields,omitempty"`
+}
+
+// errorCodes are the codes of statuses whose code isn't their status text.
+var errorCodes = map[int]string{
+	http.StatusTooManyRequests:     "rate_limited",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// statusErrorCode is the code of an error that has no code of its own, the
+// status text in snake case, like not_found.
+func statusErrorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text))
+}
+
+// respondWithError responds with the code of the status, see
+// respondWithErrorCode for errors clients tell apart.
+func respondWithError(w http.ResponseWriter, status int, msg string) {
+	respondWithErrorCode(w, status, statusErrorCode(status), msg)
+}
+
+func respondWithErrorCode(w http.ResponseWriter, status int, code, msg string) {
+	respondWithJSON(w, status, apiError{Error: apiErrorDetail{Code: code, Message: msg}})
+}
+
+// respondWithFieldError responds with an error about one field of the
+// request body.
+func respondWithFieldError(w http.ResponseWriter, status int, code, field, msg string) {
+	respondWithJSON(w, status, apiError{Error: apiErrorDetail{Code: code, Message: msg, Field: field}})
+}
+
+// recoverMiddleware turns a panicking handler into a 500 instead of a closed
+// connection, and logs the panic with its stack.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			// handlers abort a response with it on purpose
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			attrs := []any{"method", r.Method, "path", r.URL.Path, "panic", v, "stack", string(debug.Stack())}
+			if info := requestInfoFrom(r.Context()); info != nil {
+				attrs = append(attrs, "request_id", info.ID)
+			}
+			httpLog.Error("Panic handling request", attrs...)
+			respondWithError(w, 500, "Internal server error")
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, 404, "Not found")
+}
+
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, 405, "Method not allowed")
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/storage"
+)
+
+const (
+	feedIconTimeout = 10 * time.Second
+	feedIconMaxBody = 256 << 10
+	feedIconMaxPage = 512 << 10
+
+	// icons are looked up again after a week, missing ones too
+	feedIconMaxAge        = 7 * 24 * time.Hour
+	feedIconRefreshBatch  = 100
+	feedIconRefreshPeriod = time.Hour
+	feedIconCacheMaxAge   = 24 * time.Hour
+	feedIconStoragePrefix = "icons/"
+)
+
+var errNoFeedIcon = errors.New("no icon found")
+
+func feedIconKey(feedID uuid.UUID) string {
+	return feedIconStoragePrefix + feedID.String()
+}
+
+// fetchFeedIcon looks up the icon of the site the feed belongs to and stores
+// it. A site without a usable icon is recorded as such, so it isn't asked
+// again before the next refresh. Only storing can fail.
+func fetchFeedIcon(ctx context.Context, apiConfig apiConfig, feed database.Feed) (database.FeedIcon, error) {
+	ctx, cancel := context.WithTimeout(ctx, feedIconTimeout)
+	defer cancel()
+
+	params := database.UpsertFeedIconParams{FeedID: feed.ID, FetchedAt: time.Now()}
+	sourceURL, contentType, data, err := findSiteIcon(ctx, feed.Url)
+	if err != nil {
+		fetcherLog.Error("Error fetching icon", "feed_url", feed.Url, "err", err)
+	} else {
+		hash := sha256.Sum256(data)
+		err = apiConfig.Storage.Put(ctx, feedIconKey(feed.ID), bytes.NewReader(data), int64(len(data)), contentType)
+		if err != nil {
+			return database.FeedIcon{}, err
+		}
+
+		params.SourceUrl = sourceURL
+		params.ContentType = contentType
+		params.Hash = hex.EncodeToString(hash[:16])
+	}
+
+	return apiConfig.DB.UpsertFeedIcon(ctx, params)
+}
+
+// findSiteIcon tries the icons the home page of the feed's site links to and
+// then /favicon.ico, and returns the first that downloads as an image.
+func findSiteIcon(ctx context.Context, feedURL string) (string, string, []byte, error) {
+	site, err := url.Parse(feedURL)
+	if err != nil {
+		return "", "", nil, err
+	}
+	site = &url.URL{Scheme: site.Scheme, Host: site.Host, Path: "/"}
+
+	candidates, err := linkedIcons(ctx, site)
+	if err != nil {
+		fetcherLog.Error("Error reading icon links", "site", site, "err", err)
+	}
+	candidates = append(candidates, site.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+
+	for _, candidate := range candidates {
+		contentType, data, err := downloadIcon(ctx, candidate)
+		if err == nil {
+			return candidate, contentType, data, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", nil, ctx.Err()
+		}
+	}
+
+	return "", "", nil, errNoFeedIcon
+}
+
+// linkedIcons reads the <link rel="icon"> elements of the page, plain icons
+// before apple-touch-icons.
+func linkedIcons(ctx context.Context, page *url.URL) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	doc, err := goquery.NewDocumentFromReader(io.LimitReader(resp.Body, feedIconMaxPage))
+	if err != nil {
+		return nil, err
+	}
+
+	var icons, touchIcons []string
+	doc.Find("link[rel][href]").Each(func(_ int, link *goquery.Selection) {
+		rel, _ := link.Attr("rel")
+		href, _ := link.Attr("href")
+		ref, err := resp.Request.URL.Parse(strings.TrimSpace(href))
+		if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") {
+			return
+		}
+
+		rels := strings.Fields(strings.ToLower(rel))
+		switch {
+		case slices.Contains(rels, "icon"):
+			icons = append(icons, ref.String())
+		case slices.Contains(rels, "apple-touch-icon"):
+			touchIcons = append(touchIcons, ref.String())
+		}
+	})
+
+	return append(icons, touchIcons...), nil
+}
+
+// downloadIcon fetches an image. SVGs are refused, they could run scripts
+// when served from our origin.
+func downloadIcon(ctx context.Context, iconURL string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, iconURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", nil, &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	data, err := readFeedBody(resp.Body, feedIconMaxBody)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) == 0 {
+		return "", nil, errNoFeedIcon
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		return "", nil, errNoFeedIcon
+	}
+
+	return contentType, data, nil
+}
+
+// refreshFeedIcons fetches the icons of feeds that have none yet or whose
+// icon is older than feedIconMaxAge, a batch at a time.
+func refreshFeedIcons(ctx context.Context, apiConfig apiConfig) {
+	feeds, err := apiConfig.DB.GetFeedsWithStaleIcons(ctx, database.GetFeedsWithStaleIconsParams{
+		FetchedAt: time.Now().Add(-feedIconMaxAge),
+		Limit:     feedIconRefreshBatch,
+	})
+	if err != nil {
+		fetcherLog.Error("Error getting feeds to refresh icons of", "err", err)
+		return
+	}
+
+	for _, feed := range feeds {
+		if ctx.Err() != nil {
+			return
+		}
+
+		_, err := fetchFeedIcon(ctx, apiConfig, feed)
+		if err != nil {
+			fetcherLog.Error("Error saving icon", "feed_url", feed.Url, "err", err)
+		}
+	}
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/icon
+
+Returns the favicon of the site the feed belongs to. Icons are cached by the
+server and refreshed weekly, responses may be cached for a day and carry an
+ETag for revalidation. Feeds whose site has no icon return 404.
+*/
+func getFeedIconHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		context := r.Context()
+		icon, err := apiConfig.DB.GetFeedIcon(context, feedID)
+		if errors.Is(err, sql.ErrNoRows) {
+			icon, err = fetchMissingFeedIcon(context, apiConfig, feedID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed icon", "err", err)
+			respondWithError(w, 500, "Error getting feed icon")
+			return
+		}
+		if icon.ContentType == "" {
+			respondWithError(w, 404, "Feed has no icon")
+			return
+		}
+
+		etag := `"` + icon.Hash + `"`
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(feedIconCacheMaxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		body, err := apiConfig.Storage.Get(context, feedIconKey(feedID))
+		if errors.Is(err, storage.ErrNotFound) {
+			respondWithError(w, 404, "Feed has no icon")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error reading feed icon", "err", err)
+			respondWithError(w, 500, "Error getting feed icon")
+			return
+		}
+		defer body.Close()
+
+		w.Header().Set("Content-Type", icon.ContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(200)
+		io.Copy(w, body)
+	}
+}
+
+// fetchMissingFeedIcon fetches the icon of a feed that has never been looked
+// up, for the first request for it.
+func fetchMissingFeedIcon(ctx context.Context, apiConfig apiConfig, feedID uuid.UUID) (database.FeedIcon, error) {
+	feed, err := apiConfig.DB.GetFeedByID(ctx, feedID)
+	if err != nil {
+		return database.FeedIcon{}, err
+	}
+
+	return fetchFeedIcon(ctx, apiConfig, feed)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const feedNoteMaxLength = 500
+
+// feedNoteResponse is a note of the feed's owner, like "official mirror",
+// shown to everyone with the feed.
+type feedNoteResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Note      string    `json:"note"`
+}
+
+func newFeedNoteResponse(note database.FeedNote) feedNoteResponse {
+	return feedNoteResponse{
+		ID:        note.ID,
+		CreatedAt: note.CreatedAt,
+		UserID:    note.UserID,
+		Note:      note.Note,
+	}
+}
+
+// currentFeedNote returns the note of the feed, nil when it has none or the
+// last one was cleared. Every change is kept, the newest is the current one.
+func currentFeedNote(ctx context.Context, q *database.Queries, feedID uuid.UUID) (*feedNoteResponse, error) {
+	note, err := q.GetFeedNote(ctx, feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if note.Note == "" {
+		return nil, nil
+	}
+
+	resp := newFeedNoteResponse(note)
+	return &resp, nil
+}
+
+/*
+Endpoint: PUT /v1/feeds/{feed_id}/note
+
+# This is an authenticated endpoint
+
+Sets the public note of a feed, shown to all followers with the feed. Only the
+owner of the feed can set it, an empty note removes it. Earlier notes stay in
+the history.
+*/
+func putFeedNoteHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type NoteRequest struct {
+			Note string `json:"note"`
+		}
+
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		var req NoteRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		req.Note = strings.TrimSpace(req.Note)
+		if utf8.RuneCountInString(req.Note) > feedNoteMaxLength {
+			respondWithError(w, 400, "Note is too long")
+			return
+		}
+
+		context := r.Context()
+		feed, err := apiConfig.DB.GetFeedByID(context, feedID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error setting feed note")
+			return
+		}
+		if feed.UserID != user.ID {
+			respondWithError(w, 403, "Only the owner of the feed can set its note")
+			return
+		}
+
+		note, err := apiConfig.DB.CreateFeedNote(context, database.CreateFeedNoteParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			FeedID:    feed.ID,
+			UserID:    user.ID,
+			Note:      req.Note,
+		})
+		if err != nil {
+			httpLog.Error("Error creating feed note", "err", err)
+			respondWithError(w, 500, "Error setting feed note")
+			return
+		}
+
+		respondWithJSON(w, 200, newFeedNoteResponse(note))
+	}
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/note/history
+
+Lists every note the feed had, newest first. Removals show up as empty notes.
+*/
+func getFeedNoteHistoryHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		context := r.Context()
+		_, err = apiConfig.DB.GetVisibleFeedByID(context, database.GetVisibleFeedByIDParams{
+			ID:     feedID,
+			UserID: apiConfig.requestUserID(r),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error getting feed notes")
+			return
+		}
+
+		notes, err := apiConfig.DB.GetFeedNoteHistory(context, feedID)
+		if err != nil {
+			httpLog.Error("Error getting feed notes", "err", err)
+			respondWithError(w, 500, "Error getting feed notes")
+			return
+		}
+
+		resp := make([]feedNoteResponse, 0, len(notes))
+		for _, note := range notes {
+			resp = append(resp, newFeedNoteResponse(note))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	feedPreviewDefaultItems = 5
+	feedPreviewMaxItems     = 20
+)
+
+type feedPreviewItem struct {
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Author      string     `json:"author"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+/*
+Endpoint: POST /v1/feeds/preview
+
+# This is an authenticated endpoint
+
+Fetches and parses a feed without storing anything, for a confirmation before
+subscribing. Like POST /v1/feeds, the url can be a page that links to its feed.
+limit is how many of the first items to return, 5 by default and up to 20.
+credentials, scraper and source work as for POST /v1/feeds and are only used
+for this fetch, so selectors and filters can be tried out before subscribing.
+
+	{
+		"url": "https://blog.example.com/",
+		"limit": 3
+	}
+
+The response has the url of the feed that was found, its title, description
+and link, how many items it has and the first of them. feed_id is the id of
+the feed when it is already known to the server, null otherwise.
+*/
+func postFeedPreviewHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type PreviewRequest struct {
+			URL         string           `json:"url"`
+			Limit       *int             `json:"limit"`
+			Credentials *feedCredentials `json:"credentials"`
+			Scraper     *feedScraper     `json:"scraper"`
+			Source      *feedSource      `json:"source"`
+		}
+		type PreviewResponse struct {
+			URL         string            `json:"url"`
+			FeedID      *uuid.UUID        `json:"feed_id"`
+			Title       string            `json:"title"`
+			Description string            `json:"description"`
+			Link        string            `json:"link"`
+			ItemCount   int               `json:"item_count"`
+			Items       []feedPreviewItem `json:"items"`
+		}
+
+		var req PreviewRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		var v validation
+		limit := feedPreviewDefaultItems
+		if req.Limit != nil {
+			if *req.Limit < 1 || *req.Limit > feedPreviewMaxItems {
+				v.add("limit", "limit must be between 1 and 20")
+			}
+			limit = *req.Limit
+		}
+		if req.Credentials != nil {
+			v.check("credentials", req.Credentials.normalize())
+		}
+		if req.Scraper != nil {
+			v.check("scraper", req.Scraper.normalize())
+		}
+		if req.Source != nil {
+			v.check("source", req.Source.normalize())
+			if !v.has("source") {
+				req.URL = req.Source.url()
+			}
+		} else {
+			v.check("url", validateFeedURL(req.URL))
+		}
+		if v.respond(w) {
+			return
+		}
+
+		feedURL, err := normalizeFeedURL(req.URL)
+		if err != nil {
+			respondWithError(w, 400, errInvalidFeedURL.Error())
+			return
+		}
+		if translated, ok := translateFeedURL(feedURL); ok && req.Scraper == nil {
+			feedURL = translated
+		}
+
+		context := r.Context()
+		err = checkPublicURL(context, feedURL)
+		if errors.Is(err, errPrivateFeedURL) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		var discoveredURL string
+		var parsed *gofeed.Feed
+		if req.Scraper != nil {
+			discoveredURL = feedURL
+			parsed, err = scrapeURL(context, feedURL, req.Credentials, req.Scraper)
+			if errors.Is(err, errNoScrapedItems) {
+				respondWithError(w, 400, err.Error())
+				return
+			}
+		} else if fetchSource := sourceFetcher(feedURL); fetchSource != nil {
+			discoveredURL = feedURL
+			parsed, err = fetchSource(context, feedURL)
+		} else {
+			discoveredURL, parsed, err = discoverFeed(context, feedURL, req.Credentials)
+		}
+		if err != nil {
+			httpLog.Error("Error previewing feed", "feed_url", feedURL, "err", err)
+			respondWithError(w, 400, errNotAFeed.Error())
+			return
+		}
+
+		resp := PreviewResponse{
+			URL:         discoveredURL,
+			Title:       strings.TrimSpace(parsed.Title),
+			Description: strings.TrimSpace(parsed.Description),
+			Link:        parsed.Link,
+			ItemCount:   len(parsed.Items),
+			Items:       []feedPreviewItem{},
+		}
+
+		feed, err := apiConfig.DB.GetFeedByUrl(context, discoveredURL)
+		if err == nil {
+			err = sharedFeed(context, apiConfig.DB, user.ID, feed)
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, errFeedNotShared) {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error previewing feed")
+			return
+		}
+		if err == nil {
+			resp.FeedID = &feed.ID
+		}
+
+		for _, item := range parsed.Items {
+			if len(resp.Items) == limit {
+				break
+			}
+			if item == nil {
+				continue
+			}
+
+			preview := feedPreviewItem{
+				Title:  strings.TrimSpace(item.Title),
+				URL:    item.Link,
+				Author: itemAuthor(item),
+			}
+			if published, ok := parseItemDate(item); ok {
+				preview.PublishedAt = &published
+			}
+			resp.Items = append(resp.Items, preview)
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/storage"
+	"github.com/mmcdole/gofeed"
+)
+
+const feedSnapshotStoragePrefix = "feed_snapshots/"
+
+func feedSnapshotKey(feedID, snapshotID uuid.UUID) string {
+	return feedSnapshotStoragePrefix + feedID.String() + "/" + snapshotID.String() + ".gz"
+}
+
+// saveFeedSnapshot stores the payload of a fetch gzipped and drops the
+// snapshots of the feed beyond the newest keep.
+func saveFeedSnapshot(ctx context.Context, apiConfig apiConfig, feed database.Feed, result feedFetchResult, keep int) error {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(result.Body)
+	if err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	id := uuid.New()
+	err = apiConfig.Storage.Put(ctx, feedSnapshotKey(feed.ID, id), bytes.NewReader(compressed.Bytes()), int64(compressed.Len()), "application/gzip")
+	if err != nil {
+		return err
+	}
+
+	_, err = apiConfig.DB.CreateFeedSnapshot(ctx, database.CreateFeedSnapshotParams{
+		ID:             id,
+		FeedID:         feed.ID,
+		FetchedAt:      time.Now(),
+		ContentType:    result.ContentType,
+		Size:           int32(len(result.Body)),
+		CompressedSize: int32(compressed.Len()),
+	})
+	if err != nil {
+		return err
+	}
+
+	old, err := apiConfig.DB.GetFeedSnapshotsBeyond(ctx, database.GetFeedSnapshotsBeyondParams{
+		FeedID: feed.ID,
+		Keep:   int32(keep),
+	})
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range old {
+		err := apiConfig.Storage.Delete(ctx, feedSnapshotKey(snapshot.FeedID, snapshot.ID))
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		err = apiConfig.DB.DeleteFeedSnapshot(ctx, snapshot.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var errSnapshotNotFound = errors.New("Snapshot not found")
+
+// loadFeedSnapshot returns the snapshot named in the url with its payload,
+// errSnapshotNotFound when the feed has no such snapshot.
+func loadFeedSnapshot(ctx context.Context, apiConfig apiConfig, r *http.Request) (database.FeedSnapshot, []byte, error) {
+	feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+	if err != nil {
+		return database.FeedSnapshot{}, nil, errSnapshotNotFound
+	}
+	snapshotID, err := uuid.Parse(chi.URLParam(r, "snapshot_id"))
+	if err != nil {
+		return database.FeedSnapshot{}, nil, errSnapshotNotFound
+	}
+
+	snapshot, err := apiConfig.DB.GetFeedSnapshot(ctx, database.GetFeedSnapshotParams{
+		ID:     snapshotID,
+		FeedID: feedID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.FeedSnapshot{}, nil, errSnapshotNotFound
+	}
+	if err != nil {
+		return database.FeedSnapshot{}, nil, err
+	}
+
+	blob, err := apiConfig.Storage.Get(ctx, feedSnapshotKey(snapshot.FeedID, snapshot.ID))
+	if errors.Is(err, storage.ErrNotFound) {
+		return database.FeedSnapshot{}, nil, errSnapshotNotFound
+	}
+	if err != nil {
+		return database.FeedSnapshot{}, nil, err
+	}
+	defer blob.Close()
+
+	gz, err := gzip.NewReader(blob)
+	if err != nil {
+		return database.FeedSnapshot{}, nil, err
+	}
+	body, err := readFeedBody(gz, feedMaxBody)
+	if err != nil {
+		return database.FeedSnapshot{}, nil, err
+	}
+
+	return snapshot, body, nil
+}
+
+/*
+Endpoint: GET /v1/admin/feeds/{feed_id}/snapshots
+
+# This is an admin endpoint
+
+Lists the raw payloads kept for the feed, newest first. The fetcher keeps the
+last FEED_SNAPSHOTS payloads of every feed, none by default. Payloads are
+stored gzipped under feed_snapshots/ in the blob store.
+*/
+func getFeedSnapshotsHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		snapshots, err := apiConfig.DB.GetFeedSnapshots(r.Context(), feedID)
+		if err != nil {
+			httpLog.Error("Error getting feed snapshots", "err", err)
+			respondWithError(w, 500, "Error getting snapshots")
+			return
+		}
+		if snapshots == nil {
+			snapshots = []database.FeedSnapshot{}
+		}
+
+		respondWithJSON(w, 200, snapshots)
+	}
+}
+
+/*
+Endpoint: GET /v1/admin/feeds/{feed_id}/snapshots/{snapshot_id}
+
+# This is an admin endpoint
+
+Returns the payload of the snapshot as the feed served it, with its content
+type.
+*/
+func getFeedSnapshotHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot, body, err := loadFeedSnapshot(r.Context(), apiConfig, r)
+		if errors.Is(err, errSnapshotNotFound) {
+			respondWithErrorCode(w, 404, "snapshot_not_found", err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error reading feed snapshot", "err", err)
+			respondWithError(w, 500, "Error getting snapshot")
+			return
+		}
+
+		if snapshot.ContentType != "" {
+			w.Header().Set("Content-Type", snapshot.ContentType)
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.WriteHeader(200)
+		w.Write(body)
+	}
+}
+
+/*
+Endpoint: POST /v1/admin/feeds/{feed_id}/snapshots/{snapshot_id}/replay
+
+# This is an admin endpoint
+
+Runs the parser of this build over the snapshot, or the scraper when the feed
+is scraped, without fetching the feed or saving anything, and returns the
+parsed feed, or the error of the parser:
+
+	{
+		"snapshot": {...},
+		"feed": {...},
+		"error": null
+	}
+*/
+func replayFeedSnapshotHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type ReplayResponse struct {
+			Snapshot database.FeedSnapshot `json:"snapshot"`
+			Feed     *gofeed.Feed          `json:"feed"`
+			Error    *string               `json:"error"`
+		}
+
+		snapshot, body, err := loadFeedSnapshot(r.Context(), apiConfig, r)
+		if errors.Is(err, errSnapshotNotFound) {
+			respondWithErrorCode(w, 404, "snapshot_not_found", err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error reading feed snapshot", "err", err)
+			respondWithError(w, 500, "Error getting snapshot")
+			return
+		}
+
+		scraper, err := loadFeedScraper(r.Context(), apiConfig.DB, snapshot.FeedID)
+		if err != nil {
+			httpLog.Error("Error loading feed scraper", "err", err)
+			respondWithError(w, 500, "Error getting snapshot")
+			return
+		}
+
+		resp := ReplayResponse{Snapshot: snapshot}
+		if scraper != nil {
+			var feed database.Feed
+			feed, err = apiConfig.DB.GetFeedByID(r.Context(), snapshot.FeedID)
+			if err != nil {
+				httpLog.Error("Error getting feed", "err", err)
+				respondWithError(w, 500, "Error getting snapshot")
+				return
+			}
+			resp.Feed, err = scraper.scrape(body, snapshot.ContentType, feed.Url)
+		} else {
+			resp.Feed, err = parseFeedBody(body, snapshot.ContentType)
+		}
+		if err != nil {
+			message := err.Error()
+			resp.Error = &message
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	feedVerifyTimeout = 10 * time.Second
+	feedVerifyMaxBody = 5 << 20
+)
+
+var (
+	errInvalidFeedURL = errors.New("Invalid feed URL")
+	errNotAFeed       = errors.New("URL is not a valid feed")
+	// errFeedNotShared is a feed another user added with credentials, its
+	// posts are theirs alone.
+	errFeedNotShared = errors.New("The feed was added by another user with credentials and isn't shared")
+)
+
+// normalizeFeedURL makes sure the url is an absolute http(s) url and brings it
+// to a canonical form, so the same feed can't be added twice with different spelling.
+func normalizeFeedURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", errors.New("URL is not valid")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errors.New("URL must use http or https")
+	}
+	if u.Host == "" {
+		return "", errors.New("URL must have a host")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = host + ":" + port
+	}
+
+	u.Host = host
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+
+	return u.String(), nil
+}
+
+// validateFeedURL checks that the url of a feed is an absolute http(s) url.
+func validateFeedURL(raw string) error {
+	if strings.TrimSpace(raw) == "" {
+		return errors.New("URL is required")
+	}
+	_, err := normalizeFeedURL(raw)
+	return err
+}
+
+// validateFeedName checks the name given to a feed, an empty one means the
+// title of the feed is used.
+func validateFeedName(name string) error {
+	if name != "" && strings.TrimSpace(name) == "" {
+		return errors.New("Name must not be blank")
+	}
+	return nil
+}
+
+// canonicalPostURL is the url of a post without tracking parameters, so an
+// article syndicated in several feeds is recognized as the same one. Links
+// that aren't absolute http(s) urls are returned as they are.
+func canonicalPostURL(link string) string {
+	normalized, err := normalizeFeedURL(link)
+	if err != nil {
+		return link
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return normalized
+	}
+
+	query := u.Query()
+	for key := range query {
+		if strings.HasPrefix(strings.ToLower(key), "utm_") {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// canonicalURLBackfillBatch is how many posts repairCanonicalURLs reads at once.
+const canonicalURLBackfillBatch = 500
+
+// repairCanonicalURLs gives the posts listed by migration 052 the canonical url
+// of their url. They were saved before canonicalPostURL and only had the
+// fragment cut off, so they didn't match the same posts saved since.
+func repairCanonicalURLs(ctx context.Context, db *database.Queries) {
+	var repaired int
+	for {
+		posts, err := db.GetCanonicalURLBackfill(ctx, canonicalURLBackfillBatch)
+		if err != nil {
+			fetcherLog.Error("Error getting posts to repair canonical urls of", "err", err)
+			return
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		ids := make([]uuid.UUID, 0, len(posts))
+		for _, post := range posts {
+			canonical := canonicalPostURL(post.Url)
+			if canonical != post.CanonicalUrl {
+				err := db.SetPostCanonicalURL(ctx, database.SetPostCanonicalURLParams{ID: post.ID, CanonicalUrl: canonical})
+				if err != nil {
+					fetcherLog.Error("Error repairing canonical url", "post_id", post.ID, "err", err)
+					return
+				}
+				repaired++
+			}
+			ids = append(ids, post.ID)
+		}
+
+		if err := db.DeleteCanonicalURLBackfill(ctx, ids); err != nil {
+			fetcherLog.Error("Error repairing canonical urls", "err", err)
+			return
+		}
+	}
+
+	if repaired > 0 {
+		fetcherLog.Info("Repaired canonical urls of posts", "posts", repaired)
+	}
+}
+
+// resolvedFeed is the feed behind a url, stored already or to be created.
+type resolvedFeed struct {
+	userID uuid.UUID
+	feed   database.Feed
+	stored bool
+	params database.CreateFeedParams
+}
+
+// sharedFeed checks that the user can follow the stored feed, which they
+// can't when it's private to another user, see GetVisibleFeedByID.
+func sharedFeed(ctx context.Context, db *database.Queries, userID uuid.UUID, feed database.Feed) error {
+	if feed.UserID == userID {
+		return nil
+	}
+	_, err := db.GetVisibleFeedByID(ctx, database.GetVisibleFeedByIDParams{ID: feed.ID, UserID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errFeedNotShared
+	}
+	return err
+}
+
+// resolveFeed finds the feed stored under the url, or checks that the url (or
+// a feed it links to) really is a feed to create. A stored feed another user
+// added with credentials is errFeedNotShared. creds are used for the check
+// when the feed is private. With a scraper the url is a page instead, which
+// the scraper has to find items on. Pages of YouTube channels and subreddits
+// are stored as their feed, feeds of sources are checked through their api.
+//
+// It goes out to the network, so it runs before the transaction that creates
+// the feed, see inFeedTx.
+func resolveFeed(ctx context.Context, db *database.Queries, userID uuid.UUID, name, rawURL string, creds *feedCredentials, scraper *feedScraper) (resolvedFeed, error) {
+	feedURL, err := normalizeFeedURL(rawURL)
+	if err != nil {
+		return resolvedFeed{}, errInvalidFeedURL
+	}
+	if translated, ok := translateFeedURL(feedURL); ok && scraper == nil {
+		feedURL = translated
+	}
+
+	feed, err := db.GetFeedByUrl(ctx, feedURL)
+	if err == nil {
+		return resolvedFeed{userID: userID, feed: feed, stored: true}, sharedFeed(ctx, db, userID, feed)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return resolvedFeed{}, err
+	}
+
+	err = checkPublicURL(ctx, feedURL)
+	if errors.Is(err, errPrivateFeedURL) {
+		return resolvedFeed{}, err
+	}
+
+	var discoveredURL string
+	var parsed *gofeed.Feed
+	if scraper != nil {
+		discoveredURL = feedURL
+		parsed, err = scrapeURL(ctx, feedURL, creds, scraper)
+		if errors.Is(err, errNoScrapedItems) {
+			return resolvedFeed{}, err
+		}
+	} else if fetchSource := sourceFetcher(feedURL); fetchSource != nil {
+		discoveredURL = feedURL
+		parsed, err = fetchSource(ctx, feedURL)
+	} else {
+		discoveredURL, parsed, err = discoverFeed(ctx, feedURL, creds)
+	}
+	if err != nil {
+		fetcherLog.Error("Error verifying feed", "feed_url", feedURL, "err", err)
+		return resolvedFeed{}, errNotAFeed
+	}
+
+	if discoveredURL != feedURL {
+		feed, err = db.GetFeedByUrl(ctx, discoveredURL)
+		if err == nil {
+			return resolvedFeed{userID: userID, feed: feed, stored: true}, sharedFeed(ctx, db, userID, feed)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return resolvedFeed{}, err
+		}
+	}
+
+	if name == "" {
+		name = parsed.Title
+	}
+	if name == "" {
+		name = discoveredURL
+	}
+
+	return resolvedFeed{userID: userID, params: database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		Name:      name,
+		Url:       discoveredURL,
+		UserID:    userID,
+	}}, nil
+}
+
+// inFeedTx runs fn in a transaction with the resolved feed, which is created
+// in it unless it is stored. When another request stored the same feed since
+// it was resolved, the insert fails on the unique url, and the transaction is
+// run once more with the feed that request stored.
+func inFeedTx(ctx context.Context, db *database.Queries, resolved resolvedFeed, fn func(q *database.Queries, feed database.Feed) error) error {
+	run := func(q *database.Queries) error {
+		feed := resolved.feed
+		if !resolved.stored {
+			var err error
+			feed, err = q.GetFeedByUrl(ctx, resolved.params.Url)
+			if err == nil {
+				err = sharedFeed(ctx, q, resolved.userID, feed)
+			} else if errors.Is(err, sql.ErrNoRows) {
+				feed, err = q.CreateFeed(ctx, resolved.params)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return fn(q, feed)
+	}
+
+	err := database.InTx(ctx, db, run)
+	if isUniqueViolation(err, "feeds_url_key") {
+		err = database.InTx(ctx, db, run)
+	}
+	return err
+}
+
+// discoverFeed fetches the url and parses it as a feed. When the url points
+// to an html page instead, the feeds it advertises via <link rel="alternate">
+// are tried in order. creds are only sent to the host of the url.
+func discoverFeed(ctx context.Context, feedURL string, creds *feedCredentials) (string, *gofeed.Feed, error) {
+	body, contentType, err := fetchForDiscovery(ctx, feedURL, creds)
+	if err != nil {
+		return "", nil, err
+	}
+
+	feed, parseErr := gofeed.NewParser().Parse(bytes.NewReader(feedBodyToUTF8(body, contentType)))
+	if parseErr == nil {
+		return feedURL, feed, nil
+	}
+	if !strings.Contains(contentType, "html") {
+		return "", nil, parseErr
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var candidates []string
+	doc.Find(`link[rel="alternate"]`).Each(func(_ int, link *goquery.Selection) {
+		linkType, _ := link.Attr("type")
+		href, _ := link.Attr("href")
+		if href == "" || !isFeedContentType(linkType) {
+			return
+		}
+
+		ref, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+
+		candidate, err := normalizeFeedURL(base.ResolveReference(ref).String())
+		if err == nil {
+			candidates = append(candidates, candidate)
+		}
+	})
+
+	for _, candidate := range candidates {
+		candidateCreds := creds
+		if candidateURL, err := url.Parse(candidate); err != nil || !strings.EqualFold(candidateURL.Host, base.Host) {
+			candidateCreds = nil
+		}
+
+		body, contentType, err := fetchForDiscovery(ctx, candidate, candidateCreds)
+		if err != nil {
+			continue
+		}
+
+		feed, err := gofeed.NewParser().Parse(bytes.NewReader(feedBodyToUTF8(body, contentType)))
+		if err == nil {
+			return candidate, feed, nil
+		}
+	}
+
+	return "", nil, parseErr
+}
+
+func isFeedContentType(contentType string) bool {
+	switch strings.ToLower(contentType) {
+	case "application/rss+xml", "application/atom+xml", "application/feed+json", "application/json":
+		return true
+	}
+	return false
+}
+
+func fetchForDiscovery(ctx context.Context, feedURL string, creds *feedCredentials) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, feedVerifyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req = creds.apply(req)
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, feedVerifyMaxBody))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, resp.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// fetchAttempt is what the fetch log keeps about a poll besides its outcome.
+type fetchAttempt struct {
+	StartedAt time.Time
+	// StatusCode is 0 when no response came back.
+	StatusCode int
+	ItemsFound int
+}
+
+// recordFetchLog adds the attempt to the fetch log of the feed.
+func (f *fetcher) recordFetchLog(feed database.Feed, attempt fetchAttempt, outcome fetchOutcome, fetchErr error) {
+	var statusErr *feedStatusError
+	if errors.As(fetchErr, &statusErr) {
+		attempt.StatusCode = statusErr.StatusCode
+	}
+
+	params := database.CreateFetchLogParams{
+		ID:            uuid.New(),
+		FeedID:        feed.ID,
+		StartedAt:     attempt.StartedAt,
+		DurationMs:    int32(time.Since(attempt.StartedAt).Milliseconds()),
+		StatusCode:    sql.NullInt32{Int32: int32(attempt.StatusCode), Valid: attempt.StatusCode != 0},
+		ItemsFound:    int32(attempt.ItemsFound),
+		ItemsInserted: int32(outcome.NewPosts),
+	}
+	if fetchErr != nil {
+		params.Error = sql.NullString{String: fetchErr.Error(), Valid: true}
+	}
+
+	// the job context may be what failed, so don't reuse it for bookkeeping
+	err := f.apiConfig.DB.CreateFetchLog(context.Background(), params)
+	if err != nil {
+		fetcherLog.Error("Error recording fetch", "feed_url", feed.Url, "err", err)
+	}
+}
+
+// pruneFetchLogs deletes the attempts older than the retention of the log.
+func pruneFetchLogs(ctx context.Context, q *database.Queries, retention time.Duration) (int64, error) {
+	if retention == 0 {
+		return 0, nil
+	}
+	return q.DeleteFetchLogsBefore(ctx, time.Now().Add(-retention))
+}
+
+type fetchLogResponse struct {
+	ID            uuid.UUID `json:"id"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    int32     `json:"duration_ms"`
+	StatusCode    *int32    `json:"status_code"`
+	ItemsFound    int32     `json:"items_found"`
+	ItemsInserted int32     `json:"items_inserted"`
+	Error         *string   `json:"error"`
+}
+
+func newFetchLogResponse(entry database.FetchLog) fetchLogResponse {
+	resp := fetchLogResponse{
+		ID:            entry.ID,
+		StartedAt:     entry.StartedAt,
+		DurationMs:    entry.DurationMs,
+		ItemsFound:    entry.ItemsFound,
+		ItemsInserted: entry.ItemsInserted,
+	}
+	if entry.StatusCode.Valid {
+		resp.StatusCode = &entry.StatusCode.Int32
+	}
+	if entry.Error.Valid {
+		resp.Error = &entry.Error.String
+	}
+	return resp
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/fetch_log
+
+# This is an authenticated endpoint
+
+Lists the recent fetches of the feed, newest first and paginated: when each
+started, how long it took, the HTTP status (null when the server couldn't be
+reached), how many items the feed had and how many of them were new, and the
+error if the fetch failed. A 304 has items_found 0. Entries are kept for
+FETCH_LOG_RETENTION. Only the owner and the followers of the feed can see them.
+*/
+func getFetchLogHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feed, ok := getFollowedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		context := r.Context()
+		entries, err := apiConfig.DB.GetFetchLogsPage(context, database.GetFetchLogsPageParams{
+			FeedID:     feed.ID,
+			BeforeTime: page.BeforeTime(),
+			BeforeID:   page.BeforeID(),
+			Limit:      page.QueryLimit(),
+		})
+		if err != nil {
+			httpLog.Error("Error getting fetch log", "err", err)
+			respondWithError(w, 500, "Error getting fetch log")
+			return
+		}
+		entries = finishPage(w, r, page, entries, func(entry database.FetchLog) pageCursor {
+			return pageCursor{Time: entry.StartedAt, ID: entry.ID}
+		})
+
+		resp := []fetchLogResponse{}
+		for _, entry := range entries {
+			resp = append(resp, newFetchLogResponse(entry))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
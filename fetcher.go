@@ -0,0 +1,800 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+const (
+	fetchBackoffBase = time.Minute
+	fetchBackoffMax  = 24 * time.Hour
+)
+
+// deadFeedPolicy decides when a feed that keeps failing with errors that
+// look permanent (404, 410, unknown host) gets disabled.
+type deadFeedPolicy struct {
+	Failures int32
+	Window   time.Duration
+}
+
+// fetcher polls feeds with a fixed number of workers reading from a channel,
+// so a long feed list can't open an unbounded number of connections.
+type fetcher struct {
+	apiConfig apiConfig
+	config    fetcherConfig
+	jobs      chan database.Feed
+	hosts     *hostLimiter
+	health    *healthcheck
+
+	// contentSlots bounds the feeds whose posts are being extracted, see
+	// queuePostContents.
+	contentSlots chan struct{}
+
+	// jobsCtx is the parent of every fetch, cancelled when Shutdown gives up
+	// waiting for the workers.
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	workers    sync.WaitGroup
+
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]bool
+}
+
+func newFetcher(apiConfig apiConfig, config fetcherConfig) *fetcher {
+	f := &fetcher{
+		apiConfig: apiConfig,
+		config:    config,
+		jobs:      make(chan database.Feed),
+		hosts:     newHostLimiter(config.HostInterval),
+		health:    newHealthcheck(config.HealthcheckURL),
+		inFlight:  map[uuid.UUID]bool{},
+
+		contentSlots: make(chan struct{}, config.Workers),
+	}
+	f.jobsCtx, f.cancelJobs = context.WithCancel(context.Background())
+
+	for i := 0; i < config.Workers; i++ {
+		f.workers.Add(1)
+		go f.work()
+	}
+
+	return f
+}
+
+// run polls due feeds every config.Interval until ctx is done.
+func (f *fetcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.config.Interval):
+		}
+
+		err := f.getUnprocessedFeedsAndProcessThem(ctx)
+		f.health.Report(ctx, err)
+		f.publishScheduledItems(ctx)
+	}
+}
+
+// Shutdown stops the workers once the fetches they are running are done. If
+// ctx ends first, the remaining fetches are cancelled. run must have returned
+// before Shutdown is called.
+func (f *fetcher) Shutdown(ctx context.Context) error {
+	close(f.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		f.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		f.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		f.cancelJobs()
+		return ctx.Err()
+	}
+}
+
+func (f *fetcher) work() {
+	defer f.workers.Done()
+
+	for feed := range f.jobs {
+		ctx, cancel := context.WithTimeout(f.jobsCtx, f.config.Timeout)
+		f.processFeed(ctx, feed)
+		cancel()
+
+		f.mu.Lock()
+		delete(f.inFlight, feed.ID)
+		f.mu.Unlock()
+	}
+}
+
+// enqueue hands the feed to the workers unless it is already being fetched.
+// It blocks while all workers are busy, or until ctx is done.
+func (f *fetcher) enqueue(ctx context.Context, feed database.Feed) {
+	f.mu.Lock()
+	if f.inFlight[feed.ID] {
+		f.mu.Unlock()
+		return
+	}
+	f.inFlight[feed.ID] = true
+	f.mu.Unlock()
+
+	select {
+	case f.jobs <- feed:
+	case <-ctx.Done():
+		f.mu.Lock()
+		delete(f.inFlight, feed.ID)
+		f.mu.Unlock()
+	}
+}
+
+func (f *fetcher) getUnprocessedFeedsAndProcessThem(ctx context.Context) error {
+	feeds, err := f.apiConfig.DB.GetNextFeedsToFetch(ctx, database.GetNextFeedsToFetchParams{
+		LastFetchedAt: sql.NullTime{Time: time.Now().Add(-f.config.MinRefresh), Valid: true},
+		Limit:         f.config.BatchSize,
+	})
+	if err != nil {
+		fetcherLog.Error("Error getting feeds", "err", err)
+		return err
+	}
+
+	for _, feed := range feeds {
+		f.enqueue(ctx, feed)
+	}
+
+	return nil
+}
+
+// fetchOutcome summarizes a successful poll of a feed.
+type fetchOutcome struct {
+	NotModified bool `json:"not_modified"`
+	NewPosts    int  `json:"new_posts"`
+}
+
+func (f *fetcher) processFeed(ctx context.Context, feed database.Feed) (outcome fetchOutcome, err error) {
+	err = f.hosts.Wait(ctx, feed.Url)
+	if err != nil {
+		fetcherLog.Warn("Skipping feed, host is busy", "feed_url", feed.Url, "err", err)
+		return fetchOutcome{}, err
+	}
+
+	attempt := fetchAttempt{StartedAt: time.Now()}
+	defer func() {
+		f.recordFetchLog(feed, attempt, outcome, err)
+	}()
+
+	creds, err := loadFeedCredentials(ctx, f.apiConfig, feed.ID)
+	if err != nil {
+		fetcherLog.Error("Error loading credentials", "feed_url", feed.Url, "err", err)
+		f.recordFailure(feed, err)
+		return fetchOutcome{}, err
+	}
+
+	scraper, err := loadFeedScraper(ctx, f.apiConfig.DB, feed.ID)
+	if err != nil {
+		fetcherLog.Error("Error loading scraper", "feed_url", feed.Url, "err", err)
+		f.recordFailure(feed, err)
+		return fetchOutcome{}, err
+	}
+
+	result, err := getAndParseRssFeed(ctx, feed, creds, scraper)
+	if f.config.Snapshots > 0 && result.Body != nil {
+		err := saveFeedSnapshot(ctx, f.apiConfig, feed, result, f.config.Snapshots)
+		if err != nil {
+			fetcherLog.Error("Error saving snapshot", "feed_url", feed.Url, "err", err)
+		}
+	}
+	if err != nil {
+		fetcherLog.Error("Error parsing feed", "err", err)
+		f.recordFailure(feed, err)
+		return fetchOutcome{}, err
+	}
+	attempt.StatusCode = result.StatusCode
+	if result.Feed != nil {
+		attempt.ItemsFound = len(result.Feed.Items)
+	}
+
+	err = f.apiConfig.DB.RecordFeedTransfer(ctx, database.RecordFeedTransferParams{
+		FeedID:              feed.ID,
+		UpdatedAt:           time.Now(),
+		LastTransferBytes:   int64(result.TransferBytes),
+		LastBodyBytes:       int64(result.BodyBytes),
+		LastContentEncoding: result.ContentEncoding,
+	})
+	if err != nil {
+		fetcherLog.Error("Error recording transfer", "feed_url", feed.Url, "err", err)
+	}
+
+	// a 304 tells nothing new about the feed, so keep the interval learned last time
+	outcome = fetchOutcome{NotModified: result.Feed == nil}
+	interval := refreshIntervalDefault
+	if feed.RefreshIntervalSeconds.Valid {
+		interval = time.Duration(feed.RefreshIntervalSeconds.Int32) * time.Second
+	}
+	if result.Feed != nil {
+		posts, err := saveRssPosts(ctx, f.apiConfig, feed, result.Feed)
+		if err != nil {
+			fetcherLog.Error("Error saving posts", "feed_url", feed.Url, "err", err)
+		}
+		outcome.NewPosts = len(posts)
+		if f.config.FullContent && len(posts) > 0 {
+			f.queuePostContents(posts)
+		}
+		interval = refreshInterval(result.Feed)
+	}
+	interval = max(interval, f.config.MinRefresh)
+
+	err = f.apiConfig.DB.MarkFeedAsFetched(ctx, database.MarkFeedAsFetchedParams{
+		Url:                    feed.Url,
+		Etag:                   sql.NullString{String: result.ETag, Valid: result.ETag != ""},
+		LastModified:           sql.NullString{String: result.LastModified, Valid: result.LastModified != ""},
+		NextFetchAt:            sql.NullTime{Time: time.Now().Add(interval), Valid: true},
+		RefreshIntervalSeconds: sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true},
+	})
+	if err != nil {
+		fetcherLog.Error("Error marking feed as fetched", "err", err)
+		return outcome, err
+	}
+
+	if result.MovedTo != "" {
+		f.moveFeed(ctx, feed, result.MovedTo)
+	}
+
+	return outcome, nil
+}
+
+var errFetchInFlight = errors.New("Feed is already being fetched")
+
+// fetchNow polls the feed in the calling goroutine instead of waiting for the
+// scheduler, unless a worker is fetching it at the moment.
+func (f *fetcher) fetchNow(ctx context.Context, feed database.Feed) (fetchOutcome, error) {
+	f.mu.Lock()
+	if f.inFlight[feed.ID] {
+		f.mu.Unlock()
+		return fetchOutcome{}, errFetchInFlight
+	}
+	f.inFlight[feed.ID] = true
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.inFlight, feed.ID)
+		f.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	return f.processFeed(ctx, feed)
+}
+
+// recordFailure bumps the failure counter of the feed and pushes its next
+// fetch back exponentially, so broken feeds stop being retried every minute.
+func (f *fetcher) recordFailure(feed database.Feed, fetchErr error) {
+	failures := feed.ConsecutiveFailures + 1
+	nextFetch := time.Now().Add(fetchBackoff(failures))
+
+	// the job context may be what failed, so don't reuse it for bookkeeping
+	ctx := context.Background()
+	err := f.apiConfig.DB.MarkFeedFetchFailed(ctx, database.MarkFeedFetchFailedParams{
+		ID:          feed.ID,
+		LastError:   sql.NullString{String: fetchErr.Error(), Valid: true},
+		NextFetchAt: sql.NullTime{Time: nextFetch, Valid: true},
+	})
+	if err != nil {
+		fetcherLog.Error("Error recording feed failure", "err", err)
+		return
+	}
+
+	failingSince := time.Now()
+	if feed.FailingSince.Valid {
+		failingSince = feed.FailingSince.Time
+	}
+
+	if failures == 1 {
+		dispatchFeedEvent(ctx, f.apiConfig, feed.ID, eventFeedFailing, struct {
+			Feed  database.Feed
+			Error string
+		}{feed, fetchErr.Error()})
+	}
+
+	if isDeadFeedError(fetchErr) && failures >= f.config.Dead.Failures && time.Since(failingSince) >= f.config.Dead.Window {
+		f.disable(ctx, feed, fetchErr)
+	}
+}
+
+func (f *fetcher) disable(ctx context.Context, feed database.Feed, fetchErr error) {
+	err := f.apiConfig.DB.DisableFeed(ctx, database.DisableFeedParams{
+		ID:             feed.ID,
+		DisabledReason: sql.NullString{String: fetchErr.Error(), Valid: true},
+	})
+	if err != nil {
+		fetcherLog.Error("Error disabling feed", "err", err)
+		return
+	}
+	fetcherLog.Warn("Disabled feed", "feed_url", feed.Url, "err", fetchErr)
+
+	feed, err = f.apiConfig.DB.GetFeedByID(ctx, feed.ID)
+	if err != nil {
+		fetcherLog.Error("Error getting feed", "err", err)
+		return
+	}
+	dispatchFeedEvent(ctx, f.apiConfig, feed.ID, eventFeedDead, feed)
+}
+
+// feedStatusError is returned when a feed answers with a non 2xx status.
+type feedStatusError struct {
+	StatusCode int
+}
+
+func (e *feedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.StatusCode)
+}
+
+// isDeadFeedError reports whether err suggests the feed is gone for good
+// rather than temporarily unavailable.
+func isDeadFeedError(err error) bool {
+	var statusErr *feedStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+
+	return false
+}
+
+func fetchBackoff(failures int32) time.Duration {
+	backoff := fetchBackoffBase
+	for i := int32(1); i < failures && backoff < fetchBackoffMax; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, fetchBackoffMax)
+}
+
+// feedFetchResult is what a single poll of a feed produced.
+type feedFetchResult struct {
+	// Feed is nil when the server answered 304 Not Modified.
+	Feed         *gofeed.Feed
+	StatusCode   int
+	ETag         string
+	LastModified string
+	// MovedTo is the new url of the feed if it was reached only through
+	// permanent redirects.
+	MovedTo string
+	// TransferBytes is the size of the body as sent, BodyBytes once decoded.
+	TransferBytes   int
+	BodyBytes       int
+	ContentEncoding string
+	// Body is the decoded body and ContentType its type as served, for
+	// snapshots. Body is set even when the feed didn't parse.
+	Body        []byte
+	ContentType string
+}
+
+// getAndParseRssFeed fetches the feed, with creds when it's private, and
+// parses it, or scrapes the page with scraper when it has one. Feeds of
+// sources are read from their api, see sourceFetcher.
+func getAndParseRssFeed(ctx context.Context, feed database.Feed, creds *feedCredentials, scraper *feedScraper) (feedFetchResult, error) {
+	if fetchSource := sourceFetcher(feed.Url); fetchSource != nil && scraper == nil {
+		parsed, err := fetchSource(ctx, feed.Url)
+		if err != nil {
+			return feedFetchResult{}, err
+		}
+		return feedFetchResult{StatusCode: http.StatusOK, Feed: parsed}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.Url, nil)
+	if err != nil {
+		return feedFetchResult{}, err
+	}
+
+	// conditional request, so unchanged feeds cost a 304 instead of a full download
+	if feed.Etag.Valid {
+		req.Header.Set("If-None-Match", feed.Etag.String)
+	}
+	if feed.LastModified.Valid {
+		req.Header.Set("If-Modified-Since", feed.LastModified.String)
+	}
+	req.Header.Set("Accept-Encoding", feedAcceptEncoding)
+	req = creds.apply(req)
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return feedFetchResult{}, err
+	}
+	defer resp.Body.Close()
+
+	result := feedFetchResult{
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		MovedTo:      permanentRedirect(resp),
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		if result.ETag == "" {
+			result.ETag = feed.Etag.String
+		}
+		if result.LastModified == "" {
+			result.LastModified = feed.LastModified.String
+		}
+		return result, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return feedFetchResult{}, &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	raw, err := readFeedBody(resp.Body, feedMaxBody)
+	if err != nil {
+		return feedFetchResult{}, err
+	}
+	body, err := decodeFeedBody(raw, resp.Header.Get("Content-Encoding"), feedMaxBody)
+	if err != nil {
+		return feedFetchResult{}, err
+	}
+	result.TransferBytes = len(raw)
+	result.BodyBytes = len(body)
+	result.ContentEncoding = resp.Header.Get("Content-Encoding")
+	result.Body = body
+	result.ContentType = resp.Header.Get("Content-Type")
+
+	if scraper != nil {
+		result.Feed, err = scraper.scrape(body, result.ContentType, feed.Url)
+	} else {
+		result.Feed, err = parseFeedBody(body, result.ContentType)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// parseFeedBody parses a decoded feed body in the charset it declares.
+func parseFeedBody(body []byte, contentType string) (*gofeed.Feed, error) {
+	body = feedBodyToUTF8(body, contentType)
+	return newFeedParser().Parse(bytes.NewReader(body))
+}
+
+// saveRssPosts stores the items of the feed and returns the new posts. Items
+// already stored are updated in place. Items that can't be saved don't stop
+// the rest of the feed, their errors are returned together.
+func saveRssPosts(ctx context.Context, apiConfig apiConfig, feed database.Feed, feedContent *gofeed.Feed) ([]database.Post, error) {
+	saved, err := storeRssPosts(ctx, apiConfig.DB, feed, feedContent)
+	announcePosts(ctx, apiConfig, feed, saved)
+	return saved, err
+}
+
+// storeRssPosts is saveRssPosts without telling anyone about the new posts,
+// for callers that store them in a transaction and announce them once it
+// commits, see announcePosts.
+func storeRssPosts(ctx context.Context, db *database.Queries, feed database.Feed, feedContent *gofeed.Feed) ([]database.Post, error) {
+	fetchedAt := time.Now()
+	var saved []database.Post
+	var errs []error
+	for _, item := range feedContent.Items {
+		fetcherLog.Debug("Saving item", "feed_url", feed.Url, "title", item.Title)
+
+		guid := itemGUID(item)
+		postParams := database.UpsertPostParams{
+			ID:           uuid.New(),
+			CreatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+			Title:        item.Title,
+			Url:          item.Link,
+			Description:  itemDescription(item),
+			PublishedAt:  sql.NullTime{Time: itemPublishedAt(item, fetchedAt), Valid: true},
+			FeedID:       feed.ID,
+			Guid:         guid,
+			CanonicalUrl: canonicalPostURL(item.Link),
+			Content:      item.Content,
+			Author:       itemAuthor(item),
+			Categories:   itemCategories(item),
+			Enclosures:   itemEnclosures(item),
+			Duration:     itemDuration(item),
+			Episode:      itemEpisode(item),
+			ThumbnailUrl: itemThumbnail(item),
+		}
+
+		row, err := db.UpsertPost(ctx, postParams)
+		if errors.Is(err, sql.ErrNoRows) {
+			// already stored and unchanged
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("saving post %s: %w", guid, err))
+			continue
+		}
+		if !row.Inserted {
+			continue
+		}
+
+		post := database.Post{
+			ID:               row.ID,
+			CreatedAt:        row.CreatedAt,
+			UpdatedAt:        row.UpdatedAt,
+			Title:            row.Title,
+			Url:              row.Url,
+			Description:      row.Description,
+			PublishedAt:      row.PublishedAt,
+			FeedID:           row.FeedID,
+			Guid:             row.Guid,
+			CanonicalUrl:     row.CanonicalUrl,
+			Content:          row.Content,
+			Author:           row.Author,
+			Categories:       row.Categories,
+			Enclosures:       row.Enclosures,
+			ImageUrl:         row.ImageUrl,
+			SiteName:         row.SiteName,
+			PageCanonicalUrl: row.PageCanonicalUrl,
+			Duration:         row.Duration,
+			Episode:          row.Episode,
+			ThumbnailUrl:     row.ThumbnailUrl,
+		}
+		saved = append(saved, post)
+	}
+
+	return saved, errors.Join(errs...)
+}
+
+// announcePosts fires the webhooks and notifications of new posts of the feed.
+func announcePosts(ctx context.Context, apiConfig apiConfig, feed database.Feed, posts []database.Post) {
+	for _, post := range posts {
+		dispatchFeedEvent(ctx, apiConfig, post.FeedID, eventPostCreated, post)
+	}
+	queueChatNotifications(ctx, apiConfig, feed.ID, posts)
+	notifyTelegram(ctx, apiConfig, feed, posts)
+}
+
+// postEnclosure is a file attached to an item, like the audio of a podcast
+// episode. Posts keep them as a json list.
+type postEnclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length int64  `json:"length,omitempty"`
+}
+
+// itemGUID identifies the item within its feed, items without a guid by
+// their link.
+func itemGUID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	return item.Link
+}
+
+func itemAuthor(item *gofeed.Item) string {
+	var names []string
+	for _, author := range item.Authors {
+		if author == nil {
+			continue
+		}
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		} else if email := strings.TrimSpace(author.Email); email != "" {
+			names = append(names, email)
+		}
+	}
+	if len(names) == 0 && item.Author != nil {
+		names = append(names, strings.TrimSpace(item.Author.Name))
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func itemCategories(item *gofeed.Item) []string {
+	categories := []string{}
+	for _, category := range item.Categories {
+		category = strings.TrimSpace(category)
+		if category != "" && !slices.Contains(categories, category) {
+			categories = append(categories, category)
+		}
+	}
+
+	return categories
+}
+
+func itemEnclosures(item *gofeed.Item) json.RawMessage {
+	enclosures := []postEnclosure{}
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+		enclosures = append(enclosures, postEnclosure{URL: enclosure.URL, Type: enclosure.Type, Length: length})
+	}
+
+	data, err := json.Marshal(enclosures)
+	if err != nil {
+		return json.RawMessage("[]")
+	}
+
+	return data
+}
+
+// itemDescription is the description of the item, or its media:description
+// when it has none, as YouTube does.
+func itemDescription(item *gofeed.Item) string {
+	if item.Description != "" {
+		return item.Description
+	}
+	for _, description := range mediaElements(item.Extensions["media"], "description") {
+		if value := strings.TrimSpace(description.Value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// itemDuration reads the itunes:duration of a podcast episode in seconds. It
+// is given either as seconds or as [[HH:]MM:]SS. Other items can have one as
+// the duration of their media:content, in seconds.
+func itemDuration(item *gofeed.Item) sql.NullInt32 {
+	if item.ITunesExt == nil {
+		for _, content := range mediaElements(item.Extensions["media"], "content") {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(content.Attrs["duration"]), 10, 32)
+			if err == nil && seconds >= 0 {
+				return sql.NullInt32{Int32: int32(seconds), Valid: true}
+			}
+		}
+		return sql.NullInt32{}
+	}
+
+	var seconds int32
+	parts := strings.Split(strings.TrimSpace(item.ITunesExt.Duration), ":")
+	if len(parts) > 3 {
+		return sql.NullInt32{}
+	}
+	for _, part := range parts {
+		// fractions of a second are dropped
+		part, _, _ = strings.Cut(part, ".")
+		n, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || n < 0 {
+			return sql.NullInt32{}
+		}
+		seconds = seconds*60 + int32(n)
+	}
+
+	return sql.NullInt32{Int32: seconds, Valid: true}
+}
+
+// itemEpisode reads the itunes:episode number of a podcast episode.
+func itemEpisode(item *gofeed.Item) sql.NullInt32 {
+	if item.ITunesExt == nil {
+		return sql.NullInt32{}
+	}
+
+	episode, err := strconv.ParseInt(strings.TrimSpace(item.ITunesExt.Episode), 10, 32)
+	if err != nil || episode < 0 {
+		return sql.NullInt32{}
+	}
+
+	return sql.NullInt32{Int32: int32(episode), Valid: true}
+}
+
+// itemThumbnail picks the preview image of an item: a Media RSS thumbnail,
+// then an image in media:content, then what gofeed found (the itunes image,
+// an image enclosure or the first image of the content). Posts without one
+// get the og:image of their page during content extraction.
+func itemThumbnail(item *gofeed.Item) sql.NullString {
+	var candidates []string
+	media := item.Extensions["media"]
+	for _, thumbnail := range mediaElements(media, "thumbnail") {
+		candidates = append(candidates, thumbnail.Attrs["url"])
+	}
+	for _, content := range mediaElements(media, "content") {
+		if content.Attrs["medium"] == "image" || strings.HasPrefix(content.Attrs["type"], "image/") {
+			candidates = append(candidates, content.Attrs["url"])
+		}
+	}
+	if item.Image != nil {
+		candidates = append(candidates, item.Image.URL)
+	}
+
+	base, _ := url.Parse(item.Link)
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		ref, err := url.Parse(candidate)
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			ref = base.ResolveReference(ref)
+		}
+		if ref.Scheme == "http" || ref.Scheme == "https" {
+			return sql.NullString{String: ref.String(), Valid: true}
+		}
+	}
+
+	return sql.NullString{}
+}
+
+// mediaElements returns the Media RSS elements with the given name, both
+// directly on the item and inside its media:group elements.
+func mediaElements(media map[string][]ext.Extension, name string) []ext.Extension {
+	elements := append([]ext.Extension{}, media[name]...)
+	for _, group := range media["group"] {
+		elements = append(elements, group.Children[name]...)
+	}
+
+	return elements
+}
+
+// publishedLayouts are tried in order on dates gofeed couldn't parse itself.
+var publishedLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// itemPublishedAt is when the item was published, or updated if the feed only
+// says that. Items without a usable date count as published when fetched.
+func itemPublishedAt(item *gofeed.Item, fetchedAt time.Time) time.Time {
+	if published, ok := parseItemDate(item); ok {
+		return published
+	}
+
+	return fetchedAt
+}
+
+func parseItemDate(item *gofeed.Item) (time.Time, bool) {
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed, true
+	}
+	if item.UpdatedParsed != nil {
+		return *item.UpdatedParsed, true
+	}
+
+	for _, value := range []string{item.Published, item.Updated} {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		for _, layout := range publishedLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+		fetcherLog.Debug("Unknown date format", "value", value, "item_url", item.Link)
+	}
+
+	return time.Time{}, false
+}
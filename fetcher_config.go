@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// fetcherConfig holds the knobs of the feed scheduler and its workers.
+type fetcherConfig struct {
+	// Interval is how long the scheduler sleeps between runs.
+	Interval time.Duration
+	// BatchSize is how many due feeds a single run picks up.
+	BatchSize int32
+	// MinRefresh is the minimum time between two fetches of the same feed.
+	MinRefresh   time.Duration
+	Workers      int
+	Timeout      time.Duration
+	HostInterval time.Duration
+	Dead         deadFeedPolicy
+	// HealthcheckURL is pinged after every scheduler run, if set.
+	HealthcheckURL string
+	// FullContent makes the fetcher extract the article of every new post.
+	FullContent bool
+	// LogRetention is how long fetch log entries are kept, 0 keeps them
+	// forever.
+	LogRetention time.Duration
+	// Snapshots is how many raw payloads are kept per feed, 0 keeps none.
+	Snapshots int
+}
+
+// fetcherConfigFromEnv reads the fetcher settings, falling back to defaults
+// for unset variables and failing on values that don't make sense.
+func fetcherConfigFromEnv() (fetcherConfig, error) {
+	cfg := fetcherConfig{
+		Interval:     60 * time.Second,
+		BatchSize:    10,
+		MinRefresh:   0,
+		Workers:      5,
+		Timeout:      30 * time.Second,
+		HostInterval: 2 * time.Second,
+		Dead:         deadFeedPolicy{Failures: 10, Window: 72 * time.Hour},
+		LogRetention: 7 * 24 * time.Hour,
+	}
+
+	var err error
+	if cfg.Interval, err = envDuration("FETCH_INTERVAL", cfg.Interval, time.Second); err != nil {
+		return fetcherConfig{}, err
+	}
+	batchSize, err := envInt("FETCH_BATCH_SIZE", int(cfg.BatchSize), 1)
+	if err != nil {
+		return fetcherConfig{}, err
+	}
+	cfg.BatchSize = int32(batchSize)
+	if cfg.MinRefresh, err = envDuration("FEED_MIN_REFRESH_INTERVAL", cfg.MinRefresh, 0); err != nil {
+		return fetcherConfig{}, err
+	}
+	if cfg.Workers, err = envInt("FETCH_WORKERS", cfg.Workers, 1); err != nil {
+		return fetcherConfig{}, err
+	}
+	if cfg.Timeout, err = envDuration("FETCH_TIMEOUT", cfg.Timeout, time.Nanosecond); err != nil {
+		return fetcherConfig{}, err
+	}
+	if cfg.HostInterval, err = envDuration("FETCH_HOST_MIN_INTERVAL", cfg.HostInterval, 0); err != nil {
+		return fetcherConfig{}, err
+	}
+	failures, err := envInt("FEED_DISABLE_AFTER_FAILURES", int(cfg.Dead.Failures), 1)
+	if err != nil {
+		return fetcherConfig{}, err
+	}
+	cfg.Dead.Failures = int32(failures)
+	if cfg.Dead.Window, err = envDuration("FEED_DISABLE_WINDOW", cfg.Dead.Window, 0); err != nil {
+		return fetcherConfig{}, err
+	}
+	if cfg.LogRetention, err = envDuration("FETCH_LOG_RETENTION", cfg.LogRetention, 0); err != nil {
+		return fetcherConfig{}, err
+	}
+	if cfg.Snapshots, err = envInt("FEED_SNAPSHOTS", cfg.Snapshots, 0); err != nil {
+		return fetcherConfig{}, err
+	}
+
+	cfg.HealthcheckURL = os.Getenv("HEALTHCHECK_FETCH_URL")
+	cfg.FullContent = os.Getenv("FETCH_FULL_CONTENT") == "true"
+
+	return cfg, nil
+}
+
+func envInt(name string, def, min int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("invalid %s: %s (must be an integer >= %d)", name, value, min)
+	}
+
+	return n, nil
+}
+
+func envDuration(name string, def, min time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < min {
+		return 0, fmt.Errorf("invalid %s: %s (must be a duration >= %s)", name, value, min)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	blogatorv1 "github.com/halfdan87/boot-go-blog-aggregator/api/blogator/v1"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+/*
+The gRPC API serves the feed, follow and post operations of api/blogator/v1
+on GRPC_PORT, next to the HTTP API, for other services to call with
+generated clients. It's off unless GRPC_PORT is set.
+
+Every call is authenticated like the HTTP API, with "authorization" metadata
+of "ApiKey <key>" or "Bearer <token>" with an OAuth or access token. Tokens
+and keys with the read scope can call the List and Get methods only, scoped
+keys need feeds:admin for the others.
+*/
+
+type grpcUserKey struct{}
+
+// newGRPCServer registers the services, and reflection for tools like grpcurl.
+func newGRPCServer(apiConfig apiConfig) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(apiConfig.grpcAuthInterceptor))
+	api := &grpcAPI{apiConfig: apiConfig}
+	blogatorv1.RegisterFeedServiceServer(server, api)
+	blogatorv1.RegisterFollowServiceServer(server, api)
+	blogatorv1.RegisterPostServiceServer(server, api)
+	reflection.Register(server)
+
+	return server
+}
+
+// grpcAuthInterceptor authenticates calls and puts the user in the context.
+func (cfg *apiConfig) grpcAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	// reflection is for tooling, it has nothing to protect
+	if strings.HasPrefix(info.FullMethod, "/grpc.reflection.") {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := md.Get("authorization")
+	if len(auth) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	scheme, credential, err := parseAuthorization(auth[0])
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	var user database.User
+	var scopes []string
+	if strings.EqualFold(scheme, "Bearer") {
+		user, scopes, err = cfg.authenticateBearer(ctx, credential)
+	} else {
+		user, scopes, err = cfg.authenticateApiKey(ctx, credential)
+	}
+	if isInvalidCredential(err) {
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	if err != nil {
+		httpLog.Error("Error getting user", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting user")
+	}
+
+	// API keys and access tokens are bound to the allowlist, OAuth tokens
+	// are for other apps
+	if !strings.EqualFold(scheme, "Bearer") || scopes == nil {
+		var remoteAddr string
+		if p, ok := peer.FromContext(ctx); ok {
+			remoteAddr = p.Addr.String()
+		}
+		if !addrAllowedFrom(remoteAddr, user.AllowedCidrs) {
+			return nil, status.Error(codes.PermissionDenied, "Not allowed from this address")
+		}
+	}
+
+	// the methods that change anything change feeds and follows
+	method := http.MethodPost
+	if grpcReadOnly(info.FullMethod) {
+		method = http.MethodGet
+	}
+	if !scopesAllow(scopes, method, scopeFeedsAdmin) {
+		return nil, status.Error(codes.PermissionDenied, "Insufficient scope")
+	}
+
+	return handler(context.WithValue(ctx, grpcUserKey{}, user), req)
+}
+
+// grpcReadOnly tells the methods that only read, like GET requests.
+func grpcReadOnly(fullMethod string) bool {
+	name := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return strings.HasPrefix(name, "List") || strings.HasPrefix(name, "Get")
+}
+
+func grpcUser(ctx context.Context) database.User {
+	user, _ := ctx.Value(grpcUserKey{}).(database.User)
+	return user
+}
+
+type grpcAPI struct {
+	blogatorv1.UnimplementedFeedServiceServer
+	blogatorv1.UnimplementedFollowServiceServer
+	blogatorv1.UnimplementedPostServiceServer
+	apiConfig apiConfig
+}
+
+func (s *grpcAPI) ListFeeds(ctx context.Context, req *blogatorv1.ListFeedsRequest) (*blogatorv1.ListFeedsResponse, error) {
+	feeds, err := s.apiConfig.DB.GetFeeds(ctx)
+	if err != nil {
+		httpLog.Error("Error getting feeds", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	resp := &blogatorv1.ListFeedsResponse{}
+	for _, feed := range feeds {
+		resp.Feeds = append(resp.Feeds, feedProto(feed))
+	}
+	return resp, nil
+}
+
+func (s *grpcAPI) GetFeed(ctx context.Context, req *blogatorv1.GetFeedRequest) (*blogatorv1.Feed, error) {
+	feedID, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid feed id")
+	}
+
+	feed, err := s.apiConfig.DB.GetVisibleFeedByID(ctx, database.GetVisibleFeedByIDParams{
+		ID:     feedID,
+		UserID: grpcUser(ctx).ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Feed not found")
+	}
+	if err != nil {
+		httpLog.Error("Error getting feed", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	return feedProto(feed), nil
+}
+
+func (s *grpcAPI) CreateFeed(ctx context.Context, req *blogatorv1.CreateFeedRequest) (*blogatorv1.CreateFeedResponse, error) {
+	user := grpcUser(ctx)
+
+	resolved, err := resolveFeed(ctx, s.apiConfig.DB, user.ID, req.GetName(), req.GetUrl(), nil, nil)
+	if errors.Is(err, errInvalidFeedURL) || errors.Is(err, errNotAFeed) || errors.Is(err, errPrivateFeedURL) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, errFeedNotShared) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		httpLog.Error("Error creating feed", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	var feed database.Feed
+	var feedFollow database.FeedFollow
+	var created bool
+	err = inFeedTx(ctx, s.apiConfig.DB, resolved, func(q *database.Queries, stored database.Feed) error {
+		feed = stored
+		var err error
+		feedFollow, created, err = followFeed(ctx, q, user.ID, feed.ID)
+		return err
+	})
+	if errors.Is(err, errFeedNotShared) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if err != nil {
+		httpLog.Error("Error creating feed", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	if created {
+		dispatchUserEvent(ctx, s.apiConfig, user.ID, eventFollowCreated, feedFollow)
+	}
+
+	return &blogatorv1.CreateFeedResponse{Feed: feedProto(feed), FeedFollow: feedFollowProto(feedFollow)}, nil
+}
+
+func (s *grpcAPI) ListFollows(ctx context.Context, req *blogatorv1.ListFollowsRequest) (*blogatorv1.ListFollowsResponse, error) {
+	feedFollows, err := s.apiConfig.DB.GetUserFeedFollows(ctx, grpcUser(ctx).ID)
+	if err != nil {
+		httpLog.Error("Error getting feed follows", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feed follows")
+	}
+
+	resp := &blogatorv1.ListFollowsResponse{}
+	for _, feedFollow := range feedFollows {
+		resp.FeedFollows = append(resp.FeedFollows, feedFollowProto(feedFollow))
+	}
+	return resp, nil
+}
+
+func (s *grpcAPI) FollowFeed(ctx context.Context, req *blogatorv1.FollowFeedRequest) (*blogatorv1.FeedFollow, error) {
+	user := grpcUser(ctx)
+	feedID, err := uuid.Parse(req.GetFeedId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid feed id")
+	}
+
+	_, err = s.apiConfig.DB.GetVisibleFeedByID(ctx, database.GetVisibleFeedByIDParams{
+		ID:     feedID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "Feed not found")
+	}
+	if err != nil {
+		httpLog.Error("Error getting feed", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	feedFollow, created, err := followFeed(ctx, s.apiConfig.DB, user.ID, feedID)
+	if err != nil {
+		httpLog.Error("Error creating feed follow", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting feeds")
+	}
+
+	if created {
+		dispatchUserEvent(ctx, s.apiConfig, user.ID, eventFollowCreated, feedFollow)
+	}
+
+	return feedFollowProto(feedFollow), nil
+}
+
+func (s *grpcAPI) UnfollowFeed(ctx context.Context, req *blogatorv1.UnfollowFeedRequest) (*blogatorv1.UnfollowFeedResponse, error) {
+	user := grpcUser(ctx)
+	feedID, err := uuid.Parse(req.GetFeedId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "Invalid feed id")
+	}
+
+	deleted, err := s.apiConfig.DB.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		httpLog.Error("Error deleting feed follow", "err", err)
+		return nil, status.Error(codes.Internal, "Error deleting feed follow")
+	}
+	if deleted == 0 {
+		return nil, status.Error(codes.NotFound, "Feed follow not found")
+	}
+
+	dispatchUserEvent(ctx, s.apiConfig, user.ID, eventFollowDeleted, struct {
+		UserID uuid.UUID `json:"user_id"`
+		FeedID uuid.UUID `json:"feed_id"`
+	}{user.ID, feedID})
+
+	return &blogatorv1.UnfollowFeedResponse{}, nil
+}
+
+func (s *grpcAPI) ListPosts(ctx context.Context, req *blogatorv1.ListPostsRequest) (*blogatorv1.ListPostsResponse, error) {
+	mediaType := req.GetMediaType()
+	if mediaType != "" && mediaType != "audio" && mediaType != "video" {
+		return nil, status.Error(codes.InvalidArgument, "Invalid media type, expected audio or video")
+	}
+
+	page := pageRequest{Limit: pageDefaultLimit}
+	if limit := int(req.GetLimit()); limit != 0 {
+		if limit < 1 || limit > pageMaxLimit {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid limit, expected 1 to %d", pageMaxLimit)
+		}
+		page.Limit = limit
+	}
+	if req.GetCursor() != "" {
+		cursor, err := parsePageCursor(req.GetCursor())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		page.Cursor = &cursor
+	}
+
+	posts, err := s.apiConfig.DB.GetPostsPageByUser(ctx, database.GetPostsPageByUserParams{
+		UserID:     grpcUser(ctx).ID,
+		MediaType:  sql.NullString{String: mediaType, Valid: mediaType != ""},
+		BeforeTime: page.BeforeTime(),
+		BeforeID:   page.BeforeID(),
+		Limit:      page.QueryLimit(),
+	})
+	if err != nil {
+		httpLog.Error("Error getting posts", "err", err)
+		return nil, status.Error(codes.Internal, "Error getting posts")
+	}
+
+	resp := &blogatorv1.ListPostsResponse{}
+	if len(posts) > page.Limit {
+		posts = posts[:page.Limit]
+		last := posts[len(posts)-1]
+		resp.NextCursor = pageCursor{Time: postSortTime(last.PublishedAt), ID: last.ID}.String()
+	}
+	for _, post := range posts {
+		resp.Posts = append(resp.Posts, &blogatorv1.Post{
+			Id:          post.ID.String(),
+			FeedId:      post.FeedID.String(),
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			Content:     post.Content,
+			Author:      post.Author,
+			Categories:  post.Categories,
+			PublishedAt: timestampProto(post.PublishedAt),
+			CreatedAt:   timestampProto(post.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func feedProto(feed database.Feed) *blogatorv1.Feed {
+	return &blogatorv1.Feed{
+		Id:                  feed.ID.String(),
+		CreatedAt:           timestampProto(feed.CreatedAt),
+		UpdatedAt:           timestampProto(feed.UpdatedAt),
+		Name:                feed.Name,
+		Url:                 feed.Url,
+		UserId:              feed.UserID.String(),
+		LastFetchedAt:       timestampProto(feed.LastFetchedAt),
+		ConsecutiveFailures: feed.ConsecutiveFailures,
+		LastError:           feed.LastError.String,
+		DisabledAt:          timestampProto(feed.DisabledAt),
+	}
+}
+
+func feedFollowProto(feedFollow database.FeedFollow) *blogatorv1.FeedFollow {
+	return &blogatorv1.FeedFollow{
+		Id:        feedFollow.ID.String(),
+		CreatedAt: timestampProto(feedFollow.CreatedAt),
+		UserId:    feedFollow.UserID.String(),
+		FeedId:    feedFollow.FeedID.String(),
+	}
+}
+
+// timestampProto leaves null times unset.
+func timestampProto(t sql.NullTime) *timestamppb.Timestamp {
+	if !t.Valid {
+		return nil
+	}
+	return timestamppb.New(t.Time)
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// guestCacheMaxEntries bounds the cache, every page and limit is an entry.
+const guestCacheMaxEntries = 1000
+
+// guestMode serves a public timeline of the feeds an admin picked to visitors
+// without an account. Responses are the same for everyone, so they are cached
+// by the server and may be cached by browsers and proxies for CacheMaxAge.
+type guestMode struct {
+	CacheMaxAge time.Duration
+
+	mu    sync.Mutex
+	cache map[string]guestCacheEntry
+}
+
+type guestCacheEntry struct {
+	body    []byte
+	link    string
+	expires time.Time
+}
+
+// guestModeFromEnv reads GUEST_MODE and GUEST_CACHE_MAX_AGE. Guest mode is
+// off, and nil returned, unless GUEST_MODE is "true".
+func guestModeFromEnv() (*guestMode, error) {
+	if os.Getenv("GUEST_MODE") != "true" {
+		return nil, nil
+	}
+
+	maxAge, err := envDuration("GUEST_CACHE_MAX_AGE", 5*time.Minute, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return &guestMode{CacheMaxAge: maxAge, cache: map[string]guestCacheEntry{}}, nil
+}
+
+func (g *guestMode) lookup(key string) (guestCacheEntry, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	entry, ok := g.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return guestCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (g *guestMode) store(key string, entry guestCacheEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.cache) >= guestCacheMaxEntries {
+		now := time.Now()
+		for k, e := range g.cache {
+			if now.After(e.expires) {
+				delete(g.cache, k)
+			}
+		}
+	}
+	if len(g.cache) >= guestCacheMaxEntries {
+		clear(g.cache)
+	}
+	g.cache[key] = entry
+}
+
+// purge drops every cached response, after the public feeds changed.
+func (g *guestMode) purge() {
+	if g == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	clear(g.cache)
+}
+
+// serve responds with the cached response for the request, or with what load
+// returns, caching it. load may set a Link header on w, it is cached too.
+func (g *guestMode) serve(w http.ResponseWriter, r *http.Request, load func() (interface{}, int, error)) {
+	key := r.URL.RequestURI()
+	entry, ok := g.lookup(key)
+	if !ok {
+		payload, code, err := load()
+		if err != nil {
+			respondWithError(w, code, err.Error())
+			return
+		}
+
+		body, err := json.Marshal(payload)
+		if err != nil {
+			httpLog.Error("Error encoding response", "err", err)
+			respondWithError(w, 500, "Internal Server Error")
+			return
+		}
+
+		entry = guestCacheEntry{
+			body:    body,
+			link:    w.Header().Get("Link"),
+			expires: time.Now().Add(g.CacheMaxAge),
+		}
+		g.store(key, entry)
+	}
+
+	sum := sha256.Sum256(entry.body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	maxAge := int(time.Until(entry.expires).Round(time.Second).Seconds())
+	if entry.link != "" {
+		w.Header().Set("Link", entry.link)
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", maxAge, int(g.CacheMaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(entry.body)
+}
+
+type publicFeedResponse struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Url  string    `json:"url"`
+}
+
+func getPublicFeeds(ctx context.Context, q *database.Queries) ([]publicFeedResponse, error) {
+	feeds, err := q.GetPublicFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := make([]publicFeedResponse, 0, len(feeds))
+	for _, feed := range feeds {
+		resp = append(resp, publicFeedResponse{ID: feed.ID, Name: feed.Name, Url: feed.Url})
+	}
+	return resp, nil
+}
+
+/*
+Endpoint: GET /v1/public/feeds
+
+Lists the feeds of the public timeline. Only exists when GUEST_MODE is on.
+*/
+func getPublicFeedsHandler(apiConfig apiConfig, guest *guestMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if guest == nil {
+			respondWithError(w, 404, "Not found")
+			return
+		}
+
+		guest.serve(w, r, func() (interface{}, int, error) {
+			feeds, err := getPublicFeeds(r.Context(), apiConfig.DB)
+			if err != nil {
+				httpLog.Error("Error getting public feeds", "err", err)
+				return nil, 500, errors.New("Error getting feeds")
+			}
+			return feeds, 200, nil
+		})
+	}
+}
+
+/*
+Endpoint: GET /v1/public/posts?limit=&cursor=
+
+Returns the public timeline, the posts of the feeds an admin made public,
+newest first and paginated like other post lists. Only exists when GUEST_MODE
+is on. Nothing about users is included, not even who added a feed.
+*/
+func getPublicPostsHandler(apiConfig apiConfig, guest *guestMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type PublicFeed struct {
+			ID   uuid.UUID `json:"id"`
+			Name string    `json:"name"`
+		}
+		type PublicPost struct {
+			ID           uuid.UUID  `json:"id"`
+			Title        string     `json:"title"`
+			Url          string     `json:"url"`
+			Description  string     `json:"description"`
+			PublishedAt  *time.Time `json:"published_at"`
+			ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+			Feed         PublicFeed `json:"feed"`
+		}
+
+		if guest == nil {
+			respondWithError(w, 404, "Not found")
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		guest.serve(w, r, func() (interface{}, int, error) {
+			posts, err := apiConfig.DB.GetPublicPostsPage(r.Context(), database.GetPublicPostsPageParams{
+				BeforeTime: page.BeforeTime(),
+				BeforeID:   page.BeforeID(),
+				Limit:      page.QueryLimit(),
+			})
+			if err != nil {
+				httpLog.Error("Error getting public posts", "err", err)
+				return nil, 500, errors.New("Error getting posts")
+			}
+
+			posts = finishPage(w, r, page, posts, func(post database.GetPublicPostsPageRow) pageCursor {
+				return pageCursor{Time: postSortTime(post.PublishedAt), ID: post.ID}
+			})
+
+			resp := make([]PublicPost, 0, len(posts))
+			for _, post := range posts {
+				public := PublicPost{
+					ID:           post.ID,
+					Title:        post.Title,
+					Url:          post.Url,
+					Description:  post.Description,
+					ThumbnailURL: proxiedImageURL(apiConfig, post.ThumbnailUrl.String),
+					Feed:         PublicFeed{ID: post.FeedID, Name: post.FeedName},
+				}
+				if post.PublishedAt.Valid {
+					public.PublishedAt = &post.PublishedAt.Time
+				}
+				resp = append(resp, public)
+			}
+			return resp, 200, nil
+		})
+	}
+}
+
+/*
+Endpoint: PUT /v1/admin/public_feeds/{feed_id}
+
+# This is an admin endpoint
+
+Adds a feed to the public timeline and returns the public feeds. Feeds can be
+picked while guest mode is off, to prepare the timeline.
+*/
+func putPublicFeedHandler(apiConfig apiConfig, guest *guestMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		context := r.Context()
+		// private feeds can't be made public
+		_, err = apiConfig.DB.GetVisibleFeedByID(context, database.GetVisibleFeedByIDParams{ID: feedID})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error adding public feed")
+			return
+		}
+
+		err = apiConfig.DB.AddPublicFeed(context, database.AddPublicFeedParams{
+			FeedID:    feedID,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			httpLog.Error("Error adding public feed", "err", err)
+			respondWithError(w, 500, "Error adding public feed")
+			return
+		}
+		guest.purge()
+
+		feeds, err := getPublicFeeds(context, apiConfig.DB)
+		if err != nil {
+			httpLog.Error("Error getting public feeds", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		respondWithJSON(w, 200, feeds)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/admin/public_feeds/{feed_id}
+
+# This is an admin endpoint
+
+Removes a feed from the public timeline and returns the public feeds left.
+*/
+func deletePublicFeedHandler(apiConfig apiConfig, guest *guestMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		context := r.Context()
+		removed, err := apiConfig.DB.RemovePublicFeed(context, feedID)
+		if err != nil {
+			httpLog.Error("Error removing public feed", "err", err)
+			respondWithError(w, 500, "Error removing public feed")
+			return
+		}
+		if removed == 0 {
+			respondWithError(w, 404, "Feed is not public")
+			return
+		}
+		guest.purge()
+
+		feeds, err := getPublicFeeds(context, apiConfig.DB)
+		if err != nil {
+			httpLog.Error("Error getting public feeds", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		respondWithJSON(w, 200, feeds)
+	}
+}
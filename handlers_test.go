@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// fakeDB is a database/sql driver for handler tests. It answers each query,
+// told apart by its sqlc name, with what the test set up for it, and no rows
+// for the rest.
+type fakeDB struct {
+	mu      sync.Mutex
+	answers map[string]func(args []driver.Value) fakeResult
+	queries []string
+}
+
+// fakeResult is the answer to a query: rows for queries that return some,
+// affected for the others.
+type fakeResult struct {
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// newFakeDB returns the fake and queries that run against it.
+func newFakeDB(t *testing.T) (*fakeDB, *database.Queries) {
+	f := &fakeDB{answers: map[string]func(args []driver.Value) fakeResult{}}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return f, database.New(db)
+}
+
+// on answers the named query with what answer returns for its arguments.
+func (f *fakeDB) on(name string, answer func(args []driver.Value) fakeResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers[name] = answer
+}
+
+// ran tells whether the named query ran.
+func (f *fakeDB) ran(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, query := range f.queries {
+		if query == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) answer(query string, named []driver.NamedValue) fakeResult {
+	name := query
+	// -- name: GetFeedByID :one
+	if fields := strings.Fields(query); len(fields) > 2 && fields[1] == "name:" {
+		name = fields[2]
+	}
+
+	f.mu.Lock()
+	f.queries = append(f.queries, name)
+	answer, ok := f.answers[name]
+	f.mu.Unlock()
+	if !ok {
+		return fakeResult{}
+	}
+
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		args[i] = arg.Value
+	}
+	return answer(args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{f}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn{d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fake database: prepared statements are not supported")
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.db.answer(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{rows: result.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.db.answer(query, args)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// asUser serves the handler as authedHandler does once user is authenticated.
+func asUser(user database.User, handler authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r, user)
+	}
+}
+
+// feedRow is the feed as a row of the feeds table.
+func feedRow(feed database.Feed) []driver.Value {
+	return []driver.Value{
+		feed.ID.String(), nil, nil, feed.Name, feed.Url, feed.UserID.String(),
+		nil, nil, nil, int64(feed.ConsecutiveFailures), nil, nil, nil, nil, nil, nil,
+	}
+}
+
+// postRow is the post as a row of the posts table.
+func postRow(post database.Post) []driver.Value {
+	return []driver.Value{
+		post.ID.String(), nil, nil, post.Title, post.Url, post.Description, nil, post.FeedID.String(),
+		post.Guid, post.CanonicalUrl, post.Content, post.Author, "{}", []byte("[]"), nil, nil, nil, nil, nil, nil,
+	}
+}
+
+// followRows are the follows of the feeds as rows of the feed_follows table.
+func followRows(userID uuid.UUID, feedIDs ...uuid.UUID) [][]driver.Value {
+	var rows [][]driver.Value
+	for _, feedID := range feedIDs {
+		rows = append(rows, []driver.Value{uuid.NewString(), nil, nil, userID.String(), feedID.String()})
+	}
+	return rows
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const healthcheckTimeout = 10 * time.Second
+
+var healthcheckClient = &http.Client{Timeout: healthcheckTimeout}
+
+// healthcheck pings a dead man's switch (healthchecks.io and compatible
+// services) after background work ran, so operators hear about it when the
+// pings stop. Failures are reported on the same URL with "/fail" appended.
+// A nil healthcheck does nothing.
+type healthcheck struct {
+	url string
+}
+
+func newHealthcheck(url string) *healthcheck {
+	if url == "" {
+		return nil
+	}
+	return &healthcheck{url: strings.TrimSuffix(url, "/")}
+}
+
+// Report pings the success URL when err is nil and the failure URL otherwise.
+func (h *healthcheck) Report(ctx context.Context, err error) {
+	if h == nil {
+		return
+	}
+
+	url := h.url
+	body := ""
+	if err != nil {
+		url += "/fail"
+		body = err.Error()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthcheckTimeout)
+	defer cancel()
+
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+	if reqErr != nil {
+		fetcherLog.Error("Error creating healthcheck ping", "err", reqErr)
+		return
+	}
+
+	resp, reqErr := healthcheckClient.Do(req)
+	if reqErr != nil {
+		fetcherLog.Error("Error pinging healthcheck", "err", reqErr)
+		return
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter spaces out requests to the same host, so many feeds living on
+// one domain (medium.com, substack.com, ...) don't hit it all at once.
+type hostLimiter struct {
+	minInterval time.Duration
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newHostLimiter(minInterval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		minInterval: minInterval,
+		limiters:    map[string]*rate.Limiter{},
+	}
+}
+
+// Wait blocks until a request to rawURL's host is allowed or ctx is done.
+func (h *hostLimiter) Wait(ctx context.Context, rawURL string) error {
+	if h.minInterval <= 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	return h.limiter(strings.ToLower(u.Hostname())).Wait(ctx)
+}
+
+func (h *hostLimiter) limiter(host string) *rate.Limiter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	limiter, ok := h.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(h.minInterval), 1)
+		h.limiters[host] = limiter
+	}
+
+	return limiter
+}
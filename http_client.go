@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	feedDialTimeout     = 10 * time.Second
+	feedTLSTimeout      = 10 * time.Second
+	feedResponseTimeout = 15 * time.Second
+	feedMaxRedirects    = 5
+	feedKeepAlive       = 30 * time.Second
+	// feedMaxBody caps how much of a feed is read, a few MB already is a huge feed
+	feedMaxBody = 10 << 20
+	// feedAcceptEncoding is requested explicitly, the transport then leaves
+	// decoding to decodeFeedBody, which copes with mislabeled responses
+	feedAcceptEncoding = "gzip, deflate"
+)
+
+var errFeedTooLarge = fmt.Errorf("feed is larger than %d bytes", feedMaxBody)
+
+// feedClient is used for every request to a feed. Overall deadlines come from
+// the request context, the transport bounds the single phases so a server
+// that accepts the connection and then stalls can't hold a worker.
+//
+// Feeds are fetched through a proxy when one is configured, see
+// feedProxyFor for how destinations are checked then.
+var feedClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 feedProxyFor,
+		DialContext:           dialFeed,
+		TLSHandshakeTimeout:   feedTLSTimeout,
+		ResponseHeaderTimeout: feedResponseTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   2,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= feedMaxRedirects {
+			return fmt.Errorf("stopped after %d redirects", feedMaxRedirects)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return errors.New("redirect to unsupported scheme " + req.URL.Scheme)
+		}
+		stripCredentialsOnRedirect(req, via)
+		return nil
+	},
+}
+
+// readFeedBody reads at most limit bytes of r, failing instead of truncating
+// when there is more.
+func readFeedBody(r io.Reader, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, errFeedTooLarge
+	}
+
+	return body, nil
+}
+
+// decodeFeedBody decompresses a body fetched with feedAcceptEncoding. Gzip is
+// recognized by its magic bytes whatever the Content-Encoding says, servers
+// send gzipped files as application/xml and label plain bodies as gzip. The
+// same goes for deflate, which is sent both zlib wrapped, as specified, and
+// raw. The decoded body is limited like a plain one.
+func decodeFeedBody(raw []byte, contentEncoding string, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case bytes.HasPrefix(raw, []byte{0x1f, 0x8b}):
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case isZlib(raw):
+		zr, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case strings.EqualFold(strings.TrimSpace(contentEncoding), "deflate"):
+		fr := flate.NewReader(bytes.NewReader(raw))
+		defer fr.Close()
+		r = fr
+	default:
+		return raw, nil
+	}
+
+	return readFeedBody(r, limit)
+}
+
+// isZlib reports whether data starts with a zlib header, a deflate method
+// byte with a checksum that's a multiple of 31.
+func isZlib(data []byte) bool {
+	return len(data) >= 2 && data[0]&0x0f == 8 && data[0]>>4 <= 7 && (uint16(data[0])<<8|uint16(data[1]))%31 == 0
+}
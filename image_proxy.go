@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/storage"
+)
+
+const (
+	imageProxyTimeout       = 15 * time.Second
+	imageProxyMaxBody       = 5 << 20
+	imageProxyCacheMaxAge   = 7 * 24 * time.Hour
+	imageProxyStoragePrefix = "images/"
+)
+
+// imageProxyTypes are the image formats the proxy serves. SVGs are left out,
+// they could run scripts on the API origin.
+var imageProxyTypes = map[string]bool{
+	"image/jpeg":   true,
+	"image/png":    true,
+	"image/gif":    true,
+	"image/webp":   true,
+	"image/bmp":    true,
+	"image/x-icon": true,
+}
+
+var errNotAnImage = errors.New("not an image")
+
+// signImageURL is the signature that makes the proxy serve imageURL. Only
+// urls the server handed out are signed, so it can't be used as an open proxy.
+func signImageURL(secret []byte, imageURL string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(imageURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// proxiedImageURL points imageURL at the image proxy. Without a secret the
+// proxy is off and imageURL is returned as is.
+func proxiedImageURL(apiConfig apiConfig, imageURL string) string {
+	if len(apiConfig.ImageProxySecret) == 0 || imageURL == "" {
+		return imageURL
+	}
+
+	query := url.Values{}
+	query.Set("url", imageURL)
+	query.Set("sig", signImageURL(apiConfig.ImageProxySecret, imageURL))
+	return apiConfig.BaseURL + "/v1/proxy/image?" + query.Encode()
+}
+
+// proxyImages points every image of an html fragment at the image proxy.
+func proxyImages(apiConfig apiConfig, fragment string) string {
+	if len(apiConfig.ImageProxySecret) == 0 || !strings.Contains(fragment, "<img") {
+		return fragment
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return fragment
+	}
+
+	doc.Find("img").Each(func(_ int, img *goquery.Selection) {
+		// the candidates of srcset would bypass the proxy
+		img.RemoveAttr("srcset")
+		if src, ok := img.Attr("src"); ok {
+			img.SetAttr("src", proxiedImageURL(apiConfig, strings.TrimSpace(src)))
+		}
+	})
+
+	proxied, err := doc.Find("body").Html()
+	if err != nil {
+		return fragment
+	}
+
+	return proxied
+}
+
+func imageProxyKey(imageURL string) string {
+	sum := sha256.Sum256([]byte(imageURL))
+	return imageProxyStoragePrefix + hex.EncodeToString(sum[:])
+}
+
+// fetchProxiedImage downloads the image and caches it in storage.
+func fetchProxiedImage(ctx context.Context, apiConfig apiConfig, imageURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageProxyTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	data, err := readFeedBody(resp.Body, imageProxyMaxBody)
+	if err != nil {
+		return nil, err
+	}
+	contentType := http.DetectContentType(data)
+	if !imageProxyTypes[contentType] {
+		return nil, errNotAnImage
+	}
+
+	err = apiConfig.Storage.Put(ctx, imageProxyKey(imageURL), bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		httpLog.Error("Error caching image", "image_url", imageURL, "err", err)
+	}
+
+	return data, nil
+}
+
+// cachedProxiedImage reads an image fetched before, nil when there is none.
+func cachedProxiedImage(ctx context.Context, apiConfig apiConfig, imageURL string) ([]byte, error) {
+	body, err := apiConfig.Storage.Get(ctx, imageProxyKey(imageURL))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+/*
+Endpoint: GET /v1/proxy/image?url=&sig=
+
+Serves an image of a post from the API origin, so readers don't load mixed
+content or tracking pixels from other sites. Images are fetched once, limited
+to 5MB and cached. The server hands out proxied urls in place of image urls
+in post content when IMAGE_PROXY_SECRET is set; sig is the hex HMAC-SHA256 of
+url with that secret, requests with any other signature are refused.
+*/
+func getProxiedImageHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(apiConfig.ImageProxySecret) == 0 {
+			respondWithErrorCode(w, 404, "feature_disabled", "Image proxy is disabled")
+			return
+		}
+
+		imageURL := r.URL.Query().Get("url")
+		sig := r.URL.Query().Get("sig")
+		if !hmac.Equal([]byte(sig), []byte(signImageURL(apiConfig.ImageProxySecret, imageURL))) {
+			respondWithError(w, 403, "Invalid signature")
+			return
+		}
+
+		parsed, err := url.Parse(imageURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			respondWithError(w, 400, "Invalid url")
+			return
+		}
+
+		context := r.Context()
+		data, err := cachedProxiedImage(context, apiConfig, imageURL)
+		if err != nil {
+			httpLog.Error("Error reading cached image", "err", err)
+		}
+		if data == nil {
+			data, err = fetchProxiedImage(context, apiConfig, imageURL)
+			if err != nil {
+				httpLog.Error("Error proxying image", "image_url", imageURL, "err", err)
+				respondWithError(w, 502, "Error fetching image")
+				return
+			}
+		}
+
+		contentType := http.DetectContentType(data)
+		if !imageProxyTypes[contentType] {
+			respondWithError(w, 502, "Error fetching image")
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'none'")
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(imageProxyCacheMaxAge.Seconds())))
+		w.WriteHeader(200)
+		w.Write(data)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// integrityReport counts rows that break the relations between users, feeds,
+// follows and posts. The foreign keys prevent most of them today, but older
+// schemas and hand edits could leave them behind.
+type integrityReport struct {
+	OrphanedFeedFollows  int64 `json:"orphaned_feed_follows"`
+	OrphanedPosts        int64 `json:"orphaned_posts"`
+	DuplicateFeedFollows int64 `json:"duplicate_feed_follows"`
+}
+
+func checkIntegrity(ctx context.Context, q *database.Queries) (integrityReport, error) {
+	var report integrityReport
+	var err error
+
+	if report.OrphanedFeedFollows, err = q.CountOrphanedFeedFollows(ctx); err != nil {
+		return integrityReport{}, err
+	}
+	if report.OrphanedPosts, err = q.CountOrphanedPosts(ctx); err != nil {
+		return integrityReport{}, err
+	}
+	if report.DuplicateFeedFollows, err = q.CountDuplicateFeedFollows(ctx); err != nil {
+		return integrityReport{}, err
+	}
+
+	return report, nil
+}
+
+// repairIntegrity deletes the rows checkIntegrity counts and reports how many
+// it deleted. Of duplicate follows one is kept.
+func repairIntegrity(ctx context.Context, q *database.Queries) (integrityReport, error) {
+	var report integrityReport
+	var err error
+
+	if report.OrphanedFeedFollows, err = q.DeleteOrphanedFeedFollows(ctx); err != nil {
+		return integrityReport{}, err
+	}
+	if report.OrphanedPosts, err = q.DeleteOrphanedPosts(ctx); err != nil {
+		return integrityReport{}, err
+	}
+	if report.DuplicateFeedFollows, err = q.DeleteDuplicateFeedFollows(ctx); err != nil {
+		return integrityReport{}, err
+	}
+
+	return report, nil
+}
+
+/*
+Endpoint: GET /v1/admin/integrity
+
+# This is an admin endpoint
+
+Counts follows of missing feeds or users, posts of missing feeds and follows
+of the same feed by the same user more than once.
+*/
+func getIntegrityHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		context := r.Context()
+		report, err := checkIntegrity(context, apiConfig.DB)
+		if err != nil {
+			httpLog.Error("Error checking integrity", "err", err)
+			respondWithError(w, 500, "Error checking integrity")
+			return
+		}
+
+		respondWithJSON(w, 200, report)
+	}
+}
+
+/*
+Endpoint: POST /v1/admin/integrity/repair
+
+# This is an admin endpoint
+
+Deletes everything GET /v1/admin/integrity counts in one transaction and
+returns how many rows of each kind were deleted.
+*/
+func repairIntegrityHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		context := r.Context()
+		var report integrityReport
+		err := database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			var err error
+			report, err = repairIntegrity(context, q)
+			return err
+		})
+		if err != nil {
+			httpLog.Error("Error repairing integrity", "err", err)
+			respondWithError(w, 500, "Error repairing integrity")
+			return
+		}
+
+		httpLog.Info("Integrity repair deleted", "report", report)
+		respondWithJSON(w, 200, report)
+	}
+}
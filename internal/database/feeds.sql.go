@@ -15,7 +15,7 @@ import (
 const createFeed = `-- name: CreateFeed :one
 INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
 VALUES ($1, $2, $3, $4, $5, $6)
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds
 `
 
 type CreateFeedParams struct {
@@ -45,12 +45,135 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.RefreshIntervalSeconds,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const disableFeed = `-- name: DisableFeed :exec
+UPDATE feeds SET disabled_at = now(), updated_at = now(), disabled_reason = $2, next_fetch_at = NULL
+WHERE id = $1
+`
+
+type DisableFeedParams struct {
+	ID             uuid.UUID
+	DisabledReason sql.NullString
+}
+
+func (q *Queries) DisableFeed(ctx context.Context, arg DisableFeedParams) error {
+	_, err := q.db.ExecContext(ctx, disableFeed, arg.ID, arg.DisabledReason)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :one
+UPDATE feeds SET disabled_at = NULL, disabled_reason = NULL, updated_at = now(),
+    consecutive_failures = 0, last_error = NULL, next_fetch_at = NULL, failing_since = NULL
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, enableFeed, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.RefreshIntervalSeconds,
+	)
+	return i, err
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.RefreshIntervalSeconds,
+	)
+	return i, err
+}
+
+const getFeedByUrl = `-- name: GetFeedByUrl :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds WHERE url = $1
+`
+
+func (q *Queries) GetFeedByUrl(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByUrl, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.RefreshIntervalSeconds,
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds
+-- newsletters and feeds fetched with credentials are their owner's alone
+WHERE url NOT LIKE 'newsletter:%'
+    AND NOT EXISTS (SELECT 1 FROM feed_credentials c WHERE c.feed_id = feeds.id)
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -70,6 +193,61 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsWithUndatedPosts = `-- name: GetFeedsWithUndatedPosts :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds f
+WHERE f.disabled_at IS NULL
+    AND EXISTS (SELECT 1 FROM posts p WHERE p.feed_id = f.id AND p.published_at IS NULL)
+`
+
+func (q *Queries) GetFeedsWithUndatedPosts(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithUndatedPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -85,11 +263,64 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds ORDER BY last_fetched_at NULLS FIRST LIMIT $1
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds
+WHERE disabled_at IS NULL AND (next_fetch_at IS NULL OR next_fetch_at <= now())
+    AND (last_fetched_at IS NULL OR last_fetched_at <= $1)
+    AND url NOT LIKE 'newsletter:%'
+ORDER BY last_fetched_at NULLS FIRST LIMIT $2
 `
 
-func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
+type GetNextFeedsToFetchParams struct {
+	LastFetchedAt sql.NullTime
+	Limit         int32
+}
+
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.LastFetchedAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserFeeds = `-- name: GetUserFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) GetUserFeeds(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFeeds, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +336,15 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -119,11 +359,134 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 	return items, nil
 }
 
+const getVisibleFeedByID = `-- name: GetVisibleFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM feeds
+WHERE id = $1
+    -- newsletters and feeds with credentials only to their owner
+    AND (user_id = $2 OR (url NOT LIKE 'newsletter:%'
+        AND NOT EXISTS (SELECT 1 FROM feed_credentials c WHERE c.feed_id = feeds.id)))
+`
+
+type GetVisibleFeedByIDParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetVisibleFeedByID(ctx context.Context, arg GetVisibleFeedByIDParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleFeedByID, arg.ID, arg.UserID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.NextFetchAt,
+		&i.FailingSince,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.RefreshIntervalSeconds,
+	)
+	return i, err
+}
+
+const handOverUserFeeds = `-- name: HandOverUserFeeds :many
+UPDATE feeds f SET user_id = (
+    SELECT ff.user_id FROM feed_follows ff
+    WHERE ff.feed_id = f.id AND ff.user_id <> f.user_id
+    ORDER BY ff.created_at, ff.id LIMIT 1
+), updated_at = now()
+WHERE f.user_id = $1
+    -- newsletters go with their owner
+    AND f.url NOT LIKE 'newsletter:%'
+    AND EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id AND ff.user_id <> f.user_id)
+RETURNING f.id
+`
+
+func (q *Queries) HandOverUserFeeds(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, handOverUserFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markFeedAsFetched = `-- name: MarkFeedAsFetched :exec
-UPDATE feeds SET last_fetched_at = now(), updated_at = now() WHERE url = $1
+UPDATE feeds SET last_fetched_at = now(), updated_at = now(), etag = $2, last_modified = $3,
+    consecutive_failures = 0, last_error = NULL, failing_since = NULL,
+    next_fetch_at = $4, refresh_interval_seconds = $5
+WHERE url = $1
 `
 
-func (q *Queries) MarkFeedAsFetched(ctx context.Context, url string) error {
-	_, err := q.db.ExecContext(ctx, markFeedAsFetched, url)
+type MarkFeedAsFetchedParams struct {
+	Url                    string
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	NextFetchAt            sql.NullTime
+	RefreshIntervalSeconds sql.NullInt32
+}
+
+func (q *Queries) MarkFeedAsFetched(ctx context.Context, arg MarkFeedAsFetchedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedAsFetched,
+		arg.Url,
+		arg.Etag,
+		arg.LastModified,
+		arg.NextFetchAt,
+		arg.RefreshIntervalSeconds,
+	)
+	return err
+}
+
+const markFeedFetchFailed = `-- name: MarkFeedFetchFailed :exec
+UPDATE feeds SET last_fetched_at = now(), updated_at = now(),
+    consecutive_failures = consecutive_failures + 1, last_error = $2, next_fetch_at = $3,
+    failing_since = COALESCE(failing_since, now())
+WHERE id = $1
+`
+
+type MarkFeedFetchFailedParams struct {
+	ID          uuid.UUID
+	LastError   sql.NullString
+	NextFetchAt sql.NullTime
+}
+
+func (q *Queries) MarkFeedFetchFailed(ctx context.Context, arg MarkFeedFetchFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFetchFailed, arg.ID, arg.LastError, arg.NextFetchAt)
+	return err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :exec
+UPDATE feeds SET url = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateFeedUrlParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedUrl, arg.ID, arg.Url)
 	return err
 }
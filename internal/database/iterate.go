@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// The Iterate* helpers run the same statements as their sqlc generated
+// counterparts but hand the rows to fn one by one instead of collecting them,
+// so large result sets can be streamed without holding them in memory.
+
+func (q *Queries) IterateFeeds(ctx context.Context, fn func(Feed) error) error {
+	rows, err := q.db.QueryContext(ctx, getFeeds)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+func (q *Queries) IteratePostsByUser(ctx context.Context, userID uuid.UUID, fn func(GetPostsByUserRow) error) error {
+	rows, err := q.db.QueryContext(ctx, getPostsByUser, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i GetPostsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.CanonicalUrl,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Enclosures,
+			&i.ImageUrl,
+			&i.SiteName,
+			&i.PageCanonicalUrl,
+			&i.Duration,
+			&i.Episode,
+			&i.ThumbnailUrl,
+			&i.ID_2,
+			&i.CreatedAt_2,
+			&i.UpdatedAt_2,
+			&i.Name,
+			&i.Url_2,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
@@ -6,18 +6,83 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+	KeyHash   string
+	Scopes    []string
+}
+
+type Bookmark struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	PostID      uuid.NullUUID
+	Url         string
+	Title       string
+	Description string
+	Folder      string
+}
+
+type ChatNotification struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Provider   string
+	WebhookUrl string
+	FeedID     uuid.NullUUID
+	Keywords   []string
+	Template   string
+	LastSentAt sql.NullTime
+	LastError  sql.NullString
+}
+
+type ChatNotificationPost struct {
+	ChatNotificationID uuid.UUID
+	PostID             uuid.UUID
+	CreatedAt          time.Time
+}
+
+type EmailVerification struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     sql.NullTime
-	UpdatedAt     sql.NullTime
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                     uuid.UUID
+	CreatedAt              sql.NullTime
+	UpdatedAt              sql.NullTime
+	Name                   string
+	Url                    string
+	UserID                 uuid.UUID
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	ConsecutiveFailures    int32
+	LastError              sql.NullString
+	NextFetchAt            sql.NullTime
+	FailingSince           sql.NullTime
+	DisabledAt             sql.NullTime
+	DisabledReason         sql.NullString
+	RefreshIntervalSeconds sql.NullInt32
+}
+
+type FeedCredential struct {
+	FeedID    uuid.UUID
+	UpdatedAt time.Time
+	Sealed    []byte
 }
 
 type FeedFollow struct {
@@ -28,21 +93,291 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
-type Post struct {
-	ID          uuid.UUID
-	CreatedAt   sql.NullTime
-	UpdatedAt   sql.NullTime
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
+type FeedIcon struct {
 	FeedID      uuid.UUID
+	FetchedAt   time.Time
+	SourceUrl   string
+	ContentType string
+	Hash        string
 }
 
-type User struct {
+type FeedNote struct {
 	ID        uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
-	Name      string
-	Apikey    string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	UserID    uuid.UUID
+	Note      string
+}
+
+type FeedScraper struct {
+	FeedID        uuid.UUID
+	UpdatedAt     time.Time
+	ItemSelector  string
+	LinkSelector  string
+	TitleSelector string
+	DateSelector  string
+}
+
+type FeedSnapshot struct {
+	ID             uuid.UUID
+	FeedID         uuid.UUID
+	FetchedAt      time.Time
+	ContentType    string
+	Size           int32
+	CompressedSize int32
+}
+
+type FeedTransferStat struct {
+	FeedID              uuid.UUID
+	UpdatedAt           time.Time
+	Fetches             int64
+	LastTransferBytes   int64
+	LastBodyBytes       int64
+	LastContentEncoding string
+	TotalTransferBytes  int64
+	TotalBodyBytes      int64
+}
+
+type FeedUrlChange struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	OldUrl    string
+	NewUrl    string
+}
+
+type FetchLog struct {
+	ID            uuid.UUID
+	FeedID        uuid.UUID
+	StartedAt     time.Time
+	DurationMs    int32
+	StatusCode    sql.NullInt32
+	ItemsFound    int32
+	ItemsInserted int32
+	Error         sql.NullString
+}
+
+type NewsletterAddress struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Token     string
+	CreatedAt time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	SecretHash   string
+	RedirectUris []string
+}
+
+type OauthCode struct {
+	CodeHash      string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	ExpiresAt     time.Time
+	CodeChallenge string
+}
+
+type OauthToken struct {
+	TokenHash string
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	Scopes    []string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type PasswordReset struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Post struct {
+	ID               uuid.UUID
+	CreatedAt        sql.NullTime
+	UpdatedAt        sql.NullTime
+	Title            string
+	Url              string
+	Description      string
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Guid             string
+	CanonicalUrl     string
+	Content          string
+	Author           string
+	Categories       []string
+	Enclosures       json.RawMessage
+	ImageUrl         sql.NullString
+	SiteName         sql.NullString
+	PageCanonicalUrl sql.NullString
+	Duration         sql.NullInt32
+	Episode          sql.NullInt32
+	ThumbnailUrl     sql.NullString
+}
+
+type PostCanonicalUrlBackfill struct {
+	PostID uuid.UUID
+}
+
+type PostContent struct {
+	PostID    uuid.UUID
+	CreatedAt time.Time
+	Html      string
+	Text      string
+}
+
+type PublicFeed struct {
+	FeedID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ReadingProgress struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	UpdatedAt time.Time
+	Percent   float64
+	Anchor    string
+}
+
+type ScheduledItem struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+	Guid      string
+	PublishAt time.Time
+	Item      json.RawMessage
+}
+
+type Session struct {
+	TokenHash  string
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	ID         uuid.UUID
+	LastUsedAt time.Time
+	UserAgent  string
+}
+
+type SocialLoginState struct {
+	StateHash   string
+	Provider    string
+	UserID      uuid.NullUUID
+	RedirectUri string
+	ExpiresAt   time.Time
+}
+
+type SyncChange struct {
+	ID        int64
+	Txid      int64
+	CreatedAt time.Time
+	UserID    uuid.NullUUID
+	FeedID    uuid.NullUUID
+	Entity    string
+	EntityID  uuid.UUID
+	Deleted   bool
+}
+
+type TelegramChat struct {
+	UserID    uuid.UUID
+	ChatID    int64
+	CreatedAt time.Time
+}
+
+type TelegramFeed struct {
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type TelegramLinkCode struct {
+	CodeHash  string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+}
+
+type TelegramMessage struct {
+	ChatID    int64
+	MessageID int64
+	PostID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type User struct {
+	ID               uuid.UUID
+	CreatedAt        sql.NullTime
+	UpdatedAt        sql.NullTime
+	Name             string
+	Email            sql.NullString
+	EmailVerifiedAt  sql.NullTime
+	AllowedCidrs     []string
+	ApikeyHash       string
+	PasswordHash     sql.NullString
+	Timezone         string
+	DefaultPostLimit sql.NullInt32
+	DigestFrequency  string
+	DigestHour       int16
+}
+
+type UserFeedToken struct {
+	UserID    uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+}
+
+type UserIdentity struct {
+	Provider  string
+	Subject   string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+}
+
+type Webhook struct {
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	UserID                  uuid.UUID
+	Url                     string
+	Secret                  string
+	PreviousSecret          sql.NullString
+	PreviousSecretExpiresAt sql.NullTime
+	Events                  []string
+	Format                  string
+}
+
+type WebhookDeadLetter struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	WebhookID   uuid.UUID
+	DeliveryID  uuid.UUID
+	EventType   string
+	Payload     string
+	ContentType string
+	Attempts    int32
+	Error       sql.NullString
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	WebhookID     uuid.UUID
+	EventType     string
+	Payload       string
+	Redelivery    bool
+	Status        string
+	ResponseCode  sql.NullInt32
+	LatencyMs     sql.NullInt32
+	Error         sql.NullString
+	DeliveredAt   sql.NullTime
+	ContentType   string
+	Attempts      int32
+	NextAttemptAt sql.NullTime
 }
@@ -8,38 +8,330 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const deleteCanonicalURLBackfill = `-- name: DeleteCanonicalURLBackfill :exec
+DELETE FROM post_canonical_url_backfills WHERE post_id = ANY($1::uuid[])
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   sql.NullTime
-	UpdatedAt   sql.NullTime
-	Title       string
-	Url         string
-	Description string
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+func (q *Queries) DeleteCanonicalURLBackfill(ctx context.Context, postIds []uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteCanonicalURLBackfill, pq.Array(postIds))
+	return err
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
+const fillMissingPublishedAt = `-- name: FillMissingPublishedAt :execrows
+UPDATE posts SET published_at = coalesce(created_at, now()), updated_at = now()
+WHERE published_at IS NULL
+`
+
+func (q *Queries) FillMissingPublishedAt(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, fillMissingPublishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getCanonicalURLBackfill = `-- name: GetCanonicalURLBackfill :many
+SELECT p.id, p.url, p.canonical_url FROM post_canonical_url_backfills b
+JOIN posts p ON p.id = b.post_id
+ORDER BY b.post_id
+LIMIT $1
+`
+
+type GetCanonicalURLBackfillRow struct {
+	ID           uuid.UUID
+	Url          string
+	CanonicalUrl string
+}
+
+func (q *Queries) GetCanonicalURLBackfill(ctx context.Context, limit int32) ([]GetCanonicalURLBackfillRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCanonicalURLBackfill, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCanonicalURLBackfillRow
+	for rows.Next() {
+		var i GetCanonicalURLBackfillRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.CanonicalUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCompactPostsByUser = `-- name: GetCompactPostsByUser :many
+SELECT p.id, p.title, f.name AS feed_name, p.published_at, p.thumbnail_url,
+    (b.id IS NOT NULL)::boolean AS bookmarked, rp.percent AS reading_progress
+FROM (
+    -- only columns of posts_feed_id_compact_idx, so the index covers it
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text))
+        p.id, p.title, p.feed_id, p.published_at, p.thumbnail_url
+    FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN bookmarks b ON b.post_id = p.id AND b.user_id = $1
+LEFT JOIN reading_progress rp ON rp.post_id = p.id AND rp.user_id = $1
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+`
+
+type GetCompactPostsByUserRow struct {
+	ID              uuid.UUID
+	Title           string
+	FeedName        string
+	PublishedAt     sql.NullTime
+	ThumbnailUrl    sql.NullString
+	Bookmarked      bool
+	ReadingProgress sql.NullFloat64
+}
+
+func (q *Queries) GetCompactPostsByUser(ctx context.Context, userID uuid.UUID) ([]GetCompactPostsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCompactPostsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCompactPostsByUserRow
+	for rows.Next() {
+		var i GetCompactPostsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.FeedName,
+			&i.PublishedAt,
+			&i.ThumbnailUrl,
+			&i.Bookmarked,
+			&i.ReadingProgress,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCompactPostsPageByUser = `-- name: GetCompactPostsPageByUser :many
+SELECT p.id, p.title, f.name AS feed_name, p.published_at, p.thumbnail_url,
+    (b.id IS NOT NULL)::boolean AS bookmarked, rp.percent AS reading_progress
+FROM posts p
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN bookmarks b ON b.post_id = p.id AND b.user_id = $1
+LEFT JOIN reading_progress rp ON rp.post_id = p.id AND rp.user_id = $1
+WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    -- the first of the posts sharing a canonical url, like GetPostsByUser
+    AND NOT EXISTS (
+        SELECT 1 FROM posts d
+        WHERE p.canonical_url <> '' AND d.canonical_url = p.canonical_url
+            AND EXISTS (SELECT 1 FROM feed_follows dff WHERE dff.feed_id = d.feed_id AND dff.user_id = $1)
+            AND (d.created_at, d.id) < (p.created_at, p.id)
+    )
+    AND ($2::timestamp IS NULL
+        OR (coalesce(p.published_at, 'epoch'), p.id) < ($2::timestamp, $3::uuid))
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT $4
+`
+
+type GetCompactPostsPageByUserParams struct {
+	UserID     uuid.UUID
+	BeforeTime sql.NullTime
+	BeforeID   uuid.NullUUID
+	Limit      int32
+}
+
+type GetCompactPostsPageByUserRow struct {
+	ID              uuid.UUID
+	Title           string
+	FeedName        string
+	PublishedAt     sql.NullTime
+	ThumbnailUrl    sql.NullString
+	Bookmarked      bool
+	ReadingProgress sql.NullFloat64
+}
+
+func (q *Queries) GetCompactPostsPageByUser(ctx context.Context, arg GetCompactPostsPageByUserParams) ([]GetCompactPostsPageByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCompactPostsPageByUser,
+		arg.UserID,
+		arg.BeforeTime,
+		arg.BeforeID,
+		arg.Limit,
 	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCompactPostsPageByUserRow
+	for rows.Next() {
+		var i GetCompactPostsPageByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.FeedName,
+			&i.PublishedAt,
+			&i.ThumbnailUrl,
+			&i.Bookmarked,
+			&i.ReadingProgress,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMediaPostsByUser = `-- name: GetMediaPostsByUser :many
+SELECT p.id, p.created_at, p.updated_at, title, p.url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url, f.id, f.created_at, f.updated_at, name, f.url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM (
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text)) p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.guid, p.canonical_url, p.content, p.author, p.categories, p.enclosures, p.image_url, p.site_name, p.page_canonical_url, p.duration, p.episode, p.thumbnail_url FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+        AND EXISTS (
+            SELECT 1 FROM jsonb_array_elements(p.enclosures) e
+            WHERE e->>'type' LIKE $2::text || '/%'
+        )
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+`
+
+type GetMediaPostsByUserParams struct {
+	UserID    uuid.UUID
+	MediaType string
+}
+
+type GetMediaPostsByUserRow struct {
+	ID                     uuid.UUID
+	CreatedAt              sql.NullTime
+	UpdatedAt              sql.NullTime
+	Title                  string
+	Url                    string
+	Description            string
+	PublishedAt            sql.NullTime
+	FeedID                 uuid.UUID
+	Guid                   string
+	CanonicalUrl           string
+	Content                string
+	Author                 string
+	Categories             []string
+	Enclosures             json.RawMessage
+	ImageUrl               sql.NullString
+	SiteName               sql.NullString
+	PageCanonicalUrl       sql.NullString
+	Duration               sql.NullInt32
+	Episode                sql.NullInt32
+	ThumbnailUrl           sql.NullString
+	ID_2                   uuid.UUID
+	CreatedAt_2            sql.NullTime
+	UpdatedAt_2            sql.NullTime
+	Name                   string
+	Url_2                  string
+	UserID                 uuid.UUID
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	ConsecutiveFailures    int32
+	LastError              sql.NullString
+	NextFetchAt            sql.NullTime
+	FailingSince           sql.NullTime
+	DisabledAt             sql.NullTime
+	DisabledReason         sql.NullString
+	RefreshIntervalSeconds sql.NullInt32
+}
+
+func (q *Queries) GetMediaPostsByUser(ctx context.Context, arg GetMediaPostsByUserParams) ([]GetMediaPostsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMediaPostsByUser, arg.UserID, arg.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMediaPostsByUserRow
+	for rows.Next() {
+		var i GetMediaPostsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.CanonicalUrl,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Enclosures,
+			&i.ImageUrl,
+			&i.SiteName,
+			&i.PageCanonicalUrl,
+			&i.Duration,
+			&i.Episode,
+			&i.ThumbnailUrl,
+			&i.ID_2,
+			&i.CreatedAt_2,
+			&i.UpdatedAt_2,
+			&i.Name,
+			&i.Url_2,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostByID = `-- name: GetPostByID :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url FROM posts WHERE id = $1
+`
+
+func (q *Queries) GetPostByID(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByID, id)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -50,32 +342,71 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.CanonicalUrl,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.Enclosures,
+		&i.ImageUrl,
+		&i.SiteName,
+		&i.PageCanonicalUrl,
+		&i.Duration,
+		&i.Episode,
+		&i.ThumbnailUrl,
 	)
 	return i, err
 }
 
 const getPostsByUser = `-- name: GetPostsByUser :many
-SELECT p.id, p.created_at, p.updated_at, title, p.url, description, published_at, feed_id, f.id, f.created_at, f.updated_at, name, f.url, user_id, last_fetched_at FROM posts p
+SELECT p.id, p.created_at, p.updated_at, title, p.url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url, f.id, f.created_at, f.updated_at, name, f.url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM (
+    -- the first of the posts sharing a canonical url, posts without a link have
+    -- none and are never duplicates
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text)) p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.guid, p.canonical_url, p.content, p.author, p.categories, p.enclosures, p.image_url, p.site_name, p.page_canonical_url, p.duration, p.episode, p.thumbnail_url FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
 JOIN feeds f ON f.id = p.feed_id
-WHERE f.user_id = $1
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
 `
 
 type GetPostsByUserRow struct {
-	ID            uuid.UUID
-	CreatedAt     sql.NullTime
-	UpdatedAt     sql.NullTime
-	Title         string
-	Url           string
-	Description   string
-	PublishedAt   sql.NullTime
-	FeedID        uuid.UUID
-	ID_2          uuid.UUID
-	CreatedAt_2   sql.NullTime
-	UpdatedAt_2   sql.NullTime
-	Name          string
-	Url_2         string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                     uuid.UUID
+	CreatedAt              sql.NullTime
+	UpdatedAt              sql.NullTime
+	Title                  string
+	Url                    string
+	Description            string
+	PublishedAt            sql.NullTime
+	FeedID                 uuid.UUID
+	Guid                   string
+	CanonicalUrl           string
+	Content                string
+	Author                 string
+	Categories             []string
+	Enclosures             json.RawMessage
+	ImageUrl               sql.NullString
+	SiteName               sql.NullString
+	PageCanonicalUrl       sql.NullString
+	Duration               sql.NullInt32
+	Episode                sql.NullInt32
+	ThumbnailUrl           sql.NullString
+	ID_2                   uuid.UUID
+	CreatedAt_2            sql.NullTime
+	UpdatedAt_2            sql.NullTime
+	Name                   string
+	Url_2                  string
+	UserID                 uuid.UUID
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	ConsecutiveFailures    int32
+	LastError              sql.NullString
+	NextFetchAt            sql.NullTime
+	FailingSince           sql.NullTime
+	DisabledAt             sql.NullTime
+	DisabledReason         sql.NullString
+	RefreshIntervalSeconds sql.NullInt32
 }
 
 func (q *Queries) GetPostsByUser(ctx context.Context, userID uuid.UUID) ([]GetPostsByUserRow, error) {
@@ -96,6 +427,18 @@ func (q *Queries) GetPostsByUser(ctx context.Context, userID uuid.UUID) ([]GetPo
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.CanonicalUrl,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Enclosures,
+			&i.ImageUrl,
+			&i.SiteName,
+			&i.PageCanonicalUrl,
+			&i.Duration,
+			&i.Episode,
+			&i.ThumbnailUrl,
 			&i.ID_2,
 			&i.CreatedAt_2,
 			&i.UpdatedAt_2,
@@ -103,6 +446,71 @@ func (q *Queries) GetPostsByUser(ctx context.Context, userID uuid.UUID) ([]GetPo
 			&i.Url_2,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsPageByFeed = `-- name: GetPostsPageByFeed :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url FROM posts
+WHERE feed_id = $1
+ORDER BY coalesce(published_at, 'epoch') DESC, id DESC
+LIMIT $2
+`
+
+type GetPostsPageByFeedParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetPostsPageByFeed(ctx context.Context, arg GetPostsPageByFeedParams) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsPageByFeed, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.CanonicalUrl,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Enclosures,
+			&i.ImageUrl,
+			&i.SiteName,
+			&i.PageCanonicalUrl,
+			&i.Duration,
+			&i.Episode,
+			&i.ThumbnailUrl,
 		); err != nil {
 			return nil, err
 		}
@@ -116,3 +524,301 @@ func (q *Queries) GetPostsByUser(ctx context.Context, userID uuid.UUID) ([]GetPo
 	}
 	return items, nil
 }
+
+const getPostsPageByUser = `-- name: GetPostsPageByUser :many
+SELECT p.id, p.created_at, p.updated_at, title, p.url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url, f.id, f.created_at, f.updated_at, name, f.url, user_id, last_fetched_at, etag, last_modified, consecutive_failures, last_error, next_fetch_at, failing_since, disabled_at, disabled_reason, refresh_interval_seconds FROM posts p
+JOIN feeds f ON f.id = p.feed_id
+WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    -- the first of the posts sharing a canonical url, like GetPostsByUser
+    AND NOT EXISTS (
+        SELECT 1 FROM posts d
+        WHERE p.canonical_url <> '' AND d.canonical_url = p.canonical_url
+            AND EXISTS (SELECT 1 FROM feed_follows dff WHERE dff.feed_id = d.feed_id AND dff.user_id = $1)
+            AND (d.created_at, d.id) < (p.created_at, p.id)
+    )
+    AND ($2::text IS NULL OR EXISTS (
+        SELECT 1 FROM jsonb_array_elements(p.enclosures) e
+        WHERE e->>'type' LIKE $2::text || '/%'
+    ))
+    AND ($3::timestamp IS NULL
+        OR (coalesce(p.published_at, 'epoch'), p.id) < ($3::timestamp, $4::uuid))
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT $5
+`
+
+type GetPostsPageByUserParams struct {
+	UserID     uuid.UUID
+	MediaType  sql.NullString
+	BeforeTime sql.NullTime
+	BeforeID   uuid.NullUUID
+	Limit      int32
+}
+
+type GetPostsPageByUserRow struct {
+	ID                     uuid.UUID
+	CreatedAt              sql.NullTime
+	UpdatedAt              sql.NullTime
+	Title                  string
+	Url                    string
+	Description            string
+	PublishedAt            sql.NullTime
+	FeedID                 uuid.UUID
+	Guid                   string
+	CanonicalUrl           string
+	Content                string
+	Author                 string
+	Categories             []string
+	Enclosures             json.RawMessage
+	ImageUrl               sql.NullString
+	SiteName               sql.NullString
+	PageCanonicalUrl       sql.NullString
+	Duration               sql.NullInt32
+	Episode                sql.NullInt32
+	ThumbnailUrl           sql.NullString
+	ID_2                   uuid.UUID
+	CreatedAt_2            sql.NullTime
+	UpdatedAt_2            sql.NullTime
+	Name                   string
+	Url_2                  string
+	UserID                 uuid.UUID
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	ConsecutiveFailures    int32
+	LastError              sql.NullString
+	NextFetchAt            sql.NullTime
+	FailingSince           sql.NullTime
+	DisabledAt             sql.NullTime
+	DisabledReason         sql.NullString
+	RefreshIntervalSeconds sql.NullInt32
+}
+
+func (q *Queries) GetPostsPageByUser(ctx context.Context, arg GetPostsPageByUserParams) ([]GetPostsPageByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsPageByUser,
+		arg.UserID,
+		arg.MediaType,
+		arg.BeforeTime,
+		arg.BeforeID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsPageByUserRow
+	for rows.Next() {
+		var i GetPostsPageByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.CanonicalUrl,
+			&i.Content,
+			&i.Author,
+			pq.Array(&i.Categories),
+			&i.Enclosures,
+			&i.ImageUrl,
+			&i.SiteName,
+			&i.PageCanonicalUrl,
+			&i.Duration,
+			&i.Episode,
+			&i.ThumbnailUrl,
+			&i.ID_2,
+			&i.CreatedAt_2,
+			&i.UpdatedAt_2,
+			&i.Name,
+			&i.Url_2,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.NextFetchAt,
+			&i.FailingSince,
+			&i.DisabledAt,
+			&i.DisabledReason,
+			&i.RefreshIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (q *Queries) SetPostCanonicalURL(ctx context.Context, arg SetPostCanonicalURLParams) error {
+	_, err := q.db.ExecContext(ctx, setPostCanonicalURL, arg.ID, arg.CanonicalUrl)
+	return err
+}
+
+const setMissingPublishedAt = `-- name: SetMissingPublishedAt :execrows
+UPDATE posts SET published_at = $3, updated_at = now()
+WHERE feed_id = $1 AND guid = $2 AND published_at IS NULL
+`
+
+type SetMissingPublishedAtParams struct {
+	FeedID      uuid.UUID
+	Guid        string
+	PublishedAt sql.NullTime
+}
+
+func (q *Queries) SetMissingPublishedAt(ctx context.Context, arg SetMissingPublishedAtParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setMissingPublishedAt, arg.FeedID, arg.Guid, arg.PublishedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setPostCanonicalURL = `-- name: SetPostCanonicalURL :exec
+UPDATE posts SET canonical_url = $2 WHERE id = $1
+`
+
+type SetPostCanonicalURLParams struct {
+	ID           uuid.UUID
+	CanonicalUrl string
+}
+
+const updatePostMetadata = `-- name: UpdatePostMetadata :exec
+UPDATE posts SET image_url = $2, site_name = $3, page_canonical_url = $4,
+    thumbnail_url = coalesce(thumbnail_url, $2), updated_at = now()
+WHERE id = $1
+`
+
+type UpdatePostMetadataParams struct {
+	ID               uuid.UUID
+	ImageUrl         sql.NullString
+	SiteName         sql.NullString
+	PageCanonicalUrl sql.NullString
+}
+
+func (q *Queries) UpdatePostMetadata(ctx context.Context, arg UpdatePostMetadataParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostMetadata,
+		arg.ID,
+		arg.ImageUrl,
+		arg.SiteName,
+		arg.PageCanonicalUrl,
+	)
+	return err
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, canonical_url,
+    content, author, categories, enclosures, duration, episode, thumbnail_url)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, title = EXCLUDED.title, url = EXCLUDED.url, description = EXCLUDED.description,
+    canonical_url = EXCLUDED.canonical_url, content = EXCLUDED.content, author = EXCLUDED.author,
+    categories = EXCLUDED.categories, enclosures = EXCLUDED.enclosures, duration = EXCLUDED.duration,
+    episode = EXCLUDED.episode, thumbnail_url = coalesce(EXCLUDED.thumbnail_url, posts.thumbnail_url)
+WHERE (posts.title, posts.url, posts.description, posts.content, posts.author, posts.categories, posts.enclosures,
+        posts.duration, posts.episode, posts.thumbnail_url)
+    IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.url, EXCLUDED.description, EXCLUDED.content, EXCLUDED.author,
+        EXCLUDED.categories, EXCLUDED.enclosures, EXCLUDED.duration, EXCLUDED.episode,
+        coalesce(EXCLUDED.thumbnail_url, posts.thumbnail_url))
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, canonical_url, content, author, categories, enclosures, image_url, site_name, page_canonical_url, duration, episode, thumbnail_url, (xmax = 0)::boolean AS inserted
+`
+
+type UpsertPostParams struct {
+	ID           uuid.UUID
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+	Title        string
+	Url          string
+	Description  string
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Guid         string
+	CanonicalUrl string
+	Content      string
+	Author       string
+	Categories   []string
+	Enclosures   json.RawMessage
+	Duration     sql.NullInt32
+	Episode      sql.NullInt32
+	ThumbnailUrl sql.NullString
+}
+
+type UpsertPostRow struct {
+	ID               uuid.UUID
+	CreatedAt        sql.NullTime
+	UpdatedAt        sql.NullTime
+	Title            string
+	Url              string
+	Description      string
+	PublishedAt      sql.NullTime
+	FeedID           uuid.UUID
+	Guid             string
+	CanonicalUrl     string
+	Content          string
+	Author           string
+	Categories       []string
+	Enclosures       json.RawMessage
+	ImageUrl         sql.NullString
+	SiteName         sql.NullString
+	PageCanonicalUrl sql.NullString
+	Duration         sql.NullInt32
+	Episode          sql.NullInt32
+	ThumbnailUrl     sql.NullString
+	Inserted         bool
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.CanonicalUrl,
+		arg.Content,
+		arg.Author,
+		pq.Array(arg.Categories),
+		arg.Enclosures,
+		arg.Duration,
+		arg.Episode,
+		arg.ThumbnailUrl,
+	)
+	var i UpsertPostRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.CanonicalUrl,
+		&i.Content,
+		&i.Author,
+		pq.Array(&i.Categories),
+		&i.Enclosures,
+		&i.ImageUrl,
+		&i.SiteName,
+		&i.PageCanonicalUrl,
+		&i.Duration,
+		&i.Episode,
+		&i.ThumbnailUrl,
+		&i.Inserted,
+	)
+	return i, err
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// TxBeginner is a DBTX that transactions can be begun on, like *sql.DB.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// TxWrapper is a DBTX wrapping another one, like the slow query log, that
+// wraps the transactions begun on it the same way.
+type TxWrapper interface {
+	WrapTx(tx *sql.Tx) DBTX
+}
+
+// InTx runs fn with Queries bound to a single transaction on the database db
+// runs its queries on. The transaction is committed when fn returns nil and
+// rolled back otherwise.
+func InTx(ctx context.Context, db *Queries, fn func(*Queries) error) error {
+	beginner, ok := db.db.(TxBeginner)
+	if !ok {
+		return fmt.Errorf("database: can't begin a transaction on %T", db.db)
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var txdb DBTX = tx
+	if wrapper, ok := db.db.(TxWrapper); ok {
+		txdb = wrapper.WrapTx(tx)
+	}
+
+	err = fn(New(txdb))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -10,36 +10,133 @@ import (
 	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const getUserByApiKey = `-- name: GetUserByApiKey :one
-SELECT id, created_at, updated_at, name, apikey FROM users WHERE apikey = $1
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
 `
 
-func (q *Queries) GetUserByApiKey(ctx context.Context, apikey string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByApiKey, apikey)
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
+const getUserByApiKeyHash = `-- name: GetUserByApiKeyHash :one
+SELECT id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour FROM users WHERE apikey_hash = $1
+`
+
+func (q *Queries) GetUserByApiKeyHash(ctx context.Context, apikeyHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByApiKeyHash, apikeyHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour FROM users WHERE lower(email) = lower($1)
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
 	var i User
 	err := row.Scan(
 		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.Apikey,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
+	)
+	return i, err
+}
+
+const getUserByName = `-- name: GetUserByName :one
+SELECT id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour FROM users WHERE lower(name) = lower($1)
+`
+
+func (q *Queries) GetUserByName(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByName, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
 	)
 	return i, err
 }
 
 const insertUser = `-- name: InsertUser :one
-INSERT INTO users (id, created_at, updated_at, name, apikey)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
-RETURNING id, created_at, updated_at, name, apikey
+INSERT INTO users (id, created_at, updated_at, name, email, allowed_cidrs, apikey_hash, password_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour
 `
 
 type InsertUserParams struct {
-	ID        uuid.UUID
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
-	Name      string
+	ID           uuid.UUID
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+	Name         string
+	Email        sql.NullString
+	AllowedCidrs []string
+	ApikeyHash   string
+	PasswordHash sql.NullString
 }
 
 func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, error) {
@@ -48,6 +145,88 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.Email,
+		pq.Array(arg.AllowedCidrs),
+		arg.ApikeyHash,
+		arg.PasswordHash,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
+	)
+	return i, err
+}
+
+const markUserEmailVerified = `-- name: MarkUserEmailVerified :exec
+UPDATE users SET email_verified_at = now(), updated_at = now()
+WHERE id = $1 AND email = $2
+`
+
+type MarkUserEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email sql.NullString
+}
+
+func (q *Queries) MarkUserEmailVerified(ctx context.Context, arg MarkUserEmailVerifiedParams) error {
+	_, err := q.db.ExecContext(ctx, markUserEmailVerified, arg.ID, arg.Email)
+	return err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash sql.NullString
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET name = $2, email = $3, email_verified_at = $4, timezone = $5,
+    default_post_limit = $6, digest_frequency = $7, digest_hour = $8, updated_at = now()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, email, email_verified_at, allowed_cidrs, apikey_hash, password_hash, timezone, default_post_limit, digest_frequency, digest_hour
+`
+
+type UpdateUserProfileParams struct {
+	ID               uuid.UUID
+	Name             string
+	Email            sql.NullString
+	EmailVerifiedAt  sql.NullTime
+	Timezone         string
+	DefaultPostLimit sql.NullInt32
+	DigestFrequency  string
+	DigestHour       int16
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.Name,
+		arg.Email,
+		arg.EmailVerifiedAt,
+		arg.Timezone,
+		arg.DefaultPostLimit,
+		arg.DigestFrequency,
+		arg.DigestHour,
 	)
 	var i User
 	err := row.Scan(
@@ -55,7 +234,15 @@ func (q *Queries) InsertUser(ctx context.Context, arg InsertUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
-		&i.Apikey,
+		&i.Email,
+		&i.EmailVerifiedAt,
+		pq.Array(&i.AllowedCidrs),
+		&i.ApikeyHash,
+		&i.PasswordHash,
+		&i.Timezone,
+		&i.DefaultPostLimit,
+		&i.DigestFrequency,
+		&i.DigestHour,
 	)
 	return i, err
 }
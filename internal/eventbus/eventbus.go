@@ -0,0 +1,96 @@
+// Package eventbus publishes the aggregator's events to message brokers, for
+// setups that already run one and would rather consume from it than receive
+// webhooks.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Message is one event on its way to a broker.
+type Message struct {
+	// Type is the event type, e.g. "post.created".
+	Type string
+	// FeedID is the feed the event is about, uuid.Nil for events that are not
+	// about a single feed. Brokers with topic hierarchies route on it.
+	FeedID uuid.UUID
+	Data   []byte
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// NewFromEnv builds the publishers listed in EVENT_BUS, a comma separated
+// list of "nats" and "mqtt". It returns nil when EVENT_BUS is unset,
+// publishing is optional.
+func NewFromEnv() (Publisher, error) {
+	var publishers multi
+	for _, backend := range strings.Split(os.Getenv("EVENT_BUS"), ",") {
+		var publisher Publisher
+		var err error
+		switch backend = strings.TrimSpace(backend); backend {
+		case "":
+			continue
+		case "nats":
+			publisher, err = NewNATS(os.Getenv("NATS_URL"), envOr("EVENT_BUS_SUBJECT_PREFIX", "blogator"))
+		case "mqtt":
+			publisher, err = NewMQTT(MQTTConfig{
+				URL:         os.Getenv("MQTT_URL"),
+				ClientID:    envOr("MQTT_CLIENT_ID", "blogator"),
+				Username:    os.Getenv("MQTT_USERNAME"),
+				Password:    os.Getenv("MQTT_PASSWORD"),
+				TopicPrefix: envOr("MQTT_TOPIC_PREFIX", "blogator"),
+			})
+		default:
+			err = fmt.Errorf("unknown EVENT_BUS: %s", backend)
+		}
+		if err != nil {
+			publishers.Close()
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	switch len(publishers) {
+	case 0:
+		return nil, nil
+	case 1:
+		return publishers[0], nil
+	default:
+		return publishers, nil
+	}
+}
+
+func envOr(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// multi publishes every message to all of its publishers.
+type multi []Publisher
+
+func (m multi) Publish(ctx context.Context, msg Message) error {
+	var errs []error
+	for _, publisher := range m {
+		errs = append(errs, publisher.Publish(ctx, msg))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multi) Close() error {
+	var errs []error
+	for _, publisher := range m {
+		errs = append(errs, publisher.Close())
+	}
+	return errors.Join(errs...)
+}
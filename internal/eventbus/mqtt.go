@@ -0,0 +1,80 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+)
+
+const mqttTimeout = 10 * time.Second
+
+type MQTTConfig struct {
+	// URL of the broker, tcp://localhost:1883 when empty.
+	URL         string
+	ClientID    string
+	Username    string
+	Password    string
+	TopicPrefix string
+}
+
+// MQTT publishes to "<prefix>/<event type with / for .>/<feed id>", e.g.
+// "blogator/post/created/<feed id>", so a dashboard can subscribe to one feed
+// or to "blogator/post/created/+" for all of them. Events not about a feed
+// leave out the last level.
+type MQTT struct {
+	client mqtt.Client
+	prefix string
+}
+
+func NewMQTT(config MQTTConfig) (*MQTT, error) {
+	if config.URL == "" {
+		config.URL = "tcp://localhost:1883"
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.URL).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetAutoReconnect(true)
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(mqttTimeout) {
+		return nil, errors.New("timed out connecting to MQTT broker")
+	}
+	if err := token.Error(); err != nil {
+		return nil, err
+	}
+
+	return &MQTT{client: client, prefix: config.TopicPrefix}, nil
+}
+
+func (m *MQTT) topic(msg Message) string {
+	topic := m.prefix + "/" + strings.ReplaceAll(msg.Type, ".", "/")
+	if msg.FeedID != uuid.Nil {
+		topic += "/" + msg.FeedID.String()
+	}
+	return topic
+}
+
+func (m *MQTT) Publish(ctx context.Context, msg Message) error {
+	token := m.client.Publish(m.topic(msg), 1, false, msg.Data)
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *MQTT) Close() error {
+	// give in-flight publishes a moment to be acknowledged
+	m.client.Disconnect(250)
+	return nil
+}
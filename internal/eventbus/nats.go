@@ -0,0 +1,38 @@
+package eventbus
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATS publishes to the subject "<prefix>.<event type>", e.g.
+// "blogator.post.created".
+type NATS struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATS connects to the NATS server at url, nats.DefaultURL when empty. The
+// connection reconnects on its own, events published while it is down are
+// buffered by the client.
+func NewNATS(url, prefix string) (*NATS, error) {
+	if url == "" {
+		url = nats.DefaultURL
+	}
+
+	conn, err := nats.Connect(url, nats.Name("blogator"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &NATS{conn: conn, prefix: prefix}, nil
+}
+
+func (n *NATS) Publish(ctx context.Context, msg Message) error {
+	return n.conn.Publish(n.prefix+"."+msg.Type, msg.Data)
+}
+
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
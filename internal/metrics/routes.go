@@ -0,0 +1,157 @@
+// Package metrics keeps in-process latency statistics per route and a log of
+// slow database queries, for the admin endpoint to show.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// samplesPerRoute bounds the memory used per route, percentiles are computed
+// over the latest samples only.
+const samplesPerRoute = 1024
+
+type RouteStats struct {
+	Route  string `json:"route"`
+	Count  int64  `json:"count"`
+	Errors int64  `json:"errors"`
+	P50Ms  int64  `json:"p50_ms"`
+	P90Ms  int64  `json:"p90_ms"`
+	P99Ms  int64  `json:"p99_ms"`
+	// WithinSLO is the share of the sampled requests that finished within the
+	// latency target.
+	WithinSLO float64 `json:"within_slo"`
+}
+
+type routeSamples struct {
+	count   int64
+	errors  int64
+	samples []time.Duration
+	next    int
+}
+
+// Routes records request latencies per chi route pattern.
+type Routes struct {
+	target time.Duration
+
+	mu     sync.Mutex
+	routes map[string]*routeSamples
+}
+
+// NewRoutes tracks latencies against the SLO target latency.
+func NewRoutes(target time.Duration) *Routes {
+	return &Routes{
+		target: target,
+		routes: map[string]*routeSamples{},
+	}
+}
+
+func (r *Routes) Target() time.Duration {
+	return r.target
+}
+
+// Middleware times every request. It must be mounted on the router whose
+// patterns should be reported.
+func (r *Routes) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, req)
+
+		pattern := chi.RouteContext(req.Context()).RoutePattern()
+		if pattern == "" {
+			// keep unknown paths from growing the map without bound
+			pattern = "unmatched"
+		}
+		r.observe(req.Method+" "+pattern, time.Since(start), sw.status >= 500)
+	})
+}
+
+func (r *Routes) observe(route string, d time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.routes[route]
+	if !ok {
+		s = &routeSamples{}
+		r.routes[route] = s
+	}
+
+	s.count++
+	if failed {
+		s.errors++
+	}
+	if len(s.samples) < samplesPerRoute {
+		s.samples = append(s.samples, d)
+	} else {
+		s.samples[s.next] = d
+		s.next = (s.next + 1) % samplesPerRoute
+	}
+}
+
+// Snapshot returns the statistics of every route seen so far, slowest p99 first.
+func (r *Routes) Snapshot() []RouteStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]RouteStats, 0, len(r.routes))
+	for route, s := range r.routes {
+		sorted := slices.Clone(s.samples)
+		slices.Sort(sorted)
+
+		within := sort.Search(len(sorted), func(i int) bool { return sorted[i] > r.target })
+		stats = append(stats, RouteStats{
+			Route:     route,
+			Count:     s.count,
+			Errors:    s.errors,
+			P50Ms:     percentile(sorted, 0.50).Milliseconds(),
+			P90Ms:     percentile(sorted, 0.90).Milliseconds(),
+			P99Ms:     percentile(sorted, 0.99).Milliseconds(),
+			WithinSLO: float64(within) / float64(len(sorted)),
+		})
+	}
+
+	slices.SortFunc(stats, func(a, b RouteStats) int { return int(b.P99Ms - a.P99Ms) })
+	return stats
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*p)]
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streaming responses (NDJSON) working through the wrapper.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets websockets upgrade through the wrapper.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
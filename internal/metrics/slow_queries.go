@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// slowQueriesKept is how many of the latest slow queries are kept.
+const slowQueriesKept = 100
+
+type SlowQuery struct {
+	Name     string    `json:"name"`
+	Query    string    `json:"query"`
+	Args     []string  `json:"args"`
+	Duration int64     `json:"duration_ms"`
+	At       time.Time `json:"at"`
+}
+
+// SlowQueries wraps a database.DBTX and remembers the queries that took
+// longer than the threshold. Arguments are reduced to their types, so the log
+// holds no user data.
+type SlowQueries struct {
+	// Logger, when set, gets a warning for every slow query.
+	Logger *slog.Logger
+
+	db        database.DBTX
+	threshold time.Duration
+
+	mu      sync.Mutex
+	queries []SlowQuery
+	next    int
+}
+
+func NewSlowQueries(db database.DBTX, threshold time.Duration) *SlowQueries {
+	return &SlowQueries{db: db, threshold: threshold}
+}
+
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+func (s *SlowQueries) observe(query string, args []interface{}, start time.Time) {
+	d := time.Since(start)
+	if d < s.threshold {
+		return
+	}
+
+	name := ""
+	if m := queryName.FindStringSubmatch(query); m != nil {
+		name = m[1]
+	}
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = fmt.Sprintf("<%T>", arg)
+	}
+
+	entry := SlowQuery{
+		Name:     name,
+		Query:    query,
+		Args:     redacted,
+		Duration: d.Milliseconds(),
+		At:       start,
+	}
+	if s.Logger != nil {
+		s.Logger.Warn("Slow query", "name", name, "duration_ms", entry.Duration)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queries) < slowQueriesKept {
+		s.queries = append(s.queries, entry)
+	} else {
+		s.queries[s.next] = entry
+		s.next = (s.next + 1) % slowQueriesKept
+	}
+}
+
+// Snapshot returns the kept slow queries, newest first.
+func (s *SlowQueries) Snapshot() []SlowQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queries := make([]SlowQuery, 0, len(s.queries))
+	for i := len(s.queries) - 1; i >= 0; i-- {
+		queries = append(queries, s.queries[(s.next+i)%len(s.queries)])
+	}
+	return queries
+}
+
+func (s *SlowQueries) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer s.observe(query, args, time.Now())
+	return s.db.ExecContext(ctx, query, args...)
+}
+
+func (s *SlowQueries) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return s.db.PrepareContext(ctx, query)
+}
+
+func (s *SlowQueries) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer s.observe(query, args, time.Now())
+	return s.db.QueryContext(ctx, query, args...)
+}
+
+func (s *SlowQueries) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer s.observe(query, args, time.Now())
+	return s.db.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx begins a transaction on the wrapped database, see WrapTx.
+func (s *SlowQueries) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	beginner, ok := s.db.(database.TxBeginner)
+	if !ok {
+		return nil, fmt.Errorf("metrics: can't begin a transaction on %T", s.db)
+	}
+	return beginner.BeginTx(ctx, opts)
+}
+
+// WrapTx remembers the slow queries of the transaction like those run outside
+// of one.
+func (s *SlowQueries) WrapTx(tx *sql.Tx) database.DBTX {
+	return &slowQueriesTx{s: s, tx: tx}
+}
+
+type slowQueriesTx struct {
+	s  *SlowQueries
+	tx *sql.Tx
+}
+
+func (t *slowQueriesTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer t.s.observe(query, args, time.Now())
+	return t.tx.ExecContext(ctx, query, args...)
+}
+
+func (t *slowQueriesTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return t.tx.PrepareContext(ctx, query)
+}
+
+func (t *slowQueriesTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer t.s.observe(query, args, time.Now())
+	return t.tx.QueryContext(ctx, query, args...)
+}
+
+func (t *slowQueriesTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer t.s.observe(query, args, time.Now())
+	return t.tx.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,204 @@
+// Package migrate applies the goose migrations of sql/schema at startup.
+//
+// It understands the subset of goose the schema uses: files named
+// <version>_<name>.sql with "-- +goose Up" and "-- +goose Down" sections,
+// each applied in a transaction. Applied versions are recorded in goose's own
+// goose_db_version table, so the goose command line tool and this package can
+// be used on the same database interchangeably.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	upMarker   = "-- +goose Up"
+	downMarker = "-- +goose Down"
+)
+
+// Migration is one file of the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Record is a row of the version table, an applied or rolled back migration.
+type Record struct {
+	Version   int64     `json:"version"`
+	IsApplied bool      `json:"is_applied"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Load reads the migrations in the root of fsys, ordered by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	seen := map[int64]string{}
+	for _, name := range names {
+		prefix, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrate: %s: name must be <version>_<name>.sql", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: %s: invalid version", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrate: %s and %s have the same version", other, name)
+		}
+		seen[version] = name
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := split(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %s: %w", name, err)
+		}
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(rest, path.Ext(rest)),
+			Up:      up,
+			Down:    down,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func split(script string) (string, string, error) {
+	upAt := strings.Index(script, upMarker)
+	if upAt < 0 {
+		return "", "", errors.New("no " + upMarker + " section")
+	}
+	up := script[upAt+len(upMarker):]
+
+	down := ""
+	if downAt := strings.Index(up, downMarker); downAt >= 0 {
+		down = up[downAt+len(downMarker):]
+		up = up[:downAt]
+	}
+
+	return strings.TrimSpace(up), strings.TrimSpace(down), nil
+}
+
+// ensureVersionTable creates the version table like goose does.
+func ensureVersionTable(ctx context.Context, db *sql.DB) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT to_regclass('goose_db_version') IS NOT NULL`).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `CREATE TABLE goose_db_version (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL default now(),
+		PRIMARY KEY(id)
+	)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true)`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// History returns every migration applied or rolled back, oldest first.
+func History(ctx context.Context, db *sql.DB) ([]Record, error) {
+	if err := ensureVersionTable(ctx, db); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version_id, is_applied, tstamp FROM goose_db_version
+		WHERE version_id > 0 ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []Record
+	for rows.Next() {
+		var record Record
+		var tstamp sql.NullTime
+		if err := rows.Scan(&record.Version, &record.IsApplied, &tstamp); err != nil {
+			return nil, err
+		}
+		record.Timestamp = tstamp.Time
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Pending returns the migrations that aren't applied, in the order they need
+// to be. Like goose, a version counts as applied when its last record says so.
+func Pending(ctx context.Context, db *sql.DB, migrations []Migration) ([]Migration, error) {
+	history, err := History(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int64]bool{}
+	for _, record := range history {
+		applied[record.Version] = record.IsApplied
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Apply runs the up section of the migration and records it, in one
+// transaction.
+func Apply(ctx context.Context, db *sql.DB, migration Migration) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if migration.Up != "" {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)`, migration.Version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
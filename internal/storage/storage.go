@@ -0,0 +1,110 @@
+// Package storage keeps large blobs (article snapshots, images, exports, raw
+// feed archives) out of Postgres, either on local disk or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// NewFromEnv builds the store selected by STORAGE_BACKEND ("local" by default, or "s3").
+func NewFromEnv() (Store, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	switch backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "data"
+		}
+		return NewLocal(dir)
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			UseSSL:    os.Getenv("S3_USE_SSL") != "false",
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND: %s", backend)
+	}
+}
+
+// RetentionPolicy removes objects under Prefix once they are older than MaxAge.
+type RetentionPolicy struct {
+	Prefix string
+	MaxAge time.Duration
+}
+
+// ParseRetentionPolicies parses a list like "snapshots/=168h,exports/=24h".
+func ParseRetentionPolicies(s string) ([]RetentionPolicy, error) {
+	var policies []RetentionPolicy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		prefix, age, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention policy: %s", part)
+		}
+
+		maxAge, err := time.ParseDuration(age)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention policy %s: %w", part, err)
+		}
+
+		policies = append(policies, RetentionPolicy{Prefix: prefix, MaxAge: maxAge})
+	}
+
+	return policies, nil
+}
+
+// Cleanup deletes every object that has outlived its retention policy and
+// returns how many were removed.
+func Cleanup(ctx context.Context, store Store, policies []RetentionPolicy) (int, error) {
+	deleted := 0
+	for _, policy := range policies {
+		objects, err := store.List(ctx, policy.Prefix)
+		if err != nil {
+			return deleted, err
+		}
+
+		cutoff := time.Now().Add(-policy.MaxAge)
+		for _, object := range objects {
+			if object.LastModified.After(cutoff) {
+				continue
+			}
+
+			err := store.Delete(ctx, object.Key)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return deleted, err
+			}
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
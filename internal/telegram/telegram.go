@@ -0,0 +1,133 @@
+// Package telegram is a client for the few Telegram Bot API methods the
+// aggregator's bot uses, see https://core.telegram.org/bots/api.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SecretHeader carries the secret_token given to SetWebhook on the updates
+// Telegram posts.
+const SecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID      int64    `json:"message_id"`
+	Chat           Chat     `json:"chat"`
+	Text           string   `json:"text"`
+	ReplyToMessage *Message `json:"reply_to_message"`
+}
+
+type Chat struct {
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// Error is an error the Bot API answered with. Code is its HTTP status, 403
+// means the bot was blocked or removed from the chat.
+type Error struct {
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram: %d %s", e.Code, e.Description)
+}
+
+type Bot struct {
+	token  string
+	apiURL string
+	client *http.Client
+}
+
+func NewBot(token string) *Bot {
+	return &Bot{
+		token:  token,
+		apiURL: "https://api.telegram.org",
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetMe returns the bot's own user, whose username makes the t.me links.
+func (b *Bot) GetMe(ctx context.Context) (User, error) {
+	var me User
+	err := b.call(ctx, "getMe", struct{}{}, &me)
+	return me, err
+}
+
+// SetWebhook has Telegram post the bot's updates to webhookURL, with secret in
+// SecretHeader.
+func (b *Bot) SetWebhook(ctx context.Context, webhookURL, secret string) error {
+	return b.call(ctx, "setWebhook", map[string]interface{}{
+		"url":             webhookURL,
+		"secret_token":    secret,
+		"allowed_updates": []string{"message"},
+	}, nil)
+}
+
+// SendMessage sends text in Telegram's HTML flavor to the chat.
+func (b *Bot) SendMessage(ctx context.Context, chatID int64, text string) (Message, error) {
+	var msg Message
+	err := b.call(ctx, "sendMessage", map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       text,
+		"parse_mode": "HTML",
+	}, &msg)
+	return msg, err
+}
+
+func (b *Bot) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiURL+"/bot"+b.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// the url holds the token, keep it out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("telegram: decoding %s: %w", method, err)
+	}
+	if !reply.OK {
+		return &Error{Code: resp.StatusCode, Description: reply.Description}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
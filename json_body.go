@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxJSONBody is how large a JSON request body may be, endpoints that take
+// more, like pushing items, have their own limit.
+const maxJSONBody = 1 << 20
+
+// decodeJSONBody decodes the body of the request into v, writing the error
+// response itself when that fails. The body has to be sent as
+// application/json, be at most maxJSONBody and only have fields v knows, the
+// error names the field that is wrong.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeJSONBodyLimit(w, r, v, maxJSONBody)
+}
+
+// decodeJSONBodyLimit is decodeJSONBody with a limit of its own.
+func decodeJSONBodyLimit(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondWithErrorCode(w, 415, "unsupported_media_type", "Content-Type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errTrailingJSON
+	}
+	if err != nil {
+		respondWithJSONBodyError(w, err)
+		return false
+	}
+
+	return true
+}
+
+var errTrailingJSON = errors.New("Body must be a single JSON value")
+
+func respondWithJSONBodyError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondWithErrorCode(w, 413, "body_too_large", fmt.Sprintf("Body larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		respondWithErrorCode(w, 400, "invalid_body", "Body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondWithErrorCode(w, 400, "invalid_json", "Body ends in the middle of a JSON value")
+	case errors.As(err, &syntaxErr):
+		respondWithErrorCode(w, 400, "invalid_json", fmt.Sprintf("Invalid JSON at byte %d", syntaxErr.Offset))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		respondWithFieldError(w, 400, "invalid_field", typeErr.Field, fmt.Sprintf("Field %q must be %s", typeErr.Field, jsonKind(typeErr.Type)))
+	case errors.As(err, &typeErr):
+		respondWithErrorCode(w, 400, "invalid_body", fmt.Sprintf("Body must be %s", jsonKind(typeErr.Type)))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for it
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		respondWithFieldError(w, 400, "unknown_field", field, fmt.Sprintf("Unknown field %q", field))
+	case errors.Is(err, errTrailingJSON):
+		respondWithErrorCode(w, 400, "invalid_json", err.Error())
+	default:
+		// errors of UnmarshalJSON methods
+		respondWithErrorCode(w, 400, "invalid_body", err.Error())
+	}
+}
+
+// jsonKind names the JSON value that decodes into t.
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
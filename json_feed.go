@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// jsonFeedVersion is the version of the JSON Feed format the feeds are
+// rendered in, see https://www.jsonfeed.org/version/1.1/.
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Icon        string           `json:"icon,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title,omitempty"`
+	ContentHTML   string               `json:"content_html"`
+	Summary       string               `json:"summary,omitempty"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished *time.Time           `json:"date_published,omitempty"`
+	DateModified  *time.Time           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Tags          []string             `json:"tags,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+	// Source is an extension naming the feed of the item in timelines.
+	Source *jsonFeedSource `json:"_source,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL               string `json:"url"`
+	MimeType          string `json:"mime_type"`
+	SizeInBytes       int64  `json:"size_in_bytes,omitempty"`
+	DurationInSeconds int32  `json:"duration_in_seconds,omitempty"`
+}
+
+type jsonFeedSource struct {
+	FeedID  uuid.UUID `json:"feed_id"`
+	Name    string    `json:"name"`
+	FeedURL string    `json:"feed_url"`
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/json_feed
+
+Renders the latest posts of a feed in the JSON Feed 1.1 format. Public feeds
+are open to everyone, other feeds only to their owner and followers, with the
+Authorization header.
+
+limit picks how many posts, 50 by default and at most 200.
+*/
+func getFeedJSONFeedHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		limit, err := feedLimit(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		context := r.Context()
+		feed, err := apiConfig.DB.GetFeedByID(context, feedID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+
+		public, err := apiConfig.DB.IsPublicFeed(context, feed.ID)
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+		if !public {
+			user, scopes, ok := apiConfig.authenticate(w, r)
+			if !ok {
+				return
+			}
+			if !scopesAllow(scopes, r.Method, routeWriteScope(r.URL.Path)) {
+				respondWithErrorCode(w, 403, "insufficient_scope", "Insufficient scope")
+				return
+			}
+
+			follows, err := followsFeed(context, apiConfig.DB, user.ID, feed.ID)
+			if err != nil {
+				httpLog.Error("Error getting feed follows", "err", err)
+				respondWithError(w, 500, "Error getting feed")
+				return
+			}
+			if feed.UserID != user.ID && !follows {
+				// the same as a missing feed, to not tell which exist
+				respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+				return
+			}
+		}
+
+		posts, err := apiConfig.DB.GetPostsPageByFeed(context, database.GetPostsPageByFeedParams{
+			FeedID: feed.ID,
+			Limit:  int32(limit),
+		})
+		if err != nil {
+			httpLog.Error("Error getting posts", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+
+		doc := jsonFeed{
+			Version: jsonFeedVersion,
+			Title:   feed.Name,
+			FeedURL: apiConfig.BaseURL + "/v1/feeds/" + feed.ID.String() + "/json_feed",
+			Icon:    apiConfig.BaseURL + "/v1/feeds/" + feed.ID.String() + "/icon",
+			Items:   []jsonFeedItem{},
+		}
+		if isWebURL(feed.Url) {
+			doc.HomePageURL = feed.Url
+		}
+		for _, post := range posts {
+			doc.Items = append(doc.Items, jsonFeedPost(post))
+		}
+
+		respondWithJSONFeed(w, doc, public)
+	}
+}
+
+/*
+Endpoint: GET /v1/users/me/feed.json
+
+Renders the latest posts of the user in the JSON Feed 1.1 format, with the
+feed of every item in _source. Takes the feed token and limit like
+GET /v1/users/me/feed.xml, the token also in the path as
+/v1/users/me/feed/{token}.json.
+*/
+func getPersonalJSONFeedHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := apiConfig.personalFeedUser(w, r)
+		if !ok {
+			return
+		}
+
+		limit, err := feedLimit(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		posts, err := apiConfig.DB.GetPostsPageByUser(r.Context(), database.GetPostsPageByUserParams{
+			UserID: user.ID,
+			Limit:  int32(limit),
+		})
+		if err != nil {
+			httpLog.Error("Error getting posts", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+
+		doc := jsonFeed{
+			Version:     jsonFeedVersion,
+			Title:       "Posts of " + user.Name,
+			FeedURL:     apiConfig.BaseURL + "/v1/users/me/feed.json",
+			Description: "The latest posts of the feeds " + user.Name + " follows",
+			Authors:     []jsonFeedAuthor{{Name: user.Name}},
+			Items:       []jsonFeedItem{},
+		}
+		for _, row := range posts {
+			item := jsonFeedPost(database.Post{
+				ID:           row.ID,
+				CreatedAt:    row.CreatedAt,
+				UpdatedAt:    row.UpdatedAt,
+				Title:        row.Title,
+				Url:          row.Url,
+				Description:  row.Description,
+				PublishedAt:  row.PublishedAt,
+				Content:      row.Content,
+				Author:       row.Author,
+				Categories:   row.Categories,
+				Enclosures:   row.Enclosures,
+				ImageUrl:     row.ImageUrl,
+				Duration:     row.Duration,
+				ThumbnailUrl: row.ThumbnailUrl,
+			})
+			item.Source = &jsonFeedSource{FeedID: row.FeedID, Name: row.Name, FeedURL: row.Url_2}
+			doc.Items = append(doc.Items, item)
+		}
+
+		respondWithJSONFeed(w, doc, false)
+	}
+}
+
+// jsonFeedPost renders a post as an item. Items need content, the description
+// stands in for posts without any.
+func jsonFeedPost(post database.Post) jsonFeedItem {
+	item := jsonFeedItem{
+		ID:          "urn:uuid:" + post.ID.String(),
+		URL:         post.Url,
+		Title:       post.Title,
+		ContentHTML: post.Content,
+		Tags:        post.Categories,
+	}
+	if item.ContentHTML == "" {
+		item.ContentHTML = post.Description
+	} else if post.Description != post.Content {
+		item.Summary = htmlText(post.Description)
+	}
+	if post.PublishedAt.Valid {
+		published := post.PublishedAt.Time.UTC()
+		item.DatePublished = &published
+	}
+	if post.UpdatedAt.Valid {
+		modified := post.UpdatedAt.Time.UTC()
+		item.DateModified = &modified
+	}
+	if post.Author != "" {
+		item.Authors = []jsonFeedAuthor{{Name: post.Author}}
+	}
+	if post.ImageUrl.Valid {
+		item.Image = post.ImageUrl.String
+	} else if post.ThumbnailUrl.Valid {
+		item.Image = post.ThumbnailUrl.String
+	}
+
+	var enclosures []postEnclosure
+	if err := json.Unmarshal(post.Enclosures, &enclosures); err == nil {
+		for _, enclosure := range enclosures {
+			attachment := jsonFeedAttachment{
+				URL:         enclosure.URL,
+				MimeType:    enclosure.Type,
+				SizeInBytes: enclosure.Length,
+			}
+			if attachment.MimeType == "" {
+				attachment.MimeType = "application/octet-stream"
+			}
+			// the duration of a post is the one of its media
+			if post.Duration.Valid && len(enclosures) == 1 {
+				attachment.DurationInSeconds = post.Duration.Int32
+			}
+			item.Attachments = append(item.Attachments, attachment)
+		}
+	}
+
+	return item
+}
+
+// respondWithJSONFeed writes a JSON Feed with its own content type, which
+// also keeps the field names middleware from renaming its fields.
+func respondWithJSONFeed(w http.ResponseWriter, doc jsonFeed, public bool) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		httpLog.Error("Error rendering feed", "err", err)
+		respondWithError(w, 500, "Error getting feed")
+		return
+	}
+
+	cacheControl := "private, max-age=300"
+	if public {
+		cacheControl = "public, max-age=300"
+	}
+	w.Header().Set("Content-Type", "application/feed+json")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+// followsFeed tells whether the user follows the feed.
+func followsFeed(ctx context.Context, db *database.Queries, userID, feedID uuid.UUID) (bool, error) {
+	feedFollows, err := db.GetUserFeedFollows(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, feedFollow := range feedFollows {
+		if feedFollow.FeedID == feedID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
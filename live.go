@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const (
+	liveWriteWait  = 10 * time.Second
+	livePongWait   = 60 * time.Second
+	livePingPeriod = livePongWait * 9 / 10
+	// liveMaxMessage bounds the commands clients send
+	liveMaxMessage = 4096
+	// liveSendBuffer is how many messages a connection can fall behind before
+	// it's dropped
+	liveSendBuffer = 64
+
+	liveCommandPing      = "ping"
+	liveCommandSubscribe = "subscribe"
+	liveCommandMarkRead  = "mark_read"
+)
+
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// liveHub holds the open websocket connections of this instance by user.
+// Events only reach the connections of the instance they happen on.
+type liveHub struct {
+	mu     sync.Mutex
+	conns  map[uuid.UUID]map[*liveConn]struct{}
+	closed bool
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{conns: map[uuid.UUID]map[*liveConn]struct{}{}}
+}
+
+func (h *liveHub) add(c *liveConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+
+	if h.conns[c.userID] == nil {
+		h.conns[c.userID] = map[*liveConn]struct{}{}
+	}
+	h.conns[c.userID][c] = struct{}{}
+	return true
+}
+
+func (h *liveHub) remove(c *liveConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns[c.userID], c)
+	if len(h.conns[c.userID]) == 0 {
+		delete(h.conns, c.userID)
+	}
+}
+
+// empty tells whether no one is connected, to skip looking up who an event
+// is for.
+func (h *liveHub) empty() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.conns) == 0
+}
+
+// publish sends the event to the connections of the users that subscribed to
+// it. feedID is the feed the event is about, or uuid.Nil.
+func (h *liveHub) publish(userIDs []uuid.UUID, feedID uuid.UUID, event webhookEvent) {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		httpLog.Error("Error encoding live event", "err", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, userID := range userIDs {
+		for c := range h.conns[userID] {
+			if c.wants(event.Type, feedID) && !c.enqueue(msg) {
+				c.close(websocket.ClosePolicyViolation, "too slow to keep up")
+			}
+		}
+	}
+}
+
+// Close closes every connection, for shutdown. Hijacked connections aren't
+// waited for by the server.
+func (h *liveHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for _, conns := range h.conns {
+		for c := range conns {
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		}
+	}
+}
+
+type liveConn struct {
+	userID uuid.UUID
+	// scopes of the credential the connection was opened with, see
+	// requestScopes
+	scopes []string
+	ws     *websocket.Conn
+	send   chan []byte
+
+	closeOnce   sync.Once
+	closed      chan struct{}
+	closeCode   int
+	closeReason string
+
+	// the subscription filters, empty for everything
+	mu      sync.Mutex
+	events  []string
+	feedIDs []uuid.UUID
+}
+
+func newLiveConn(userID uuid.UUID, scopes []string, ws *websocket.Conn) *liveConn {
+	return &liveConn{
+		userID: userID,
+		scopes: scopes,
+		ws:     ws,
+		send:   make(chan []byte, liveSendBuffer),
+		closed: make(chan struct{}),
+	}
+}
+
+func (c *liveConn) subscribe(events []string, feedIDs []uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = events
+	c.feedIDs = feedIDs
+}
+
+// wants tells whether the filters let the event through. The feed filter
+// leaves out events about other feeds, events that aren't about a feed pass.
+func (c *liveConn) wants(eventType string, feedID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.events) > 0 && !slices.Contains(c.events, eventType) {
+		return false
+	}
+	if len(c.feedIDs) > 0 && feedID != uuid.Nil && !slices.Contains(c.feedIDs, feedID) {
+		return false
+	}
+	return true
+}
+
+// enqueue queues a message without blocking, false when the queue is full.
+func (c *liveConn) enqueue(msg []byte) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *liveConn) reply(reply liveReply) {
+	msg, err := json.Marshal(reply)
+	if err != nil {
+		httpLog.Error("Error encoding live reply", "err", err)
+		return
+	}
+	if !c.enqueue(msg) {
+		c.close(websocket.ClosePolicyViolation, "too slow to keep up")
+	}
+}
+
+func (c *liveConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.closed)
+	})
+}
+
+// writeLoop is the only writer of the connection: it sends the queued
+// messages and the pings, and the close message once the connection closes.
+func (c *liveConn) writeLoop() {
+	ticker := time.NewTicker(livePingPeriod)
+	defer ticker.Stop()
+	defer c.ws.Close()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.closed:
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			c.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(liveWriteWait))
+			return
+		}
+	}
+}
+
+// liveCommand is a message from a client:
+//
+//	{"type": "ping", "id": "1"}
+//	{"type": "subscribe", "events": ["post.created"], "feed_ids": ["..."]}
+//	{"type": "mark_read", "post_id": "..."}
+//
+// id is optional and comes back in the reply.
+type liveCommand struct {
+	ID      string      `json:"id"`
+	Type    string      `json:"type"`
+	Events  []string    `json:"events"`
+	FeedIDs []uuid.UUID `json:"feed_ids"`
+	PostID  uuid.UUID   `json:"post_id"`
+}
+
+// liveReply answers a command with "pong", "ok" or "error". Events have
+// dotted types, so the two don't mix up.
+type liveReply struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+/*
+Endpoint: GET /v1/ws
+
+# This is an authenticated endpoint
+
+Upgrades to a websocket that receives the events of the user as they happen,
+the same events and payloads as webhooks: new posts of followed feeds, feeds
+failing, follows created and deleted, bookmarks. Takes filters in the query,
+events and feed_ids as comma separated lists, which the subscribe command
+replaces later on.
+
+Clients send commands as JSON, see liveCommand: ping, subscribe and mark_read,
+which sets the reading progress of a post to 100 and needs the posts:write
+scope like PUT /v1/posts/{post_id}/progress does. Every command is answered
+with pong, ok or error. The server pings every 54 seconds and closes
+connections that don't answer within a minute, or that fall behind.
+*/
+func liveHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		events, feedIDs, err := parseLiveFilters(r.URL.Query().Get("events"), r.URL.Query().Get("feed_ids"))
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		ws, err := liveUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// the upgrader answered the request already
+			return
+		}
+
+		c := newLiveConn(user.ID, requestScopes(r), ws)
+		c.subscribe(events, feedIDs)
+		if !apiConfig.Live.add(c) {
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		}
+		defer apiConfig.Live.remove(c)
+
+		go c.writeLoop()
+		c.readLoop(apiConfig)
+	}
+}
+
+func (c *liveConn) readLoop(apiConfig apiConfig) {
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.ws.SetReadLimit(liveMaxMessage)
+	c.ws.SetReadDeadline(time.Now().Add(livePongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+
+	for {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				httpLog.Error("Error reading websocket", "user_id", c.userID, "err", err)
+			}
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(livePongWait))
+
+		var cmd liveCommand
+		if err := json.Unmarshal(msg, &cmd); err != nil {
+			c.reply(liveReply{Type: "error", Message: "Error decoding command"})
+			continue
+		}
+		c.reply(c.handle(apiConfig, cmd))
+	}
+}
+
+func (c *liveConn) handle(apiConfig apiConfig, cmd liveCommand) liveReply {
+	switch cmd.Type {
+	case liveCommandPing:
+		return liveReply{Type: "pong", ID: cmd.ID}
+	case liveCommandSubscribe:
+		if err := validateWebhookEvents(cmd.Events); err != nil {
+			return liveReply{Type: "error", ID: cmd.ID, Message: err.Error()}
+		}
+		c.subscribe(cmd.Events, cmd.FeedIDs)
+		return liveReply{Type: "ok", ID: cmd.ID}
+	case liveCommandMarkRead:
+		if !scopesAllow(c.scopes, http.MethodPut, scopePostsWrite) {
+			return liveReply{Type: "error", ID: cmd.ID, Message: "Insufficient scope"}
+		}
+		err := markPostRead(context.Background(), apiConfig.DB, c.userID, cmd.PostID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return liveReply{Type: "error", ID: cmd.ID, Message: "Post not found"}
+		}
+		if err != nil {
+			httpLog.Error("Error saving reading progress", "err", err)
+			return liveReply{Type: "error", ID: cmd.ID, Message: "Error saving progress"}
+		}
+		return liveReply{Type: "ok", ID: cmd.ID}
+	}
+	return liveReply{Type: "error", ID: cmd.ID, Message: "Unknown command: " + cmd.Type}
+}
+
+func markPostRead(ctx context.Context, db *database.Queries, userID, postID uuid.UUID) error {
+	_, err := db.GetPostByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.UpsertReadingProgress(ctx, database.UpsertReadingProgressParams{
+		UserID:    userID,
+		PostID:    postID,
+		UpdatedAt: time.Now(),
+		Percent:   100,
+	})
+	return err
+}
+
+func parseLiveFilters(events, feedIDs string) ([]string, []uuid.UUID, error) {
+	var eventTypes []string
+	for _, event := range strings.Split(events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			eventTypes = append(eventTypes, event)
+		}
+	}
+	if err := validateWebhookEvents(eventTypes); err != nil {
+		return nil, nil, err
+	}
+
+	var ids []uuid.UUID
+	for _, s := range strings.Split(feedIDs, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, nil, errors.New("Invalid feed id: " + s)
+		}
+		ids = append(ids, id)
+	}
+
+	return eventTypes, ids, nil
+}
+
+// publishLive sends the event to the websockets of userID, or of the
+// followers of feedID for events about a feed.
+func publishLive(ctx context.Context, apiConfig apiConfig, feedID, userID uuid.UUID, event webhookEvent) {
+	if apiConfig.Live == nil || apiConfig.Live.empty() {
+		return
+	}
+
+	userIDs := []uuid.UUID{userID}
+	if userID == uuid.Nil {
+		var err error
+		userIDs, err = apiConfig.DB.GetFeedFollowerIDs(ctx, feedID)
+		if err != nil {
+			httpLog.Error("Error getting followers", "feed_id", feedID, "err", err)
+			return
+		}
+	}
+
+	apiConfig.Live.publish(userIDs, feedID, event)
+}
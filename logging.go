@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// The loggers of the parts of the server, their lines carry the subsystem.
+// configureLogging replaces them, until then they log to the default logger.
+var (
+	httpLog          = slog.Default().With("subsystem", "http")
+	fetcherLog       = slog.Default().With("subsystem", "fetcher")
+	dbLog            = slog.Default().With("subsystem", "db")
+	webhookLog       = slog.Default().With("subsystem", "webhooks")
+	telegramLog      = slog.Default().With("subsystem", "telegram")
+	notificationsLog = slog.Default().With("subsystem", "notifications")
+)
+
+// configureLogging sets up logging from LOG_LEVEL, one of debug, info (the
+// default), warn and error, and LOG_FORMAT, text (the default) or json.
+func configureLogging() error {
+	var level slog.Level
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid LOG_LEVEL: %s (must be debug, info, warn or error)", value)
+		}
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format := strings.ToLower(os.Getenv("LOG_FORMAT")); format {
+	case "", "text":
+		handler = slog.NewTextHandler(os.Stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT: %s (must be text or json)", format)
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+	httpLog = logger.With("subsystem", "http")
+	fetcherLog = logger.With("subsystem", "fetcher")
+	dbLog = logger.With("subsystem", "db")
+	webhookLog = logger.With("subsystem", "webhooks")
+	telegramLog = logger.With("subsystem", "telegram")
+	notificationsLog = logger.With("subsystem", "notifications")
+
+	return nil
+}
+
+// fatal logs an error the server can't start or go on with, and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
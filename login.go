@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// accessTokenTTL is how long a JWT from POST /v1/login or POST
+	// /v1/refresh works, sessionTTL how long its refresh token does.
+	accessTokenTTL = 15 * time.Minute
+	sessionTTL     = 30 * 24 * time.Hour
+	// sessionUserAgentLength is how much of the User-Agent a session keeps
+	// to tell devices apart.
+	sessionUserAgentLength = 256
+
+	passwordMinLength = 8
+	// passwordMaxLength is as much as bcrypt looks at.
+	passwordMaxLength = 72
+
+	loginRateInterval = 10 * time.Second
+	loginRateBurst    = 5
+)
+
+var errInvalidLogin = errors.New("Invalid email or password")
+
+// dummyPasswordHash is compared against when there is no user with the email,
+// so a login takes as long whether the email is known or not.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+func validatePassword(password string) error {
+	if len(password) < passwordMinLength {
+		return errors.New("Password is too short, at least 8 characters")
+	}
+	if len(password) > passwordMaxLength {
+		return errors.New("Password is too long, at most 72 bytes")
+	}
+	return nil
+}
+
+func hashPassword(password string) (sql.NullString, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+// jwtSecretFromEnv is the key access tokens are signed with. Without
+// JWT_SECRET it's random, access tokens then stop working on a restart and
+// clients refresh them.
+func jwtSecretFromEnv() ([]byte, error) {
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+
+	slog.Warn("JWT_SECRET isn't set, access tokens only last until a restart")
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	return secret, err
+}
+
+// authenticateBearer resolves an access token from POST /v1/login, which has
+// full access and comes back with nil scopes, or an OAuth token. Unknown
+// tokens are sql.ErrNoRows.
+func (cfg *apiConfig) authenticateBearer(ctx context.Context, token string) (database.User, []string, error) {
+	if !jwt.LooksLike(token) {
+		return cfg.authenticateOAuthToken(ctx, token)
+	}
+
+	claims, err := jwt.Verify(token, cfg.JWTSecret, time.Now())
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return database.User{}, nil, jwt.ErrInvalid
+	}
+
+	user, err := cfg.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	return user, nil, nil
+}
+
+type sessionTokens struct {
+	AccessToken      string    `json:"access_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// startSession starts a session of the user on the device of the request, see
+// continueSession.
+func startSession(ctx context.Context, apiConfig apiConfig, r *http.Request, userID uuid.UUID) (sessionTokens, error) {
+	return continueSession(ctx, apiConfig, r, database.Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	})
+}
+
+// continueSession stores a new refresh token for the session and signs an
+// access token to go with it. The session keeps its id and when it started,
+// so GET /v1/sessions lists one session per login.
+func continueSession(ctx context.Context, apiConfig apiConfig, r *http.Request, session database.Session) (sessionTokens, error) {
+	userID := session.UserID
+	now := time.Now()
+	expiresAt := now.Add(accessTokenTTL)
+	accessToken, err := jwt.Sign(jwt.Claims{
+		Subject:   userID.String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, apiConfig.JWTSecret)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	refreshToken, err := generateToken()
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	err = apiConfig.DB.CreateSession(ctx, database.CreateSessionParams{
+		ID:         session.ID,
+		TokenHash:  hashToken(refreshToken),
+		UserID:     userID,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(sessionTTL),
+		UserAgent:  truncateTitle(r.UserAgent(), sessionUserAgentLength),
+	})
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	return sessionTokens{
+		AccessToken:      accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: now.Add(sessionTTL),
+	}, nil
+}
+
+/*
+Endpoint: POST /v1/login
+
+Logs in with the email and password of a user, as set when creating them with
+POST /v1/users or with PUT /v1/users/me/password:
+
+	{
+		"email": "jane@example.com",
+		"password": "..."
+	}
+
+Responds with an access token, a JWT that works like the user's API key for
+15 minutes, sent as Authorization: Bearer <token>, and a refresh token for
+POST /v1/refresh that lasts 30 days:
+
+	{
+		"access_token": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...",
+		"expires_at": "2024-01-02T15:19:05Z",
+		"refresh_token": "...",
+		"refresh_expires_at": "2024-02-01T15:04:05Z"
+	}
+
+A few attempts per user go through at once, then one every 10 seconds.
+*/
+func postLoginHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	limiter := newUserLimiter(loginRateInterval, loginRateBurst)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		type LoginRequest struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+
+		var req LoginRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		user, err := apiConfig.DB.GetUserByEmail(context, req.Email)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !user.PasswordHash.Valid) {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+			respondWithErrorCode(w, 401, "invalid_login", errInvalidLogin.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting user", "err", err)
+			respondWithError(w, 500, "Error logging in")
+			return
+		}
+
+		if !limiter.Allow(user.ID) {
+			w.Header().Set("Retry-After", "10")
+			respondWithError(w, 429, "Too many logins, try again later")
+			return
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(req.Password)) != nil {
+			respondWithErrorCode(w, 401, "invalid_login", errInvalidLogin.Error())
+			return
+		}
+
+		if !requestAllowedFrom(r, user.AllowedCidrs) {
+			respondWithError(w, 403, "Login is not allowed from this address")
+			return
+		}
+
+		tokens, err := startSession(context, apiConfig, r, user.ID)
+		if err != nil {
+			httpLog.Error("Error starting session", "err", err)
+			respondWithError(w, 500, "Error logging in")
+			return
+		}
+
+		respondWithJSON(w, 200, tokens)
+	}
+}
+
+/*
+Endpoint: POST /v1/refresh
+
+Trades a refresh token for a new access token, and a new refresh token; the
+old one stops working:
+
+	{
+		"refresh_token": "..."
+	}
+
+The response is the one of POST /v1/login.
+*/
+func postRefreshHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type RefreshRequest struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		var req RefreshRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		session, err := apiConfig.DB.ConsumeSession(context, hashToken(req.RefreshToken))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(session.ExpiresAt)) {
+			respondWithError(w, 401, "Invalid refresh token")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting session", "err", err)
+			respondWithError(w, 500, "Error refreshing")
+			return
+		}
+
+		tokens, err := continueSession(context, apiConfig, r, session)
+		if err != nil {
+			httpLog.Error("Error starting session", "err", err)
+			respondWithError(w, 500, "Error refreshing")
+			return
+		}
+
+		respondWithJSON(w, 200, tokens)
+	}
+}
+
+/*
+Endpoint: POST /v1/logout
+
+# This is an authenticated endpoint
+
+Revokes a refresh token from POST /v1/login. Its access tokens keep working
+until they expire:
+
+	{
+		"refresh_token": "..."
+	}
+*/
+func postLogoutHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type LogoutRequest struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		var req LogoutRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteSession(context, database.DeleteSessionParams{
+			TokenHash: hashToken(req.RefreshToken),
+			UserID:    user.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting session", "err", err)
+			respondWithError(w, 500, "Error logging out")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "refresh_token_not_found", "Refresh token not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: PUT /v1/users/me/password
+
+# This endpoint requires an API key
+
+Or an access token. Sets the password to log in with, for users with an
+email. Changing a password needs the current one and revokes all refresh
+tokens and password reset tokens, access tokens keep working until they
+expire:
+
+	{
+		"current_password": "...",
+		"password": "..."
+	}
+*/
+func putPasswordHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type PasswordRequest struct {
+			CurrentPassword string `json:"current_password"`
+			Password        string `json:"password"`
+		}
+
+		var req PasswordRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		if !user.Email.Valid {
+			respondWithError(w, 400, "A password needs an email to log in with")
+			return
+		}
+		if user.PasswordHash.Valid && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(req.CurrentPassword)) != nil {
+			respondWithError(w, 403, "Current password is wrong")
+			return
+		}
+		var v validation
+		v.check("password", validatePassword(req.Password))
+		if v.respond(w) {
+			return
+		}
+
+		passwordHash, err := hashPassword(req.Password)
+		if err != nil {
+			httpLog.Error("Error hashing password", "err", err)
+			respondWithError(w, 500, "Error setting password")
+			return
+		}
+
+		context := r.Context()
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			err := q.UpdateUserPassword(context, database.UpdateUserPasswordParams{
+				ID:           user.ID,
+				PasswordHash: passwordHash,
+			})
+			if err != nil {
+				return err
+			}
+			if err := q.DeleteUserPasswordResets(context, user.ID); err != nil {
+				return err
+			}
+			return q.DeleteUserSessions(context, user.ID)
+		})
+		if err != nil {
+			httpLog.Error("Error setting password", "err", err)
+			respondWithError(w, 500, "Error setting password")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
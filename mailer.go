@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+type mailer interface {
+	Send(to, subject, body string) error
+}
+
+// newMailerFromEnv sends mail through SMTP_HOST when it is set, otherwise
+// messages are only written to the log, which is enough for local development.
+func newMailerFromEnv() mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return logMailer{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "blogator@localhost"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return smtpMailer{
+		addr: host + ":" + port,
+		auth: auth,
+		from: from,
+	}
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func (m smtpMailer) Send(to, subject, body string) error {
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		body,
+	}, "\r\n")
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{to}, []byte(msg))
+}
+
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	notificationsLog.Info("Mail", "to", to, "subject", subject, "body", body)
+	return nil
+}
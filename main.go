@@ -6,44 +6,107 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/eventbus"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/jwt"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/metrics"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/storage"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/telegram"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
-	"github.com/mmcdole/gofeed"
+	"google.golang.org/grpc"
 )
 
 type apiConfig struct {
-	DB *database.Queries
+	DB      *database.Queries
+	Conn    *sql.DB
+	Storage storage.Store
+	Mailer  mailer
+	BaseURL string
+	// Events is nil unless an event bus is configured.
+	Events       eventbus.Publisher
+	EventsFormat string
+	AdminToken   string
+	Routes       *metrics.Routes
+	SlowQueries  *metrics.SlowQueries
+	// ImageProxySecret signs image proxy urls, the proxy is off without it.
+	ImageProxySecret []byte
+	// FeedCredentialsKey seals the credentials of private feeds, feeds can't
+	// have credentials without it.
+	FeedCredentialsKey []byte
+	// NewsletterDomain is the domain of the newsletter addresses, users can't
+	// get one without it. NewsletterSigningKey checks the inbound requests.
+	NewsletterDomain     string
+	NewsletterSigningKey []byte
+	// Live holds the websockets of /v1/ws.
+	Live *liveHub
+	// WebhookQueue is nudged when deliveries are queued.
+	WebhookQueue *webhookQueue
+	// Telegram is the bot, nil without TELEGRAM_BOT_TOKEN. TelegramSecret
+	// checks its updates, TelegramUsername makes the t.me links.
+	Telegram         *telegram.Bot
+	TelegramSecret   string
+	TelegramUsername string
+	// JWTSecret signs the access tokens of POST /v1/login.
+	JWTSecret []byte
+	// SocialProviders are the providers users can log in with, by name, see
+	// social_login.go. SocialRedirectURIs are where logins can end.
+	SocialProviders    map[string]*socialProvider
+	SocialRedirectURIs []string
 }
 
 type authedHandler func(http.ResponseWriter, *http.Request, database.User)
 
 func (cfg *apiConfig) authedHandler(handler authedHandler) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
+		user, scopes, ok := cfg.authenticate(w, r)
+		if !ok {
+			return
+		}
 
-		if auth == "" {
-			respondWithError(w, 401, "Unauthorized")
+		if !scopesAllow(scopes, r.Method, routeWriteScope(r.URL.Path)) {
+			respondWithErrorCode(w, 403, "insufficient_scope", "Insufficient scope")
 			return
 		}
 
-		apiKey, err := getApiKeyFromAuth(auth)
-		if err != nil {
-			respondWithError(w, 401, "Unauthorized")
+		handler(w, r.WithContext(context.WithValue(r.Context(), scopesKey{}, scopes)), user)
+	}
+}
+
+type scopesKey struct{}
+
+// requestScopes are the scopes of an authedHandler request, nil with the
+// user's own API key.
+func requestScopes(r *http.Request) []string {
+	scopes, _ := r.Context().Value(scopesKey{}).([]string)
+	return scopes
+}
+
+// apiKeyHandler only lets the user's own API key or access tokens through,
+// for endpoints a delegated OAuth token must never reach (e.g. minting more
+// tokens).
+func (cfg *apiConfig) apiKeyHandler(handler authedHandler) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, scopes, ok := cfg.authenticate(w, r)
+		if !ok {
 			return
 		}
 
-		user, err := cfg.DB.GetUserByApiKey(context.Background(), apiKey)
-		if err != nil {
-			respondWithError(w, 500, "Error getting user")
+		if scopes != nil {
+			respondWithError(w, 403, "This endpoint requires an API key")
 			return
 		}
 
@@ -51,10 +114,103 @@ func (cfg *apiConfig) authedHandler(handler authedHandler) func(http.ResponseWri
 	}
 }
 
+var errMissingCredential = errors.New("Missing credential")
+
+// isInvalidCredential tells whether authenticating failed on the credential,
+// which the client has to fix, rather than on the server.
+func isInvalidCredential(err error) bool {
+	return errors.Is(err, errMissingCredential) || errors.Is(err, sql.ErrNoRows) || errors.Is(err, jwt.ErrInvalid) || errors.Is(err, jwt.ErrExpired) || errors.Is(err, errTokenExpired)
+}
+
+// checkedCredential is the user behind the credential of a request, or why
+// there is none. rateLimitMiddleware checks it first and keeps it in the
+// context, so authenticate doesn't look it up again.
+type checkedCredential struct {
+	user   database.User
+	scopes []string
+	bearer bool
+	err    error
+}
+
+type checkedCredentialKey struct{}
+
+// checkCredential resolves the user behind the Authorization header, see
+// authenticate.
+func (cfg *apiConfig) checkCredential(r *http.Request) checkedCredential {
+	if checked, ok := r.Context().Value(checkedCredentialKey{}).(checkedCredential); ok {
+		return checked
+	}
+
+	scheme, credential, err := parseAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		return checkedCredential{err: errMissingCredential}
+	}
+
+	checked := checkedCredential{bearer: strings.EqualFold(scheme, "Bearer")}
+	if checked.bearer {
+		checked.user, checked.scopes, checked.err = cfg.authenticateBearer(r.Context(), credential)
+	} else {
+		checked.user, checked.scopes, checked.err = cfg.authenticateApiKey(r.Context(), credential)
+	}
+	return checked
+}
+
+// requestUserID is the user of the request on public endpoints, which show
+// them their private feeds too, or uuid.Nil without a valid credential.
+func (cfg *apiConfig) requestUserID(r *http.Request) uuid.UUID {
+	checked := cfg.checkCredential(r)
+	if checked.err != nil {
+		return uuid.Nil
+	}
+	return checked.user.ID
+}
+
+// respondUnauthorized responds 401 with the schemes the API takes.
+func respondUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `ApiKey, Bearer`)
+	respondWithError(w, 401, "Unauthorized")
+}
+
+// authenticate resolves the user behind the Authorization header, writing the
+// error response itself when that fails. The user's own API key and access
+// tokens from POST /v1/login grant full access and come back with nil scopes.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, r *http.Request) (database.User, []string, bool) {
+	checked := cfg.checkCredential(r)
+	if isInvalidCredential(checked.err) {
+		respondUnauthorized(w)
+		return database.User{}, nil, false
+	}
+	if checked.err != nil {
+		httpLog.Error("Error authenticating", "err", checked.err)
+		respondWithError(w, 500, "Error getting user")
+		return database.User{}, nil, false
+	}
+	user, scopes, bearer := checked.user, checked.scopes, checked.bearer
+
+	// access tokens from POST /v1/login stand in for the API key
+	if bearer && scopes == nil && !requestAllowedFrom(r, user.AllowedCidrs) {
+		respondWithError(w, 403, "Login is not allowed from this address")
+		return database.User{}, nil, false
+	}
+	if !bearer && !requestAllowedFrom(r, user.AllowedCidrs) {
+		respondWithError(w, 403, "API key is not allowed from this address")
+		return database.User{}, nil, false
+	}
+
+	setRequestUser(r, user.ID)
+	return user, scopes, true
+}
+
 func main() {
+	// first, LOG_LEVEL and LOG_FORMAT can come from .env too
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading properties")
+		fatal("Error loading properties")
+	}
+
+	if err := configureLogging(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	port := os.Getenv("PORT")
@@ -62,35 +218,296 @@ func main() {
 		port = "8080"
 	}
 
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
 	dbUrl := os.Getenv("DB_CONNECTION_STRING")
 
 	db, err := sql.Open("postgres", dbUrl)
 	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
+		fatal("Error opening database", "err", err)
+	}
+
+	migrationConfig, err := migrationConfigFromEnv()
+	if err != nil {
+		fatal("Error reading migration config", "err", err)
+	}
+	if migrationConfig.OnStart {
+		if err := migrateOnStart(context.Background(), db, migrationConfig); err != nil {
+			fatal("Error migrating database", "err", err)
+		}
+	}
+
+	sloTarget, err := envDuration("SLO_LATENCY_TARGET", 500*time.Millisecond, time.Millisecond)
+	if err != nil {
+		fatal("Error reading SLO target", "err", err)
+	}
+
+	slowQueryThreshold, err := envDuration("SLOW_QUERY_THRESHOLD", 200*time.Millisecond, 0)
+	if err != nil {
+		fatal("Error reading slow query threshold", "err", err)
+	}
+
+	slowQueries := metrics.NewSlowQueries(db, slowQueryThreshold)
+	slowQueries.Logger = dbLog
+	dbQueries := database.New(slowQueries)
+
+	blobStore, err := storage.NewFromEnv()
+	if err != nil {
+		fatal("Error opening storage", "err", err)
+	}
+
+	retentionPolicies, err := storage.ParseRetentionPolicies(os.Getenv("STORAGE_RETENTION"))
+	if err != nil {
+		fatal("Error parsing STORAGE_RETENTION", "err", err)
+	}
+
+	events, err := eventbus.NewFromEnv()
+	if err != nil {
+		fatal("Error connecting to event bus", "err", err)
 	}
 
-	dbQueries := database.New(db)
+	eventsFormat := os.Getenv("EVENT_BUS_FORMAT")
+	if eventsFormat == "" {
+		eventsFormat = webhookFormatJSON
+	}
+	if !slices.Contains(webhookFormats, eventsFormat) {
+		fatal("Invalid EVENT_BUS_FORMAT", "events_format", eventsFormat)
+	}
+
+	feedCredentialsKey, err := parseFeedCredentialsKey(os.Getenv("FEED_CREDENTIALS_KEY"))
+	if err != nil {
+		fatal("Error reading feed credentials key", "err", err)
+	}
+
+	chatNotificationInterval, err := envDuration("CHAT_NOTIFICATION_INTERVAL", time.Minute, 10*time.Second)
+	if err != nil {
+		fatal("Error reading CHAT_NOTIFICATION_INTERVAL", "err", err)
+	}
+
+	webhookQueueConfig, err := webhookQueueConfigFromEnv()
+	if err != nil {
+		fatal("Error reading webhook queue config", "err", err)
+	}
+
+	jwtSecret, err := jwtSecretFromEnv()
+	if err != nil {
+		fatal("Error reading JWT_SECRET", "err", err)
+	}
+
+	var telegramBot *telegram.Bot
+	var telegramUsername string
+	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
+		telegramBot = telegram.NewBot(token)
+		setupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		telegramUsername, err = setupTelegram(setupCtx, telegramBot, strings.TrimSuffix(baseURL, "/"), token)
+		cancel()
+		if err != nil {
+			// the bot still sends posts, Telegram keeps the previous webhook
+			telegramLog.Error("Error setting up the Telegram bot", "err", err)
+		}
+	}
 
 	apiConfig := apiConfig{
-		DB: dbQueries,
+		DB:                   dbQueries,
+		Conn:                 db,
+		Storage:              blobStore,
+		Mailer:               newMailerFromEnv(),
+		BaseURL:              strings.TrimSuffix(baseURL, "/"),
+		Events:               events,
+		EventsFormat:         eventsFormat,
+		AdminToken:           os.Getenv("ADMIN_TOKEN"),
+		ImageProxySecret:     []byte(os.Getenv("IMAGE_PROXY_SECRET")),
+		Routes:               metrics.NewRoutes(sloTarget),
+		SlowQueries:          slowQueries,
+		FeedCredentialsKey:   feedCredentialsKey,
+		NewsletterDomain:     strings.ToLower(os.Getenv("NEWSLETTER_DOMAIN")),
+		NewsletterSigningKey: []byte(os.Getenv("NEWSLETTER_SIGNING_KEY")),
+		Live:                 newLiveHub(),
+		WebhookQueue:         newWebhookQueue(webhookQueueConfig),
+		Telegram:             telegramBot,
+		TelegramSecret:       telegramWebhookSecret(os.Getenv("TELEGRAM_BOT_TOKEN")),
+		TelegramUsername:     telegramUsername,
+		JWTSecret:            jwtSecret,
+		SocialProviders:      socialProvidersFromEnv(),
+		SocialRedirectURIs:   socialRedirectURIsFromEnv(),
+	}
+
+	allowPrivateNetworks = os.Getenv("FETCH_ALLOW_PRIVATE_NETWORKS") == "true"
+	if err := configureFetchProxy(os.Getenv("FETCH_PROXY")); err != nil {
+		fatal("Error reading FETCH_PROXY", "err", err)
+	}
+
+	youtubeAPIKey = os.Getenv("YOUTUBE_API_KEY")
+
+	fetchConfig, err := fetcherConfigFromEnv()
+	if err != nil {
+		fatal("Error reading fetcher config", "err", err)
+	}
+
+	feedFetcher := newFetcher(apiConfig, fetchConfig)
+
+	guest, err := guestModeFromEnv()
+	if err != nil {
+		fatal("Error reading guest mode config", "err", err)
+	}
+
+	retention, err := retentionConfigFromEnv()
+	if err != nil {
+		fatal("Error reading data retention config", "err", err)
+	}
+
+	warmup, err := warmupConfigFromEnv()
+	if err != nil {
+		fatal("Error reading cache warm-up config", "err", err)
+	}
+	var ready atomic.Bool
+
+	rateLimit, err := rateLimitConfigFromEnv()
+	if err != nil {
+		fatal("Error reading rate limit config", "err", err)
+	}
+
+	// longer than FETCH_TIMEOUT, POST /v1/feeds/{feed_id}/refresh waits for a fetch
+	requestTimeout, err := envDuration("REQUEST_TIMEOUT", time.Minute, 0)
+	if err != nil {
+		fatal("Error reading request timeout", "err", err)
+	}
+
+	fieldNames, err := fieldNamesFromEnv()
+	if err != nil {
+		fatal("Error reading API_FIELD_NAMES", "err", err)
 	}
 
 	router := chi.NewRouter()
+	router.Use(requestLogMiddleware)
+	router.Use(recoverMiddleware)
+	router.Use(requestTimeoutMiddleware(requestTimeout))
+	router.Use(apiConfig.Routes.Middleware)
+	router.Use(rateLimitMiddleware(rateLimit, apiConfig.checkCredential))
+	router.Use(fieldNamesMiddleware(fieldNames))
 	v1Router := chi.NewRouter()
 
-	v1Router.Get("/healthz", readinessHandler)
+	v1Router.Get("/healthz", readinessHandler(&ready))
 	v1Router.Get("/err", errorHandler)
+	v1Router.Get("/privacy", getPrivacyHandler(retention))
 	v1Router.Post("/users", postUsersHandler(apiConfig))
+	v1Router.Put("/users/me/password", apiConfig.apiKeyHandler(putPasswordHandler(apiConfig)))
+	v1Router.Delete("/users/me", apiConfig.apiKeyHandler(deleteUserHandler(apiConfig)))
+	v1Router.Patch("/users/me", apiConfig.apiKeyHandler(patchUserHandler(apiConfig)))
+	v1Router.Get("/users/me/export", apiConfig.apiKeyHandler(getUserExportHandler(apiConfig)))
+	v1Router.Post("/login", postLoginHandler(apiConfig))
+	v1Router.Post("/refresh", postRefreshHandler(apiConfig))
+	v1Router.Get("/auth/{provider}/login", getSocialLoginHandler(apiConfig))
+	v1Router.Get("/auth/{provider}/callback", getSocialCallbackHandler(apiConfig))
+	v1Router.Post("/auth/{provider}/link", apiConfig.apiKeyHandler(postSocialLinkHandler(apiConfig)))
+	v1Router.Get("/users/me/identities", apiConfig.authedHandler(getUserIdentitiesHandler(apiConfig)))
+	v1Router.Delete("/users/me/identities/{provider}", apiConfig.authedHandler(deleteUserIdentityHandler(apiConfig)))
+	v1Router.Post("/logout", apiConfig.authedHandler(postLogoutHandler(apiConfig)))
+	v1Router.Get("/sessions", apiConfig.apiKeyHandler(getSessionsHandler(apiConfig)))
+	v1Router.Delete("/sessions", apiConfig.apiKeyHandler(deleteSessionsHandler(apiConfig)))
+	v1Router.Delete("/sessions/{session_id}", apiConfig.apiKeyHandler(deleteSessionHandler(apiConfig)))
 	v1Router.Get("/users", apiConfig.authedHandler(getUsersHandler(apiConfig)))
+	v1Router.Get("/users/check", checkUserNameHandler(apiConfig))
+	v1Router.Post("/users/me/feed_token", apiConfig.apiKeyHandler(postFeedTokenHandler(apiConfig)))
+	v1Router.Delete("/users/me/feed_token", apiConfig.authedHandler(deleteFeedTokenHandler(apiConfig)))
+	v1Router.Get("/users/me/feed.xml", getPersonalFeedHandler(apiConfig))
+	v1Router.Get("/users/me/feed/{token}.xml", getPersonalFeedHandler(apiConfig))
+	v1Router.Get("/users/me/feed.json", getPersonalJSONFeedHandler(apiConfig))
+	v1Router.Get("/users/me/feed/{token}.json", getPersonalJSONFeedHandler(apiConfig))
+	v1Router.Post("/users/verify/resend", apiConfig.authedHandler(resendEmailVerificationHandler(apiConfig)))
+	v1Router.Get("/verify", verifyEmailHandler(apiConfig))
+	v1Router.Post("/password/forgot", postForgotPasswordHandler(apiConfig))
+	v1Router.Post("/password/reset", postResetPasswordHandler(apiConfig))
 	v1Router.Post("/feeds", apiConfig.authedHandler(postFeedsHandler(apiConfig)))
+	v1Router.Post("/feeds/preview", apiConfig.authedHandler(postFeedPreviewHandler(apiConfig)))
 	v1Router.Get("/feeds", getFeedsHandler(apiConfig))
+	v1Router.Get("/feeds/{feed_id}", getFeedHandler(apiConfig))
+	v1Router.Get("/feeds/{feed_id}/icon", getFeedIconHandler(apiConfig))
+	v1Router.Get("/feeds/{feed_id}/json_feed", getFeedJSONFeedHandler(apiConfig))
+	v1Router.Put("/feeds/{feed_id}/note", apiConfig.authedHandler(putFeedNoteHandler(apiConfig)))
+	v1Router.Get("/feeds/{feed_id}/note/history", getFeedNoteHistoryHandler(apiConfig))
+	v1Router.Post("/feeds/{feed_id}/enable", apiConfig.authedHandler(enableFeedHandler(apiConfig)))
+	v1Router.Get("/feeds/{feed_id}/fetch_log", apiConfig.authedHandler(getFetchLogHandler(apiConfig)))
+	v1Router.Post("/feeds/{feed_id}/refresh", apiConfig.authedHandler(refreshFeedHandler(apiConfig, feedFetcher)))
+	v1Router.Post("/feeds/{feed_id}/items", apiConfig.authedHandler(postFeedItemsHandler(apiConfig)))
+	v1Router.Get("/feeds/{feed_id}/items/scheduled", apiConfig.authedHandler(getScheduledItemsHandler(apiConfig)))
+	v1Router.Delete("/feeds/{feed_id}/items/scheduled/{item_id}", apiConfig.authedHandler(deleteScheduledItemHandler(apiConfig)))
+	v1Router.Get("/feeds/{feed_id}/scraper", apiConfig.authedHandler(getFeedScraperHandler(apiConfig)))
+	v1Router.Put("/feeds/{feed_id}/scraper", apiConfig.authedHandler(putFeedScraperHandler(apiConfig)))
+	v1Router.Delete("/feeds/{feed_id}/scraper", apiConfig.authedHandler(deleteFeedScraperHandler(apiConfig)))
+	v1Router.Get("/feeds/{feed_id}/credentials", apiConfig.authedHandler(getFeedCredentialsHandler(apiConfig)))
+	v1Router.Put("/feeds/{feed_id}/credentials", apiConfig.authedHandler(putFeedCredentialsHandler(apiConfig)))
+	v1Router.Delete("/feeds/{feed_id}/credentials", apiConfig.authedHandler(deleteFeedCredentialsHandler(apiConfig)))
 
 	v1Router.Post("/feed_follows", apiConfig.authedHandler(postFeedFollowHandler(apiConfig)))
+	v1Router.Post("/feed_follows/by_url", deprecatedHandler(feedFollowByURLDeprecation, apiConfig.authedHandler(postFeedFollowByURLHandler(apiConfig))))
 	v1Router.Delete("/feed_follows/{feed_id}", apiConfig.authedHandler(deleteFeedFollowHandler(apiConfig)))
 	v1Router.Get("/feed_follows", apiConfig.authedHandler(getUserFeedFollowsHandler(apiConfig)))
 
 	v1Router.Get("/posts", apiConfig.authedHandler(getPostsHandler(apiConfig)))
-
+	v1Router.Get("/posts/export", apiConfig.authedHandler(exportPostsHandler(apiConfig)))
+	v1Router.Get("/posts/compact", apiConfig.authedHandler(getCompactPostsHandler(apiConfig)))
+	v1Router.Get("/sync", apiConfig.authedHandler(getSyncHandler(apiConfig)))
+	v1Router.Post("/batch", apiConfig.authedHandler(postBatchHandler(apiConfig)))
+	v1Router.Get("/posts/{post_id}/content", apiConfig.authedHandler(getPostContentHandler(apiConfig)))
+	v1Router.Get("/posts/{post_id}/progress", apiConfig.authedHandler(getReadingProgressHandler(apiConfig)))
+	v1Router.Put("/posts/{post_id}/progress", apiConfig.authedHandler(putReadingProgressHandler(apiConfig)))
+	v1Router.Get("/proxy/image", getProxiedImageHandler(apiConfig))
+
+	v1Router.Get("/public/feeds", getPublicFeedsHandler(apiConfig, guest))
+	v1Router.Get("/public/posts", getPublicPostsHandler(apiConfig, guest))
+
+	v1Router.Post("/newsletters/address", apiConfig.authedHandler(postNewsletterAddressHandler(apiConfig)))
+	v1Router.Get("/newsletters/address", apiConfig.authedHandler(getNewsletterAddressHandler(apiConfig)))
+	v1Router.Post("/newsletters/inbound", postNewsletterInboundHandler(apiConfig))
+
+	v1Router.Post("/bookmarks", apiConfig.authedHandler(postBookmarkHandler(apiConfig)))
+	v1Router.Get("/bookmarks", apiConfig.authedHandler(getBookmarksHandler(apiConfig)))
+	v1Router.Delete("/bookmarks/{bookmark_id}", apiConfig.authedHandler(deleteBookmarkHandler(apiConfig)))
+	v1Router.Post("/bookmarks/import", apiConfig.authedHandler(importBookmarksHandler(apiConfig)))
+
+	v1Router.Get("/ws", apiConfig.authedHandler(liveHandler(apiConfig)))
+
+	v1Router.Post("/webhooks", apiConfig.authedHandler(postWebhookHandler(apiConfig)))
+	v1Router.Get("/webhooks", apiConfig.authedHandler(getWebhooksHandler(apiConfig)))
+	v1Router.Patch("/webhooks/{webhook_id}", apiConfig.authedHandler(patchWebhookHandler(apiConfig)))
+	v1Router.Delete("/webhooks/{webhook_id}", apiConfig.authedHandler(deleteWebhookHandler(apiConfig)))
+	v1Router.Post("/webhooks/{webhook_id}/rotate_secret", apiConfig.authedHandler(rotateWebhookSecretHandler(apiConfig)))
+	v1Router.Get("/webhooks/{webhook_id}/deliveries", apiConfig.authedHandler(getWebhookDeliveriesHandler(apiConfig)))
+	v1Router.Post("/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver", apiConfig.authedHandler(redeliverWebhookHandler(apiConfig)))
+	v1Router.Get("/telegram", apiConfig.authedHandler(getTelegramHandler(apiConfig)))
+	v1Router.Delete("/telegram", apiConfig.authedHandler(deleteTelegramHandler(apiConfig)))
+	v1Router.Post("/telegram/link", apiConfig.authedHandler(postTelegramLinkHandler(apiConfig)))
+	v1Router.Put("/telegram/feeds", apiConfig.authedHandler(putTelegramFeedsHandler(apiConfig)))
+	v1Router.Post("/telegram/webhook", postTelegramWebhookHandler(apiConfig))
+	v1Router.Post("/chat_notifications", apiConfig.authedHandler(postChatNotificationHandler(apiConfig)))
+	v1Router.Get("/chat_notifications", apiConfig.authedHandler(getChatNotificationsHandler(apiConfig)))
+	v1Router.Delete("/chat_notifications/{chat_notification_id}", apiConfig.authedHandler(deleteChatNotificationHandler(apiConfig)))
+	v1Router.Get("/webhooks/{webhook_id}/dead_letters", apiConfig.authedHandler(getWebhookDeadLettersHandler(apiConfig)))
+	v1Router.Post("/webhooks/{webhook_id}/dead_letters/{dead_letter_id}/redeliver", apiConfig.authedHandler(redeliverWebhookDeadLetterHandler(apiConfig)))
+
+	v1Router.Post("/api_keys", apiConfig.apiKeyHandler(postApiKeyHandler(apiConfig)))
+	v1Router.Get("/api_keys", apiConfig.authedHandler(getApiKeysHandler(apiConfig)))
+	v1Router.Delete("/api_keys/{api_key_id}", apiConfig.authedHandler(deleteApiKeyHandler(apiConfig)))
+	v1Router.Post("/oauth/clients", apiConfig.apiKeyHandler(postOAuthClientHandler(apiConfig)))
+	v1Router.Post("/oauth/authorize", apiConfig.apiKeyHandler(postOAuthAuthorizeHandler(apiConfig)))
+	v1Router.Post("/oauth/token", postOAuthTokenHandler(apiConfig))
+
+	v1Router.Get("/admin/metrics", apiConfig.adminHandler(getAdminMetricsHandler(apiConfig)))
+	v1Router.Get("/admin/integrity", apiConfig.adminHandler(getIntegrityHandler(apiConfig)))
+	v1Router.Post("/admin/integrity/repair", apiConfig.adminHandler(repairIntegrityHandler(apiConfig)))
+	v1Router.Get("/admin/migrations", apiConfig.adminHandler(getMigrationsHandler(apiConfig)))
+	v1Router.Get("/admin/feeds/{feed_id}/snapshots", apiConfig.adminHandler(getFeedSnapshotsHandler(apiConfig)))
+	v1Router.Get("/admin/feeds/{feed_id}/snapshots/{snapshot_id}", apiConfig.adminHandler(getFeedSnapshotHandler(apiConfig)))
+	v1Router.Post("/admin/feeds/{feed_id}/snapshots/{snapshot_id}/replay", apiConfig.adminHandler(replayFeedSnapshotHandler(apiConfig)))
+	v1Router.Put("/admin/public_feeds/{feed_id}", apiConfig.adminHandler(putPublicFeedHandler(apiConfig, guest)))
+	v1Router.Delete("/admin/public_feeds/{feed_id}", apiConfig.adminHandler(deletePublicFeedHandler(apiConfig, guest)))
+
+	router.NotFound(notFoundHandler)
+	router.MethodNotAllowed(methodNotAllowedHandler)
 	router.Mount("/v1", v1Router)
 
 	server := &http.Server{
@@ -98,55 +515,232 @@ func main() {
 		Handler: router,
 	}
 
-	// running processors to go off every 60 seconds
+	// the gRPC API listens on a port of its own when GRPC_PORT is set
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		grpcListener, err = net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			fatal("Error listening on GRPC_PORT", "err", err)
+		}
+		grpcServer = newGRPCServer(apiConfig)
+	}
+
+	shutdownTimeout, err := envDuration("SHUTDOWN_TIMEOUT", 30*time.Second, 0)
+	if err != nil {
+		fatal("Error reading shutdown timeout", "err", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// running processors to go off every FETCH_INTERVAL
+	schedulerDone := make(chan struct{})
+	go func() {
+		feedFetcher.run(ctx)
+		close(schedulerDone)
+	}()
+
+	// delivering queued webhooks
+	webhookQueueDone := make(chan struct{})
+	go func() {
+		apiConfig.WebhookQueue.run(ctx, apiConfig)
+		close(webhookQueueDone)
+	}()
+
+	// sending the posts queued for chat notifications in batches
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(chatNotificationInterval):
+			}
+
+			sendChatNotifications(ctx, apiConfig)
+		}
+	}()
+
+	// posts saved before dates fell back to the fetch time have none
+	go feedFetcher.repairPublishedDates(ctx)
+
+	// posts saved before canonicalPostURL only had the fragment cut off
+	go repairCanonicalURLs(ctx, apiConfig.DB)
+
+	// fetching icons of new feeds and refreshing old ones
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(feedIconRefreshPeriod):
+			}
+
+			refreshFeedIcons(ctx, apiConfig)
+		}
+	}()
+
+	// cleaning up expired blobs once an hour
 	go func() {
 		for {
-			time.Sleep(60 * time.Second)
-			getUnprocessedFeedsAndProcessThemAsync(apiConfig)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Hour):
+			}
+
+			deleted, err := storage.Cleanup(ctx, blobStore, retentionPolicies)
+			if err != nil {
+				dbLog.Error("Error cleaning up storage", "err", err)
+			}
+			if deleted > 0 {
+				dbLog.Info("Deleted expired objects from storage", "count", deleted)
+			}
+		}
+	}()
+
+	// purging personal data past its retention once an hour
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Hour):
+			}
+
+			report, err := purgePersonalData(ctx, apiConfig.DB, retention)
+			if err != nil {
+				dbLog.Error("Error purging personal data", "err", err)
+			}
+			if report != (purgeReport{}) {
+				dbLog.Info("Purged personal data", "report", report)
+			}
+		}
+	}()
+
+	// pruning the fetch log past FETCH_LOG_RETENTION once an hour
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Hour):
+			}
+
+			deleted, err := pruneFetchLogs(ctx, apiConfig.DB, fetchConfig.LogRetention)
+			if err != nil {
+				dbLog.Error("Error pruning fetch log", "err", err)
+			}
+			if deleted > 0 {
+				dbLog.Info("Pruned fetch log entries", "count", deleted)
+			}
+		}
+	}()
+
+	go func() {
+		slog.Info("Serving HTTP", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("Error serving HTTP", "err", err)
 		}
 	}()
 
-	fmt.Println("START")
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Problem: %v", err)
+	if grpcServer != nil {
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				fatal("Error serving gRPC", "err", err)
+			}
+		}()
 	}
-	fmt.Println("STOP")
-}
 
-func readinessHandler(w http.ResponseWriter, r *http.Request) {
-	type ReadinessResponse struct {
-		Status string `json:"status"`
+	// priming the caches while /v1/healthz holds traffic back, through a
+	// router of its own so the warm-up doesn't show in the route metrics
+	if warmup.OnStart {
+		warmRouter := chi.NewRouter()
+		warmRouter.Mount("/v1", v1Router)
+		go warmCaches(ctx, warmRouter, warmupPaths(guest), warmup, &ready)
+	} else {
+		ready.Store(true)
 	}
 
-	resp := ReadinessResponse{
-		Status: "ok",
+	<-ctx.Done()
+	stop()
+	slog.Info("Shutting down, waiting for requests and fetches to finish", "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// the server doesn't wait for websockets, they are hijacked
+	apiConfig.Live.Close()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "err", err)
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
 	}
 
-	respondWithJSON(w, 200, resp)
-}
+	<-schedulerDone
+	if err := feedFetcher.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Gave up waiting for fetches", "err", err)
+	}
+	// deliveries the queue didn't get to stay queued for the next start
+	<-webhookQueueDone
 
-func errorHandler(w http.ResponseWriter, r *http.Request) {
-	type ErrorResponse struct {
-		Error string `json:"error"`
+	if apiConfig.Events != nil {
+		if err := apiConfig.Events.Close(); err != nil {
+			slog.Error("Error closing event bus", "err", err)
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "err", err)
 	}
+	slog.Info("Stopped")
+}
+
+// readinessHandler reports "warming" with a 503 until the caches are primed.
+func readinessHandler(ready *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type ReadinessResponse struct {
+			Status string `json:"status"`
+		}
+
+		if !ready.Load() {
+			respondWithJSON(w, 503, ReadinessResponse{Status: "warming"})
+			return
+		}
+
+		resp := ReadinessResponse{
+			Status: "ok",
+		}
 
-	resp := ErrorResponse{
-		Error: "something went wrong",
+		respondWithJSON(w, 200, resp)
 	}
+}
 
-	respondWithJSON(w, 500, resp)
+func errorHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithError(w, 500, "Something went wrong")
 }
 
 func postUsersHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		type UsersRequest struct {
-			Name string `json:"name"`
+			Name         string   `json:"name"`
+			Email        string   `json:"email"`
+			Password     string   `json:"password"`
+			AllowedCIDRs []string `json:"allowed_cidrs"`
 		}
 
 		var req UsersRequest
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil {
-			respondWithError(w, 400, "Error decoding request")
+		if !decodeJSONBody(w, r, &req) {
 			return
 		}
 
@@ -157,85 +751,258 @@ func postUsersHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.R
 			Name      string    `json:"name"`
 		}
 
-		context := context.Background()
+		var v validation
+		v.check("name", validateUserName(req.Name))
+		if req.Email != "" {
+			v.check("email", validateEmail(req.Email))
+		}
+		if req.Password != "" {
+			if req.Email == "" {
+				v.add("email", "A password needs an email to log in with")
+			}
+			v.check("password", validatePassword(req.Password))
+		}
+		allowedCIDRs, err := normalizeAllowedCIDRs(req.AllowedCIDRs)
+		v.check("allowed_cidrs", err)
+		if v.respond(w) {
+			return
+		}
+
+		var passwordHash sql.NullString
+		if req.Password != "" {
+			passwordHash, err = hashPassword(req.Password)
+			if err != nil {
+				httpLog.Error("Error hashing password", "err", err)
+				respondWithError(w, 500, "Error creating user")
+				return
+			}
+		}
+
+		context := r.Context()
+		_, err = apiConfig.DB.GetUserByName(context, req.Name)
+		if err == nil {
+			respondWithErrorCode(w, 409, "user_name_taken", errUserNameTaken.Error())
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			httpLog.Error("Error getting user", "err", err)
+			respondWithError(w, 500, "Error getting users")
+			return
+		}
+
+		apiKey, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating api key", "err", err)
+			respondWithError(w, 500, "Error creating user")
+			return
+		}
+
 		userParams := database.InsertUserParams{
-			ID:        uuid.New(),
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			Name:      req.Name,
+			ID:           uuid.New(),
+			CreatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+			UpdatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+			Name:         req.Name,
+			Email:        sql.NullString{String: req.Email, Valid: req.Email != ""},
+			AllowedCidrs: allowedCIDRs,
+			ApikeyHash:   hashToken(apiKey),
+			PasswordHash: passwordHash,
 		}
 
 		user, err := apiConfig.DB.InsertUser(context, userParams)
+		if isUniqueViolation(err, "users_name_unique") || isUniqueViolation(err, "users_name_lower_unique") {
+			respondWithErrorCode(w, 409, "user_name_taken", errUserNameTaken.Error())
+			return
+		}
+		if isUniqueViolation(err, "users_email_unique") {
+			respondWithErrorCode(w, 409, "email_taken", errEmailTaken.Error())
+			return
+		}
 		if err != nil {
 			respondWithError(w, 500, "Error getting users")
 			return
 		}
 
-		respondWithJSON(w, 200, user)
+		if user.Email.Valid {
+			err = sendEmailVerification(context, apiConfig, user)
+			if err != nil {
+				httpLog.Error("Error sending email verification", "err", err)
+			}
+		}
+
+		// the only time the key is around, only its hash is stored
+		resp := newUserResponse(user)
+		resp.Apikey = apiKey
+		respondCreated(w, "/v1/users/me", resp)
+	}
+}
+
+// userResponse is a user without the hash of their API key. Apikey is only
+// set when the user is created.
+type userResponse struct {
+	ID               uuid.UUID
+	CreatedAt        sql.NullTime
+	UpdatedAt        sql.NullTime
+	Name             string
+	Apikey           string `json:",omitempty"`
+	Email            sql.NullString
+	EmailVerifiedAt  sql.NullTime
+	AllowedCidrs     []string
+	Timezone         string
+	DefaultPostLimit sql.NullInt32
+	DigestFrequency  string
+	DigestHour       int16
+}
+
+func newUserResponse(user database.User) userResponse {
+	return userResponse{
+		ID:               user.ID,
+		CreatedAt:        user.CreatedAt,
+		UpdatedAt:        user.UpdatedAt,
+		Name:             user.Name,
+		Email:            user.Email,
+		EmailVerifiedAt:  user.EmailVerifiedAt,
+		AllowedCidrs:     user.AllowedCidrs,
+		Timezone:         user.Timezone,
+		DefaultPostLimit: user.DefaultPostLimit,
+		DigestFrequency:  user.DigestFrequency,
+		DigestHour:       user.DigestHour,
 	}
 }
 
 func getUsersHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
 	return func(w http.ResponseWriter, r *http.Request, user database.User) {
-		respondWithJSON(w, 200, user)
+		respondWithJSON(w, 200, newUserResponse(user))
 	}
 }
 
 func postFeedsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
 	return func(w http.ResponseWriter, r *http.Request, user database.User) {
 		type FeedRequest struct {
-			Name string `json:"name"`
-			URL  string `json:"url"`
+			Name        string           `json:"name"`
+			URL         string           `json:"url"`
+			Credentials *feedCredentials `json:"credentials"`
+			Scraper     *feedScraper     `json:"scraper"`
+			Source      *feedSource      `json:"source"`
 		}
 
 		var req FeedRequest
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil {
-			respondWithError(w, 400, "Error decoding request")
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Credentials != nil && len(apiConfig.FeedCredentialsKey) == 0 {
+			respondWithErrorCode(w, 400, "feature_disabled", errFeedCredentialsDisabled.Error())
 			return
 		}
 
-		context := context.Background()
-		feedParams := database.CreateFeedParams{
-			ID:        uuid.New(),
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			Name:      req.Name,
-			Url:       req.URL,
-			UserID:    user.ID,
+		var v validation
+		v.check("name", validateFeedName(req.Name))
+		if req.Credentials != nil {
+			v.check("credentials", req.Credentials.normalize())
+		}
+		if req.Scraper != nil {
+			v.check("scraper", req.Scraper.normalize())
+		}
+		if req.Source != nil {
+			v.check("source", req.Source.normalize())
+			if !v.has("source") {
+				req.URL = req.Source.url()
+			}
+		} else {
+			v.check("url", validateFeedURL(req.URL))
+		}
+		if v.respond(w) {
+			return
 		}
 
-		feed, err := apiConfig.DB.CreateFeed(context, feedParams)
+		context := r.Context()
+		resolved, err := resolveFeed(context, apiConfig.DB, user.ID, req.Name, req.URL, req.Credentials, req.Scraper)
+		if errors.Is(err, errInvalidFeedURL) || errors.Is(err, errNotAFeed) || errors.Is(err, errPrivateFeedURL) || errors.Is(err, errNoScrapedItems) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		if errors.Is(err, errFeedNotShared) {
+			respondWithErrorCode(w, 409, "feed_not_shared", err.Error())
+			return
+		}
 		if err != nil {
-			log.Printf("Error creating feed: %v", err)
+			httpLog.Error("Error creating feed", "err", err)
 			respondWithError(w, 500, "Error getting feeds")
 			return
 		}
 
-		feedFollowParams := database.CreateFeedFollowParams{
-			ID:        uuid.New(),
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UserID:    user.ID,
-			FeedID:    feed.ID,
+		var feed database.Feed
+		var feedFollow database.FeedFollow
+		var created bool
+		err = inFeedTx(context, apiConfig.DB, resolved, func(q *database.Queries, stored database.Feed) error {
+			feed = stored
+			var err error
+
+			// credentials of a feed someone else added are left alone
+			if req.Credentials != nil && feed.UserID == user.ID {
+				shared, err := followedByOthers(context, q, feed)
+				if err != nil {
+					return err
+				}
+				if shared {
+					return errFeedFollowedByOthers
+				}
+				err = saveFeedCredentials(context, q, apiConfig.FeedCredentialsKey, feed.ID, *req.Credentials)
+				if err != nil {
+					return err
+				}
+			}
+			if req.Scraper != nil && feed.UserID == user.ID {
+				_, err = saveFeedScraper(context, q, feed.ID, *req.Scraper)
+				if err != nil {
+					return err
+				}
+			}
+
+			feedFollow, created, err = followFeed(context, q, user.ID, feed.ID)
+			return err
+		})
+		if errors.Is(err, errFeedFollowedByOthers) {
+			respondWithErrorCode(w, 409, "feed_followed_by_others", err.Error())
+			return
+		}
+		if errors.Is(err, errFeedNotShared) {
+			respondWithErrorCode(w, 409, "feed_not_shared", err.Error())
+			return
 		}
-		_, err = apiConfig.DB.CreateFeedFollow(context, feedFollowParams)
 		if err != nil {
-			log.Printf("Error creating feed follow: %v", err)
+			httpLog.Error("Error creating feed", "err", err)
 			respondWithError(w, 500, "Error getting feeds")
 			return
 		}
 
-		respondWithJSON(w, 200, feed)
+		if !created {
+			respondWithJSON(w, 200, feed)
+			return
+		}
+
+		dispatchUserEvent(context, apiConfig, user.ID, eventFollowCreated, feedFollow)
+		respondCreated(w, "/v1/feeds/"+feed.ID.String(), feed)
 	}
 }
 
 func getFeedsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		context := context.Background()
+		context := r.Context()
+		if wantsNDJSON(r) {
+			stream := newNDJSONWriter(w)
+			err := apiConfig.DB.IterateFeeds(context, func(feed database.Feed) error {
+				return stream.Write(feed)
+			})
+			if err != nil {
+				httpLog.Error("Error streaming feeds", "err", err)
+			}
+			stream.Flush()
+			return
+		}
+
 		feeds, err := apiConfig.DB.GetFeeds(context)
 		if err != nil {
-			log.Printf("Error getting feeds: %v", err)
+			httpLog.Error("Error getting feeds", "err", err)
 			respondWithError(w, 500, "Error getting feeds")
 			return
 		}
@@ -244,6 +1011,80 @@ func getFeedsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Re
 	}
 }
 
+/*
+Endpoint: GET /v1/feeds/{feed_id}
+
+Returns a single feed, including its fetch state (consecutive failures, last
+error and when it will be fetched next) and the note of its owner, if any.
+*/
+func getFeedHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		context := r.Context()
+		feed, err := apiConfig.DB.GetVisibleFeedByID(context, database.GetVisibleFeedByIDParams{
+			ID:     feedID,
+			UserID: apiConfig.requestUserID(r),
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		note, err := currentFeedNote(context, apiConfig.DB, feed.ID)
+		if err != nil {
+			httpLog.Error("Error getting feed note", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		respondWithJSON(w, 200, struct {
+			database.Feed
+			Note *feedNoteResponse `json:"note"`
+		}{feed, note})
+	}
+}
+
+/*
+Endpoint: POST /v1/feeds/{feed_id}/enable
+
+# This is an authenticated endpoint
+
+Revives a feed that was disabled after failing for too long, it is fetched
+again on the next run. Only the owner and the followers of the feed can.
+*/
+func enableFeedHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feed, ok := getFollowedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		context := r.Context()
+		feed, err := apiConfig.DB.EnableFeed(context, feed.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error enabling feed", "err", err)
+			respondWithError(w, 500, "Error enabling feed")
+			return
+		}
+
+		respondWithJSON(w, 200, feed)
+	}
+}
+
 func postFeedFollowHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
 	return func(w http.ResponseWriter, r *http.Request, user database.User) {
 		type FeedFollowRequest struct {
@@ -251,30 +1092,142 @@ func postFeedFollowHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *h
 		}
 
 		var req FeedFollowRequest
-		err := json.NewDecoder(r.Body).Decode(&req)
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		_, err := apiConfig.DB.GetVisibleFeedByID(context, database.GetVisibleFeedByIDParams{
+			ID:     req.FeedID,
+			UserID: user.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		feedFollow, created, err := followFeed(context, apiConfig.DB, user.ID, req.FeedID)
 		if err != nil {
-			respondWithError(w, 400, "Error decoding request")
+			httpLog.Error("Error creating feed follow", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+		if !created {
+			respondWithJSON(w, 200, feedFollow)
+			return
+		}
+
+		dispatchUserEvent(context, apiConfig, user.ID, eventFollowCreated, feedFollow)
+		respondCreated(w, "/v1/feed_follows/"+feedFollow.FeedID.String(), feedFollow)
+	}
+}
+
+// feedFollowByURLDeprecation retires POST /v1/feed_follows/by_url, POST
+// /v1/feeds does the same and takes credentials and scrapers too.
+var feedFollowByURLDeprecation = routeDeprecation{
+	Since:     time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC),
+	Sunset:    time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC),
+	Successor: "/v1/feeds",
+}
+
+/*
+Endpoint: POST /v1/feed_follows/by_url
+
+# This is an authenticated endpoint
+
+Deprecated: use POST /v1/feeds, which follows the feed as well. Responses carry
+Deprecation and Sunset headers until the route is removed.
+*/
+func postFeedFollowByURLHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type FeedFollowByURLRequest struct {
+			URL  string `json:"url"`
+			Name string `json:"name"`
+		}
+
+		var req FeedFollowByURLRequest
+		if !decodeJSONBody(w, r, &req) {
 			return
 		}
 
-		context := context.Background()
-		feedFollowParams := database.CreateFeedFollowParams{
-			ID:        uuid.New(),
-			CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
-			UserID:    user.ID,
-			FeedID:    req.FeedID,
+		var v validation
+		v.check("url", validateFeedURL(req.URL))
+		v.check("name", validateFeedName(req.Name))
+		if v.respond(w) {
+			return
 		}
 
-		feedFollow, err := apiConfig.DB.CreateFeedFollow(context, feedFollowParams)
+		context := r.Context()
+		resolved, err := resolveFeed(context, apiConfig.DB, user.ID, req.Name, req.URL, nil, nil)
+		if errors.Is(err, errInvalidFeedURL) || errors.Is(err, errNotAFeed) || errors.Is(err, errPrivateFeedURL) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		if errors.Is(err, errFeedNotShared) {
+			respondWithErrorCode(w, 409, "feed_not_shared", err.Error())
+			return
+		}
 		if err != nil {
-			log.Printf("Error creating feed follow: %v", err)
+			httpLog.Error("Error following feed", "err", err)
 			respondWithError(w, 500, "Error getting feeds")
 			return
 		}
 
-		respondWithJSON(w, 200, feedFollow)
+		var feedFollow database.FeedFollow
+		var created bool
+		err = inFeedTx(context, apiConfig.DB, resolved, func(q *database.Queries, feed database.Feed) error {
+			var err error
+			feedFollow, created, err = followFeed(context, q, user.ID, feed.ID)
+			return err
+		})
+		if errors.Is(err, errFeedNotShared) {
+			respondWithErrorCode(w, 409, "feed_not_shared", err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error following feed", "err", err)
+			respondWithError(w, 500, "Error getting feeds")
+			return
+		}
+
+		if !created {
+			respondWithJSON(w, 200, feedFollow)
+			return
+		}
+
+		dispatchUserEvent(context, apiConfig, user.ID, eventFollowCreated, feedFollow)
+		respondCreated(w, "/v1/feed_follows/"+feedFollow.FeedID.String(), feedFollow)
+	}
+}
+
+// followFeed creates a follow for the user, or returns the existing one if the
+// user already follows the feed. created tells the two cases apart.
+func followFeed(ctx context.Context, db *database.Queries, userID, feedID uuid.UUID) (feedFollow database.FeedFollow, created bool, err error) {
+	feedFollows, err := db.GetUserFeedFollows(ctx, userID)
+	if err != nil {
+		return database.FeedFollow{}, false, err
+	}
+
+	for _, feedFollow := range feedFollows {
+		if feedFollow.FeedID == feedID {
+			return feedFollow, false, nil
+		}
+	}
+
+	feedFollowParams := database.CreateFeedFollowParams{
+		ID:        uuid.New(),
+		CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+		UserID:    userID,
+		FeedID:    feedID,
 	}
+	feedFollow, err = db.CreateFeedFollow(ctx, feedFollowParams)
+	return feedFollow, err == nil, err
 }
 
 func deleteFeedFollowHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
@@ -282,28 +1235,40 @@ func deleteFeedFollowHandler(apiConfig apiConfig) func(w http.ResponseWriter, r
 		vars := chi.URLParam(r, "feed_id")
 		feedID, err := uuid.Parse(vars)
 		if err != nil {
-			respondWithError(w, 400, "Error decoding request")
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
 			return
 		}
 
-		context := context.Background()
-		err = apiConfig.DB.DeleteFeedFollow(context, feedID)
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteFeedFollow(context, database.DeleteFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feedID,
+		})
 		if err != nil {
-			log.Printf("Error deleting feed follow: %v", err)
+			httpLog.Error("Error deleting feed follow", "err", err)
 			respondWithError(w, 500, "Error deleting feed follow")
 			return
 		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "feed_follow_not_found", "Feed follow not found")
+			return
+		}
 
-		respondWithJSON(w, 200, nil)
+		dispatchUserEvent(context, apiConfig, user.ID, eventFollowDeleted, struct {
+			UserID uuid.UUID `json:"user_id"`
+			FeedID uuid.UUID `json:"feed_id"`
+		}{user.ID, feedID})
+
+		w.WriteHeader(204)
 	}
 }
 
 func getUserFeedFollowsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
 	return func(w http.ResponseWriter, r *http.Request, user database.User) {
-		context := context.Background()
+		context := r.Context()
 		feedFollows, err := apiConfig.DB.GetUserFeedFollows(context, user.ID)
 		if err != nil {
-			log.Printf("Error getting feed follows: %v", err)
+			httpLog.Error("Error getting feed follows", "err", err)
 			respondWithError(w, 500, "Error getting feed follows")
 			return
 		}
@@ -318,14 +1283,67 @@ Endpoint: GET /v1/posts
 # This is an authenticated endpoint
 
 This endpoint should return a list of posts for the authenticated user. It should accept a limit query parameter that limits the number of posts returned. The default if the parameter is not provided can be whatever you think is reasonable.
+
+With type=audio (or type=video) only posts with an enclosure of that kind are
+returned, like the episodes of podcasts for podcatcher clients.
+
+With limit or cursor the posts are paginated newest first, see pagination.go
+for the cursor format. Without either all posts are returned.
 */
 func getPostsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
 	return func(w http.ResponseWriter, r *http.Request, user database.User) {
-		context := context.Background()
+		mediaType := r.URL.Query().Get("type")
+		if mediaType != "" && mediaType != "audio" && mediaType != "video" {
+			respondWithError(w, 400, "Invalid type, expected audio or video")
+			return
+		}
+
+		context := r.Context()
+		if paginated(r) {
+			page, err := parseUserPageRequest(r, user)
+			if err != nil {
+				respondWithError(w, 400, err.Error())
+				return
+			}
+
+			posts, err := apiConfig.DB.GetPostsPageByUser(context, database.GetPostsPageByUserParams{
+				UserID:     user.ID,
+				MediaType:  sql.NullString{String: mediaType, Valid: mediaType != ""},
+				BeforeTime: page.BeforeTime(),
+				BeforeID:   page.BeforeID(),
+				Limit:      page.QueryLimit(),
+			})
+			if err != nil {
+				httpLog.Error("Error getting posts", "err", err)
+				respondWithError(w, 500, "Error getting posts")
+				return
+			}
+
+			posts = finishPage(w, r, page, posts, func(post database.GetPostsPageByUserRow) pageCursor {
+				return pageCursor{Time: postSortTime(post.PublishedAt), ID: post.ID}
+			})
+			respondWithJSON(w, 200, posts)
+			return
+		}
+
+		if mediaType != "" {
+			posts, err := apiConfig.DB.GetMediaPostsByUser(context, database.GetMediaPostsByUserParams{
+				UserID:    user.ID,
+				MediaType: mediaType,
+			})
+			if err != nil {
+				httpLog.Error("Error getting posts", "err", err)
+				respondWithError(w, 500, "Error getting posts")
+				return
+			}
+
+			respondWithJSON(w, 200, posts)
+			return
+		}
+
 		posts, err := apiConfig.DB.GetPostsByUser(context, user.ID)
-		fmt.Println("user id", user.ID)
 		if err != nil {
-			log.Printf("Error getting posts: %v", err)
+			httpLog.Error("Error getting posts", "err", err)
 			respondWithError(w, 500, "Error getting posts")
 			return
 		}
@@ -334,92 +1352,131 @@ func getPostsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Re
 	}
 }
 
-func getApiKeyFromAuth(auth string) (string, error) {
-	token := strings.Split(auth, " ")
-	if len(token) != 2 {
-		return "", errors.New("Invalid token")
-	}
+/*
+Endpoint: GET /v1/posts/export
 
-	return token[1], nil
-}
+# This is an authenticated endpoint
 
-func getAndParseRssFeed(url string) (*gofeed.Feed, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+Streams all posts of the authenticated user as newline delimited JSON.
+*/
+func exportPostsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		stream := newNDJSONWriter(w)
+		err := apiConfig.DB.IteratePostsByUser(context, user.ID, func(post database.GetPostsByUserRow) error {
+			return stream.Write(post)
+		})
+		if err != nil {
+			httpLog.Error("Error exporting posts", "err", err)
+		}
+		stream.Flush()
 	}
-	defer resp.Body.Close()
+}
 
-	feed, err := gofeed.NewParser().Parse(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+/*
+Endpoint: GET /v1/posts/compact
 
-	return feed, nil
-}
+# This is an authenticated endpoint
 
-func getUnprocessedFeedsAndProcessThemAsync(apiConfig apiConfig) {
-	ctx := context.Background()
-	feeds, err := apiConfig.DB.GetNextFeedsToFetch(ctx, 10)
-	if err != nil {
-		log.Printf("Error getting feeds: %v", err)
-		return
-	}
+Returns the same posts as GET /v1/posts with only what a list view needs: no
+descriptions and no feed details besides the feed name, plus the thumbnail of
+the post for cards when there is one and the percent of it the user read, null
+if they never opened it. It is paginated the
+same way with limit and cursor.
+*/
+func getCompactPostsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type Flags struct {
+			Bookmarked bool `json:"bookmarked"`
+		}
+		type CompactPost struct {
+			ID              uuid.UUID  `json:"id"`
+			Title           string     `json:"title"`
+			FeedName        string     `json:"feed_name"`
+			PublishedAt     *time.Time `json:"published_at"`
+			ThumbnailURL    string     `json:"thumbnail_url,omitempty"`
+			Flags           Flags      `json:"flags"`
+			ReadingProgress *float64   `json:"reading_progress"`
+		}
 
-	for _, feed := range feeds {
-		go func(feed database.Feed) {
-			feedContent, err := getAndParseRssFeed(feed.Url)
+		context := r.Context()
+		var posts []database.GetCompactPostsByUserRow
+		if paginated(r) {
+			page, err := parseUserPageRequest(r, user)
 			if err != nil {
-				log.Printf("Error parsing feed: %v", err)
+				respondWithError(w, 400, err.Error())
 				return
 			}
 
-			saveRssPosts(apiConfig, feed, feedContent)
-
-			ctx := context.Background()
-			err = apiConfig.DB.MarkFeedAsFetched(ctx, feed.Url)
+			rows, err := apiConfig.DB.GetCompactPostsPageByUser(context, database.GetCompactPostsPageByUserParams{
+				UserID:     user.ID,
+				BeforeTime: page.BeforeTime(),
+				BeforeID:   page.BeforeID(),
+				Limit:      page.QueryLimit(),
+			})
 			if err != nil {
-				log.Printf("Error marking feed as fetched: %v", err)
+				httpLog.Error("Error getting posts", "err", err)
+				respondWithError(w, 500, "Error getting posts")
 				return
 			}
-		}(feed)
-	}
-}
 
-func saveRssPosts(apiConfig apiConfig, feed database.Feed, feedContent *gofeed.Feed) {
-	ctx := context.Background()
-	for _, item := range feedContent.Items {
-		log.Printf("Item: %v", item.Title)
-		publishedStr := item.Published
-		publishedTime, err := time.Parse(time.RFC1123Z, publishedStr)
-		if err != nil {
-			log.Printf("Error parsing published time: %v", err)
-			return
+			rows = finishPage(w, r, page, rows, func(post database.GetCompactPostsPageByUserRow) pageCursor {
+				return pageCursor{Time: postSortTime(post.PublishedAt), ID: post.ID}
+			})
+			for _, row := range rows {
+				posts = append(posts, database.GetCompactPostsByUserRow(row))
+			}
+		} else {
+			var err error
+			posts, err = apiConfig.DB.GetCompactPostsByUser(context, user.ID)
+			if err != nil {
+				httpLog.Error("Error getting posts", "err", err)
+				respondWithError(w, 500, "Error getting posts")
+				return
+			}
 		}
 
-		postParams := database.CreatePostParams{
-			ID:          uuid.New(),
-			CreatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
-			UpdatedAt:   sql.NullTime{Time: time.Now(), Valid: true},
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: item.Description,
-			PublishedAt: sql.NullTime{Time: publishedTime, Valid: true},
-			FeedID:      feed.ID,
+		resp := make([]CompactPost, 0, len(posts))
+		for _, post := range posts {
+			compact := CompactPost{
+				ID:           post.ID,
+				Title:        post.Title,
+				FeedName:     post.FeedName,
+				ThumbnailURL: proxiedImageURL(apiConfig, post.ThumbnailUrl.String),
+				Flags:        Flags{Bookmarked: post.Bookmarked},
+			}
+			if post.PublishedAt.Valid {
+				compact.PublishedAt = &post.PublishedAt.Time
+			}
+			if post.ReadingProgress.Valid {
+				compact.ReadingProgress = &post.ReadingProgress.Float64
+			}
+			resp = append(resp, compact)
 		}
 
-		_, err = apiConfig.DB.CreatePost(ctx, postParams)
-		if err != nil {
-			log.Printf("Error saving post: %v", err)
-			return
-		}
+		respondWithJSON(w, 200, resp)
 	}
 }
 
+func parseAuthorization(auth string) (string, string, error) {
+	token := strings.Split(auth, " ")
+	if len(token) != 2 {
+		return "", "", errors.New("Invalid token")
+	}
+
+	return token[0], token[1], nil
+}
+
+// respondCreated responds 201 with a new resource and where it lives.
+func respondCreated(w http.ResponseWriter, location string, payload interface{}) {
+	w.Header().Set("Location", location)
+	respondWithJSON(w, 201, payload)
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Failed: %v", err)
+		httpLog.Error("Error encoding response", "err", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -430,8 +1487,3 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 		w.Write(response)
 	}
 }
-
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	payload := map[string]string{"error": msg}
-	respondWithJSON(w, code, payload)
-}
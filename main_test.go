@@ -0,0 +1,86 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestDeleteFeedFollowOfAnotherUser(t *testing.T) {
+	alice := database.User{ID: uuid.New()}
+	bob := database.User{ID: uuid.New()}
+	feedID := uuid.New()
+
+	// alice follows the feed, bob doesn't
+	fake, db := newFakeDB(t)
+	fake.on("DeleteFeedFollow", func(args []driver.Value) fakeResult {
+		if args[0] == alice.ID.String() && args[1] == feedID.String() {
+			return fakeResult{affected: 1}
+		}
+		return fakeResult{}
+	})
+	apiConfig := apiConfig{DB: db}
+
+	deleteAs := func(user database.User) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Delete("/v1/feed_follows/{feed_id}", asUser(user, deleteFeedFollowHandler(apiConfig)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/v1/feed_follows/"+feedID.String(), nil))
+		return w
+	}
+
+	if w := deleteAs(bob); w.Code != 404 {
+		t.Errorf("bob: status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if w := deleteAs(alice); w.Code != 204 {
+		t.Errorf("alice: status = %d, want 204: %s", w.Code, w.Body)
+	}
+}
+
+func TestEnableFeedOfAnotherUser(t *testing.T) {
+	alice := database.User{ID: uuid.New()}
+	bob := database.User{ID: uuid.New()}
+	carol := database.User{ID: uuid.New()}
+	feed := database.Feed{ID: uuid.New(), Name: "Feed", Url: "https://example.com/feed", UserID: alice.ID}
+
+	// alice added the feed, carol follows it, bob neither
+	fake, db := newFakeDB(t)
+	fake.on("GetFeedByID", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{feedRow(feed)}}
+	})
+	fake.on("GetUserFeedFollows", func(args []driver.Value) fakeResult {
+		if args[0] == carol.ID.String() {
+			return fakeResult{rows: followRows(carol.ID, feed.ID)}
+		}
+		return fakeResult{}
+	})
+	fake.on("EnableFeed", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{feedRow(feed)}}
+	})
+	apiConfig := apiConfig{DB: db}
+
+	enableAs := func(user database.User) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Post("/v1/feeds/{feed_id}/enable", asUser(user, enableFeedHandler(apiConfig)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/feeds/"+feed.ID.String()+"/enable", nil))
+		return w
+	}
+
+	if w := enableAs(bob); w.Code != 404 {
+		t.Errorf("bob: status = %d, want 404: %s", w.Code, w.Body)
+	}
+	if fake.ran("EnableFeed") {
+		t.Error("bob enabled the feed")
+	}
+	for name, user := range map[string]database.User{"alice": alice, "carol": carol} {
+		if w := enableAs(user); w.Code != 200 {
+			t.Errorf("%s: status = %d, want 200: %s", name, w.Code, w.Body)
+		}
+	}
+}
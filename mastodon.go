@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+/*
+Fediverse accounts are followed as a source with their handle:
+
+	{"type": "mastodon", "account": "gargron@mastodon.social", "replies": false, "boosts": true}
+
+An account without replies and boosts is its RSS feed, which has just that:
+
+	https://mastodon.social/@gargron.rss
+
+With either, the feed is read through the Mastodon api of the instance, which
+most fediverse servers implement, and stored under the url of the lookup:
+
+	https://mastodon.social/api/v1/accounts/lookup?acct=gargron&exclude_reblogs=false&exclude_replies=true
+*/
+
+const feedSourceMastodon = "mastodon"
+
+// mastodonMaxStatuses is how many of the latest statuses a fetch reads, the
+// most the api returns at once.
+const mastodonMaxStatuses = 40
+
+// mastodonTitleLength is the length titles are cut to, statuses have none.
+const mastodonTitleLength = 80
+
+var (
+	mastodonUserPattern = regexp.MustCompile(`^[A-Za-z0-9_]+([.-]+[A-Za-z0-9_]+)*$`)
+	errInvalidAccount   = errors.New("account must be a handle like user@instance")
+)
+
+// parseFediverseAccount splits a handle like @user@instance.
+func parseFediverseAccount(handle string) (user, instance string, err error) {
+	user, instance, ok := strings.Cut(strings.TrimPrefix(strings.TrimSpace(handle), "@"), "@")
+	if !ok || !mastodonUserPattern.MatchString(user) {
+		return "", "", errInvalidAccount
+	}
+
+	instance = strings.ToLower(instance)
+	u, err := url.Parse("https://" + instance)
+	if err != nil || u.Host != instance || u.Hostname() == "" || !strings.Contains(instance, ".") {
+		return "", "", errInvalidAccount
+	}
+
+	return user, instance, nil
+}
+
+// mastodonFeedURL is the url the feed of the account is stored under.
+func mastodonFeedURL(user, instance string, replies, boosts bool) string {
+	if !replies && !boosts {
+		return "https://" + instance + "/@" + user + ".rss"
+	}
+
+	query := url.Values{
+		"acct":            {user},
+		"exclude_replies": {strconv.FormatBool(!replies)},
+		"exclude_reblogs": {strconv.FormatBool(!boosts)},
+	}
+	return "https://" + instance + "/api/v1/accounts/lookup?" + query.Encode()
+}
+
+func isMastodonLookup(u *url.URL) bool {
+	return u.Scheme == "https" && u.Path == "/api/v1/accounts/lookup" && u.Query().Get("acct") != ""
+}
+
+type mastodonAccount struct {
+	ID          string `json:"id"`
+	Acct        string `json:"acct"`
+	DisplayName string `json:"display_name"`
+	Note        string `json:"note"`
+	URL         string `json:"url"`
+}
+
+type mastodonStatus struct {
+	ID               string          `json:"id"`
+	URI              string          `json:"uri"`
+	URL              string          `json:"url"`
+	CreatedAt        time.Time       `json:"created_at"`
+	Content          string          `json:"content"`
+	SpoilerText      string          `json:"spoiler_text"`
+	Account          mastodonAccount `json:"account"`
+	Reblog           *mastodonStatus `json:"reblog"`
+	MediaAttachments []mastodonMedia `json:"media_attachments"`
+}
+
+type mastodonMedia struct {
+	Type       string `json:"type"`
+	URL        string `json:"url"`
+	PreviewURL string `json:"preview_url"`
+}
+
+// mastodonFeed reads the statuses of the account of a lookup url through the
+// Mastodon api.
+func mastodonFeed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	api := "https://" + u.Host + "/api/v1/accounts/"
+
+	var account mastodonAccount
+	err = getSourceJSON(ctx, api+"lookup?"+url.Values{"acct": {query.Get("acct")}}.Encode(), nil, &account)
+	if err != nil {
+		return nil, err
+	}
+	if account.ID == "" {
+		return nil, &feedStatusError{StatusCode: http.StatusNotFound}
+	}
+
+	var statuses []mastodonStatus
+	err = getSourceJSON(ctx, api+url.PathEscape(account.ID)+"/statuses?"+url.Values{
+		"limit":           {strconv.Itoa(mastodonMaxStatuses)},
+		"exclude_replies": {query.Get("exclude_replies")},
+		"exclude_reblogs": {query.Get("exclude_reblogs")},
+	}.Encode(), nil, &statuses)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title:       account.DisplayName,
+		Description: htmlText(account.Note),
+		Link:        account.URL,
+		FeedType:    feedSourceMastodon,
+	}
+	if feed.Title == "" {
+		feed.Title = "@" + account.Acct
+	}
+
+	for _, status := range statuses {
+		feed.Items = append(feed.Items, mastodonItem(status))
+	}
+
+	return feed, nil
+}
+
+// mastodonItem turns a status into an item. The guid is the url of the
+// status, as in the RSS feed of the account. Boosts show the boosted status.
+func mastodonItem(status mastodonStatus) *gofeed.Item {
+	published := status.CreatedAt
+	item := &gofeed.Item{
+		GUID:            status.URL,
+		Link:            status.URL,
+		Published:       published.Format(time.RFC3339),
+		PublishedParsed: &published,
+		Authors:         []*gofeed.Person{{Name: "@" + status.Account.Acct}},
+	}
+	if item.GUID == "" {
+		item.GUID = status.URI
+	}
+
+	shown := status
+	prefix := ""
+	if status.Reblog != nil {
+		shown = *status.Reblog
+		prefix = "Boosted @" + shown.Account.Acct + ": "
+		item.Link = shown.URL
+		// a boost has no page of its own
+		item.GUID = status.URI
+	}
+
+	item.Content = shown.Content
+	item.Description = shown.Content
+	if shown.SpoilerText != "" {
+		item.Title = prefix + "CW: " + shown.SpoilerText
+	} else {
+		item.Title = prefix + truncateTitle(htmlText(shown.Content), mastodonTitleLength)
+	}
+
+	for _, media := range shown.MediaAttachments {
+		if media.Type == "image" && media.PreviewURL != "" {
+			item.Image = &gofeed.Image{URL: media.PreviewURL}
+			break
+		}
+	}
+
+	return item
+}
+
+// mastodonText is the text of the html of a status or bio.
+func htmlText(content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	doc.Find("br").ReplaceWithHtml(" ")
+	doc.Find("p").AppendHtml(" ")
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// truncateTitle cuts text to at most length characters, at a word when it can.
+func truncateTitle(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:length-1])
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
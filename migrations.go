@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/migrate"
+	"github.com/halfdan87/boot-go-blog-aggregator/sql/schema"
+)
+
+// migrationConfig controls applying the schema at startup.
+type migrationConfig struct {
+	// OnStart applies pending migrations before the server starts.
+	OnStart bool
+	// Hook is a url or a shell command run before migrations are applied,
+	// for example to take a backup. Migrations only run once it succeeds.
+	Hook        string
+	HookTimeout time.Duration
+}
+
+// migrationConfigFromEnv reads MIGRATE_ON_START, MIGRATION_HOOK and
+// MIGRATION_HOOK_TIMEOUT.
+func migrationConfigFromEnv() (migrationConfig, error) {
+	cfg := migrationConfig{
+		OnStart: os.Getenv("MIGRATE_ON_START") == "true",
+		Hook:    strings.TrimSpace(os.Getenv("MIGRATION_HOOK")),
+	}
+
+	var err error
+	if cfg.HookTimeout, err = envDuration("MIGRATION_HOOK_TIMEOUT", 30*time.Minute, time.Second); err != nil {
+		return migrationConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// pendingMigration is a migration as the hook and the admin endpoint see it.
+type pendingMigration struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name"`
+}
+
+func describeMigrations(migrations []migrate.Migration) []pendingMigration {
+	described := make([]pendingMigration, 0, len(migrations))
+	for _, migration := range migrations {
+		described = append(described, pendingMigration{Version: migration.Version, Name: migration.Name})
+	}
+	return described
+}
+
+// currentSchemaVersion is the highest applied version, 0 on an empty database.
+func currentSchemaVersion(history []migrate.Record) int64 {
+	applied := map[int64]bool{}
+	for _, record := range history {
+		applied[record.Version] = record.IsApplied
+	}
+
+	var current int64
+	for version, ok := range applied {
+		if ok && version > current {
+			current = version
+		}
+	}
+	return current
+}
+
+// migrateOnStart applies the pending migrations, after the hook confirmed.
+func migrateOnStart(ctx context.Context, db *sql.DB, cfg migrationConfig) error {
+	migrations, err := migrate.Load(schema.Migrations)
+	if err != nil {
+		return err
+	}
+	history, err := migrate.History(ctx, db)
+	if err != nil {
+		return err
+	}
+	pending, err := migrate.Pending(ctx, db, migrations)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	current := currentSchemaVersion(history)
+	if cfg.Hook != "" {
+		dbLog.Info("Running migration hook", "version", current)
+		err := runMigrationHook(ctx, cfg, current, pending)
+		if err != nil {
+			return fmt.Errorf("migration hook: %w", err)
+		}
+	}
+
+	for _, migration := range pending {
+		dbLog.Info("Applying migration", "version", migration.Version, "name", migration.Name)
+		if err := migrate.Apply(ctx, db, migration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runMigrationHook calls the hook and waits until it is done. A url is sent a
+// POST with the versions as json and has to answer with a 2xx. A command is
+// run by sh with MIGRATION_CURRENT_VERSION, MIGRATION_TARGET_VERSION and
+// MIGRATION_PENDING (the pending versions, comma separated) in its
+// environment and has to exit with 0.
+func runMigrationHook(ctx context.Context, cfg migrationConfig, current int64, pending []migrate.Migration) error {
+	ctx, cancel := context.WithTimeout(ctx, cfg.HookTimeout)
+	defer cancel()
+
+	target := pending[len(pending)-1].Version
+
+	if strings.HasPrefix(cfg.Hook, "http://") || strings.HasPrefix(cfg.Hook, "https://") {
+		body, err := json.Marshal(struct {
+			Event          string             `json:"event"`
+			CurrentVersion int64              `json:"current_version"`
+			TargetVersion  int64              `json:"target_version"`
+			Pending        []pendingMigration `json:"pending"`
+		}{"schema.migration.pending", current, target, describeMigrations(pending)})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Hook, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("hook answered %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	versions := make([]string, 0, len(pending))
+	for _, migration := range pending {
+		versions = append(versions, strconv.FormatInt(migration.Version, 10))
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", cfg.Hook)
+	cmd.Env = append(os.Environ(),
+		"MIGRATION_CURRENT_VERSION="+strconv.FormatInt(current, 10),
+		"MIGRATION_TARGET_VERSION="+strconv.FormatInt(target, 10),
+		"MIGRATION_PENDING="+strings.Join(versions, ","),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+/*
+Endpoint: GET /v1/admin/migrations
+
+# This is an admin endpoint
+
+Returns the schema version of the database, every migration applied or rolled
+back with its time, and the migrations this build has that aren't applied.
+*/
+func getMigrationsHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type MigrationsResponse struct {
+			CurrentVersion int64              `json:"current_version"`
+			History        []migrate.Record   `json:"history"`
+			Pending        []pendingMigration `json:"pending"`
+		}
+
+		migrations, err := migrate.Load(schema.Migrations)
+		if err != nil {
+			httpLog.Error("Error loading migrations", "err", err)
+			respondWithError(w, 500, "Error getting migrations")
+			return
+		}
+
+		context := r.Context()
+		history, err := migrate.History(context, apiConfig.Conn)
+		if err != nil {
+			httpLog.Error("Error getting migration history", "err", err)
+			respondWithError(w, 500, "Error getting migrations")
+			return
+		}
+		pending, err := migrate.Pending(context, apiConfig.Conn, migrations)
+		if err != nil {
+			httpLog.Error("Error getting pending migrations", "err", err)
+			respondWithError(w, 500, "Error getting migrations")
+			return
+		}
+		if history == nil {
+			history = []migrate.Record{}
+		}
+
+		respondWithJSON(w, 200, MigrationsResponse{
+			CurrentVersion: currentSchemaVersion(history),
+			History:        history,
+			Pending:        describeMigrations(pending),
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const ndjsonFlushInterval = time.Second
+
+// ndjsonWriter streams one JSON document per line, flushing periodically so
+// clients and proxies see progress on long exports.
+type ndjsonWriter struct {
+	w         http.ResponseWriter
+	enc       *json.Encoder
+	flusher   http.Flusher
+	lastFlush time.Time
+}
+
+func newNDJSONWriter(w http.ResponseWriter) *ndjsonWriter {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(200)
+
+	flusher, _ := w.(http.Flusher)
+	return &ndjsonWriter{
+		w:         w,
+		enc:       json.NewEncoder(w),
+		flusher:   flusher,
+		lastFlush: time.Now(),
+	}
+}
+
+func (n *ndjsonWriter) Write(v interface{}) error {
+	err := n.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	if time.Since(n.lastFlush) >= ndjsonFlushInterval {
+		n.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) Flush() {
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	n.lastFlush = time.Now()
+}
+
+func wantsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
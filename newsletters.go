@@ -0,0 +1,332 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+/*
+Newsletters come in by email. Every user can get an address on
+NEWSLETTER_DOMAIN, and what is sent to it becomes a post of their
+"Newsletters" feed:
+
+	3f9a...c2@newsletters.example.com
+
+The mail provider of the domain forwards the messages to
+POST /v1/newsletters/inbound, in the format of Mailgun routes. The feed has
+no url to fetch, it's stored under newsletter:<user id> and the scheduler
+skips it. It's private: other users don't see it in GET /v1/feeds or by id,
+and can't follow it.
+*/
+
+const (
+	newsletterFeedName  = "Newsletters"
+	newsletterURLPrefix = "newsletter:"
+	// newsletterMaxBody bounds an inbound message, attachments included
+	newsletterMaxBody = 25 << 20
+	// newsletterMaxAge is how old the timestamp of a signed request can be
+	newsletterMaxAge = 15 * time.Minute
+)
+
+var errNewslettersDisabled = errors.New("Newsletters are disabled")
+
+func isNewsletterFeed(feed database.Feed) bool {
+	return strings.HasPrefix(feed.Url, newsletterURLPrefix)
+}
+
+type newsletterAddressResponse struct {
+	Address   string    `json:"address"`
+	FeedID    uuid.UUID `json:"feed_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) newsletterAddressResponse(address database.NewsletterAddress) newsletterAddressResponse {
+	return newsletterAddressResponse{
+		Address:   address.Token + "@" + cfg.NewsletterDomain,
+		FeedID:    address.FeedID,
+		CreatedAt: address.CreatedAt,
+	}
+}
+
+/*
+Endpoint: POST /v1/newsletters/address
+
+# This is an authenticated endpoint
+
+Gives the user an email address for newsletters, along with the feed their
+issues are saved to, which the user follows. Asking again returns the same
+address.
+*/
+func postNewsletterAddressHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if apiConfig.NewsletterDomain == "" {
+			respondWithErrorCode(w, 404, "feature_disabled", errNewslettersDisabled.Error())
+			return
+		}
+
+		context := r.Context()
+		address, err := apiConfig.DB.GetNewsletterAddressByUser(context, user.ID)
+		if err == nil {
+			respondWithJSON(w, 200, apiConfig.newsletterAddressResponse(address))
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			httpLog.Error("Error getting newsletter address", "err", err)
+			respondWithError(w, 500, "Error creating newsletter address")
+			return
+		}
+
+		token, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating newsletter address", "err", err)
+			respondWithError(w, 500, "Error creating newsletter address")
+			return
+		}
+		// the local part of an address is at most 64 characters
+		token = token[:32]
+
+		var feedFollow database.FeedFollow
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			feed, err := q.CreateFeed(context, database.CreateFeedParams{
+				ID:        uuid.New(),
+				CreatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+				UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+				Name:      newsletterFeedName,
+				Url:       newsletterURLPrefix + user.ID.String(),
+				UserID:    user.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			address, err = q.CreateNewsletterAddress(context, database.CreateNewsletterAddressParams{
+				UserID:    user.ID,
+				FeedID:    feed.ID,
+				Token:     token,
+				CreatedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+
+			feedFollow, _, err = followFeed(context, q, user.ID, feed.ID)
+			return err
+		})
+		if err != nil {
+			httpLog.Error("Error creating newsletter address", "err", err)
+			respondWithError(w, 500, "Error creating newsletter address")
+			return
+		}
+
+		dispatchUserEvent(context, apiConfig, user.ID, eventFollowCreated, feedFollow)
+
+		respondCreated(w, "/v1/newsletters/address", apiConfig.newsletterAddressResponse(address))
+	}
+}
+
+/*
+Endpoint: GET /v1/newsletters/address
+
+# This is an authenticated endpoint
+
+Responds with the newsletter address of the user, 404 until they asked for one.
+*/
+func getNewsletterAddressHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if apiConfig.NewsletterDomain == "" {
+			respondWithErrorCode(w, 404, "feature_disabled", errNewslettersDisabled.Error())
+			return
+		}
+
+		address, err := apiConfig.DB.GetNewsletterAddressByUser(r.Context(), user.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 404, "No newsletter address yet")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting newsletter address", "err", err)
+			respondWithError(w, 500, "Error getting newsletter address")
+			return
+		}
+
+		respondWithJSON(w, 200, apiConfig.newsletterAddressResponse(address))
+	}
+}
+
+/*
+Endpoint: POST /v1/newsletters/inbound
+
+Receives a message sent to a newsletter address, as a Mailgun route forwards
+it: a form with recipient, from, subject, body-html, body-plain, Message-Id
+and Date, signed with timestamp, token and signature. The signature is checked
+against NEWSLETTER_SIGNING_KEY.
+
+Responds 406 to messages for unknown addresses, which tells Mailgun not to
+retry them.
+*/
+func postNewsletterInboundHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiConfig.NewsletterDomain == "" || len(apiConfig.NewsletterSigningKey) == 0 {
+			respondWithErrorCode(w, 404, "feature_disabled", errNewslettersDisabled.Error())
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, newsletterMaxBody)
+		err := r.ParseMultipartForm(1 << 20)
+		if errors.Is(err, http.ErrNotMultipart) {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_body", "Error decoding request")
+			return
+		}
+
+		if !verifyNewsletterSignature(apiConfig.NewsletterSigningKey, r.PostForm, time.Now()) {
+			respondWithError(w, 401, "Invalid signature")
+			return
+		}
+
+		token, ok := newsletterToken(r.PostForm.Get("recipient"), apiConfig.NewsletterDomain)
+		if !ok {
+			respondWithError(w, 406, "Unknown recipient")
+			return
+		}
+
+		context := r.Context()
+		address, err := apiConfig.DB.GetNewsletterAddressByToken(context, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 406, "Unknown recipient")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting newsletter address", "err", err)
+			respondWithError(w, 500, "Error receiving newsletter")
+			return
+		}
+
+		feed, err := apiConfig.DB.GetFeedByID(context, address.FeedID)
+		if err != nil {
+			httpLog.Error("Error getting newsletter feed", "err", err)
+			respondWithError(w, 500, "Error receiving newsletter")
+			return
+		}
+
+		item := newsletterItem(r.PostForm, time.Now())
+		posts, err := saveRssPosts(context, apiConfig, feed, &gofeed.Feed{Items: []*gofeed.Item{item}})
+		if err != nil {
+			httpLog.Error("Error saving newsletter", "guid", item.GUID, "err", err)
+			respondWithError(w, 500, "Error receiving newsletter")
+			return
+		}
+
+		respondWithJSON(w, 200, struct {
+			NewPosts int `json:"new_posts"`
+		}{len(posts)})
+	}
+}
+
+// verifyNewsletterSignature checks the signature Mailgun adds to the requests
+// it sends: the hex HMAC-SHA256 of timestamp and token under the signing key.
+func verifyNewsletterSignature(key []byte, form map[string][]string, now time.Time) bool {
+	get := func(name string) string {
+		if values := form[name]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	timestamp, token, signature := get("timestamp"), get("token"), get("signature")
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || token == "" {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > newsletterMaxAge || age < -newsletterMaxAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp + token))
+	return hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil))))
+}
+
+// newsletterToken is the local part of a recipient on the newsletter domain.
+func newsletterToken(recipient, domain string) (string, bool) {
+	// of several recipients the first is the one the route matched
+	recipient, _, _ = strings.Cut(recipient, ",")
+	addr, err := mail.ParseAddress(recipient)
+	if err != nil {
+		return "", false
+	}
+
+	local, host, ok := strings.Cut(addr.Address, "@")
+	if !ok || !strings.EqualFold(host, domain) || local == "" {
+		return "", false
+	}
+	return strings.ToLower(local), true
+}
+
+// newsletterItem turns an inbound message into an item. Messages have no url,
+// so the item links to its Message-Id.
+func newsletterItem(form map[string][]string, receivedAt time.Time) *gofeed.Item {
+	get := func(name string) string {
+		if values := form[name]; len(values) > 0 {
+			return strings.TrimSpace(values[0])
+		}
+		return ""
+	}
+
+	messageID := strings.Trim(get("Message-Id"), "<>")
+	if messageID == "" {
+		// some providers lowercase the headers they pass on
+		messageID = strings.Trim(get("message-id"), "<>")
+	}
+	if messageID == "" {
+		sum := sha256.Sum256([]byte(get("from") + get("subject") + get("Date")))
+		messageID = hex.EncodeToString(sum[:16])
+	}
+
+	item := &gofeed.Item{
+		GUID:        messageID,
+		Link:        "mid:" + messageID,
+		Title:       get("subject"),
+		Content:     get("body-html"),
+		Description: get("stripped-text"),
+	}
+	if item.Description == "" {
+		item.Description = get("body-plain")
+	}
+	if item.Title == "" {
+		item.Title = truncateTitle(strings.Join(strings.Fields(item.Description), " "), mastodonTitleLength)
+	}
+
+	if from, err := mail.ParseAddress(get("from")); err == nil {
+		name := from.Name
+		if name == "" {
+			name = from.Address
+		}
+		item.Authors = []*gofeed.Person{{Name: name, Email: from.Address}}
+	} else if from := get("from"); from != "" {
+		item.Authors = []*gofeed.Person{{Name: from}}
+	}
+
+	published := receivedAt
+	if date, err := mail.ParseDate(get("Date")); err == nil && date.Before(receivedAt.Add(time.Hour)) {
+		published = date
+	}
+	item.Published = published.Format(time.RFC3339)
+	item.PublishedParsed = &published
+
+	return item
+}
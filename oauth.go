@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const (
+	oauthCodeTTL  = 10 * time.Minute
+	oauthTokenTTL = 30 * 24 * time.Hour
+
+	scopeRead  = "read"
+	scopeWrite = "write"
+	// scopePostsWrite and scopeFeedsAdmin are the parts of write that API
+	// keys can be limited to: read state and bookmarks, and feeds and follows.
+	scopePostsWrite = "posts:write"
+	scopeFeedsAdmin = "feeds:admin"
+)
+
+var oauthScopes = []string{scopeRead, scopeWrite}
+
+var errTokenExpired = errors.New("Token expired")
+
+// pkceVerifier is a code_verifier of RFC 7636 section 4.1.
+var pkceVerifier = regexp.MustCompile(`^[A-Za-z0-9._~-]{43,128}$`)
+
+// pkceChallenge is the S256 code_challenge of the verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// hashToken is how codes, secrets and tokens are stored, so a database leak
+// doesn't hand out working credentials.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// scopesAllow decides whether a request with the given scopes may use method
+// on a route that writeScope lets change, see routeWriteScope. Nil scopes
+// mean the request was made with the user's own API key, which can do
+// anything. Any scope allows reading.
+func scopesAllow(scopes []string, method, writeScope string) bool {
+	if scopes == nil || slices.Contains(scopes, scopeWrite) {
+		return true
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return len(scopes) > 0
+	}
+
+	return writeScope != "" && slices.Contains(scopes, writeScope)
+}
+
+// routeWriteScope is the scope short of write that allows changes under
+// path, empty when only write will do.
+func routeWriteScope(path string) string {
+	under := func(prefix string) bool {
+		return path == prefix || strings.HasPrefix(path, prefix+"/")
+	}
+
+	switch {
+	case under("/v1/posts"), under("/v1/bookmarks"), under("/v1/batch"):
+		return scopePostsWrite
+	case under("/v1/feeds"), under("/v1/feed_follows"):
+		return scopeFeedsAdmin
+	}
+	return ""
+}
+
+func (cfg *apiConfig) authenticateOAuthToken(ctx context.Context, token string) (database.User, []string, error) {
+	oauthToken, err := cfg.DB.GetOAuthToken(ctx, hashToken(token))
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	if time.Now().After(oauthToken.ExpiresAt) {
+		return database.User{}, nil, errTokenExpired
+	}
+
+	user, err := cfg.DB.GetUserByID(ctx, oauthToken.UserID)
+	if err != nil {
+		return database.User{}, nil, err
+	}
+
+	scopes := oauthToken.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	return user, scopes, nil
+}
+
+/*
+Endpoint: POST /v1/oauth/clients
+
+# This endpoint requires an API key
+
+Registers a third-party app. The client secret is only returned here.
+*/
+func postOAuthClientHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ClientRequest struct {
+			Name         string   `json:"name"`
+			RedirectURIs []string `json:"redirect_uris"`
+		}
+
+		var req ClientRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		var v validation
+		v.require("name", req.Name, "Name is required")
+		if len(req.RedirectURIs) == 0 {
+			v.add("redirect_uris", "At least one redirect URI is required")
+		}
+		for _, redirectURI := range req.RedirectURIs {
+			u, err := url.Parse(redirectURI)
+			if err != nil || !u.IsAbs() || u.Fragment != "" {
+				v.add("redirect_uris", "Invalid redirect URI: "+redirectURI)
+			}
+		}
+		if v.respond(w) {
+			return
+		}
+
+		secret, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating client secret", "err", err)
+			respondWithError(w, 500, "Error creating client")
+			return
+		}
+
+		context := r.Context()
+		client, err := apiConfig.DB.CreateOAuthClient(context, database.CreateOAuthClientParams{
+			ID:           uuid.New(),
+			CreatedAt:    time.Now(),
+			UserID:       user.ID,
+			Name:         req.Name,
+			SecretHash:   hashToken(secret),
+			RedirectUris: req.RedirectURIs,
+		})
+		if err != nil {
+			httpLog.Error("Error creating oauth client", "err", err)
+			respondWithError(w, 500, "Error creating client")
+			return
+		}
+
+		type ClientResponse struct {
+			ClientID     uuid.UUID `json:"client_id"`
+			ClientSecret string    `json:"client_secret"`
+			Name         string    `json:"name"`
+			RedirectURIs []string  `json:"redirect_uris"`
+		}
+
+		respondWithJSON(w, 201, ClientResponse{
+			ClientID:     client.ID,
+			ClientSecret: secret,
+			Name:         client.Name,
+			RedirectURIs: client.RedirectUris,
+		})
+	}
+}
+
+/*
+Endpoint: POST /v1/oauth/authorize
+
+# This endpoint requires an API key
+
+Called by the user's own client once they approved the third-party app.
+Returns the redirect URI, carrying a single use authorization code, that the
+client should send the user to. redirect_uri has to be one the client
+registered, exactly.
+
+Public clients should use PKCE (RFC 7636): code_challenge is then passed on
+with code_challenge_method "S256", the only method supported, and the token
+request has to carry the code_verifier of it.
+*/
+func postOAuthAuthorizeHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type AuthorizeRequest struct {
+			ClientID            uuid.UUID `json:"client_id"`
+			RedirectURI         string    `json:"redirect_uri"`
+			Scope               string    `json:"scope"`
+			State               string    `json:"state"`
+			CodeChallenge       string    `json:"code_challenge"`
+			CodeChallengeMethod string    `json:"code_challenge_method"`
+		}
+
+		var req AuthorizeRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		client, err := apiConfig.DB.GetOAuthClient(context, req.ClientID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 400, "Unknown client")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting oauth client", "err", err)
+			respondWithError(w, 500, "Error authorizing client")
+			return
+		}
+
+		// the token request has to repeat it, so it's never filled in here
+		redirectURI := req.RedirectURI
+		if !slices.Contains(client.RedirectUris, redirectURI) {
+			respondWithError(w, 400, "Redirect URI is not registered for this client")
+			return
+		}
+
+		if req.CodeChallenge != "" || req.CodeChallengeMethod != "" {
+			if req.CodeChallengeMethod != "S256" {
+				respondWithError(w, 400, "Only the S256 code_challenge_method is supported")
+				return
+			}
+			// the challenge is a sha256 in unpadded base64url
+			if len(req.CodeChallenge) != 43 {
+				respondWithError(w, 400, "Invalid code_challenge")
+				return
+			}
+		}
+
+		scopes := strings.Fields(req.Scope)
+		if len(scopes) == 0 {
+			scopes = []string{scopeRead}
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(oauthScopes, scope) {
+				respondWithError(w, 400, "Unknown scope: "+scope)
+				return
+			}
+		}
+
+		code, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating authorization code", "err", err)
+			respondWithError(w, 500, "Error authorizing client")
+			return
+		}
+
+		err = apiConfig.DB.CreateOAuthCode(context, database.CreateOAuthCodeParams{
+			CodeHash:      hashToken(code),
+			ClientID:      client.ID,
+			UserID:        user.ID,
+			RedirectUri:   redirectURI,
+			Scopes:        scopes,
+			ExpiresAt:     time.Now().Add(oauthCodeTTL),
+			CodeChallenge: req.CodeChallenge,
+		})
+		if err != nil {
+			httpLog.Error("Error creating authorization code", "err", err)
+			respondWithError(w, 500, "Error authorizing client")
+			return
+		}
+
+		target, err := url.Parse(redirectURI)
+		if err != nil {
+			respondWithError(w, 500, "Error authorizing client")
+			return
+		}
+		query := target.Query()
+		query.Set("code", code)
+		if req.State != "" {
+			query.Set("state", req.State)
+		}
+		target.RawQuery = query.Encode()
+
+		respondWithJSON(w, 200, map[string]string{"redirect_uri": target.String()})
+	}
+}
+
+// respondWithOAuthError responds in the format of RFC 6749 section 5.2, which
+// OAuth clients expect from the token endpoint instead of the apiError one.
+func respondWithOAuthError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, map[string]string{"error": code})
+}
+
+/*
+Endpoint: POST /v1/oauth/token
+
+Exchanges an authorization code for an access token (RFC 6749 section 4.1.3).
+The client authenticates with client_id/client_secret form fields or HTTP Basic auth.
+redirect_uri has to be the one of the authorization, and code_verifier is
+required when it had a code_challenge.
+*/
+func postOAuthTokenHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := r.ParseForm()
+		if err != nil {
+			respondWithOAuthError(w, 400, "invalid_request")
+			return
+		}
+
+		if r.PostForm.Get("grant_type") != "authorization_code" {
+			respondWithOAuthError(w, 400, "unsupported_grant_type")
+			return
+		}
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID = r.PostForm.Get("client_id")
+			clientSecret = r.PostForm.Get("client_secret")
+		}
+
+		parsedClientID, err := uuid.Parse(clientID)
+		if err != nil {
+			respondWithOAuthError(w, 401, "invalid_client")
+			return
+		}
+
+		context := r.Context()
+		client, err := apiConfig.DB.GetOAuthClient(context, parsedClientID)
+		if err != nil || subtle.ConstantTimeCompare([]byte(client.SecretHash), []byte(hashToken(clientSecret))) != 1 {
+			respondWithOAuthError(w, 401, "invalid_client")
+			return
+		}
+
+		code, err := apiConfig.DB.ConsumeOAuthCode(context, hashToken(r.PostForm.Get("code")))
+		if err != nil {
+			respondWithOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") || time.Now().After(code.ExpiresAt) {
+			respondWithOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		if code.CodeChallenge != "" {
+			verifier := r.PostForm.Get("code_verifier")
+			if !pkceVerifier.MatchString(verifier) || subtle.ConstantTimeCompare([]byte(pkceChallenge(verifier)), []byte(code.CodeChallenge)) != 1 {
+				respondWithOAuthError(w, 400, "invalid_grant")
+				return
+			}
+		}
+
+		token, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating access token", "err", err)
+			respondWithOAuthError(w, 500, "server_error")
+			return
+		}
+
+		err = apiConfig.DB.CreateOAuthToken(context, database.CreateOAuthTokenParams{
+			TokenHash: hashToken(token),
+			ClientID:  client.ID,
+			UserID:    code.UserID,
+			Scopes:    code.Scopes,
+			CreatedAt: time.Now(),
+			ExpiresAt: time.Now().Add(oauthTokenTTL),
+		})
+		if err != nil {
+			httpLog.Error("Error creating access token", "err", err)
+			respondWithOAuthError(w, 500, "server_error")
+			return
+		}
+
+		type TokenResponse struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+			ExpiresIn   int    `json:"expires_in"`
+			Scope       string `json:"scope"`
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		respondWithJSON(w, 200, TokenResponse{
+			AccessToken: token,
+			TokenType:   "Bearer",
+			ExpiresIn:   int(oauthTokenTTL.Seconds()),
+			Scope:       strings.Join(code.Scopes, " "),
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestOAuthTokenPKCE(t *testing.T) {
+	clientID := uuid.New()
+	const secret = "secret"
+	const redirectURI = "https://app.example.com/callback"
+	verifier := strings.Repeat("v", 43)
+
+	fake, db := newFakeDB(t)
+	fake.on("GetOAuthClient", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{{
+			clientID.String(), time.Now(), uuid.NewString(), "App", hashToken(secret), "{" + redirectURI + "}",
+		}}}
+	})
+	fake.on("ConsumeOAuthCode", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{{
+			args[0], clientID.String(), uuid.NewString(), redirectURI, "{read}", time.Now().Add(time.Minute), pkceChallenge(verifier),
+		}}}
+	})
+	apiConfig := apiConfig{DB: db}
+
+	exchange := func(redirectURI, verifier string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {"code"},
+			"client_id":     {clientID.String()},
+			"client_secret": {secret},
+			"redirect_uri":  {redirectURI},
+			"code_verifier": {verifier},
+		}
+		r := httptest.NewRequest(http.MethodPost, "/v1/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		postOAuthTokenHandler(apiConfig)(w, r)
+		return w
+	}
+
+	if w := exchange(redirectURI, strings.Repeat("x", 43)); w.Code != 400 {
+		t.Errorf("wrong verifier: status = %d, want 400: %s", w.Code, w.Body)
+	}
+	if w := exchange(redirectURI, ""); w.Code != 400 {
+		t.Errorf("no verifier: status = %d, want 400: %s", w.Code, w.Body)
+	}
+	if w := exchange("https://app.example.com/other", verifier); w.Code != 400 {
+		t.Errorf("other redirect_uri: status = %d, want 400: %s", w.Code, w.Body)
+	}
+	if fake.ran("CreateOAuthToken") {
+		t.Fatal("a token was created for a failed exchange")
+	}
+	if w := exchange(redirectURI, verifier); w.Code != 200 {
+		t.Errorf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+/*
+Paginated list endpoints accept two query parameters:
+
+	limit   how many items to return, up to 500
+	cursor  where to continue, as returned for the previous page
+
+Items come newest first. Their order is stable: items with the same
+timestamp, like a batch of posts published at once, are ordered by their
+id, so going from page to page never skips or repeats an item.
+
+When there are more items the response has a Link header pointing at the
+next page, with all other query parameters kept:
+
+	Link: </v1/posts?cursor=MjAyNC0wMS0wMlQxNTowNDowNVp8...&limit=50>; rel="next"
+
+The last page has no Link header.
+
+A cursor is the sort key of the last item of a page: its timestamp in RFC
+3339 with fractional seconds, a "|" and its id, encoded as unpadded base64url.
+Clients should pass cursors back as they got them, the format is documented
+for debugging only.
+*/
+
+const (
+	pageDefaultLimit = 50
+	pageMaxLimit     = 500
+)
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// pageCursor is the position after the last item of a page.
+type pageCursor struct {
+	Time time.Time
+	ID   uuid.UUID
+}
+
+func (c pageCursor) String() string {
+	raw := c.Time.Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parsePageCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	timestamp, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return pageCursor{}, errInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	cursorID, err := uuid.Parse(id)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+
+	return pageCursor{Time: t, ID: cursorID}, nil
+}
+
+// pageRequest is the page a client asked for. Cursor is nil on the first
+// page.
+type pageRequest struct {
+	Limit  int
+	Cursor *pageCursor
+}
+
+// paginated reports whether the client asked for pages at all. Endpoints that
+// predate pagination return everything otherwise.
+func paginated(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Has("limit") || query.Has("cursor")
+}
+
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	page := pageRequest{Limit: pageDefaultLimit}
+
+	query := r.URL.Query()
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > pageMaxLimit {
+			return pageRequest{}, errors.New("Invalid limit, expected 1 to " + strconv.Itoa(pageMaxLimit))
+		}
+		page.Limit = n
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := parsePageCursor(cursor)
+		if err != nil {
+			return pageRequest{}, err
+		}
+		page.Cursor = &c
+	}
+
+	return page, nil
+}
+
+// parseUserPageRequest is parsePageRequest with the user's default limit, see
+// PATCH /v1/users/me.
+func parseUserPageRequest(r *http.Request, user database.User) (pageRequest, error) {
+	page, err := parsePageRequest(r)
+	if err == nil && !r.URL.Query().Has("limit") && user.DefaultPostLimit.Valid {
+		page.Limit = int(user.DefaultPostLimit.Int32)
+	}
+	return page, err
+}
+
+// BeforeTime and BeforeID are the cursor as query parameters, null on the
+// first page.
+func (p pageRequest) BeforeTime() sql.NullTime {
+	if p.Cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.Cursor.Time, Valid: true}
+}
+
+func (p pageRequest) BeforeID() uuid.NullUUID {
+	if p.Cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+// QueryLimit is one more than the page holds, the extra row tells whether
+// there is a next page.
+func (p pageRequest) QueryLimit() int32 {
+	return int32(p.Limit + 1)
+}
+
+// finishPage drops the extra row of the query and, when there was one, links
+// the next page.
+func finishPage[T any](w http.ResponseWriter, r *http.Request, page pageRequest, items []T, cursor func(T) pageCursor) []T {
+	if len(items) <= page.Limit {
+		return items
+	}
+	items = items[:page.Limit]
+
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", cursor(items[len(items)-1]).String())
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", "<"+next.RequestURI()+">; rel=\"next\"")
+
+	return items
+}
+
+// postSortTime is what posts are paged by. Posts saved before publication
+// dates were always filled in sort last, as in the queries.
+func postSortTime(publishedAt sql.NullTime) time.Time {
+	if !publishedAt.Valid {
+		return time.Unix(0, 0).UTC()
+	}
+	return publishedAt.Time
+}
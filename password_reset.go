@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const passwordResetTTL = time.Hour
+
+var errInvalidPasswordReset = errors.New("Invalid or expired token")
+
+// sendPasswordReset mails the user a token to set a new password with.
+func sendPasswordReset(ctx context.Context, apiConfig apiConfig, user database.User) error {
+	token, err := generateToken()
+	if err != nil {
+		return err
+	}
+
+	err = apiConfig.DB.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email.String,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nsomeone asked to reset the password of your account. If it was you, set a new one with this token at POST %s/v1/password/reset:\n\n%s\n\nThe token expires in an hour. If it wasn't you, ignore this mail.\n", user.Name, apiConfig.BaseURL, token)
+	return apiConfig.Mailer.Send(user.Email.String, "Reset your password", body)
+}
+
+/*
+Endpoint: POST /v1/password/forgot
+
+Mails a token to reset the password to the user with the email, when it is
+verified:
+
+	{
+		"email": "jane@example.com"
+	}
+
+The response is the same whether there is such a user or not. A few mails go
+out per user at once, then one a minute.
+*/
+func postForgotPasswordHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	limiter := newUserLimiter(emailRateInterval, emailRateBurst)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		type ForgotRequest struct {
+			Email string `json:"email"`
+		}
+
+		var req ForgotRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		user, err := apiConfig.DB.GetUserByEmail(context, req.Email)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			httpLog.Error("Error getting user", "err", err)
+			respondWithError(w, 500, "Error sending password reset")
+			return
+		}
+
+		// unknown emails and too many requests look like a sent mail, so the
+		// response doesn't tell who has an account
+		if err == nil && isEmailVerified(user) && limiter.Allow(user.ID) {
+			err = sendPasswordReset(context, apiConfig, user)
+			if err != nil {
+				httpLog.Error("Error sending password reset", "err", err)
+				respondWithError(w, 500, "Error sending password reset")
+				return
+			}
+		}
+
+		respondWithJSON(w, 202, map[string]string{"status": "sent"})
+	}
+}
+
+/*
+Endpoint: POST /v1/password/reset
+
+Sets a new password with a token from POST /v1/password/forgot. A token works
+once, and the reset revokes all refresh tokens:
+
+	{
+		"token": "...",
+		"password": "..."
+	}
+*/
+func postResetPasswordHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type ResetRequest struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		var req ResetRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		// before the token is used up, so a too short password can be retried
+		var v validation
+		v.check("password", validatePassword(req.Password))
+		if v.respond(w) {
+			return
+		}
+
+		passwordHash, err := hashPassword(req.Password)
+		if err != nil {
+			httpLog.Error("Error hashing password", "err", err)
+			respondWithError(w, 500, "Error resetting password")
+			return
+		}
+
+		context := r.Context()
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			reset, err := q.ConsumePasswordReset(context, hashToken(req.Token))
+			if errors.Is(err, sql.ErrNoRows) || (err == nil && time.Now().After(reset.ExpiresAt)) {
+				return errInvalidPasswordReset
+			}
+			if err != nil {
+				return err
+			}
+
+			user, err := q.GetUserByID(context, reset.UserID)
+			if err != nil {
+				return err
+			}
+			// the email changed since, the token went to someone else
+			if !user.Email.Valid || !strings.EqualFold(user.Email.String, reset.Email) {
+				return errInvalidPasswordReset
+			}
+
+			err = q.UpdateUserPassword(context, database.UpdateUserPasswordParams{
+				ID:           user.ID,
+				PasswordHash: passwordHash,
+			})
+			if err != nil {
+				return err
+			}
+			if err := q.DeleteUserPasswordResets(context, user.ID); err != nil {
+				return err
+			}
+			return q.DeleteUserSessions(context, user.ID)
+		})
+		if errors.Is(err, errInvalidPasswordReset) {
+			v.add("token", err.Error())
+			v.respond(w)
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error resetting password", "err", err)
+			respondWithError(w, 500, "Error resetting password")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+/*
+The posts of a user are a feed too, for other readers and automations that
+can't send an Authorization header. They read it with a feed token, in the
+query or in the path:
+
+	/v1/users/me/feed.xml?token=...
+	/v1/users/me/feed/....xml
+
+The token only reads the feed. Asking for a new one revokes the old one.
+*/
+
+// renderedFeedDefaultLimit and renderedFeedMaxLimit bound the posts of the
+// feeds rendered for other readers, see feedLimit.
+const (
+	renderedFeedDefaultLimit = 50
+	renderedFeedMaxLimit     = 200
+)
+
+/*
+Endpoint: POST /v1/users/me/feed_token
+
+# This endpoint requires an API key
+
+Creates the feed token of the user, replacing the one they had. The token is
+only returned here, along with the urls of the feed.
+*/
+func postFeedTokenHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type FeedTokenResponse struct {
+			Token   string    `json:"token"`
+			RSSURL  string    `json:"rss_url"`
+			AtomURL string    `json:"atom_url"`
+			JSONURL string    `json:"json_url"`
+			Created time.Time `json:"created_at"`
+		}
+
+		token, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating feed token", "err", err)
+			respondWithError(w, 500, "Error creating feed token")
+			return
+		}
+
+		feedToken, err := apiConfig.DB.UpsertUserFeedToken(r.Context(), database.UpsertUserFeedTokenParams{
+			UserID:    user.ID,
+			TokenHash: hashToken(token),
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			httpLog.Error("Error creating feed token", "err", err)
+			respondWithError(w, 500, "Error creating feed token")
+			return
+		}
+
+		feedURL := apiConfig.BaseURL + "/v1/users/me/feed/" + token
+		respondCreated(w, "/v1/users/me/feed_token", FeedTokenResponse{
+			Token:   token,
+			RSSURL:  feedURL + ".xml",
+			AtomURL: feedURL + ".xml?format=atom",
+			JSONURL: feedURL + ".json",
+			Created: feedToken.CreatedAt,
+		})
+	}
+}
+
+/*
+Endpoint: DELETE /v1/users/me/feed_token
+
+# This is an authenticated endpoint
+
+Revokes the feed token of the user.
+*/
+func deleteFeedTokenHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		err := apiConfig.DB.DeleteUserFeedToken(r.Context(), user.ID)
+		if err != nil {
+			httpLog.Error("Error deleting feed token", "err", err)
+			respondWithError(w, 500, "Error deleting feed token")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: GET /v1/users/me/feed.xml
+
+Renders the latest posts of the user as RSS, or as Atom with format=atom. The
+user is the one of the feed token, passed as token in the query or in the path
+as /v1/users/me/feed/{token}.xml. Without a token the Authorization header is
+used like on other endpoints.
+
+limit picks how many posts, 50 by default and at most 200.
+*/
+func getPersonalFeedHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := apiConfig.personalFeedUser(w, r)
+		if !ok {
+			return
+		}
+
+		limit, err := feedLimit(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "rss" && format != "atom" {
+			respondWithError(w, 400, "format must be rss or atom")
+			return
+		}
+
+		posts, err := apiConfig.DB.GetPostsPageByUser(r.Context(), database.GetPostsPageByUserParams{
+			UserID: user.ID,
+			Limit:  int32(limit),
+		})
+		if err != nil {
+			httpLog.Error("Error getting posts", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+
+		selfURL := apiConfig.BaseURL + "/v1/users/me/feed.xml"
+		var doc interface{}
+		contentType := "application/rss+xml; charset=utf-8"
+		if format == "atom" {
+			doc = atomPersonalFeed(user, posts, selfURL+"?format=atom")
+			contentType = "application/atom+xml; charset=utf-8"
+		} else {
+			doc = rssPersonalFeed(user, posts, selfURL)
+		}
+
+		data, err := xml.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			httpLog.Error("Error rendering feed", "err", err)
+			respondWithError(w, 500, "Error getting feed")
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		w.WriteHeader(200)
+		w.Write([]byte(xml.Header))
+		w.Write(data)
+	}
+}
+
+// personalFeedUser resolves the user of a personal feed from the feed token
+// in the path or query, or else the Authorization header. It writes the error
+// response itself when that fails.
+func (cfg *apiConfig) personalFeedUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+
+	if token == "" {
+		user, scopes, ok := cfg.authenticate(w, r)
+		if !ok {
+			return database.User{}, false
+		}
+		if !scopesAllow(scopes, r.Method, routeWriteScope(r.URL.Path)) {
+			respondWithErrorCode(w, 403, "insufficient_scope", "Insufficient scope")
+			return database.User{}, false
+		}
+		return user, true
+	}
+
+	context := r.Context()
+	feedToken, err := cfg.DB.GetUserFeedToken(context, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 401, "Unauthorized")
+		return database.User{}, false
+	}
+	if err != nil {
+		httpLog.Error("Error getting feed token", "err", err)
+		respondWithError(w, 500, "Error getting feed")
+		return database.User{}, false
+	}
+
+	user, err := cfg.DB.GetUserByID(context, feedToken.UserID)
+	if err != nil {
+		httpLog.Error("Error getting user", "err", err)
+		respondWithError(w, 500, "Error getting feed")
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// feedLimit reads how many posts a rendered feed has from the limit query
+// parameter.
+func feedLimit(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return renderedFeedDefaultLimit, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, errors.New("Invalid limit")
+	}
+	return min(n, renderedFeedMaxLimit), nil
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description,omitempty"`
+	Creator     string    `xml:"dc:creator,omitempty"`
+	GUID        rssGUID   `xml:"guid"`
+	PubDate     string    `xml:"pubDate,omitempty"`
+	Source      rssSource `xml:"source"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+func rssPersonalFeed(user database.User, posts []database.GetPostsPageByUserRow, selfURL string) rssFeed {
+	feed := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       "Posts of " + user.Name,
+			Link:        selfURL,
+			Description: "The latest posts of the feeds " + user.Name + " follows",
+			Self:        atomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if len(posts) > 0 {
+		feed.Channel.LastBuildDate = personalFeedUpdated(posts).Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		item := rssItem{
+			Title:       post.Title,
+			Link:        post.Url,
+			Description: post.Description,
+			Creator:     post.Author,
+			GUID:        rssGUID{Value: "urn:uuid:" + post.ID.String()},
+			Source:      rssSource{URL: post.Url_2, Name: post.Name},
+		}
+		if post.PublishedAt.Valid {
+			item.PubDate = post.PublishedAt.Time.Format(time.RFC1123Z)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+
+	return feed
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published,omitempty"`
+	Links     []atomLink  `xml:"link"`
+	Author    *atomPerson `xml:"author"`
+	Summary   *atomText   `xml:"summary"`
+	Content   *atomText   `xml:"content"`
+	Source    atomSource  `xml:"source"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomSource struct {
+	Title string     `xml:"title"`
+	Links []atomLink `xml:"link"`
+}
+
+func atomPersonalFeed(user database.User, posts []database.GetPostsPageByUserRow, selfURL string) atomFeed {
+	feed := atomFeed{
+		ID:      "urn:uuid:" + user.ID.String(),
+		Title:   "Posts of " + user.Name,
+		Updated: personalFeedUpdated(posts).Format(time.RFC3339),
+		Links:   []atomLink{{Href: selfURL, Rel: "self", Type: "application/atom+xml"}},
+		Author:  atomPerson{Name: user.Name},
+	}
+
+	for _, post := range posts {
+		updated := postUpdatedAt(post)
+		entry := atomEntry{
+			ID:      "urn:uuid:" + post.ID.String(),
+			Title:   post.Title,
+			Updated: updated.Format(time.RFC3339),
+			Links:   []atomLink{{Href: post.Url, Rel: "alternate"}},
+			Source: atomSource{
+				Title: post.Name,
+				Links: []atomLink{{Href: post.Url_2, Rel: "self"}},
+			},
+		}
+		if post.PublishedAt.Valid {
+			entry.Published = post.PublishedAt.Time.Format(time.RFC3339)
+		}
+		if post.Author != "" {
+			entry.Author = &atomPerson{Name: post.Author}
+		}
+		if post.Description != "" {
+			entry.Summary = &atomText{Type: "html", Value: post.Description}
+		}
+		if post.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: post.Content}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+// personalFeedUpdated is when the newest of the posts changed, now without
+// posts.
+func personalFeedUpdated(posts []database.GetPostsPageByUserRow) time.Time {
+	var updated time.Time
+	for _, post := range posts {
+		if t := postUpdatedAt(post); t.After(updated) {
+			updated = t
+		}
+	}
+	if updated.IsZero() {
+		return time.Now().UTC()
+	}
+	return updated
+}
+
+func postUpdatedAt(post database.GetPostsPageByUserRow) time.Time {
+	switch {
+	case post.UpdatedAt.Valid:
+		return post.UpdatedAt.Time.UTC()
+	case post.PublishedAt.Valid:
+		return post.PublishedAt.Time.UTC()
+	}
+	return post.CreatedAt.Time.UTC()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestPostFeedTokenHandler(t *testing.T) {
+	fake, db := newFakeDB(t)
+	fake.on("UpsertUserFeedToken", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{{args[0], args[1], args[2]}}}
+	})
+	apiConfig := apiConfig{DB: db, BaseURL: "https://example.com"}
+	user := database.User{ID: uuid.New()}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/users/me/feed_token", nil)
+	postFeedTokenHandler(apiConfig)(w, r, user)
+
+	if w.Code != 201 {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Location"); got != "/v1/users/me/feed_token" {
+		t.Errorf("Location = %q, want /v1/users/me/feed_token", got)
+	}
+
+	var resp struct {
+		Token   string    `json:"token"`
+		RSSURL  string    `json:"rss_url"`
+		Created time.Time `json:"created_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || resp.RSSURL != "https://example.com/v1/users/me/feed/"+resp.Token+".xml" {
+		t.Errorf("token = %q, rss_url = %q", resp.Token, resp.RSSURL)
+	}
+}
+
+func TestDeleteFeedTokenHandler(t *testing.T) {
+	fake, db := newFakeDB(t)
+	apiConfig := apiConfig{DB: db}
+	user := database.User{ID: uuid.New()}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/v1/users/me/feed_token", nil)
+	deleteFeedTokenHandler(apiConfig)(w, r, user)
+
+	if w.Code != 204 {
+		t.Fatalf("status = %d, want 204: %s", w.Code, w.Body)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("body = %q, want none", w.Body)
+	}
+	if !fake.ran("DeleteUserFeedToken") {
+		t.Error("the feed token wasn't deleted")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// fetchProxy is the proxy set for the deployment, nil without one.
+var fetchProxy func(*url.URL) (*url.URL, error)
+
+// trustedProxyAddrs are the host:ports of the proxies the operator set. They
+// may be on private networks, unlike the feeds behind them.
+var trustedProxyAddrs = map[string]bool{}
+
+var errInvalidProxy = errors.New("proxy must be an http, https, socks5 or socks5h url with a host")
+
+// configureFetchProxy sets the proxy of the fetcher from FETCH_PROXY: empty
+// for none, "env" for the proxies in HTTP_PROXY, HTTPS_PROXY and NO_PROXY, or
+// the url of a proxy for all fetches, socks5:// included.
+func configureFetchProxy(setting string) error {
+	switch setting {
+	case "":
+		return nil
+	case "env":
+		cfg := httpproxy.FromEnvironment()
+		for _, raw := range []string{cfg.HTTPProxy, cfg.HTTPSProxy} {
+			if raw == "" {
+				continue
+			}
+			if !strings.Contains(raw, "://") {
+				raw = "http://" + raw
+			}
+			proxyURL, err := parseProxyURL(raw)
+			if err != nil {
+				return err
+			}
+			trustedProxyAddrs[proxyAddr(proxyURL)] = true
+		}
+		fetchProxy = cfg.ProxyFunc()
+		return nil
+	}
+
+	proxyURL, err := parseProxyURL(setting)
+	if err != nil {
+		return err
+	}
+	trustedProxyAddrs[proxyAddr(proxyURL)] = true
+	fetchProxy = func(*url.URL) (*url.URL, error) { return proxyURL, nil }
+	return nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || proxyURL.Host == "" {
+		return nil, errInvalidProxy
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return proxyURL, nil
+	}
+	return nil, errInvalidProxy
+}
+
+// proxyAddr is the address the transport dials for the proxy.
+func proxyAddr(proxyURL *url.URL) string {
+	if proxyURL.Port() != "" {
+		return proxyURL.Host
+	}
+
+	port := "80"
+	switch proxyURL.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+type feedProxyKey struct{}
+
+// withFeedProxy has the requests made with ctx go through the proxy of a
+// feed instead of the one of the deployment.
+func withFeedProxy(ctx context.Context, proxyURL *url.URL) context.Context {
+	return context.WithValue(ctx, feedProxyKey{}, proxyURL)
+}
+
+// feedProxyFor is the Proxy of feedClient. Behind a proxy dialControl only
+// sees the proxy, so the destination of the request is checked here, on
+// every redirect too. Hosts that don't resolve here are refused. The proxy
+// resolves the host again, the check can't rule out a DNS answer that changes
+// in between.
+func feedProxyFor(req *http.Request) (*url.URL, error) {
+	proxyURL, _ := req.Context().Value(feedProxyKey{}).(*url.URL)
+	if proxyURL == nil && fetchProxy != nil {
+		var err error
+		proxyURL, err = fetchProxy(req.URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if proxyURL == nil {
+		return nil, nil
+	}
+
+	if err := checkPublicURL(req.Context(), req.URL.String()); err != nil {
+		return nil, err
+	}
+	return proxyURL, nil
+}
+
+// dialFeed connects to feeds and proxies. Only the proxies of the operator
+// skip dialControl, the proxy of a feed has to be public like any feed.
+func dialFeed(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   feedDialTimeout,
+		KeepAlive: feedKeepAlive,
+	}
+	if !trustedProxyAddrs[addr] {
+		dialer.Control = dialControl
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// repairPublishedDates dates the posts that were saved without a publication
+// date, they sort unpredictably otherwise. Feeds that still list those items
+// are fetched again and the dates parsed with what the fetcher knows today.
+// Posts whose items are gone count as published when they were first seen.
+func (f *fetcher) repairPublishedDates(ctx context.Context) {
+	feeds, err := f.apiConfig.DB.GetFeedsWithUndatedPosts(ctx)
+	if err != nil {
+		fetcherLog.Error("Error getting feeds with undated posts", "err", err)
+		return
+	}
+
+	var redated int64
+	for _, feed := range feeds {
+		n, err := f.redatePosts(ctx, feed)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fetcherLog.Error("Error re-parsing dates", "feed_url", feed.Url, "err", err)
+		}
+		redated += n
+	}
+
+	filled, err := f.apiConfig.DB.FillMissingPublishedAt(ctx)
+	if err != nil {
+		fetcherLog.Error("Error filling in missing publication dates", "err", err)
+		return
+	}
+
+	if redated > 0 || filled > 0 {
+		fetcherLog.Info("Dated posts without a publication date", "from_feeds", redated, "first_seen", filled)
+	}
+}
+
+// redatePosts fetches the feed in full and sets the dates of its undated
+// posts from the items.
+func (f *fetcher) redatePosts(ctx context.Context, feed database.Feed) (int64, error) {
+	err := f.hosts.Wait(ctx, feed.Url)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	// without the validators, a 304 would leave nothing to parse
+	feed.Etag, feed.LastModified = sql.NullString{}, sql.NullString{}
+	creds, err := loadFeedCredentials(ctx, f.apiConfig, feed.ID)
+	if err != nil {
+		return 0, err
+	}
+	scraper, err := loadFeedScraper(ctx, f.apiConfig.DB, feed.ID)
+	if err != nil {
+		return 0, err
+	}
+	result, err := getAndParseRssFeed(ctx, feed, creds, scraper)
+	if err != nil {
+		return 0, err
+	}
+
+	var redated int64
+	for _, item := range result.Feed.Items {
+		published, ok := parseItemDate(item)
+		if !ok {
+			continue
+		}
+
+		n, err := f.apiConfig.DB.SetMissingPublishedAt(ctx, database.SetMissingPublishedAtParams{
+			FeedID:      feed.ID,
+			Guid:        itemGUID(item),
+			PublishedAt: sql.NullTime{Time: published, Valid: true},
+		})
+		if err != nil {
+			return redated, err
+		}
+		redated += n
+	}
+
+	return redated, nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	pushMaxItems = 100
+	pushMaxBody  = 1 << 20
+
+	// pushMaxURLLength is what posts.url holds, the others keep pushed items
+	// to the size of fetched ones, the full text goes in content.
+	pushMaxURLLength         = 512
+	pushMaxGUIDLength        = 512
+	pushMaxTitleLength       = 255
+	pushMaxDescriptionLength = 1024
+)
+
+// pushedItem is an item sent to the push API, with the fields of a feed item.
+type pushedItem struct {
+	GUID        string          `json:"guid"`
+	Title       string          `json:"title"`
+	Url         string          `json:"url"`
+	Description string          `json:"description"`
+	Content     string          `json:"content"`
+	Author      string          `json:"author"`
+	PublishedAt *time.Time      `json:"published_at"`
+	Categories  []string        `json:"categories"`
+	Enclosures  []postEnclosure `json:"enclosures"`
+	ImageURL    string          `json:"image_url"`
+	// PublishAt keeps the item from followers until then, see
+	// publishScheduledItems.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+}
+
+// validate records what is wrong with the item, under field, the position of
+// the item in the request.
+func (p pushedItem) validate(v *validation, field string) {
+	guid, link := strings.TrimSpace(p.GUID), strings.TrimSpace(p.Url)
+	if guid == "" && link == "" {
+		v.add(field+".guid", "A guid or an url is required")
+	}
+	if utf8.RuneCountInString(guid) > pushMaxGUIDLength {
+		v.add(field+".guid", fmt.Sprintf("GUID longer than %d characters", pushMaxGUIDLength))
+	}
+	if link != "" && !isWebURL(link) {
+		v.add(field+".url", "URL must use http or https")
+	} else if utf8.RuneCountInString(link) > pushMaxURLLength {
+		v.add(field+".url", fmt.Sprintf("URL longer than %d characters", pushMaxURLLength))
+	}
+	if strings.TrimSpace(p.Title) == "" && p.Description == "" && p.Content == "" {
+		v.add(field+".title", "A title, description or content is required")
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(p.Title)) > pushMaxTitleLength {
+		v.add(field+".title", fmt.Sprintf("Title longer than %d characters", pushMaxTitleLength))
+	}
+	if utf8.RuneCountInString(p.Description) > pushMaxDescriptionLength {
+		v.add(field+".description", fmt.Sprintf("Description longer than %d characters", pushMaxDescriptionLength))
+	}
+	if p.ImageURL != "" && !isWebURL(p.ImageURL) {
+		v.add(field+".image_url", "Image URL must use http or https")
+	}
+	for i, enclosure := range p.Enclosures {
+		if !isWebURL(enclosure.URL) {
+			v.add(fmt.Sprintf("%s.enclosures[%d].url", field, i), "Enclosure URL must use http or https")
+		}
+	}
+}
+
+// feedItem turns the item into what the feed parser produces, so pushed items
+// are saved like fetched ones. The item is expected to be valid, see validate.
+func (p pushedItem) feedItem() *gofeed.Item {
+	item := &gofeed.Item{
+		GUID:        strings.TrimSpace(p.GUID),
+		Title:       strings.TrimSpace(p.Title),
+		Link:        strings.TrimSpace(p.Url),
+		Description: p.Description,
+		Content:     p.Content,
+		Categories:  p.Categories,
+	}
+	if p.Author != "" {
+		item.Authors = []*gofeed.Person{{Name: p.Author}}
+	}
+	if p.PublishedAt != nil {
+		item.Published = p.PublishedAt.Format(time.RFC3339)
+		item.PublishedParsed = p.PublishedAt
+	}
+	for _, enclosure := range p.Enclosures {
+		item.Enclosures = append(item.Enclosures, &gofeed.Enclosure{
+			URL:    enclosure.URL,
+			Type:   enclosure.Type,
+			Length: strconv.FormatInt(enclosure.Length, 10),
+		})
+	}
+	if p.ImageURL != "" {
+		item.Image = &gofeed.Image{URL: p.ImageURL}
+	}
+
+	return item
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+/*
+Endpoint: POST /v1/feeds/{feed_id}/items
+
+# This is an authenticated endpoint
+
+Pushes items into a feed without fetching it, for publishers and scripts that
+post announcements directly. Only the owner of the feed can push. Takes up to
+100 items:
+
+	{"items": [{"guid": "...", "title": "...", "url": "https://...",
+		"description": "...", "content": "...", "author": "...",
+		"published_at": "2024-01-02T15:04:05Z", "categories": ["..."],
+		"enclosures": [{"url": "...", "type": "audio/mpeg", "length": 123}],
+		"image_url": "https://...", "publish_at": "2024-01-03T09:00:00Z"}]}
+
+Every item needs a guid or an url, and a title, description or content.
+Titles are up to 255 characters, descriptions up to 1024. Invalid requests are
+answered 422 with every problem found, the field of an item named after its
+position, like items[3].url; nothing is saved then.
+
+Items are deduped and updated like fetched ones: pushing the same guid again
+updates the post. Items without published_at are dated when they are received.
+
+Items with a publish_at in the future are scheduled instead: followers don't
+see them until the scheduler publishes them, within a fetch interval of
+publish_at, dated publish_at unless they have a published_at. Pushing the same
+guid again replaces the scheduled item, with a publish_at or not.
+
+Responds with how many items were saved as new posts and how many were
+scheduled.
+*/
+func postFeedItemsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type PushRequest struct {
+			Items []pushedItem `json:"items"`
+		}
+		type PushResponse struct {
+			Received  int `json:"received"`
+			NewPosts  int `json:"new_posts"`
+			Scheduled int `json:"scheduled"`
+		}
+
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		var req PushRequest
+		if !decodeJSONBodyLimit(w, r, &req, pushMaxBody) {
+			return
+		}
+
+		var v validation
+		if len(req.Items) == 0 {
+			v.add("items", "At least one item is required")
+		}
+		if len(req.Items) > pushMaxItems {
+			v.add("items", fmt.Sprintf("At most %d items per request", pushMaxItems))
+		}
+		for i, pushed := range req.Items {
+			pushed.validate(&v, fmt.Sprintf("items[%d]", i))
+		}
+		if v.respond(w) {
+			return
+		}
+
+		content := &gofeed.Feed{}
+		var scheduled []pushedItem
+		now := time.Now()
+		for _, pushed := range req.Items {
+			if pushed.PublishAt != nil && pushed.PublishAt.After(now) {
+				scheduled = append(scheduled, pushed)
+				continue
+			}
+			content.Items = append(content.Items, pushed.feedItem())
+		}
+
+		context := r.Context()
+		feed, err := apiConfig.DB.GetFeedByID(context, feedID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "feed_not_found", "Feed not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error saving items")
+			return
+		}
+		if feed.UserID != user.ID {
+			respondWithError(w, 403, "Only the owner of the feed can push items")
+			return
+		}
+
+		// all or nothing, so a client can push the same items again after an
+		// error
+		var posts []database.Post
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			for _, pushed := range scheduled {
+				err := scheduleItem(context, q, feed.ID, pushed)
+				if err != nil {
+					return fmt.Errorf("scheduling item: %w", err)
+				}
+			}
+
+			// items pushed again without publish_at replace their scheduled
+			// version
+			for _, item := range content.Items {
+				err := q.DeleteScheduledItemByGuid(context, database.DeleteScheduledItemByGuidParams{
+					FeedID: feed.ID,
+					Guid:   itemGUID(item),
+				})
+				if err != nil {
+					return fmt.Errorf("unscheduling item: %w", err)
+				}
+			}
+
+			posts, err = storeRssPosts(context, q, feed, content)
+			return err
+		})
+		if err != nil {
+			httpLog.Error("Error saving pushed items", "feed_url", feed.Url, "err", err)
+			respondWithError(w, 500, "Error saving items")
+			return
+		}
+		announcePosts(context, apiConfig, feed, posts)
+
+		respondWithJSON(w, 200, PushResponse{
+			Received:  len(req.Items),
+			NewPosts:  len(posts),
+			Scheduled: len(scheduled),
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitSweepInterval is how often buckets that filled up again are
+// dropped, so clients that went away don't pile up.
+const rateLimitSweepInterval = time.Minute
+
+// rateLimitConfig are the request rates of the API. A client whose API key or
+// token checks out gets a bucket for its user, everyone else, with an invalid
+// credential too, one for their address. A rate of 0 turns that limit off.
+type rateLimitConfig struct {
+	PerMinute   int
+	Burst       int
+	IPPerMinute int
+	IPBurst     int
+}
+
+// rateLimitConfigFromEnv reads RATE_LIMIT_PER_MINUTE, RATE_LIMIT_BURST,
+// RATE_LIMIT_IP_PER_MINUTE and RATE_LIMIT_IP_BURST.
+func rateLimitConfigFromEnv() (rateLimitConfig, error) {
+	var cfg rateLimitConfig
+	var err error
+	if cfg.PerMinute, err = envInt("RATE_LIMIT_PER_MINUTE", 600, 0); err != nil {
+		return rateLimitConfig{}, err
+	}
+	if cfg.Burst, err = envInt("RATE_LIMIT_BURST", 60, 1); err != nil {
+		return rateLimitConfig{}, err
+	}
+	if cfg.IPPerMinute, err = envInt("RATE_LIMIT_IP_PER_MINUTE", 120, 0); err != nil {
+		return rateLimitConfig{}, err
+	}
+	if cfg.IPBurst, err = envInt("RATE_LIMIT_IP_BURST", 20, 1); err != nil {
+		return rateLimitConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// keyLimiter hands every key its own token bucket, like userLimiter does for
+// users.
+type keyLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	limiters  map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newKeyLimiter(perMinute, burst int) *keyLimiter {
+	return &keyLimiter{
+		limit:     rate.Limit(float64(perMinute) / 60),
+		burst:     burst,
+		limiters:  map[string]*rate.Limiter{},
+		lastSweep: time.Now(),
+	}
+}
+
+// take takes a token from key's bucket. When there is none it returns how
+// long until there is, and takes nothing.
+func (l *keyLimiter) take(key string, now time.Time) (remaining int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for k, limiter := range l.limiters {
+			if limiter.TokensAt(now) >= float64(l.burst) {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.limiters[key] = limiter
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return 0, delay
+	}
+
+	return int(limiter.TokensAt(now)), 0
+}
+
+// rateLimitMiddleware answers 429 with Retry-After to clients that are out of
+// requests, and tells everyone how many they have left:
+//
+//	X-RateLimit-Limit      the requests per minute
+//	X-RateLimit-Remaining  the requests that can be made right away
+//	X-RateLimit-Reset      the seconds until the next one is allowed, 0 while some are left
+//
+// The credential is checked here, so made up ones can't get around the limit
+// of the address. The outcome is kept for authenticate.
+func rateLimitMiddleware(cfg rateLimitConfig, check func(*http.Request) checkedCredential) func(http.Handler) http.Handler {
+	users := newKeyLimiter(cfg.PerMinute, cfg.Burst)
+	ips := newKeyLimiter(cfg.IPPerMinute, cfg.IPBurst)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter, perMinute, key := ips, cfg.IPPerMinute, "ip:"+remoteHost(r)
+			if r.Header.Get("Authorization") != "" {
+				checked := check(r)
+				r = r.WithContext(context.WithValue(r.Context(), checkedCredentialKey{}, checked))
+				if checked.err == nil {
+					limiter, perMinute, key = users, cfg.PerMinute, "user:"+checked.user.ID.String()
+				}
+			}
+			if perMinute == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			remaining, wait := limiter.take(key, time.Now())
+			reset := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(perMinute))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(reset))
+			if wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(reset))
+				respondWithError(w, 429, "Too many requests, try again later")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
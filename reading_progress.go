@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// readingProgressMaxAnchor bounds the scroll anchor a client can store.
+const readingProgressMaxAnchor = 1024
+
+type readingProgressResponse struct {
+	Percent   float64   `json:"percent"`
+	Anchor    string    `json:"anchor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newReadingProgressResponse(progress database.ReadingProgress) *readingProgressResponse {
+	return &readingProgressResponse{
+		Percent:   progress.Percent,
+		Anchor:    progress.Anchor,
+		UpdatedAt: progress.UpdatedAt,
+	}
+}
+
+// userReadingProgress returns how far the user got in the post, nil if they
+// never opened it.
+func userReadingProgress(ctx context.Context, q *database.Queries, userID, postID uuid.UUID) (*readingProgressResponse, error) {
+	progress, err := q.GetReadingProgress(ctx, database.GetReadingProgressParams{
+		UserID: userID,
+		PostID: postID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newReadingProgressResponse(progress), nil
+}
+
+/*
+Endpoint: PUT /v1/posts/{post_id}/progress
+
+# This is an authenticated endpoint
+
+Stores how far the user read into the post, so another device can resume
+there. percent goes from 0 to 100, anchor is anything the client can scroll
+back to, like the id of a heading, up to 1024 bytes:
+
+	{
+		"percent": 42.5,
+		"anchor": "section-3"
+	}
+
+The latest write wins. The progress is returned by GET
+/v1/posts/{post_id}/progress, GET /v1/posts/{post_id}/content and, the
+percent only, GET /v1/posts/compact.
+*/
+func putReadingProgressHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ProgressRequest struct {
+			Percent *float64 `json:"percent"`
+			Anchor  string   `json:"anchor"`
+		}
+
+		postID, err := uuid.Parse(chi.URLParam(r, "post_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid post_id")
+			return
+		}
+
+		var req ProgressRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+		if req.Percent == nil || math.IsNaN(*req.Percent) || *req.Percent < 0 || *req.Percent > 100 {
+			respondWithError(w, 400, "percent must be between 0 and 100")
+			return
+		}
+		if len(req.Anchor) > readingProgressMaxAnchor {
+			respondWithError(w, 400, "anchor is too long")
+			return
+		}
+
+		context := r.Context()
+		_, err = apiConfig.DB.GetPostByID(context, postID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "post_not_found", "Post not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting post", "err", err)
+			respondWithError(w, 500, "Error saving progress")
+			return
+		}
+
+		progress, err := apiConfig.DB.UpsertReadingProgress(context, database.UpsertReadingProgressParams{
+			UserID:    user.ID,
+			PostID:    postID,
+			UpdatedAt: time.Now(),
+			Percent:   *req.Percent,
+			Anchor:    req.Anchor,
+		})
+		if err != nil {
+			httpLog.Error("Error saving reading progress", "err", err)
+			respondWithError(w, 500, "Error saving progress")
+			return
+		}
+
+		respondWithJSON(w, 200, newReadingProgressResponse(progress))
+	}
+}
+
+/*
+Endpoint: GET /v1/posts/{post_id}/progress
+
+# This is an authenticated endpoint
+
+Returns how far the user read into the post, 404 if they never opened it.
+*/
+func getReadingProgressHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		postID, err := uuid.Parse(chi.URLParam(r, "post_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid post_id")
+			return
+		}
+
+		progress, err := userReadingProgress(r.Context(), apiConfig.DB, user.ID, postID)
+		if err != nil {
+			httpLog.Error("Error getting reading progress", "err", err)
+			respondWithError(w, 500, "Error getting progress")
+			return
+		}
+		if progress == nil {
+			respondWithError(w, 404, "No progress for this post")
+			return
+		}
+
+		respondWithJSON(w, 200, progress)
+	}
+}
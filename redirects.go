@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// permanentRedirect returns the url the response was finally served from when
+// every redirect on the way there was permanent (301 or 308), and "" when
+// there were no redirects or one of them was only temporary.
+func permanentRedirect(resp *http.Response) string {
+	if resp.Request == nil || resp.Request.Response == nil {
+		return ""
+	}
+
+	for req := resp.Request; req.Response != nil; req = req.Response.Request {
+		status := req.Response.StatusCode
+		if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+			return ""
+		}
+	}
+
+	movedTo, err := normalizeFeedURL(resp.Request.URL.String())
+	if err != nil {
+		return ""
+	}
+
+	return movedTo
+}
+
+// errFeedNotMerged is a moved feed that has to stay on its own, see mergeFeeds.
+var errFeedNotMerged = errors.New("feed has credentials or a scraper of its owner, or the feed it moved to is private")
+
+// moveFeed points the feed at the url it permanently redirects to. If another
+// feed already lives at that url, the followers of this feed are moved over to
+// it, see mergeFeeds.
+func (f *fetcher) moveFeed(ctx context.Context, feed database.Feed, newURL string) {
+	if newURL == feed.Url {
+		return
+	}
+
+	err := database.InTx(ctx, f.apiConfig.DB, func(q *database.Queries) error {
+		target, err := q.GetFeedByUrl(ctx, newURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = q.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{ID: feed.ID, Url: newURL})
+			if err != nil {
+				return err
+			}
+			target = feed
+		} else if err != nil {
+			return err
+		} else {
+			err = mergeFeeds(ctx, q, feed, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		return q.CreateFeedUrlChange(ctx, database.CreateFeedUrlChangeParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			FeedID:    target.ID,
+			OldUrl:    feed.Url,
+			NewUrl:    newURL,
+		})
+	})
+	if errors.Is(err, errFeedNotMerged) {
+		fetcherLog.Warn("Feed moved to another feed, not merging", "feed_url", feed.Url, "new_url", newURL, "err", err)
+		return
+	}
+	if err != nil {
+		fetcherLog.Error("Error moving feed", "feed_url", feed.Url, "new_url", newURL, "err", err)
+		return
+	}
+
+	fetcherLog.Info("Feed moved permanently", "feed_url", feed.Url, "new_url", newURL)
+}
+
+// mergeFeeds moves the followers of from, with their chat notifications and
+// telegram subscriptions, over to into and disables from. Users following both
+// keep their existing follow of into.
+//
+// Anyone controlling the old url can redirect it anywhere, so nothing of from
+// is written into into: its posts, and the bookmarks and reading progress on
+// them, stay with the disabled from, as do its notes and fetch logs. A feed
+// with credentials or a scraper of its owner isn't merged at all, and no feed
+// is merged into one with credentials, whose posts are its owner's alone.
+func mergeFeeds(ctx context.Context, q *database.Queries, from, into database.Feed) error {
+	for _, feedID := range []uuid.UUID{from.ID, into.ID} {
+		_, err := q.GetFeedCredentials(ctx, feedID)
+		if err == nil {
+			return errFeedNotMerged
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+	}
+	_, err := q.GetFeedScraper(ctx, from.ID)
+	if err == nil {
+		return errFeedNotMerged
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	err = q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{ToFeedID: into.ID, FromFeedID: from.ID})
+	if err != nil {
+		return err
+	}
+	err = q.MoveChatNotifications(ctx, database.MoveChatNotificationsParams{ToFeedID: into.ID, FromFeedID: from.ID})
+	if err != nil {
+		return err
+	}
+	err = q.MoveTelegramFeeds(ctx, database.MoveTelegramFeedsParams{ToFeedID: into.ID, FromFeedID: from.ID})
+	if err != nil {
+		return err
+	}
+
+	return q.DisableFeed(ctx, database.DisableFeedParams{
+		ID:             from.ID,
+		DisabledReason: sql.NullString{String: "Moved to " + into.Url, Valid: true},
+	})
+}
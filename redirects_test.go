@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestMergeFeedsMovesOnlyFollows(t *testing.T) {
+	from := database.Feed{ID: uuid.New(), Url: "https://old.example.com/feed"}
+	into := database.Feed{ID: uuid.New(), Url: "https://new.example.com/feed"}
+
+	fake, db := newFakeDB(t)
+	err := mergeFeeds(context.Background(), db, from, into)
+	if err != nil {
+		t.Fatalf("mergeFeeds: %v", err)
+	}
+
+	for _, query := range []string{"MoveFeedFollows", "MoveChatNotifications", "MoveTelegramFeeds", "DisableFeed"} {
+		if !fake.ran(query) {
+			t.Errorf("%s didn't run", query)
+		}
+	}
+	if fake.ran("DeleteFeed") {
+		t.Error("the moved feed was deleted, and its posts with it")
+	}
+}
+
+func TestMergeFeedsWithCredentials(t *testing.T) {
+	for _, name := range []string{"from", "into"} {
+		from := database.Feed{ID: uuid.New(), Url: "https://old.example.com/feed"}
+		into := database.Feed{ID: uuid.New(), Url: "https://new.example.com/feed"}
+		withCredentials := from.ID
+		if name == "into" {
+			withCredentials = into.ID
+		}
+
+		fake, db := newFakeDB(t)
+		fake.on("GetFeedCredentials", func(args []driver.Value) fakeResult {
+			if args[0] != withCredentials.String() {
+				return fakeResult{}
+			}
+			return fakeResult{rows: [][]driver.Value{{withCredentials.String(), time.Now(), []byte("sealed")}}}
+		})
+
+		err := mergeFeeds(context.Background(), db, from, into)
+		if !errors.Is(err, errFeedNotMerged) {
+			t.Errorf("%s has credentials: err = %v, want errFeedNotMerged", name, err)
+		}
+		if fake.ran("MoveFeedFollows") {
+			t.Errorf("%s has credentials: follows were moved", name)
+		}
+	}
+}
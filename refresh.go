@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"golang.org/x/time/rate"
+)
+
+const (
+	refreshRateInterval = 10 * time.Second
+	refreshRateBurst    = 3
+)
+
+// userLimiter hands every user their own token bucket.
+type userLimiter struct {
+	every time.Duration
+	burst int
+
+	mu       sync.Mutex
+	limiters map[uuid.UUID]*rate.Limiter
+}
+
+func newUserLimiter(every time.Duration, burst int) *userLimiter {
+	return &userLimiter{
+		every:    every,
+		burst:    burst,
+		limiters: map[uuid.UUID]*rate.Limiter{},
+	}
+}
+
+func (l *userLimiter) Allow(userID uuid.UUID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.limiters[userID]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(l.every), l.burst)
+		l.limiters[userID] = limiter
+	}
+
+	return limiter.Allow()
+}
+
+/*
+Endpoint: POST /v1/feeds/{feed_id}/refresh
+
+# This is an authenticated endpoint
+
+Fetches the feed right away instead of waiting for the scheduler and responds
+with the refreshed feed and how many new posts were saved. Only the owner and
+the followers of the feed can. A user can refresh 3 times in a row, then once
+every 10 seconds.
+*/
+func refreshFeedHandler(apiConfig apiConfig, feedFetcher *fetcher) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	limiter := newUserLimiter(refreshRateInterval, refreshRateBurst)
+
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feed, ok := getFollowedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		if !limiter.Allow(user.ID) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(refreshRateInterval.Seconds())))
+			respondWithError(w, 429, "Too many refreshes, try again later")
+			return
+		}
+
+		if feed.DisabledAt.Valid {
+			respondWithError(w, 409, "Feed is disabled, enable it first")
+			return
+		}
+		if isNewsletterFeed(feed) {
+			respondWithError(w, 409, "Newsletters arrive by email and can't be refreshed")
+			return
+		}
+
+		context := r.Context()
+		outcome, err := feedFetcher.fetchNow(context, feed)
+		if errors.Is(err, errFetchInFlight) {
+			respondWithError(w, 409, err.Error())
+			return
+		}
+		if err != nil {
+			// followers find the error in the fetch log
+			httpLog.Warn("Error refreshing feed", "feed_url", feed.Url, "err", err)
+			respondWithError(w, 502, "Error fetching feed")
+			return
+		}
+
+		feed, err = apiConfig.DB.GetFeedByID(context, feed.ID)
+		if err != nil {
+			httpLog.Error("Error getting feed", "err", err)
+			respondWithError(w, 500, "Error refreshing feed")
+			return
+		}
+
+		type RefreshResponse struct {
+			Feed database.Feed `json:"feed"`
+			fetchOutcome
+		}
+
+		respondWithJSON(w, 200, RefreshResponse{Feed: feed, fetchOutcome: outcome})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern is what an X-Request-ID from a client or proxy has to look
+// like to be kept, anything else gets a new id.
+var requestIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// requestInfo is filled in while a request is handled, for its log line.
+type requestInfo struct {
+	ID     string
+	UserID uuid.NullUUID
+}
+
+type requestInfoKey struct{}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// setRequestUser records who made the request once they are authenticated.
+func setRequestUser(r *http.Request, userID uuid.UUID) {
+	if info := requestInfoFrom(r.Context()); info != nil {
+		info.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+}
+
+// requestLogMiddleware gives every request an id, sent back as X-Request-ID
+// so users can quote it in bug reports, and logs a line for it once it is
+// done, with the route pattern it matched. An X-Request-ID of the client or a
+// proxy in front is kept.
+func requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		info := &requestInfo{ID: id}
+		w.Header().Set(requestIDHeader, id)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+		// the pattern rather than the path, paths can carry secrets like the
+		// token of a personal feed
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []any{
+			"request_id", id,
+			"method", r.Method,
+			"route", route,
+			"status", sw.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		if info.UserID.Valid {
+			attrs = append(attrs, "user_id", info.UserID.UUID)
+		}
+		httpLog.Info("request", attrs...)
+	})
+}
+
+// statusWriter remembers the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush keeps streaming responses (NDJSON) working through the wrapper.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets websockets upgrade through the wrapper.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// retentionConfig is how long personal data is kept. Zero keeps it forever.
+type retentionConfig struct {
+	// WebhookDeliveries covers the delivery log and the dead letters of
+	// webhooks, including the payloads that were sent. Queued deliveries are
+	// kept until they are done.
+	WebhookDeliveries time.Duration
+	// AuditLog covers earlier versions of feed notes and who wrote them, the
+	// current note of a feed is always kept.
+	AuditLog time.Duration
+}
+
+// retentionConfigFromEnv reads DATA_RETENTION_WEBHOOK_DELIVERIES and
+// DATA_RETENTION_AUDIT_LOG.
+func retentionConfigFromEnv() (retentionConfig, error) {
+	cfg := retentionConfig{
+		WebhookDeliveries: 30 * 24 * time.Hour,
+		AuditLog:          0,
+	}
+
+	var err error
+	if cfg.WebhookDeliveries, err = envDuration("DATA_RETENTION_WEBHOOK_DELIVERIES", cfg.WebhookDeliveries, 0); err != nil {
+		return retentionConfig{}, err
+	}
+	if cfg.AuditLog, err = envDuration("DATA_RETENTION_AUDIT_LOG", cfg.AuditLog, 0); err != nil {
+		return retentionConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// purgeReport counts the rows a purge deleted.
+type purgeReport struct {
+	WebhookDeliveries  int64
+	WebhookDeadLetters int64
+	FeedNotes          int64
+	OAuthCodes         int64
+	OAuthTokens        int64
+	Sessions           int64
+	SocialLoginStates  int64
+	EmailVerifications int64
+	PasswordResets     int64
+	TelegramLinkCodes  int64
+	TelegramMessages   int64
+	SyncChanges        int64
+}
+
+// purgePersonalData deletes personal data that has outlived its retention,
+// and credentials and verification links that have expired.
+func purgePersonalData(ctx context.Context, q *database.Queries, cfg retentionConfig) (purgeReport, error) {
+	var report purgeReport
+	var err error
+
+	if cfg.WebhookDeliveries > 0 {
+		if report.WebhookDeliveries, err = q.DeleteWebhookDeliveriesBefore(ctx, time.Now().Add(-cfg.WebhookDeliveries)); err != nil {
+			return report, err
+		}
+		if report.WebhookDeadLetters, err = q.DeleteWebhookDeadLettersBefore(ctx, time.Now().Add(-cfg.WebhookDeliveries)); err != nil {
+			return report, err
+		}
+	}
+	if cfg.AuditLog > 0 {
+		if report.FeedNotes, err = q.DeleteFeedNoteHistoryBefore(ctx, time.Now().Add(-cfg.AuditLog)); err != nil {
+			return report, err
+		}
+	}
+	if report.OAuthCodes, err = q.DeleteExpiredOAuthCodes(ctx); err != nil {
+		return report, err
+	}
+	if report.OAuthTokens, err = q.DeleteExpiredOAuthTokens(ctx); err != nil {
+		return report, err
+	}
+	if report.Sessions, err = q.DeleteExpiredSessions(ctx); err != nil {
+		return report, err
+	}
+	if report.SocialLoginStates, err = q.DeleteExpiredSocialLoginStates(ctx); err != nil {
+		return report, err
+	}
+	if report.EmailVerifications, err = q.DeleteExpiredEmailVerifications(ctx); err != nil {
+		return report, err
+	}
+	if report.PasswordResets, err = q.DeleteExpiredPasswordResets(ctx); err != nil {
+		return report, err
+	}
+	if report.TelegramLinkCodes, err = q.DeleteExpiredTelegramLinkCodes(ctx); err != nil {
+		return report, err
+	}
+	if report.TelegramMessages, err = q.DeleteTelegramMessagesBefore(ctx, time.Now().Add(-telegramMessageRetention)); err != nil {
+		return report, err
+	}
+	if report.SyncChanges, err = q.DeleteSyncChangesBefore(ctx, time.Now().Add(-syncChangeRetention)); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// retentionSeconds is a retention for the privacy endpoint, null when the
+// data is kept forever.
+func retentionSeconds(d time.Duration) *int64 {
+	if d == 0 {
+		return nil
+	}
+	seconds := int64(d.Seconds())
+	return &seconds
+}
+
+/*
+Endpoint: GET /v1/privacy
+
+Describes the personal data the instance stores and for how long, for
+compliance tooling and privacy notices. A null retention_seconds means the
+data is kept until the user or an admin deletes it.
+*/
+func getPrivacyHandler(cfg retentionConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type DataCategory struct {
+			Category         string `json:"category"`
+			Description      string `json:"description"`
+			RetentionSeconds *int64 `json:"retention_seconds"`
+		}
+		type PrivacyResponse struct {
+			Stored    []DataCategory `json:"stored"`
+			NotStored []string       `json:"not_stored"`
+		}
+
+		expiry := int64(0)
+		respondWithJSON(w, 200, PrivacyResponse{
+			Stored: []DataCategory{
+				{"account", "User name, email address, hashed api key and password", nil},
+				{"identities", "Accounts at GitHub or Google linked to a user, with their email", nil},
+				{"api_keys", "Names and scopes of extra api keys, the keys only hashed", nil},
+				{"subscriptions", "Feeds a user added or follows", nil},
+				{"bookmarks", "Bookmarked posts and imported bookmarks", nil},
+				{"reading_progress", "How far a user read into posts, to resume on another device", nil},
+				{"sync_changes", "Log of changed posts, read state and follows for offline clients", retentionSeconds(syncChangeRetention)},
+				{"webhooks", "Webhook urls and secrets of a user", nil},
+				{"chat_notifications", "Slack and Discord webhook urls and keywords of a user", nil},
+				{"telegram", "Telegram chat of a user and the feeds sent to it", nil},
+				{"newsletters", "Newsletter address of a user and the emails received at it", nil},
+				{"telegram_messages", "Which post a message sent to Telegram was about, for replies to it", retentionSeconds(telegramMessageRetention)},
+				{"feed_credentials", "Usernames, passwords and headers of private feeds, encrypted", nil},
+				{"webhook_deliveries", "Log and dead letters of webhook deliveries with their payloads", retentionSeconds(cfg.WebhookDeliveries)},
+				{"audit_log", "Earlier feed notes and the users who wrote them", retentionSeconds(cfg.AuditLog)},
+				{"credentials", "OAuth codes and tokens, login sessions and logins in progress, email verification and password reset links, Telegram link codes, deleted once expired", &expiry},
+			},
+			NotStored: []string{"ip_addresses"},
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/rss"
+)
+
+const (
+	refreshIntervalMin     = 15 * time.Minute
+	refreshIntervalMax     = 24 * time.Hour
+	refreshIntervalDefault = time.Hour
+
+	// publishing frequency is learned from this many of the newest items
+	refreshSampleSize = 10
+)
+
+// refreshInterval decides how long to wait before polling a feed again. Busy
+// feeds are polled about twice per post, quiet ones down to once a day. A
+// ttl or sy:updatePeriod hint in the feed is the shortest interval used.
+func refreshInterval(feed *gofeed.Feed) time.Duration {
+	interval := refreshIntervalDefault
+	if gap, ok := publishingInterval(feed); ok {
+		interval = gap / 2
+	}
+
+	if hint, ok := feedUpdateHint(feed); ok && hint > interval {
+		interval = hint
+	}
+
+	return min(max(interval, refreshIntervalMin), refreshIntervalMax)
+}
+
+// publishingInterval is the average time between the newest items of the feed.
+func publishingInterval(feed *gofeed.Feed) (time.Duration, bool) {
+	var published []time.Time
+	for _, item := range feed.Items {
+		if item.PublishedParsed != nil {
+			published = append(published, *item.PublishedParsed)
+		} else if item.UpdatedParsed != nil {
+			published = append(published, *item.UpdatedParsed)
+		}
+	}
+	if len(published) < 2 {
+		return 0, false
+	}
+
+	slices.SortFunc(published, func(a, b time.Time) int { return b.Compare(a) })
+	published = published[:min(len(published), refreshSampleSize)]
+
+	span := published[0].Sub(published[len(published)-1])
+	if span <= 0 {
+		return 0, false
+	}
+
+	return span / time.Duration(len(published)-1), true
+}
+
+// feedUpdateHint reads the RSS <ttl> or the syndication module's
+// updatePeriod/updateFrequency, whichever the feed provides.
+func feedUpdateHint(feed *gofeed.Feed) (time.Duration, bool) {
+	if ttl, err := strconv.Atoi(feed.Custom[customTTL]); err == nil && ttl > 0 {
+		return time.Duration(ttl) * time.Minute, true
+	}
+
+	sy, ok := feed.Extensions["sy"]
+	if !ok || len(sy["updatePeriod"]) == 0 {
+		return 0, false
+	}
+
+	var period time.Duration
+	switch strings.TrimSpace(sy["updatePeriod"][0].Value) {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 30 * 24 * time.Hour
+	case "yearly":
+		period = 365 * 24 * time.Hour
+	default:
+		return 0, false
+	}
+
+	frequency := 1
+	if len(sy["updateFrequency"]) > 0 {
+		if n, err := strconv.Atoi(strings.TrimSpace(sy["updateFrequency"][0].Value)); err == nil && n > 0 {
+			frequency = n
+		}
+	}
+
+	return period / time.Duration(frequency), true
+}
+
+// customTTL is where hintTranslator keeps the RSS <ttl>, which the universal
+// gofeed.Feed has no field for.
+const customTTL = "ttl"
+
+type hintTranslator struct {
+	gofeed.DefaultRSSTranslator
+}
+
+func (t *hintTranslator) Translate(feed interface{}) (*gofeed.Feed, error) {
+	result, err := t.DefaultRSSTranslator.Translate(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	if rssFeed, ok := feed.(*rss.Feed); ok && rssFeed.TTL != "" {
+		if result.Custom == nil {
+			result.Custom = map[string]string{}
+		}
+		result.Custom[customTTL] = strings.TrimSpace(rssFeed.TTL)
+	}
+
+	return result, nil
+}
+
+func newFeedParser() *gofeed.Parser {
+	parser := gofeed.NewParser()
+	parser.RSSTranslator = &hintTranslator{}
+	return parser
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+// scheduledItemsBatch is how many due items are published per run.
+const scheduledItemsBatch = 500
+
+// scheduleItem stores a pushed item to be published at its publish_at,
+// replacing an item scheduled before with the same guid.
+func scheduleItem(ctx context.Context, q *database.Queries, feedID uuid.UUID, pushed pushedItem) error {
+	item := pushed.feedItem()
+
+	data, err := json.Marshal(pushed)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.UpsertScheduledItem(ctx, database.UpsertScheduledItemParams{
+		ID:        uuid.New(),
+		CreatedAt: time.Now(),
+		FeedID:    feedID,
+		Guid:      itemGUID(item),
+		PublishAt: *pushed.PublishAt,
+		Item:      data,
+	})
+	return err
+}
+
+// publishScheduledItems saves the scheduled items that are due as posts, so
+// followers see them and webhooks fire only now. An item is removed once
+// saved, one that can't be is retried on the next run.
+func (f *fetcher) publishScheduledItems(ctx context.Context) {
+	due, err := f.apiConfig.DB.GetDueScheduledItems(ctx, database.GetDueScheduledItemsParams{
+		PublishAt: time.Now(),
+		Limit:     scheduledItemsBatch,
+	})
+	if err != nil {
+		fetcherLog.Error("Error getting scheduled items", "err", err)
+		return
+	}
+
+	feeds := map[uuid.UUID]database.Feed{}
+	for _, scheduled := range due {
+		if ctx.Err() != nil {
+			return
+		}
+
+		feed, ok := feeds[scheduled.FeedID]
+		if !ok {
+			feed, err = f.apiConfig.DB.GetFeedByID(ctx, scheduled.FeedID)
+			if err != nil {
+				fetcherLog.Error("Error getting feed of scheduled item", "scheduled_id", scheduled.ID, "err", err)
+				continue
+			}
+			feeds[feed.ID] = feed
+		}
+
+		err := publishScheduledItem(ctx, f.apiConfig, feed, scheduled)
+		if err != nil {
+			fetcherLog.Error("Error publishing scheduled item", "scheduled_id", scheduled.ID, "err", err)
+			continue
+		}
+
+		_, err = f.apiConfig.DB.DeleteScheduledItem(ctx, database.DeleteScheduledItemParams{
+			ID:     scheduled.ID,
+			FeedID: scheduled.FeedID,
+		})
+		if err != nil {
+			fetcherLog.Error("Error removing scheduled item", "scheduled_id", scheduled.ID, "err", err)
+		}
+	}
+}
+
+func publishScheduledItem(ctx context.Context, apiConfig apiConfig, feed database.Feed, scheduled database.ScheduledItem) error {
+	var pushed pushedItem
+	err := json.Unmarshal(scheduled.Item, &pushed)
+	if err != nil {
+		return err
+	}
+	if pushed.PublishedAt == nil {
+		pushed.PublishedAt = &scheduled.PublishAt
+	}
+
+	_, err = saveRssPosts(ctx, apiConfig, feed, &gofeed.Feed{Items: []*gofeed.Item{pushed.feedItem()}})
+	return err
+}
+
+// scheduledItemResponse is a scheduled item as the owner of the feed sees it.
+type scheduledItemResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	PublishAt time.Time  `json:"publish_at"`
+	Guid      string     `json:"guid"`
+	Item      pushedItem `json:"item"`
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/items/scheduled
+
+# This is an authenticated endpoint
+
+Lists the items pushed into the feed that wait for their publish_at, soonest
+first. Only the owner of the feed can see them.
+*/
+func getScheduledItemsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feed, ok := getOwnedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		items, err := apiConfig.DB.GetScheduledItemsByFeed(r.Context(), feed.ID)
+		if err != nil {
+			httpLog.Error("Error getting scheduled items", "err", err)
+			respondWithError(w, 500, "Error getting scheduled items")
+			return
+		}
+
+		resp := make([]scheduledItemResponse, 0, len(items))
+		for _, item := range items {
+			scheduled := scheduledItemResponse{
+				ID:        item.ID,
+				CreatedAt: item.CreatedAt,
+				PublishAt: item.PublishAt,
+				Guid:      item.Guid,
+			}
+			err := json.Unmarshal(item.Item, &scheduled.Item)
+			if err != nil {
+				httpLog.Error("Error decoding scheduled item", "item_id", item.ID, "err", err)
+			}
+			resp = append(resp, scheduled)
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/feeds/{feed_id}/items/scheduled/{item_id}
+
+# This is an authenticated endpoint
+
+Cancels a scheduled item before it is published.
+*/
+func deleteScheduledItemHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		itemID, err := uuid.Parse(chi.URLParam(r, "item_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid item_id")
+			return
+		}
+
+		feed, ok := getOwnedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		deleted, err := apiConfig.DB.DeleteScheduledItem(r.Context(), database.DeleteScheduledItemParams{
+			ID:     itemID,
+			FeedID: feed.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting scheduled item", "err", err)
+			respondWithError(w, 500, "Error deleting scheduled item")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "scheduled_item_not_found", "Scheduled item not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/mmcdole/gofeed"
+)
+
+// feedScraperMaxItems bounds the items taken from a single page.
+const feedScraperMaxItems = 200
+
+var errNoScrapedItems = errors.New("The selectors match no items with a link on the page")
+
+// scrapedDateLayouts are the date formats tried on the text of the date
+// selector, after the datetime attribute of a <time> element.
+var scrapedDateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"2 Jan 2006",
+	"Monday, January 2, 2006",
+	"02.01.2006",
+}
+
+// feedScraper turns an html page into a feed for sites without one. Item
+// selects the element of each post, the others are looked up inside it: Link
+// the link of the post, the first <a> by default, Title its title, the text
+// of the link by default, and Date when it was published. Selectors are CSS.
+type feedScraper struct {
+	Item  string `json:"item"`
+	Link  string `json:"link"`
+	Title string `json:"title"`
+	Date  string `json:"date"`
+}
+
+func (s *feedScraper) normalize() error {
+	s.Item = strings.TrimSpace(s.Item)
+	s.Link = strings.TrimSpace(s.Link)
+	s.Title = strings.TrimSpace(s.Title)
+	s.Date = strings.TrimSpace(s.Date)
+
+	if s.Item == "" {
+		return errors.New("The scraper needs an item selector")
+	}
+	for name, selector := range map[string]string{"item": s.Item, "link": s.Link, "title": s.Title, "date": s.Date} {
+		if selector == "" {
+			continue
+		}
+		if _, err := cascadia.Compile(selector); err != nil {
+			return fmt.Errorf("Invalid %s selector: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// scraperFind selects inside sel, or returns sel itself when selector is empty.
+func scraperFind(sel *goquery.Selection, selector string) *goquery.Selection {
+	if selector == "" {
+		return sel
+	}
+	return sel.FindMatcher(cascadia.MustCompile(selector)).First()
+}
+
+// scrape builds a feed from the items the selectors find on the page at
+// pageURL. Links are resolved against the page, items without one are skipped.
+func (s *feedScraper) scrape(body []byte, contentType, pageURL string) (*gofeed.Feed, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(feedBodyToUTF8(body, contentType)))
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title:    strings.TrimSpace(doc.Find("title").First().Text()),
+		Link:     pageURL,
+		FeedType: "scrape",
+	}
+	if description, ok := doc.Find(`meta[name="description"]`).First().Attr("content"); ok {
+		feed.Description = strings.TrimSpace(description)
+	}
+
+	seen := map[string]bool{}
+	doc.FindMatcher(cascadia.MustCompile(s.Item)).EachWithBreak(func(_ int, item *goquery.Selection) bool {
+		link := item.Find("a[href]").First()
+		if s.Link != "" {
+			link = scraperFind(item, s.Link)
+		} else if goquery.NodeName(item) == "a" {
+			link = item
+		}
+
+		href, ok := link.Attr("href")
+		if !ok {
+			return true
+		}
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return true
+		}
+		itemURL := base.ResolveReference(ref)
+		if itemURL.Scheme != "http" && itemURL.Scheme != "https" {
+			return true
+		}
+		itemURL.Fragment = ""
+		if seen[itemURL.String()] {
+			return true
+		}
+		seen[itemURL.String()] = true
+
+		title := strings.TrimSpace(link.Text())
+		if s.Title != "" {
+			title = strings.TrimSpace(scraperFind(item, s.Title).Text())
+		}
+
+		feedItem := &gofeed.Item{
+			Title: strings.Join(strings.Fields(title), " "),
+			Link:  itemURL.String(),
+			GUID:  itemURL.String(),
+		}
+		if s.Date != "" {
+			if published, ok := parseScrapedDate(scraperFind(item, s.Date)); ok {
+				feedItem.PublishedParsed = &published
+				feedItem.Published = published.Format(time.RFC3339)
+			}
+		}
+
+		feed.Items = append(feed.Items, feedItem)
+		return len(feed.Items) < feedScraperMaxItems
+	})
+
+	if len(feed.Items) == 0 {
+		return nil, errNoScrapedItems
+	}
+	return feed, nil
+}
+
+func parseScrapedDate(sel *goquery.Selection) (time.Time, bool) {
+	candidates := []string{}
+	if datetime, ok := sel.Attr("datetime"); ok {
+		candidates = append(candidates, datetime)
+	}
+	candidates = append(candidates, strings.Join(strings.Fields(sel.Text()), " "))
+
+	for _, candidate := range candidates {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		for _, layout := range scrapedDateLayouts {
+			if t, err := time.Parse(layout, candidate); err == nil {
+				return t.UTC(), true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// scrapeURL fetches the page and scrapes it, to check the selectors before
+// they are saved.
+func scrapeURL(ctx context.Context, pageURL string, creds *feedCredentials, scraper *feedScraper) (*gofeed.Feed, error) {
+	body, contentType, err := fetchForDiscovery(ctx, pageURL, creds)
+	if err != nil {
+		return nil, err
+	}
+	return scraper.scrape(body, contentType, pageURL)
+}
+
+func saveFeedScraper(ctx context.Context, q *database.Queries, feedID uuid.UUID, scraper feedScraper) (database.FeedScraper, error) {
+	return q.UpsertFeedScraper(ctx, database.UpsertFeedScraperParams{
+		FeedID:        feedID,
+		UpdatedAt:     time.Now(),
+		ItemSelector:  scraper.Item,
+		LinkSelector:  scraper.Link,
+		TitleSelector: scraper.Title,
+		DateSelector:  scraper.Date,
+	})
+}
+
+// loadFeedScraper returns the scraper of the feed, nil when it is a real feed.
+func loadFeedScraper(ctx context.Context, q *database.Queries, feedID uuid.UUID) (*feedScraper, error) {
+	stored, err := q.GetFeedScraper(ctx, feedID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newFeedScraper(stored), nil
+}
+
+func newFeedScraper(stored database.FeedScraper) *feedScraper {
+	return &feedScraper{
+		Item:  stored.ItemSelector,
+		Link:  stored.LinkSelector,
+		Title: stored.TitleSelector,
+		Date:  stored.DateSelector,
+	}
+}
+
+/*
+Endpoint: GET /v1/feeds/{feed_id}/scraper
+
+# This is an authenticated endpoint
+
+Returns the selectors the feed is scraped with, 404 if it is a real feed.
+*/
+func getFeedScraperHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feedID, err := uuid.Parse(chi.URLParam(r, "feed_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid feed_id")
+			return
+		}
+
+		scraper, err := loadFeedScraper(r.Context(), apiConfig.DB, feedID)
+		if err != nil {
+			httpLog.Error("Error getting feed scraper", "err", err)
+			respondWithError(w, 500, "Error getting scraper")
+			return
+		}
+		if scraper == nil {
+			respondWithError(w, 404, "Feed is not scraped")
+			return
+		}
+
+		respondWithJSON(w, 200, scraper)
+	}
+}
+
+/*
+Endpoint: PUT /v1/feeds/{feed_id}/scraper
+
+# This is an authenticated endpoint
+
+Scrapes the page of the feed with CSS selectors from now on, instead of parsing
+it as a feed, for sites that don't publish one:
+
+	{
+		"item": "article.post",
+		"link": "h2 a",
+		"title": "h2",
+		"date": "time"
+	}
+
+Only item is required, see feedScraper for the defaults. The page is fetched
+first and the selectors have to find at least one item with a link. Only the
+owner of the feed can set them.
+*/
+func putFeedScraperHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		var scraper feedScraper
+		if !decodeJSONBody(w, r, &scraper) {
+			return
+		}
+		var v validation
+		v.check("scraper", scraper.normalize())
+		if v.respond(w) {
+			return
+		}
+
+		feed, ok := getOwnedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		context := r.Context()
+		creds, err := loadFeedCredentials(context, apiConfig, feed.ID)
+		if err != nil {
+			httpLog.Error("Error loading credentials", "feed_url", feed.Url, "err", err)
+			respondWithError(w, 500, "Error saving scraper")
+			return
+		}
+
+		_, err = scrapeURL(context, feed.Url, creds, &scraper)
+		if errors.Is(err, errNoScrapedItems) {
+			v.add("scraper", err.Error())
+			v.respond(w)
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error scraping", "feed_url", feed.Url, "err", err)
+			respondWithError(w, 400, "Error fetching the page of the feed")
+			return
+		}
+
+		stored, err := saveFeedScraper(context, apiConfig.DB, feed.ID, scraper)
+		if err != nil {
+			httpLog.Error("Error saving feed scraper", "err", err)
+			respondWithError(w, 500, "Error saving scraper")
+			return
+		}
+
+		respondWithJSON(w, 200, newFeedScraper(stored))
+	}
+}
+
+/*
+Endpoint: DELETE /v1/feeds/{feed_id}/scraper
+
+# This is an authenticated endpoint
+
+Stops scraping the feed, its url is parsed as a feed again.
+*/
+func deleteFeedScraperHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		feed, ok := getOwnedFeed(w, r, apiConfig, user)
+		if !ok {
+			return
+		}
+
+		deleted, err := apiConfig.DB.DeleteFeedScraper(r.Context(), feed.ID)
+		if err != nil {
+			httpLog.Error("Error deleting feed scraper", "err", err)
+			respondWithError(w, 500, "Error deleting scraper")
+			return
+		}
+		if deleted == 0 {
+			respondWithError(w, 404, "Feed is not scraped")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+// sessionResponse is a login of the user, without its refresh token.
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+func newSessionResponse(session database.Session) sessionResponse {
+	return sessionResponse{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+		UserAgent:  session.UserAgent,
+	}
+}
+
+/*
+Endpoint: GET /v1/sessions
+
+# This endpoint requires an API key
+
+Lists the logins of the user that haven't expired, the devices they are on,
+most recently used first. A session starts with POST /v1/login or a social
+login and lasts through its refreshes: created_at is when the user logged in,
+last_used_at when the session was last refreshed and user_agent the
+User-Agent of that request.
+*/
+func getSessionsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		sessions, err := apiConfig.DB.GetUserSessions(r.Context(), database.GetUserSessionsParams{
+			UserID:    user.ID,
+			ExpiresAt: time.Now(),
+		})
+		if err != nil {
+			httpLog.Error("Error getting sessions", "err", err)
+			respondWithError(w, 500, "Error getting sessions")
+			return
+		}
+
+		resp := make([]sessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			resp = append(resp, newSessionResponse(session))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/sessions/{session_id}
+
+# This endpoint requires an API key
+
+Revokes a session, like POST /v1/logout with its refresh token. Its access
+tokens keep working until they expire.
+*/
+func deleteSessionHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		sessionID, err := uuid.Parse(chi.URLParam(r, "session_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid session_id")
+			return
+		}
+
+		deleted, err := apiConfig.DB.DeleteSessionByID(r.Context(), database.DeleteSessionByIDParams{
+			ID:     sessionID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting session", "err", err)
+			respondWithError(w, 500, "Error deleting session")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "session_not_found", "Session not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/sessions
+
+# This endpoint requires an API key
+
+Revokes all sessions of the user, logging them out everywhere. Their access
+tokens keep working until they expire.
+*/
+func deleteSessionsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		err := apiConfig.DB.DeleteUserSessions(r.Context(), user.ID)
+		if err != nil {
+			httpLog.Error("Error deleting sessions", "err", err)
+			respondWithError(w, 500, "Error deleting sessions")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
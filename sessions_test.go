@@ -0,0 +1,70 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+func TestRefreshKeepsSession(t *testing.T) {
+	sessionID, userID := uuid.New(), uuid.New()
+	loggedInAt := time.Now().Add(-24 * time.Hour).UTC()
+
+	fake, db := newFakeDB(t)
+	fake.on("ConsumeSession", func(args []driver.Value) fakeResult {
+		return fakeResult{rows: [][]driver.Value{{
+			args[0], userID.String(), loggedInAt, time.Now().Add(time.Hour), sessionID.String(), loggedInAt, "old agent",
+		}}}
+	})
+	var created []driver.Value
+	fake.on("CreateSession", func(args []driver.Value) fakeResult {
+		created = args
+		return fakeResult{}
+	})
+	apiConfig := apiConfig{DB: db, JWTSecret: []byte("secret")}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/refresh", strings.NewReader(`{"refresh_token": "token"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", "new agent")
+	postRefreshHandler(apiConfig)(w, r)
+
+	if w.Code != 200 {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	if created == nil {
+		t.Fatal("no new refresh token was stored")
+	}
+	// id, token_hash, user_id, created_at, last_used_at, expires_at, user_agent
+	if created[0] != sessionID.String() {
+		t.Errorf("id = %v, want %v", created[0], sessionID)
+	}
+	if created[3] != loggedInAt {
+		t.Errorf("created_at = %v, want %v", created[3], loggedInAt)
+	}
+	if created[6] != "new agent" {
+		t.Errorf("user_agent = %v, want new agent", created[6])
+	}
+}
+
+func TestDeleteSessionOfAnotherUser(t *testing.T) {
+	_, db := newFakeDB(t)
+	apiConfig := apiConfig{DB: db}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/v1/sessions/"+uuid.NewString(), nil)
+	router := chi.NewRouter()
+	router.Delete("/v1/sessions/{session_id}", asUser(database.User{ID: uuid.New()}, deleteSessionHandler(apiConfig)))
+	router.ServeHTTP(w, r)
+
+	if w.Code != 404 {
+		t.Errorf("status = %d, want 404: %s", w.Code, w.Body)
+	}
+}
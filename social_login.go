@@ -0,0 +1,604 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+/*
+Users can log in with an account at GitHub or Google instead of a password.
+A provider is on once its client id and secret are set:
+
+	GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET
+	GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET
+
+registered at the provider with {BASE_URL}/v1/auth/{provider}/callback as
+the callback url. SOCIAL_LOGIN_REDIRECT_URIS lists, comma separated, the
+pages of clients the tokens can be sent to after a login.
+
+The first login with an account links it to the user with the same verified
+email, or creates a new user. Logged in users can link more accounts with
+POST /v1/auth/{provider}/link.
+*/
+
+const socialLoginStateTTL = 10 * time.Minute
+
+var (
+	errUnknownProvider     = errors.New("Unknown login provider")
+	errInvalidLoginState   = errors.New("Invalid or expired login, start over")
+	errIdentityLinked      = errors.New("This account is linked to another user")
+	errSocialEmailTaken    = errors.New("A user with this email exists, log in and link the account from there")
+	errInvalidRedirectURI  = errors.New("Invalid redirect_uri, it isn't in SOCIAL_LOGIN_REDIRECT_URIS")
+	socialUserNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+)
+
+var socialHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// socialProvider is an OAuth 2 provider users can log in with.
+type socialProvider struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	Scopes       []string
+	// identity tells whose account an access token is for.
+	identity func(ctx context.Context, accessToken string) (socialIdentity, error)
+}
+
+// socialIdentity is an account at a provider. Login is a name to start from
+// for new users.
+type socialIdentity struct {
+	Subject       string
+	Login         string
+	Email         string
+	EmailVerified bool
+}
+
+func socialProvidersFromEnv() map[string]*socialProvider {
+	providers := map[string]*socialProvider{}
+
+	if id, secret := os.Getenv("GITHUB_CLIENT_ID"), os.Getenv("GITHUB_CLIENT_SECRET"); id != "" && secret != "" {
+		providers["github"] = &socialProvider{
+			ClientID:     id,
+			ClientSecret: secret,
+			AuthURL:      "https://github.com/login/oauth/authorize",
+			TokenURL:     "https://github.com/login/oauth/access_token",
+			Scopes:       []string{"read:user", "user:email"},
+			identity:     githubIdentity,
+		}
+	}
+	if id, secret := os.Getenv("GOOGLE_CLIENT_ID"), os.Getenv("GOOGLE_CLIENT_SECRET"); id != "" && secret != "" {
+		providers["google"] = &socialProvider{
+			ClientID:     id,
+			ClientSecret: secret,
+			AuthURL:      "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL:     "https://oauth2.googleapis.com/token",
+			Scopes:       []string{"openid", "email", "profile"},
+			identity:     googleIdentity,
+		}
+	}
+
+	return providers
+}
+
+func socialRedirectURIsFromEnv() []string {
+	var uris []string
+	for _, uri := range strings.Split(os.Getenv("SOCIAL_LOGIN_REDIRECT_URIS"), ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+func (p *socialProvider) authorizeURL(callbackURL, state string) string {
+	query := url.Values{
+		"client_id":     {p.ClientID},
+		"redirect_uri":  {callbackURL},
+		"response_type": {"code"},
+		"scope":         {strings.Join(p.Scopes, " ")},
+		"state":         {state},
+	}
+	return p.AuthURL + "?" + query.Encode()
+}
+
+// exchange trades the code of the callback for an access token.
+func (p *socialProvider) exchange(ctx context.Context, callbackURL, code string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {p.ClientID},
+		"client_secret": {p.ClientSecret},
+		"redirect_uri":  {callbackURL},
+		"code":          {code},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := socialHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token: %d %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+
+	return token.AccessToken, nil
+}
+
+func getSocialJSON(ctx context.Context, rawURL, accessToken string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := socialHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func githubIdentity(ctx context.Context, accessToken string) (socialIdentity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getSocialJSON(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return socialIdentity{}, err
+	}
+
+	// the email of /user is whatever the user made public, verified or not
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getSocialJSON(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return socialIdentity{}, err
+	}
+
+	identity := socialIdentity{Subject: strconv.FormatInt(user.ID, 10), Login: user.Login}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	return identity, nil
+}
+
+func googleIdentity(ctx context.Context, accessToken string) (socialIdentity, error) {
+	var userinfo struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err := getSocialJSON(ctx, "https://openidconnect.googleapis.com/v1/userinfo", accessToken, &userinfo); err != nil {
+		return socialIdentity{}, err
+	}
+
+	login, _, _ := strings.Cut(userinfo.Email, "@")
+	return socialIdentity{
+		Subject:       userinfo.Sub,
+		Login:         login,
+		Email:         userinfo.Email,
+		EmailVerified: userinfo.EmailVerified,
+	}, nil
+}
+
+func socialCallbackURL(apiConfig apiConfig, provider string) string {
+	return apiConfig.BaseURL + "/v1/auth/" + provider + "/callback"
+}
+
+// startSocialLogin stores the state of a login at the provider and returns
+// the url to send the user to.
+func startSocialLogin(ctx context.Context, apiConfig apiConfig, name string, userID uuid.NullUUID, redirectURI string) (string, error) {
+	provider := apiConfig.SocialProviders[name]
+	if provider == nil {
+		return "", errUnknownProvider
+	}
+	if redirectURI != "" && !slices.Contains(apiConfig.SocialRedirectURIs, redirectURI) {
+		return "", errInvalidRedirectURI
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = apiConfig.DB.CreateSocialLoginState(ctx, database.CreateSocialLoginStateParams{
+		StateHash:   hashToken(state),
+		Provider:    name,
+		UserID:      userID,
+		RedirectUri: redirectURI,
+		ExpiresAt:   time.Now().Add(socialLoginStateTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return provider.authorizeURL(socialCallbackURL(apiConfig, name), state), nil
+}
+
+/*
+Endpoint: GET /v1/auth/{provider}/login?redirect_uri=
+
+Sends the user to log in at the provider, github or google. Once they did,
+the callback responds like POST /v1/login, or with redirect_uri sends the
+browser there with the tokens, or an error, in the fragment:
+
+	https://app.example.com/login#access_token=...&expires_at=...&refresh_token=...&refresh_expires_at=...
+	https://app.example.com/login#error=...
+*/
+func getSocialLoginHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redirectURI := r.URL.Query().Get("redirect_uri")
+		authorizeURL, err := startSocialLogin(r.Context(), apiConfig, chi.URLParam(r, "provider"), uuid.NullUUID{}, redirectURI)
+		if errors.Is(err, errUnknownProvider) {
+			respondWithErrorCode(w, 404, "provider_not_found", err.Error())
+			return
+		}
+		if errors.Is(err, errInvalidRedirectURI) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error starting social login", "err", err)
+			respondWithError(w, 500, "Error starting login")
+			return
+		}
+
+		http.Redirect(w, r, authorizeURL, http.StatusFound)
+	}
+}
+
+/*
+Endpoint: POST /v1/auth/{provider}/link
+
+# This endpoint requires an API key
+
+Or an access token. Returns the url to send the user to, to link their
+account at the provider. The callback then sends them to redirect_uri, with
+linked or error in the fragment, or responds with the linked account:
+
+	{
+		"redirect_uri": "https://app.example.com/settings"
+	}
+*/
+func postSocialLinkHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type LinkRequest struct {
+			RedirectURI string `json:"redirect_uri"`
+		}
+
+		var req LinkRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		authorizeURL, err := startSocialLogin(r.Context(), apiConfig, chi.URLParam(r, "provider"), uuid.NullUUID{UUID: user.ID, Valid: true}, req.RedirectURI)
+		if errors.Is(err, errUnknownProvider) {
+			respondWithErrorCode(w, 404, "provider_not_found", err.Error())
+			return
+		}
+		if errors.Is(err, errInvalidRedirectURI) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error starting social login", "err", err)
+			respondWithError(w, 500, "Error starting login")
+			return
+		}
+
+		type LinkResponse struct {
+			URL string `json:"url"`
+		}
+
+		respondWithJSON(w, 200, LinkResponse{URL: authorizeURL})
+	}
+}
+
+/*
+Endpoint: GET /v1/auth/{provider}/callback
+
+Where the provider sends the user back to, see GET /v1/auth/{provider}/login.
+*/
+func getSocialCallbackHandler(apiConfig apiConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "provider")
+		provider := apiConfig.SocialProviders[name]
+		if provider == nil {
+			respondWithError(w, 404, errUnknownProvider.Error())
+			return
+		}
+
+		query := r.URL.Query()
+		context := r.Context()
+		state, err := apiConfig.DB.ConsumeSocialLoginState(context, hashToken(query.Get("state")))
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && (state.Provider != name || time.Now().After(state.ExpiresAt))) {
+			respondWithError(w, 400, errInvalidLoginState.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting social login state", "err", err)
+			respondWithError(w, 500, "Error logging in")
+			return
+		}
+
+		fail := func(code int, msg string) {
+			if state.RedirectUri == "" {
+				respondWithError(w, code, msg)
+				return
+			}
+			http.Redirect(w, r, state.RedirectUri+"#"+url.Values{"error": {msg}}.Encode(), http.StatusFound)
+		}
+
+		if query.Get("error") != "" {
+			fail(400, "Login at "+name+" failed: "+query.Get("error"))
+			return
+		}
+
+		accessToken, err := provider.exchange(context, socialCallbackURL(apiConfig, name), query.Get("code"))
+		if err != nil {
+			httpLog.Error("Error exchanging code", "provider", name, "err", err)
+			fail(502, "Error logging in at "+name)
+			return
+		}
+		identity, err := provider.identity(context, accessToken)
+		if err != nil {
+			httpLog.Error("Error getting identity", "provider", name, "err", err)
+			fail(502, "Error logging in at "+name)
+			return
+		}
+
+		user, err := socialLoginUser(context, apiConfig, name, state.UserID, identity)
+		if errors.Is(err, errIdentityLinked) || errors.Is(err, errSocialEmailTaken) {
+			fail(409, err.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error logging in", "provider", name, "err", err)
+			fail(500, "Error logging in")
+			return
+		}
+
+		if state.UserID.Valid {
+			if state.RedirectUri == "" {
+				respondWithJSON(w, 200, newUserIdentityResponse(name, identity.Email))
+				return
+			}
+			http.Redirect(w, r, state.RedirectUri+"#"+url.Values{"linked": {name}}.Encode(), http.StatusFound)
+			return
+		}
+
+		if !requestAllowedFrom(r, user.AllowedCidrs) {
+			fail(403, "Login is not allowed from this address")
+			return
+		}
+
+		tokens, err := startSession(context, apiConfig, r, user.ID)
+		if err != nil {
+			httpLog.Error("Error starting session", "err", err)
+			fail(500, "Error logging in")
+			return
+		}
+
+		if state.RedirectUri == "" {
+			respondWithJSON(w, 200, tokens)
+			return
+		}
+		fragment := url.Values{
+			"access_token":       {tokens.AccessToken},
+			"expires_at":         {tokens.ExpiresAt.Format(time.RFC3339)},
+			"refresh_token":      {tokens.RefreshToken},
+			"refresh_expires_at": {tokens.RefreshExpiresAt.Format(time.RFC3339)},
+		}
+		http.Redirect(w, r, state.RedirectUri+"#"+fragment.Encode(), http.StatusFound)
+	}
+}
+
+// socialLoginUser finds the user an account at a provider logs in as: the
+// one it's linked to, linkTo when a user links it, or the user with the same
+// verified email. Otherwise it creates a user for it.
+func socialLoginUser(ctx context.Context, apiConfig apiConfig, provider string, linkTo uuid.NullUUID, identity socialIdentity) (database.User, error) {
+	linked, err := apiConfig.DB.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: provider,
+		Subject:  identity.Subject,
+	})
+	if err == nil {
+		if linkTo.Valid && linkTo.UUID != linked.UserID {
+			return database.User{}, errIdentityLinked
+		}
+		return apiConfig.DB.GetUserByID(ctx, linked.UserID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	var user database.User
+	err = database.InTx(ctx, apiConfig.DB, func(q *database.Queries) error {
+		switch {
+		case linkTo.Valid:
+			user, err = q.GetUserByID(ctx, linkTo.UUID)
+		case identity.EmailVerified:
+			user, err = q.GetUserByEmail(ctx, identity.Email)
+			if err == nil && !user.EmailVerifiedAt.Valid {
+				// whoever signed up with it never proved it's theirs
+				return errSocialEmailTaken
+			}
+			if errors.Is(err, sql.ErrNoRows) {
+				user, err = createSocialUser(ctx, q, identity)
+			}
+		default:
+			user, err = createSocialUser(ctx, q, identity)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+			Provider:  provider,
+			Subject:   identity.Subject,
+			UserID:    user.ID,
+			Email:     identity.Email,
+			CreatedAt: time.Now(),
+		})
+		return err
+	})
+	return user, err
+}
+
+// createSocialUser creates the user of an account at a provider, named
+// after it, with its email when the provider verified it.
+func createSocialUser(ctx context.Context, q *database.Queries, identity socialIdentity) (database.User, error) {
+	base := socialUserNameReplacer.ReplaceAllString(identity.Login, "-")
+	if len(base) > userNameMaxLength-4 {
+		base = base[:userNameMaxLength-4]
+	}
+	if len(base) < userNameMinLength {
+		base = "user-" + base
+	}
+
+	name := base
+	for i := 2; ; i++ {
+		_, err := q.GetUserByName(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return database.User{}, err
+		}
+		if i > 1000 {
+			return database.User{}, errUserNameTaken
+		}
+		name = base + "-" + strconv.Itoa(i)
+	}
+
+	apiKey, err := generateToken()
+	if err != nil {
+		return database.User{}, err
+	}
+
+	email := sql.NullString{String: identity.Email, Valid: identity.EmailVerified && identity.Email != ""}
+	user, err := q.InsertUser(ctx, database.InsertUserParams{
+		ID:           uuid.New(),
+		CreatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		UpdatedAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		Name:         name,
+		Email:        email,
+		AllowedCidrs: []string{},
+		ApikeyHash:   hashToken(apiKey),
+	})
+	if err != nil || !email.Valid {
+		return user, err
+	}
+
+	err = q.MarkUserEmailVerified(ctx, database.MarkUserEmailVerifiedParams{
+		ID:    user.ID,
+		Email: email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return q.GetUserByID(ctx, user.ID)
+}
+
+type userIdentityResponse struct {
+	Provider string `json:"provider"`
+	Email    string `json:"email"`
+}
+
+func newUserIdentityResponse(provider, email string) userIdentityResponse {
+	return userIdentityResponse{Provider: provider, Email: email}
+}
+
+/*
+Endpoint: GET /v1/users/me/identities
+
+# This is an authenticated endpoint
+
+Lists the accounts at login providers linked to the user.
+*/
+func getUserIdentitiesHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		identities, err := apiConfig.DB.GetUserIdentities(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting identities", "err", err)
+			respondWithError(w, 500, "Error getting identities")
+			return
+		}
+
+		resp := make([]userIdentityResponse, 0, len(identities))
+		for _, identity := range identities {
+			resp = append(resp, newUserIdentityResponse(identity.Provider, identity.Email))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: DELETE /v1/users/me/identities/{provider}
+
+# This is an authenticated endpoint
+
+Unlinks the user's account at the provider.
+*/
+func deleteUserIdentityHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteUserIdentity(context, database.DeleteUserIdentityParams{
+			UserID:   user.ID,
+			Provider: chi.URLParam(r, "provider"),
+		})
+		if err != nil {
+			httpLog.Error("Error deleting identity", "err", err)
+			respondWithError(w, 500, "Error deleting identity")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "identity_not_found", "Identity not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
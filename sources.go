@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+/*
+Some sites are followed through their api rather than a feed, to filter
+what comes in. The feed is stored under the url of the api call, so the
+fetcher knows from the url alone how to read it, and two feeds of the same
+site with different filters are two feeds:
+
+	reddit       https://www.reddit.com/r/golang/.json?min_score=50
+	hacker news  https://hn.algolia.com/api/v1/search_by_date?tags=story&numericFilters=points%3E%3D100&query=go
+
+Clients don't build these urls, POST /v1/feeds takes a source instead, see
+feedSource. A subreddit without a minimum score is its plain RSS feed.
+*/
+
+const (
+	feedSourceReddit     = "reddit"
+	feedSourceHackerNews = "hackernews"
+)
+
+// sourceMaxItems bounds the items asked from the apis in one fetch.
+const sourceMaxItems = 100
+
+// redditUserAgent identifies the fetcher to reddit, which throttles the
+// default user agent of Go hard.
+const redditUserAgent = "boot-go-blog-aggregator/1.0"
+
+var (
+	subredditPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_]{1,20}$`)
+	redditJSONPath   = regexp.MustCompile(`^/r/[A-Za-z0-9][A-Za-z0-9_]{1,20}/\.json$`)
+)
+
+// feedSource describes a feed read through an api:
+//
+//	{"type": "reddit", "subreddit": "golang", "min_score": 50}
+//	{"type": "hackernews", "query": "postgres", "min_score": 100}
+//	{"type": "mastodon", "account": "user@instance", "replies": false, "boosts": true}
+//
+// MinScore is the minimum score of a reddit post or the minimum points of a
+// Hacker News story, 0 for all of them. Query is the search of a Hacker News
+// feed, empty for all stories. Replies and Boosts include those of the
+// fediverse account, see mastodon.go.
+type feedSource struct {
+	Type      string `json:"type"`
+	Subreddit string `json:"subreddit"`
+	Query     string `json:"query"`
+	MinScore  int    `json:"min_score"`
+	Account   string `json:"account"`
+	Replies   bool   `json:"replies"`
+	Boosts    bool   `json:"boosts"`
+}
+
+func (s *feedSource) normalize() error {
+	s.Subreddit = strings.TrimPrefix(strings.TrimSpace(s.Subreddit), "r/")
+	s.Query = strings.TrimSpace(s.Query)
+	if s.MinScore < 0 {
+		return errors.New("min_score can't be negative")
+	}
+
+	switch s.Type {
+	case feedSourceReddit:
+		if !subredditPattern.MatchString(s.Subreddit) {
+			return errors.New("Invalid subreddit")
+		}
+	case feedSourceHackerNews:
+	case feedSourceMastodon:
+		user, instance, err := parseFediverseAccount(s.Account)
+		if err != nil {
+			return err
+		}
+		s.Account = user + "@" + instance
+	default:
+		return errors.New("source type must be reddit, hackernews or mastodon")
+	}
+
+	return nil
+}
+
+// url is the url the feed of the source is stored under.
+func (s *feedSource) url() string {
+	switch s.Type {
+	case feedSourceReddit:
+		base := "https://www.reddit.com/r/" + s.Subreddit
+		if s.MinScore == 0 {
+			return base + "/.rss"
+		}
+		return base + "/.json?min_score=" + strconv.Itoa(s.MinScore)
+	case feedSourceMastodon:
+		user, instance, _ := parseFediverseAccount(s.Account)
+		return mastodonFeedURL(user, instance, s.Replies, s.Boosts)
+	default:
+		query := url.Values{"tags": {"story"}}
+		if s.MinScore > 0 {
+			query.Set("numericFilters", "points>="+strconv.Itoa(s.MinScore))
+		}
+		if s.Query != "" {
+			query.Set("query", s.Query)
+		}
+		return "https://hn.algolia.com/api/v1/search_by_date?" + query.Encode()
+	}
+}
+
+// translateFeedURL turns the page of a channel, playlist or subreddit into
+// its feed. ok is false for other urls, which are left to discovery.
+func translateFeedURL(raw string) (feedURL string, ok bool) {
+	if feedURL, ok := youtubeFeedURL(raw); ok {
+		return feedURL, true
+	}
+	return redditFeedURL(raw)
+}
+
+// redditFeedURL translates https://www.reddit.com/r/golang/ to its RSS feed.
+func redditFeedURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || !isRedditHost(u.Host) {
+		return "", false
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) != 2 || segments[0] != "r" || !subredditPattern.MatchString(segments[1]) {
+		return "", false
+	}
+
+	return "https://www.reddit.com/r/" + segments[1] + "/.rss", true
+}
+
+func isRedditHost(host string) bool {
+	switch strings.ToLower(host) {
+	case "reddit.com", "www.reddit.com", "old.reddit.com":
+		return true
+	}
+	return false
+}
+
+// sourceFetcher returns the function that reads the feed at feedURL from an
+// api, nil when feedURL is an actual feed.
+func sourceFetcher(feedURL string) func(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case isRedditHost(u.Host) && redditJSONPath.MatchString(u.Path):
+		return redditFeed
+	case u.Host == "hn.algolia.com" && (u.Path == "/api/v1/search_by_date" || u.Path == "/api/v1/search"):
+		return hackerNewsFeed
+	case isMastodonLookup(u):
+		return mastodonFeed
+	case youtubeAPIKey != "" && isYouTubeFeed(feedURL):
+		return youtubeAPIFeed
+	}
+	return nil
+}
+
+// getSourceJSON fetches an api url and decodes its answer into v.
+func getSourceJSON(ctx context.Context, apiURL string, header http.Header, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &feedStatusError{StatusCode: resp.StatusCode}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// redditFeed reads the newest posts of a subreddit and keeps those with at
+// least the min_score of the url.
+func redditFeed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	minScore, _ := strconv.Atoi(u.Query().Get("min_score"))
+	subreddit := strings.Split(strings.Trim(u.Path, "/"), "/")[1]
+
+	// score builds up over the first hours, so look further back than the
+	// newest page a plain feed would show
+	apiURL := "https://www.reddit.com/r/" + url.PathEscape(subreddit) + "/new.json?raw_json=1&limit=" + strconv.Itoa(sourceMaxItems)
+	var listing struct {
+		Data struct {
+			Children []struct {
+				Data struct {
+					Name       string  `json:"name"`
+					Title      string  `json:"title"`
+					Author     string  `json:"author"`
+					Permalink  string  `json:"permalink"`
+					URL        string  `json:"url"`
+					IsSelf     bool    `json:"is_self"`
+					Selftext   string  `json:"selftext_html"`
+					Score      int     `json:"score"`
+					CreatedUTC float64 `json:"created_utc"`
+					Thumbnail  string  `json:"thumbnail"`
+				} `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	err = getSourceJSON(ctx, apiURL, http.Header{"User-Agent": {redditUserAgent}}, &listing)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title:    "r/" + subreddit,
+		Link:     "https://www.reddit.com/r/" + subreddit + "/",
+		FeedType: feedSourceReddit,
+	}
+	if minScore > 0 {
+		feed.Title += fmt.Sprintf(" (score %d+)", minScore)
+	}
+
+	for _, child := range listing.Data.Children {
+		post := child.Data
+		if post.Score < minScore {
+			continue
+		}
+
+		published := time.Unix(int64(post.CreatedUTC), 0).UTC()
+		item := &gofeed.Item{
+			// the ids of the RSS feed of reddit, t3_...
+			GUID:            post.Name,
+			Title:           post.Title,
+			Link:            "https://www.reddit.com" + post.Permalink,
+			Description:     post.Selftext,
+			Published:       published.Format(time.RFC3339),
+			PublishedParsed: &published,
+			Authors:         []*gofeed.Person{{Name: "/u/" + post.Author}},
+		}
+		if !post.IsSelf && post.URL != "" {
+			item.Description = fmt.Sprintf(`<p><a href="%s">%s</a></p>`, html.EscapeString(post.URL), html.EscapeString(post.URL))
+		}
+		if strings.HasPrefix(post.Thumbnail, "https://") {
+			item.Image = &gofeed.Image{URL: post.Thumbnail}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
+
+// hackerNewsFeed reads a search of the Algolia api of Hacker News.
+func hackerNewsFeed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("hitsPerPage", strconv.Itoa(sourceMaxItems))
+	u.RawQuery = query.Encode()
+
+	var search struct {
+		Hits []struct {
+			ObjectID    string `json:"objectID"`
+			Title       string `json:"title"`
+			URL         string `json:"url"`
+			Author      string `json:"author"`
+			Points      int    `json:"points"`
+			StoryText   string `json:"story_text"`
+			CreatedAtI  int64  `json:"created_at_i"`
+			NumComments int    `json:"num_comments"`
+		} `json:"hits"`
+	}
+	err = getSourceJSON(ctx, u.String(), nil, &search)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{
+		Title:    "Hacker News",
+		Link:     "https://news.ycombinator.com/",
+		FeedType: feedSourceHackerNews,
+	}
+	if q := query.Get("query"); q != "" {
+		feed.Title += ": " + q
+	}
+	if filter := query.Get("numericFilters"); filter != "" {
+		feed.Title += " (" + filter + ")"
+	}
+
+	for _, hit := range search.Hits {
+		discussion := "https://news.ycombinator.com/item?id=" + url.QueryEscape(hit.ObjectID)
+		published := time.Unix(hit.CreatedAtI, 0).UTC()
+		item := &gofeed.Item{
+			GUID:            discussion,
+			Title:           hit.Title,
+			Link:            discussion,
+			Description:     hit.StoryText,
+			Published:       published.Format(time.RFC3339),
+			PublishedParsed: &published,
+			Authors:         []*gofeed.Person{{Name: hit.Author}},
+		}
+		// link stories to the article, with the discussion below it
+		if hit.URL != "" {
+			item.Link = hit.URL
+			item.Description = fmt.Sprintf(`<p><a href="%s">%d comments</a></p>`, html.EscapeString(discussion), hit.NumComments)
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
@@ -0,0 +1,13 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, key_hash, scopes)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetApiKeyByHash :one
+SELECT * FROM api_keys WHERE key_hash = $1;
+
+-- name: GetUserApiKeys :many
+SELECT * FROM api_keys WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteApiKey :execrows
+DELETE FROM api_keys WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,15 @@
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (id, created_at, user_id, post_id, url, title, description, folder)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id, url) DO NOTHING
+RETURNING *;
+
+-- name: GetUserBookmarks :many
+SELECT * FROM bookmarks WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateBookmarkPreview :exec
+UPDATE bookmarks SET title = CASE WHEN title = '' THEN $2 ELSE title END, description = $3
+WHERE id = $1;
@@ -0,0 +1,45 @@
+-- name: CreateChatNotification :one
+INSERT INTO chat_notifications (id, created_at, updated_at, user_id, provider, webhook_url, feed_id, keywords, template)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetUserChatNotifications :many
+SELECT * FROM chat_notifications WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteChatNotification :execrows
+DELETE FROM chat_notifications WHERE id = $1 AND user_id = $2;
+
+-- name: GetChatNotificationsForFeed :many
+SELECT * FROM chat_notifications c
+WHERE c.feed_id = @feed_id::uuid
+    OR (c.feed_id IS NULL AND EXISTS (
+        SELECT 1 FROM feed_follows ff WHERE ff.user_id = c.user_id AND ff.feed_id = @feed_id::uuid
+    ));
+
+-- name: QueueChatNotificationPost :exec
+INSERT INTO chat_notification_posts (chat_notification_id, post_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: GetQueuedChatNotifications :many
+SELECT * FROM chat_notifications
+WHERE id IN (SELECT DISTINCT chat_notification_id FROM chat_notification_posts);
+
+-- name: ClaimChatNotificationPosts :many
+WITH claimed AS (
+    DELETE FROM chat_notification_posts WHERE chat_notification_id = $1
+    RETURNING post_id
+)
+SELECT p.id, p.title, p.url, p.author, p.published_at, f.name AS feed_name
+FROM claimed
+JOIN posts p ON p.id = claimed.post_id
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY p.published_at, p.id;
+
+-- name: FinishChatNotification :exec
+UPDATE chat_notifications SET last_sent_at = now(), last_error = $2
+WHERE id = $1;
+
+-- name: MoveChatNotifications :exec
+UPDATE chat_notifications SET feed_id = @to_feed_id, updated_at = now()
+WHERE feed_id = @from_feed_id;
@@ -0,0 +1,10 @@
+-- name: CreateEmailVerification :one
+INSERT INTO email_verifications (token_hash, user_id, email, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications WHERE token_hash = $1;
+
+-- name: DeleteUserEmailVerifications :exec
+DELETE FROM email_verifications WHERE user_id = $1;
@@ -0,0 +1,10 @@
+-- name: UpsertFeedCredentials :exec
+INSERT INTO feed_credentials (feed_id, updated_at, sealed)
+VALUES ($1, $2, $3)
+ON CONFLICT (feed_id) DO UPDATE SET updated_at = EXCLUDED.updated_at, sealed = EXCLUDED.sealed;
+
+-- name: GetFeedCredentials :one
+SELECT feed_id, updated_at, sealed FROM feed_credentials WHERE feed_id = $1;
+
+-- name: DeleteFeedCredentials :execrows
+DELETE FROM feed_credentials WHERE feed_id = $1;
@@ -3,9 +3,17 @@ INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
 VALUES ($1, $2, $3, $4, $5)
 RETURNING *;
 
--- name: DeleteFeedFollow :exec
-DELETE FROM feed_follows WHERE id = $1;
+-- name: DeleteFeedFollow :execrows
+DELETE FROM feed_follows WHERE user_id = $1 AND feed_id = $2;
 
 -- name: GetUserFeedFollows :many
 SELECT * FROM feed_follows where user_id = $1;
 
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows SET feed_id = @to_feed_id, updated_at = now()
+WHERE feed_id = @from_feed_id
+    AND user_id NOT IN (SELECT user_id FROM feed_follows WHERE feed_id = @to_feed_id);
+
+-- name: GetFeedFollowerIDs :many
+SELECT user_id FROM feed_follows WHERE feed_id = $1;
@@ -0,0 +1,17 @@
+-- name: GetFeedIcon :one
+SELECT * FROM feed_icons WHERE feed_id = $1;
+
+-- name: UpsertFeedIcon :one
+INSERT INTO feed_icons (feed_id, fetched_at, source_url, content_type, hash)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (feed_id) DO UPDATE
+SET fetched_at = EXCLUDED.fetched_at, source_url = EXCLUDED.source_url, content_type = EXCLUDED.content_type,
+    hash = EXCLUDED.hash
+RETURNING *;
+
+-- name: GetFeedsWithStaleIcons :many
+SELECT f.* FROM feeds f
+LEFT JOIN feed_icons i ON i.feed_id = f.id
+WHERE f.disabled_at IS NULL AND (i.feed_id IS NULL OR i.fetched_at < $1)
+ORDER BY i.fetched_at NULLS FIRST
+LIMIT $2;
@@ -0,0 +1,16 @@
+-- name: CreateFeedNote :one
+INSERT INTO feed_notes (id, created_at, feed_id, user_id, note)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetFeedNote :one
+SELECT * FROM feed_notes WHERE feed_id = $1
+ORDER BY created_at DESC LIMIT 1;
+
+-- name: GetFeedNoteHistory :many
+SELECT * FROM feed_notes WHERE feed_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetUserFeedNotes :many
+SELECT * FROM feed_notes WHERE user_id = $1
+ORDER BY created_at;
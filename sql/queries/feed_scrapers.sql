@@ -0,0 +1,13 @@
+-- name: UpsertFeedScraper :one
+INSERT INTO feed_scrapers (feed_id, updated_at, item_selector, link_selector, title_selector, date_selector)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, item_selector = EXCLUDED.item_selector, link_selector = EXCLUDED.link_selector,
+    title_selector = EXCLUDED.title_selector, date_selector = EXCLUDED.date_selector
+RETURNING *;
+
+-- name: GetFeedScraper :one
+SELECT * FROM feed_scrapers WHERE feed_id = $1;
+
+-- name: DeleteFeedScraper :execrows
+DELETE FROM feed_scrapers WHERE feed_id = $1;
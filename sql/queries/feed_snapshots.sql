@@ -0,0 +1,18 @@
+-- name: CreateFeedSnapshot :one
+INSERT INTO feed_snapshots (id, feed_id, fetched_at, content_type, size, compressed_size)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetFeedSnapshots :many
+SELECT * FROM feed_snapshots WHERE feed_id = $1 ORDER BY fetched_at DESC, id DESC;
+
+-- name: GetFeedSnapshot :one
+SELECT * FROM feed_snapshots WHERE id = $1 AND feed_id = $2;
+
+-- name: GetFeedSnapshotsBeyond :many
+SELECT * FROM feed_snapshots WHERE feed_id = @feed_id
+ORDER BY fetched_at DESC, id DESC
+OFFSET sqlc.arg('keep');
+
+-- name: DeleteFeedSnapshot :exec
+DELETE FROM feed_snapshots WHERE id = $1;
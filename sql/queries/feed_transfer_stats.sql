@@ -0,0 +1,25 @@
+-- name: RecordFeedTransfer :exec
+INSERT INTO feed_transfer_stats (feed_id, updated_at, fetches, last_transfer_bytes, last_body_bytes,
+    last_content_encoding, total_transfer_bytes, total_body_bytes)
+VALUES ($1, $2, 1, $3, $4, $5, $3, $4)
+ON CONFLICT (feed_id) DO UPDATE SET
+    updated_at = EXCLUDED.updated_at,
+    fetches = feed_transfer_stats.fetches + 1,
+    last_transfer_bytes = EXCLUDED.last_transfer_bytes,
+    last_body_bytes = EXCLUDED.last_body_bytes,
+    last_content_encoding = EXCLUDED.last_content_encoding,
+    total_transfer_bytes = feed_transfer_stats.total_transfer_bytes + EXCLUDED.last_transfer_bytes,
+    total_body_bytes = feed_transfer_stats.total_body_bytes + EXCLUDED.last_body_bytes;
+
+-- name: GetFeedTransferTotals :one
+SELECT coalesce(sum(fetches), 0)::bigint AS fetches,
+    coalesce(sum(total_transfer_bytes), 0)::bigint AS transfer_bytes,
+    coalesce(sum(total_body_bytes), 0)::bigint AS body_bytes
+FROM feed_transfer_stats;
+
+-- name: GetLargestFeedTransfers :many
+SELECT f.id, f.url, s.last_transfer_bytes, s.last_body_bytes, s.last_content_encoding
+FROM feed_transfer_stats s
+JOIN feeds f ON f.id = s.feed_id
+ORDER BY s.last_transfer_bytes DESC, f.id
+LIMIT $1;
@@ -0,0 +1,3 @@
+-- name: CreateFeedUrlChange :exec
+INSERT INTO feed_url_changes (id, created_at, feed_id, old_url, new_url)
+VALUES ($1, $2, $3, $4, $5);
@@ -4,12 +4,77 @@ VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT * FROM feeds;
+SELECT * FROM feeds
+-- newsletters and feeds fetched with credentials are their owner's alone
+WHERE url NOT LIKE 'newsletter:%'
+    AND NOT EXISTS (SELECT 1 FROM feed_credentials c WHERE c.feed_id = feeds.id);
+
+-- name: GetFeedByUrl :one
+SELECT * FROM feeds WHERE url = $1;
+
+-- name: GetFeedByID :one
+SELECT * FROM feeds WHERE id = $1;
+
+-- name: GetVisibleFeedByID :one
+SELECT * FROM feeds
+WHERE id = @id
+    -- newsletters and feeds with credentials only to their owner
+    AND (user_id = @user_id OR (url NOT LIKE 'newsletter:%'
+        AND NOT EXISTS (SELECT 1 FROM feed_credentials c WHERE c.feed_id = feeds.id)));
 
 -- name: GetNextFeedsToFetch :many
-SELECT * FROM feeds ORDER BY last_fetched_at NULLS FIRST LIMIT $1;
+SELECT * FROM feeds
+WHERE disabled_at IS NULL AND (next_fetch_at IS NULL OR next_fetch_at <= now())
+    AND (last_fetched_at IS NULL OR last_fetched_at <= $1)
+    AND url NOT LIKE 'newsletter:%'
+ORDER BY last_fetched_at NULLS FIRST LIMIT $2;
 	
 
 -- name: MarkFeedAsFetched :exec
-UPDATE feeds SET last_fetched_at = now(), updated_at = now() WHERE url = $1;
+UPDATE feeds SET last_fetched_at = now(), updated_at = now(), etag = $2, last_modified = $3,
+    consecutive_failures = 0, last_error = NULL, failing_since = NULL,
+    next_fetch_at = $4, refresh_interval_seconds = $5
+WHERE url = $1;
+
+-- name: MarkFeedFetchFailed :exec
+UPDATE feeds SET last_fetched_at = now(), updated_at = now(),
+    consecutive_failures = consecutive_failures + 1, last_error = $2, next_fetch_at = $3,
+    failing_since = COALESCE(failing_since, now())
+WHERE id = $1;
+
+-- name: DisableFeed :exec
+UPDATE feeds SET disabled_at = now(), updated_at = now(), disabled_reason = $2, next_fetch_at = NULL
+WHERE id = $1;
+
+-- name: EnableFeed :one
+UPDATE feeds SET disabled_at = NULL, disabled_reason = NULL, updated_at = now(),
+    consecutive_failures = 0, last_error = NULL, next_fetch_at = NULL, failing_since = NULL
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateFeedUrl :exec
+UPDATE feeds SET url = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1;
+
+-- name: GetFeedsWithUndatedPosts :many
+SELECT * FROM feeds f
+WHERE f.disabled_at IS NULL
+    AND EXISTS (SELECT 1 FROM posts p WHERE p.feed_id = f.id AND p.published_at IS NULL);
+
+-- name: GetUserFeeds :many
+SELECT * FROM feeds WHERE user_id = $1 ORDER BY created_at;
 
+-- name: HandOverUserFeeds :many
+UPDATE feeds f SET user_id = (
+    SELECT ff.user_id FROM feed_follows ff
+    WHERE ff.feed_id = f.id AND ff.user_id <> f.user_id
+    ORDER BY ff.created_at, ff.id LIMIT 1
+), updated_at = now()
+WHERE f.user_id = $1
+    -- newsletters go with their owner
+    AND f.url NOT LIKE 'newsletter:%'
+    AND EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id AND ff.user_id <> f.user_id)
+RETURNING f.id;
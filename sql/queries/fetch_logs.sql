@@ -0,0 +1,14 @@
+-- name: CreateFetchLog :exec
+INSERT INTO fetch_logs (id, feed_id, started_at, duration_ms, status_code, items_found, items_inserted, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetFetchLogsPage :many
+SELECT * FROM fetch_logs
+WHERE feed_id = @feed_id
+    AND (sqlc.narg('before_time')::timestamp IS NULL
+        OR (started_at, id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY started_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: DeleteFetchLogsBefore :execrows
+DELETE FROM fetch_logs WHERE started_at < $1;
@@ -0,0 +1,31 @@
+-- name: CountOrphanedFeedFollows :one
+SELECT count(*) FROM feed_follows ff
+WHERE NOT EXISTS (SELECT 1 FROM feeds f WHERE f.id = ff.feed_id)
+    OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = ff.user_id);
+
+-- name: DeleteOrphanedFeedFollows :execrows
+DELETE FROM feed_follows ff
+WHERE NOT EXISTS (SELECT 1 FROM feeds f WHERE f.id = ff.feed_id)
+    OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = ff.user_id);
+
+-- name: CountOrphanedPosts :one
+SELECT count(*) FROM posts p
+WHERE p.feed_id IS NULL OR NOT EXISTS (SELECT 1 FROM feeds f WHERE f.id = p.feed_id);
+
+-- name: DeleteOrphanedPosts :execrows
+DELETE FROM posts p
+WHERE p.feed_id IS NULL OR NOT EXISTS (SELECT 1 FROM feeds f WHERE f.id = p.feed_id);
+
+-- name: CountDuplicateFeedFollows :one
+SELECT count(*) FROM feed_follows ff
+WHERE EXISTS (
+    SELECT 1 FROM feed_follows o
+    WHERE o.user_id = ff.user_id AND o.feed_id = ff.feed_id AND o.id < ff.id
+);
+
+-- name: DeleteDuplicateFeedFollows :execrows
+DELETE FROM feed_follows ff
+WHERE EXISTS (
+    SELECT 1 FROM feed_follows o
+    WHERE o.user_id = ff.user_id AND o.feed_id = ff.feed_id AND o.id < ff.id
+);
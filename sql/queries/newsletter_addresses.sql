@@ -0,0 +1,16 @@
+-- name: CreateNewsletterAddress :one
+INSERT INTO newsletter_addresses (user_id, feed_id, token, created_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetNewsletterAddressByUser :one
+SELECT * FROM newsletter_addresses WHERE user_id = $1;
+
+-- name: GetNewsletterAddressByToken :one
+SELECT * FROM newsletter_addresses WHERE token = $1;
+
+-- name: GetUserNewsletters :many
+SELECT p.* FROM posts p
+JOIN newsletter_addresses n ON n.feed_id = p.feed_id
+WHERE n.user_id = $1
+ORDER BY p.created_at;
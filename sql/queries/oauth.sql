@@ -0,0 +1,22 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, user_id, name, secret_hash, redirect_uris)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients WHERE id = $1;
+
+-- name: CreateOAuthCode :exec
+INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, expires_at, code_challenge)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ConsumeOAuthCode :one
+DELETE FROM oauth_codes WHERE code_hash = $1
+RETURNING *;
+
+-- name: CreateOAuthToken :exec
+INSERT INTO oauth_tokens (token_hash, client_id, user_id, scopes, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetOAuthToken :one
+SELECT * FROM oauth_tokens WHERE token_hash = $1;
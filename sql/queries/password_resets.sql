@@ -0,0 +1,10 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (token_hash, user_id, email, created_at, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumePasswordReset :one
+DELETE FROM password_resets WHERE token_hash = $1
+RETURNING *;
+
+-- name: DeleteUserPasswordResets :exec
+DELETE FROM password_resets WHERE user_id = $1;
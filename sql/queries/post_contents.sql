@@ -0,0 +1,8 @@
+-- name: GetPostContent :one
+SELECT * FROM post_contents WHERE post_id = $1;
+
+-- name: UpsertPostContent :one
+INSERT INTO post_contents (post_id, created_at, html, text)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (post_id) DO UPDATE SET created_at = EXCLUDED.created_at, html = EXCLUDED.html, text = EXCLUDED.text
+RETURNING *;
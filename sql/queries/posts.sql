@@ -1,9 +1,129 @@
--- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-RETURNING *;
+-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, guid, canonical_url,
+    content, author, categories, enclosures, duration, episode, thumbnail_url)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, title = EXCLUDED.title, url = EXCLUDED.url, description = EXCLUDED.description,
+    canonical_url = EXCLUDED.canonical_url, content = EXCLUDED.content, author = EXCLUDED.author,
+    categories = EXCLUDED.categories, enclosures = EXCLUDED.enclosures, duration = EXCLUDED.duration,
+    episode = EXCLUDED.episode, thumbnail_url = coalesce(EXCLUDED.thumbnail_url, posts.thumbnail_url)
+WHERE (posts.title, posts.url, posts.description, posts.content, posts.author, posts.categories, posts.enclosures,
+        posts.duration, posts.episode, posts.thumbnail_url)
+    IS DISTINCT FROM (EXCLUDED.title, EXCLUDED.url, EXCLUDED.description, EXCLUDED.content, EXCLUDED.author,
+        EXCLUDED.categories, EXCLUDED.enclosures, EXCLUDED.duration, EXCLUDED.episode,
+        coalesce(EXCLUDED.thumbnail_url, posts.thumbnail_url))
+RETURNING *, (xmax = 0)::boolean AS inserted;
 
 -- name: GetPostsByUser :many
+SELECT * FROM (
+    -- the first of the posts sharing a canonical url, posts without a link have
+    -- none and are never duplicates
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text)) p.* FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC;
+
+-- name: GetPostByID :one
+SELECT * FROM posts WHERE id = $1;
+
+-- name: GetCompactPostsByUser :many
+SELECT p.id, p.title, f.name AS feed_name, p.published_at, p.thumbnail_url,
+    (b.id IS NOT NULL)::boolean AS bookmarked, rp.percent AS reading_progress
+FROM (
+    -- only columns of posts_feed_id_compact_idx, so the index covers it
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text))
+        p.id, p.title, p.feed_id, p.published_at, p.thumbnail_url
+    FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = $1)
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN bookmarks b ON b.post_id = p.id AND b.user_id = $1
+LEFT JOIN reading_progress rp ON rp.post_id = p.id AND rp.user_id = $1
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC;
+
+-- name: UpdatePostMetadata :exec
+UPDATE posts SET image_url = $2, site_name = $3, page_canonical_url = $4,
+    thumbnail_url = coalesce(thumbnail_url, $2), updated_at = now()
+WHERE id = $1;
+
+-- name: GetMediaPostsByUser :many
+SELECT * FROM (
+    SELECT DISTINCT ON (coalesce(nullif(p.canonical_url, ''), p.id::text)) p.* FROM posts p
+    WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = @user_id)
+        AND EXISTS (
+            SELECT 1 FROM jsonb_array_elements(p.enclosures) e
+            WHERE e->>'type' LIKE @media_type::text || '/%'
+        )
+    ORDER BY coalesce(nullif(p.canonical_url, ''), p.id::text), p.created_at, p.id
+) p
+JOIN feeds f ON f.id = p.feed_id
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC;
+
+-- name: GetPostsPageByUser :many
 SELECT * FROM posts p
 JOIN feeds f ON f.id = p.feed_id
-WHERE f.user_id = $1;
+WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = @user_id)
+    -- the first of the posts sharing a canonical url, like GetPostsByUser
+    AND NOT EXISTS (
+        SELECT 1 FROM posts d
+        WHERE p.canonical_url <> '' AND d.canonical_url = p.canonical_url
+            AND EXISTS (SELECT 1 FROM feed_follows dff WHERE dff.feed_id = d.feed_id AND dff.user_id = @user_id)
+            AND (d.created_at, d.id) < (p.created_at, p.id)
+    )
+    AND (sqlc.narg('media_type')::text IS NULL OR EXISTS (
+        SELECT 1 FROM jsonb_array_elements(p.enclosures) e
+        WHERE e->>'type' LIKE sqlc.narg('media_type')::text || '/%'
+    ))
+    AND (sqlc.narg('before_time')::timestamp IS NULL
+        OR (coalesce(p.published_at, 'epoch'), p.id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetCompactPostsPageByUser :many
+SELECT p.id, p.title, f.name AS feed_name, p.published_at, p.thumbnail_url,
+    (b.id IS NOT NULL)::boolean AS bookmarked, rp.percent AS reading_progress
+FROM posts p
+JOIN feeds f ON f.id = p.feed_id
+LEFT JOIN bookmarks b ON b.post_id = p.id AND b.user_id = @user_id
+LEFT JOIN reading_progress rp ON rp.post_id = p.id AND rp.user_id = @user_id
+WHERE EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = p.feed_id AND ff.user_id = @user_id)
+    -- the first of the posts sharing a canonical url, like GetPostsByUser
+    AND NOT EXISTS (
+        SELECT 1 FROM posts d
+        WHERE p.canonical_url <> '' AND d.canonical_url = p.canonical_url
+            AND EXISTS (SELECT 1 FROM feed_follows dff WHERE dff.feed_id = d.feed_id AND dff.user_id = @user_id)
+            AND (d.created_at, d.id) < (p.created_at, p.id)
+    )
+    AND (sqlc.narg('before_time')::timestamp IS NULL
+        OR (coalesce(p.published_at, 'epoch'), p.id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: SetMissingPublishedAt :execrows
+UPDATE posts SET published_at = $3, updated_at = now()
+WHERE feed_id = $1 AND guid = $2 AND published_at IS NULL;
+
+-- name: FillMissingPublishedAt :execrows
+UPDATE posts SET published_at = coalesce(created_at, now()), updated_at = now()
+WHERE published_at IS NULL;
+
+-- name: GetPostsPageByFeed :many
+SELECT * FROM posts
+WHERE feed_id = @feed_id
+ORDER BY coalesce(published_at, 'epoch') DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetCanonicalURLBackfill :many
+SELECT p.id, p.url, p.canonical_url FROM post_canonical_url_backfills b
+JOIN posts p ON p.id = b.post_id
+ORDER BY b.post_id
+LIMIT $1;
+
+-- name: SetPostCanonicalURL :exec
+UPDATE posts SET canonical_url = $2 WHERE id = $1;
+
+-- name: DeleteCanonicalURLBackfill :exec
+DELETE FROM post_canonical_url_backfills WHERE post_id = ANY(@post_ids::uuid[]);
@@ -0,0 +1,33 @@
+-- name: AddPublicFeed :exec
+INSERT INTO public_feeds (feed_id, created_at)
+VALUES ($1, $2)
+ON CONFLICT (feed_id) DO NOTHING;
+
+-- name: RemovePublicFeed :execrows
+DELETE FROM public_feeds WHERE feed_id = $1;
+
+-- name: GetPublicFeeds :many
+SELECT f.id, f.name, f.url, pf.created_at AS added_at
+FROM public_feeds pf
+JOIN feeds f ON f.id = pf.feed_id
+ORDER BY f.name, f.id;
+
+-- name: GetPublicPostsPage :many
+SELECT p.id, p.title, p.url, p.description, p.published_at, p.thumbnail_url,
+    f.id AS feed_id, f.name AS feed_name
+FROM posts p
+JOIN public_feeds pf ON pf.feed_id = p.feed_id
+JOIN feeds f ON f.id = p.feed_id
+WHERE NOT EXISTS (
+        SELECT 1 FROM posts d
+        JOIN public_feeds dpf ON dpf.feed_id = d.feed_id
+        WHERE p.canonical_url <> '' AND d.canonical_url = p.canonical_url
+            AND (d.created_at, d.id) < (p.created_at, p.id)
+    )
+    AND (sqlc.narg('before_time')::timestamp IS NULL
+        OR (coalesce(p.published_at, 'epoch'), p.id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: IsPublicFeed :one
+SELECT EXISTS (SELECT 1 FROM public_feeds WHERE feed_id = $1);
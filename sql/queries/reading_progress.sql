@@ -0,0 +1,13 @@
+-- name: UpsertReadingProgress :one
+INSERT INTO reading_progress (user_id, post_id, updated_at, percent, anchor)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, percent = EXCLUDED.percent, anchor = EXCLUDED.anchor
+RETURNING *;
+
+-- name: GetReadingProgress :one
+SELECT * FROM reading_progress WHERE user_id = $1 AND post_id = $2;
+
+-- name: GetUserReadingProgress :many
+SELECT * FROM reading_progress WHERE user_id = $1
+ORDER BY updated_at DESC;
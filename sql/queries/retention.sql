@@ -0,0 +1,37 @@
+-- name: DeleteWebhookDeliveriesBefore :execrows
+DELETE FROM webhook_deliveries WHERE created_at < $1 AND status <> 'pending';
+
+-- name: DeleteWebhookDeadLettersBefore :execrows
+DELETE FROM webhook_dead_letters WHERE created_at < $1;
+
+-- name: DeleteFeedNoteHistoryBefore :execrows
+DELETE FROM feed_notes n
+WHERE n.created_at < $1
+    AND EXISTS (SELECT 1 FROM feed_notes newer WHERE newer.feed_id = n.feed_id AND newer.created_at > n.created_at);
+
+-- name: DeleteExpiredOAuthCodes :execrows
+DELETE FROM oauth_codes WHERE expires_at < now();
+
+-- name: DeleteExpiredOAuthTokens :execrows
+DELETE FROM oauth_tokens WHERE expires_at < now();
+
+-- name: DeleteExpiredEmailVerifications :execrows
+DELETE FROM email_verifications WHERE expires_at < now();
+
+-- name: DeleteExpiredTelegramLinkCodes :execrows
+DELETE FROM telegram_link_codes WHERE expires_at < now();
+
+-- name: DeleteTelegramMessagesBefore :execrows
+DELETE FROM telegram_messages WHERE created_at < $1;
+
+-- name: DeleteSyncChangesBefore :execrows
+DELETE FROM sync_changes WHERE created_at < $1;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions WHERE expires_at < now();
+
+-- name: DeleteExpiredSocialLoginStates :execrows
+DELETE FROM social_login_states WHERE expires_at < now();
+
+-- name: DeleteExpiredPasswordResets :execrows
+DELETE FROM password_resets WHERE expires_at < now();
@@ -0,0 +1,20 @@
+-- name: UpsertScheduledItem :one
+INSERT INTO scheduled_items (id, created_at, feed_id, guid, publish_at, item)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (feed_id, guid) DO UPDATE SET publish_at = EXCLUDED.publish_at, item = EXCLUDED.item
+RETURNING *;
+
+-- name: GetScheduledItemsByFeed :many
+SELECT * FROM scheduled_items WHERE feed_id = $1
+ORDER BY publish_at, id;
+
+-- name: GetDueScheduledItems :many
+SELECT * FROM scheduled_items WHERE publish_at <= $1
+ORDER BY publish_at, id
+LIMIT $2;
+
+-- name: DeleteScheduledItem :execrows
+DELETE FROM scheduled_items WHERE id = $1 AND feed_id = $2;
+
+-- name: DeleteScheduledItemByGuid :exec
+DELETE FROM scheduled_items WHERE feed_id = $1 AND guid = $2;
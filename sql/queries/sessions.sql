@@ -0,0 +1,21 @@
+-- name: CreateSession :exec
+INSERT INTO sessions (id, token_hash, user_id, created_at, last_used_at, expires_at, user_agent)
+VALUES ($1, $2, $3, $4, $5, $6, $7);
+
+-- name: ConsumeSession :one
+DELETE FROM sessions WHERE token_hash = $1
+RETURNING *;
+
+-- name: GetUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND expires_at > $2
+ORDER BY last_used_at DESC;
+
+-- name: DeleteSession :execrows
+DELETE FROM sessions WHERE token_hash = $1 AND user_id = $2;
+
+-- name: DeleteSessionByID :execrows
+DELETE FROM sessions WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1;
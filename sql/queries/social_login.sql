@@ -0,0 +1,21 @@
+-- name: CreateSocialLoginState :exec
+INSERT INTO social_login_states (state_hash, provider, user_id, redirect_uri, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeSocialLoginState :one
+DELETE FROM social_login_states WHERE state_hash = $1
+RETURNING *;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (provider, subject, user_id, email, created_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserIdentities :many
+SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities WHERE user_id = $1 AND provider = $2;
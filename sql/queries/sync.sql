@@ -0,0 +1,24 @@
+-- name: GetSyncWatermark :one
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint AS watermark;
+
+-- name: GetSyncChanges :many
+SELECT * FROM sync_changes c
+WHERE (c.txid, c.id) > (@after_txid::bigint, @after_id::bigint)
+    AND c.txid < @until_txid::bigint
+    AND (c.user_id = @user_id::uuid
+        -- the posts of GET /v1/posts, of the feeds of the user
+        OR (c.user_id IS NULL AND c.feed_id IN (SELECT f.id FROM feeds f WHERE f.user_id = @user_id::uuid)))
+ORDER BY c.txid, c.id
+LIMIT sqlc.arg('limit');
+
+-- name: GetPostsByIDs :many
+SELECT * FROM posts WHERE id = ANY(@ids::uuid[]);
+
+-- name: GetReadingProgressByPosts :many
+SELECT * FROM reading_progress WHERE user_id = @user_id AND post_id = ANY(@post_ids::uuid[]);
+
+-- name: GetFeedFollowsByFeeds :many
+SELECT * FROM feed_follows WHERE user_id = @user_id AND feed_id = ANY(@feed_ids::uuid[]);
+
+-- name: DeleteUserSyncChanges :exec
+DELETE FROM sync_changes WHERE user_id = $1;
@@ -0,0 +1,65 @@
+-- name: CreateTelegramLinkCode :exec
+INSERT INTO telegram_link_codes (code_hash, user_id, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: ConsumeTelegramLinkCode :one
+DELETE FROM telegram_link_codes WHERE code_hash = $1 AND expires_at > now()
+RETURNING user_id;
+
+-- name: UpsertTelegramChat :one
+INSERT INTO telegram_chats (user_id, chat_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET chat_id = EXCLUDED.chat_id, created_at = EXCLUDED.created_at
+RETURNING *;
+
+-- name: GetTelegramChat :one
+SELECT * FROM telegram_chats WHERE user_id = $1;
+
+-- name: GetTelegramChatByChatID :one
+SELECT * FROM telegram_chats WHERE chat_id = $1;
+
+-- name: DeleteTelegramChat :execrows
+DELETE FROM telegram_chats WHERE user_id = $1;
+
+-- name: DeleteTelegramChatByChatID :exec
+DELETE FROM telegram_chats WHERE chat_id = $1;
+
+-- name: GetTelegramFeeds :many
+SELECT feed_id FROM telegram_feeds WHERE user_id = $1 ORDER BY created_at, feed_id;
+
+-- name: DeleteTelegramFeeds :exec
+DELETE FROM telegram_feeds WHERE user_id = $1;
+
+-- name: AddTelegramFeed :exec
+INSERT INTO telegram_feeds (user_id, feed_id, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: MoveTelegramFeeds :exec
+UPDATE telegram_feeds SET feed_id = @to_feed_id
+WHERE feed_id = @from_feed_id
+    AND user_id NOT IN (SELECT user_id FROM telegram_feeds WHERE feed_id = @to_feed_id);
+
+-- name: GetTelegramChatsForFeed :many
+SELECT tc.* FROM telegram_chats tc
+JOIN telegram_feeds tf ON tf.user_id = tc.user_id
+WHERE tf.feed_id = $1;
+
+-- name: CreateTelegramMessage :exec
+INSERT INTO telegram_messages (chat_id, message_id, post_id, created_at)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT DO NOTHING;
+
+-- name: GetTelegramMessagePost :one
+SELECT post_id FROM telegram_messages WHERE chat_id = $1 AND message_id = $2;
+
+-- name: GetUnreadTelegramPosts :many
+SELECT p.* FROM posts p
+JOIN telegram_feeds tf ON tf.feed_id = p.feed_id
+WHERE tf.user_id = $1
+    AND NOT EXISTS (
+        SELECT 1 FROM reading_progress rp
+        WHERE rp.user_id = tf.user_id AND rp.post_id = p.id AND rp.percent = 100
+    )
+ORDER BY coalesce(p.published_at, 'epoch') DESC, p.id DESC
+LIMIT $2;
@@ -0,0 +1,12 @@
+-- name: UpsertUserFeedToken :one
+INSERT INTO user_feed_tokens (user_id, token_hash, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET token_hash = EXCLUDED.token_hash, created_at = EXCLUDED.created_at
+RETURNING *;
+
+-- name: GetUserFeedToken :one
+SELECT * FROM user_feed_tokens WHERE token_hash = $1;
+
+-- name: DeleteUserFeedToken :exec
+DELETE FROM user_feed_tokens WHERE user_id = $1;
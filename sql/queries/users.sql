@@ -1,7 +1,33 @@
 -- name: InsertUser :one
-INSERT INTO users (id, created_at, updated_at, name, apikey)
-VALUES ($1, $2, $3, $4, encode(sha256(random()::text::bytea), 'hex'))
+INSERT INTO users (id, created_at, updated_at, name, email, allowed_cidrs, apikey_hash, password_hash)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
--- name: GetUserByApiKey :one
-SELECT * FROM users WHERE apikey = $1;
+-- name: GetUserByApiKeyHash :one
+SELECT * FROM users WHERE apikey_hash = $1;
+
+-- name: GetUserByName :one
+SELECT * FROM users WHERE lower(name) = lower(sqlc.arg('name'));
+
+-- name: MarkUserEmailVerified :exec
+UPDATE users SET email_verified_at = now(), updated_at = now()
+WHERE id = $1 AND email = $2;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users WHERE lower(email) = lower(sqlc.arg('email'));
+
+-- name: UpdateUserPassword :exec
+UPDATE users SET password_hash = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: UpdateUserProfile :one
+UPDATE users SET name = $2, email = $3, email_verified_at = $4, timezone = $5,
+    default_post_limit = $6, digest_frequency = $7, digest_hour = $8, updated_at = now()
+WHERE id = $1
+RETURNING *;
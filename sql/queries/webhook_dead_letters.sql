@@ -0,0 +1,16 @@
+-- name: CreateWebhookDeadLetter :exec
+INSERT INTO webhook_dead_letters (id, created_at, webhook_id, delivery_id, event_type, payload, content_type, attempts, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+
+-- name: GetWebhookDeadLetters :many
+SELECT * FROM webhook_dead_letters WHERE webhook_id = @webhook_id
+    AND (sqlc.narg('before_time')::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg('before_time')::timestamp, sqlc.narg('before_id')::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetWebhookDeadLetter :one
+SELECT * FROM webhook_dead_letters WHERE id = $1 AND webhook_id = $2;
+
+-- name: DeleteWebhookDeadLetter :exec
+DELETE FROM webhook_dead_letters WHERE id = $1;
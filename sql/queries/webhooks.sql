@@ -0,0 +1,31 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, user_id, url, secret, events, format)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING *;
+
+-- name: GetUserWebhooks :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhookByID :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: RotateWebhookSecret :one
+UPDATE webhooks SET previous_secret = secret, previous_secret_expires_at = $3, secret = $4, updated_at = now()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: GetWebhooksForFeed :many
+SELECT w.* FROM webhooks w
+JOIN feed_follows ff ON ff.user_id = w.user_id
+WHERE ff.feed_id = $1;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks SET events = $3, format = $4, updated_at = now()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
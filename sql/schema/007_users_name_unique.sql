@@ -0,0 +1,18 @@
+-- +goose Up
+-- Fails while users share a name, rename those users first.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY name HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'user names are taken more than once: %',
+            (SELECT string_agg(DISTINCT name, ', ' ORDER BY name) FROM users
+             WHERE name IN (SELECT name FROM users GROUP BY name HAVING count(*) > 1));
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE users ADD CONSTRAINT users_name_unique UNIQUE (name);
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT users_name_unique;
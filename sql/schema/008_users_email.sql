@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email varchar(255);
+ALTER TABLE users ADD COLUMN email_verified_at timestamp;
+ALTER TABLE users ADD CONSTRAINT users_email_unique UNIQUE (email);
+
+CREATE TABLE email_verifications (
+    token varchar(64) primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    email varchar(255) not null,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE email_verifications;
+ALTER TABLE users DROP CONSTRAINT users_email_unique;
+ALTER TABLE users DROP COLUMN email_verified_at;
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN allowed_cidrs text[] not null default '{}';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN allowed_cidrs;
@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE oauth_clients (
+    id uuid primary key,
+    created_at timestamp not null,
+    user_id uuid not null references users(id) on delete cascade,
+    name varchar(255) not null,
+    secret_hash varchar(64) not null,
+    redirect_uris text[] not null
+);
+
+CREATE TABLE oauth_codes (
+    code_hash varchar(64) primary key,
+    client_id uuid not null references oauth_clients(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    redirect_uri text not null,
+    scopes text[] not null,
+    expires_at timestamp not null
+);
+
+CREATE TABLE oauth_tokens (
+    token_hash varchar(64) primary key,
+    client_id uuid not null references oauth_clients(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    scopes text[] not null,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE oauth_tokens;
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
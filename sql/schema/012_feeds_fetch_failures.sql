@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN consecutive_failures int not null default 0;
+ALTER TABLE feeds ADD COLUMN last_error text;
+ALTER TABLE feeds ADD COLUMN next_fetch_at timestamp;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN next_fetch_at;
+ALTER TABLE feeds DROP COLUMN last_error;
+ALTER TABLE feeds DROP COLUMN consecutive_failures;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    id uuid primary key,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    user_id uuid not null references users(id) on delete cascade,
+    url text not null,
+    secret varchar(64) not null,
+    previous_secret varchar(64),
+    previous_secret_expires_at timestamp
+);
+
+-- +goose Down
+DROP TABLE webhooks;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN failing_since timestamp;
+ALTER TABLE feeds ADD COLUMN disabled_at timestamp;
+ALTER TABLE feeds ADD COLUMN disabled_reason text;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN disabled_reason;
+ALTER TABLE feeds DROP COLUMN disabled_at;
+ALTER TABLE feeds DROP COLUMN failing_since;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE webhook_deliveries (
+    id uuid primary key,
+    created_at timestamp not null,
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    event_type text not null,
+    payload text not null,
+    redelivery boolean not null default false,
+    status text not null default 'pending',
+    response_code int,
+    latency_ms int,
+    error text,
+    delivered_at timestamp
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_created_at_idx ON webhook_deliveries (webhook_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
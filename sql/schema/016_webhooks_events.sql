@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE webhooks ADD COLUMN events text[] not null default '{}';
+
+-- +goose Down
+ALTER TABLE webhooks DROP COLUMN events;
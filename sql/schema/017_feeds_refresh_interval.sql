@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN refresh_interval_seconds int;
+
+-- +goose Down
+ALTER TABLE feeds DROP COLUMN refresh_interval_seconds;
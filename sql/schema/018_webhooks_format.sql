@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE webhooks ADD COLUMN format text not null default 'json';
+ALTER TABLE webhook_deliveries ADD COLUMN content_type text not null default 'application/json';
+
+-- +goose Down
+ALTER TABLE webhook_deliveries DROP COLUMN content_type;
+ALTER TABLE webhooks DROP COLUMN format;
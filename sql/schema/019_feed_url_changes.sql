@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE feed_url_changes (
+    id uuid primary key,
+    created_at timestamp not null,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    old_url varchar(255) not null,
+    new_url varchar(255) not null
+);
+
+-- +goose Down
+DROP TABLE feed_url_changes;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+    id uuid primary key,
+    created_at timestamp not null,
+    user_id uuid not null references users(id) on delete cascade,
+    post_id uuid references posts(id) on delete set null,
+    url varchar(2048) not null,
+    title text not null default '',
+    description text not null default '',
+    folder text not null default '',
+    UNIQUE (user_id, url)
+);
+
+-- +goose Down
+DROP TABLE bookmarks;
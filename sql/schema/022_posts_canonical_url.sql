@@ -0,0 +1,8 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN canonical_url text;
+UPDATE posts SET canonical_url = split_part(url, '#', 1);
+ALTER TABLE posts ALTER COLUMN canonical_url SET NOT NULL;
+CREATE INDEX posts_canonical_url_idx ON posts (canonical_url);
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN canonical_url;
@@ -0,0 +1,6 @@
+-- +goose Up
+CREATE INDEX posts_feed_id_compact_idx ON posts (feed_id, canonical_url, created_at)
+    INCLUDE (id, title, published_at);
+
+-- +goose Down
+DROP INDEX posts_feed_id_compact_idx;
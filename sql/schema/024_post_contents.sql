@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE post_contents (
+    post_id uuid primary key references posts(id) on delete cascade,
+    created_at timestamp not null,
+    html text not null,
+    text text not null
+);
+
+-- +goose Down
+DROP TABLE post_contents;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content text not null default '';
+ALTER TABLE posts ADD COLUMN author text not null default '';
+ALTER TABLE posts ADD COLUMN categories text[] not null default '{}';
+ALTER TABLE posts ADD COLUMN enclosures jsonb not null default '[]';
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN enclosures;
+ALTER TABLE posts DROP COLUMN categories;
+ALTER TABLE posts DROP COLUMN author;
+ALTER TABLE posts DROP COLUMN content;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN image_url text;
+ALTER TABLE posts ADD COLUMN site_name text;
+ALTER TABLE posts ADD COLUMN page_canonical_url text;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN page_canonical_url;
+ALTER TABLE posts DROP COLUMN site_name;
+ALTER TABLE posts DROP COLUMN image_url;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN duration integer;
+ALTER TABLE posts ADD COLUMN episode integer;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN episode;
+ALTER TABLE posts DROP COLUMN duration;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN thumbnail_url text;
+
+DROP INDEX posts_feed_id_compact_idx;
+CREATE INDEX posts_feed_id_compact_idx ON posts (feed_id, canonical_url, created_at)
+    INCLUDE (id, title, published_at, thumbnail_url);
+
+-- +goose Down
+DROP INDEX posts_feed_id_compact_idx;
+CREATE INDEX posts_feed_id_compact_idx ON posts (feed_id, canonical_url, created_at)
+    INCLUDE (id, title, published_at);
+
+ALTER TABLE posts DROP COLUMN thumbnail_url;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE feed_icons (
+    feed_id uuid primary key references feeds(id) on delete cascade,
+    fetched_at timestamp not null,
+    source_url text not null default '',
+    content_type text not null default '',
+    hash text not null default ''
+);
+
+CREATE INDEX feed_icons_fetched_at_idx ON feed_icons (fetched_at);
+
+-- +goose Down
+DROP TABLE feed_icons;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE feed_notes (
+    id uuid primary key,
+    created_at timestamp not null,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    note text not null
+);
+
+CREATE INDEX feed_notes_feed_id_created_at_idx ON feed_notes (feed_id, created_at);
+
+-- +goose Down
+DROP TABLE feed_notes;
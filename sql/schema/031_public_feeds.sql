@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE public_feeds (
+    feed_id uuid primary key references feeds(id) on delete cascade,
+    created_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE public_feeds;
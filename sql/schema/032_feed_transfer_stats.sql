@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE feed_transfer_stats (
+    feed_id uuid primary key references feeds(id) on delete cascade,
+    updated_at timestamp not null,
+    fetches bigint not null,
+    last_transfer_bytes bigint not null,
+    last_body_bytes bigint not null,
+    last_content_encoding text not null,
+    total_transfer_bytes bigint not null,
+    total_body_bytes bigint not null
+);
+
+-- +goose Down
+DROP TABLE feed_transfer_stats;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE feed_credentials (
+    feed_id uuid primary key references feeds(id) on delete cascade,
+    updated_at timestamp not null,
+    sealed bytea not null
+);
+
+-- +goose Down
+DROP TABLE feed_credentials;
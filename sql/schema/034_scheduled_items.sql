@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE scheduled_items (
+    id uuid primary key,
+    created_at timestamp not null,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    guid text not null,
+    publish_at timestamp not null,
+    item jsonb not null,
+    unique (feed_id, guid)
+);
+
+CREATE INDEX scheduled_items_publish_at_idx ON scheduled_items (publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_items;
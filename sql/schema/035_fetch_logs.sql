@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE fetch_logs (
+    id uuid primary key,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    started_at timestamp not null,
+    duration_ms integer not null,
+    status_code integer,
+    items_found integer not null,
+    items_inserted integer not null,
+    error text
+);
+
+CREATE INDEX fetch_logs_feed_id_started_at_idx ON fetch_logs (feed_id, started_at);
+CREATE INDEX fetch_logs_started_at_idx ON fetch_logs (started_at);
+
+-- +goose Down
+DROP TABLE fetch_logs;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE feed_snapshots (
+    id uuid primary key,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    fetched_at timestamp not null,
+    content_type text not null,
+    size integer not null,
+    compressed_size integer not null
+);
+
+CREATE INDEX feed_snapshots_feed_id_fetched_at_idx ON feed_snapshots (feed_id, fetched_at);
+
+-- +goose Down
+DROP TABLE feed_snapshots;
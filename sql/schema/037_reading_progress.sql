@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE reading_progress (
+    user_id uuid not null references users(id) on delete cascade,
+    post_id uuid not null references posts(id) on delete cascade,
+    updated_at timestamp not null,
+    percent double precision not null check (percent >= 0 and percent <= 100),
+    anchor text not null default '',
+    PRIMARY KEY (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE reading_progress;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Fails while names that differ only in case exist, rename those users first.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users GROUP BY lower(name) HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'user names differ only in case: %',
+            (SELECT string_agg(name, ', ' ORDER BY name) FROM users
+             WHERE lower(name) IN (SELECT lower(name) FROM users GROUP BY lower(name) HAVING count(*) > 1));
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+CREATE UNIQUE INDEX users_name_lower_unique ON users (lower(name));
+
+-- +goose Down
+DROP INDEX users_name_lower_unique;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE feed_scrapers (
+    feed_id uuid primary key references feeds(id) on delete cascade,
+    updated_at timestamp not null,
+    item_selector text not null,
+    link_selector text not null default '',
+    title_selector text not null default '',
+    date_selector text not null default ''
+);
+
+-- +goose Down
+DROP TABLE feed_scrapers;
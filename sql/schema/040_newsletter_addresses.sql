@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE newsletter_addresses (
+    user_id uuid primary key references users(id) on delete cascade,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    token text not null unique,
+    created_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE newsletter_addresses;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE user_feed_tokens (
+    user_id uuid primary key references users(id) on delete cascade,
+    token_hash text not null unique,
+    created_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE user_feed_tokens;
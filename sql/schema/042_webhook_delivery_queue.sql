@@ -0,0 +1,26 @@
+-- +goose Up
+ALTER TABLE webhook_deliveries ADD COLUMN attempts int not null default 0;
+ALTER TABLE webhook_deliveries ADD COLUMN next_attempt_at timestamp;
+
+CREATE INDEX webhook_deliveries_next_attempt_at_idx ON webhook_deliveries (next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE TABLE webhook_dead_letters (
+    id uuid primary key,
+    created_at timestamp not null,
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    delivery_id uuid not null,
+    event_type text not null,
+    payload text not null,
+    content_type text not null,
+    attempts int not null,
+    error text
+);
+
+CREATE INDEX webhook_dead_letters_webhook_id_created_at_idx ON webhook_dead_letters (webhook_id, created_at);
+
+-- +goose Down
+DROP TABLE webhook_dead_letters;
+DROP INDEX webhook_deliveries_next_attempt_at_idx;
+ALTER TABLE webhook_deliveries DROP COLUMN next_attempt_at;
+ALTER TABLE webhook_deliveries DROP COLUMN attempts;
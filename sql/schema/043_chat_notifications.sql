@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE chat_notifications (
+    id uuid primary key,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    user_id uuid not null references users(id) on delete cascade,
+    provider text not null,
+    webhook_url text not null,
+    feed_id uuid references feeds(id) on delete cascade,
+    keywords text[] not null default '{}',
+    template text not null default '',
+    last_sent_at timestamp,
+    last_error text
+);
+
+CREATE INDEX chat_notifications_user_id_idx ON chat_notifications (user_id);
+CREATE INDEX chat_notifications_feed_id_idx ON chat_notifications (feed_id);
+
+CREATE TABLE chat_notification_posts (
+    chat_notification_id uuid not null references chat_notifications(id) on delete cascade,
+    post_id uuid not null references posts(id) on delete cascade,
+    created_at timestamp not null,
+    primary key (chat_notification_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE chat_notification_posts;
+DROP TABLE chat_notifications;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE telegram_chats (
+    user_id uuid primary key references users(id) on delete cascade,
+    chat_id bigint not null unique,
+    created_at timestamp not null
+);
+
+CREATE TABLE telegram_link_codes (
+    code_hash text primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    expires_at timestamp not null
+);
+
+CREATE TABLE telegram_feeds (
+    user_id uuid not null references users(id) on delete cascade,
+    feed_id uuid not null references feeds(id) on delete cascade,
+    created_at timestamp not null,
+    primary key (user_id, feed_id)
+);
+
+CREATE INDEX telegram_feeds_feed_id_idx ON telegram_feeds (feed_id);
+
+CREATE TABLE telegram_messages (
+    chat_id bigint not null,
+    message_id bigint not null,
+    post_id uuid not null references posts(id) on delete cascade,
+    created_at timestamp not null,
+    primary key (chat_id, message_id)
+);
+
+-- +goose Down
+DROP TABLE telegram_messages;
+DROP TABLE telegram_feeds;
+DROP TABLE telegram_link_codes;
+DROP TABLE telegram_chats;
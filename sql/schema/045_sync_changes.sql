@@ -0,0 +1,72 @@
+-- +goose Up
+-- A log of changes for GET /v1/sync. txid is the transaction that made the
+-- change, changes are only handed out once every transaction before them has
+-- committed, so a slow transaction can't slip a change behind a sync token.
+CREATE TABLE sync_changes (
+    id bigserial primary key,
+    txid bigint not null default pg_current_xact_id()::text::bigint,
+    created_at timestamp not null default now(),
+    user_id uuid,
+    feed_id uuid,
+    entity text not null,
+    entity_id uuid not null,
+    deleted boolean not null default false
+);
+
+CREATE INDEX sync_changes_txid_id_idx ON sync_changes (txid, id);
+CREATE INDEX sync_changes_created_at_idx ON sync_changes (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION sync_post_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_changes (feed_id, entity, entity_id, deleted) VALUES (OLD.feed_id, 'post', OLD.id, true);
+        RETURN OLD;
+    END IF;
+    INSERT INTO sync_changes (feed_id, entity, entity_id) VALUES (NEW.feed_id, 'post', NEW.id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION sync_reading_progress_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_changes (user_id, entity, entity_id, deleted) VALUES (OLD.user_id, 'read_state', OLD.post_id, true);
+        RETURN OLD;
+    END IF;
+    INSERT INTO sync_changes (user_id, entity, entity_id) VALUES (NEW.user_id, 'read_state', NEW.post_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION sync_feed_follow_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        INSERT INTO sync_changes (user_id, feed_id, entity, entity_id, deleted) VALUES (OLD.user_id, OLD.feed_id, 'follow', OLD.feed_id, true);
+        RETURN OLD;
+    END IF;
+    INSERT INTO sync_changes (user_id, feed_id, entity, entity_id) VALUES (NEW.user_id, NEW.feed_id, 'follow', NEW.feed_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER posts_sync_changes AFTER INSERT OR UPDATE OR DELETE ON posts
+    FOR EACH ROW EXECUTE FUNCTION sync_post_change();
+CREATE TRIGGER reading_progress_sync_changes AFTER INSERT OR UPDATE OR DELETE ON reading_progress
+    FOR EACH ROW EXECUTE FUNCTION sync_reading_progress_change();
+CREATE TRIGGER feed_follows_sync_changes AFTER INSERT OR DELETE ON feed_follows
+    FOR EACH ROW EXECUTE FUNCTION sync_feed_follow_change();
+
+-- +goose Down
+DROP TRIGGER feed_follows_sync_changes ON feed_follows;
+DROP TRIGGER reading_progress_sync_changes ON reading_progress;
+DROP TRIGGER posts_sync_changes ON posts;
+DROP FUNCTION sync_feed_follow_change();
+DROP FUNCTION sync_reading_progress_change();
+DROP FUNCTION sync_post_change();
+DROP TABLE sync_changes;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Extra API keys of a user, limited to scopes. The key in users.apikey keeps
+-- full access.
+CREATE TABLE api_keys (
+    id uuid primary key,
+    created_at timestamp not null,
+    user_id uuid not null references users(id) on delete cascade,
+    name text not null,
+    key_hash text not null unique,
+    scopes text[] not null
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+-- +goose Down
+DROP TABLE api_keys;
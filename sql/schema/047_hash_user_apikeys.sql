@@ -0,0 +1,14 @@
+-- +goose Up
+-- Only a SHA-256 of the API key is kept, like api_keys.key_hash, so a leak of
+-- the database doesn't leak working keys. Existing keys keep working.
+ALTER TABLE users ADD COLUMN apikey_hash text;
+UPDATE users SET apikey_hash = encode(sha256(apikey::bytea), 'hex');
+ALTER TABLE users ALTER COLUMN apikey_hash SET NOT NULL;
+ALTER TABLE users ADD CONSTRAINT users_apikey_hash_unique UNIQUE (apikey_hash);
+ALTER TABLE users DROP COLUMN apikey;
+
+-- +goose Down
+-- The keys can't be recovered, every user gets a new one.
+ALTER TABLE users ADD COLUMN
+apikey VARCHAR(64) unique not null default encode(sha256(random()::text::bytea), 'hex');
+ALTER TABLE users DROP COLUMN apikey_hash;
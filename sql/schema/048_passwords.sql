@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN password_hash text;
+
+-- Tokens from POST /v1/login, hashed like the API keys.
+CREATE TABLE sessions (
+    token_hash text primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- +goose Down
+DROP TABLE sessions;
+ALTER TABLE users DROP COLUMN password_hash;
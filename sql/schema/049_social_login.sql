@@ -0,0 +1,26 @@
+-- +goose Up
+-- Accounts at GitHub, Google, ... that log in as a user.
+CREATE TABLE user_identities (
+    provider text not null,
+    subject text not null,
+    user_id uuid not null references users(id) on delete cascade,
+    email text not null,
+    created_at timestamp not null,
+    primary key (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- The state of a login at a provider in progress. user_id is set when a
+-- logged in user links an account.
+CREATE TABLE social_login_states (
+    state_hash text primary key,
+    provider text not null,
+    user_id uuid references users(id) on delete cascade,
+    redirect_uri text not null,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+DROP TABLE social_login_states;
+DROP TABLE user_identities;
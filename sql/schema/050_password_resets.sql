@@ -0,0 +1,21 @@
+-- +goose Up
+-- Verification links are hashed like the API keys, links already sent keep
+-- working.
+UPDATE email_verifications SET token = encode(sha256(token::bytea), 'hex');
+ALTER TABLE email_verifications RENAME COLUMN token TO token_hash;
+
+CREATE TABLE password_resets (
+    token_hash text primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    email varchar(255) not null,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+CREATE INDEX password_resets_user_id_idx ON password_resets (user_id);
+
+-- +goose Down
+DROP TABLE password_resets;
+-- The links that were sent can't be recovered.
+DELETE FROM email_verifications;
+ALTER TABLE email_verifications RENAME COLUMN token_hash TO token;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN timezone text not null default 'UTC';
+-- NULL is the server's default page size.
+ALTER TABLE users ADD COLUMN default_post_limit integer;
+ALTER TABLE users ADD COLUMN digest_frequency text not null default 'off'
+    CHECK (digest_frequency IN ('off', 'daily', 'weekly'));
+ALTER TABLE users ADD COLUMN digest_hour smallint not null default 8
+    CHECK (digest_hour BETWEEN 0 AND 23);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN digest_hour;
+ALTER TABLE users DROP COLUMN digest_frequency;
+ALTER TABLE users DROP COLUMN default_post_limit;
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- 022 filled in canonical_url with the url minus its fragment, posts saved
+-- since have the url canonicalPostURL makes of theirs, without tracking
+-- parameters. The posts listed here are brought in line by the server in the
+-- background after it started, and removed from the list as they are.
+CREATE TABLE post_canonical_url_backfills (
+    post_id uuid primary key references posts(id) on delete cascade
+);
+INSERT INTO post_canonical_url_backfills (post_id) SELECT id FROM posts;
+
+-- +goose Down
+DROP TABLE post_canonical_url_backfills;
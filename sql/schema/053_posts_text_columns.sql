@@ -0,0 +1,9 @@
+-- +goose Up
+-- newsletter subjects and bodies, pushed items and video descriptions are
+-- longer than the limits feeds used to keep to
+ALTER TABLE posts ALTER COLUMN title TYPE text;
+ALTER TABLE posts ALTER COLUMN description TYPE text;
+
+-- +goose Down
+ALTER TABLE posts ALTER COLUMN description TYPE varchar(1024) USING left(description, 1024);
+ALTER TABLE posts ALTER COLUMN title TYPE varchar(255) USING left(title, 255);
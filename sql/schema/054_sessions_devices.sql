@@ -0,0 +1,19 @@
+-- +goose Up
+-- A session keeps its id from the login on, through the refresh tokens that
+-- replace each other, so it can be listed and revoked.
+ALTER TABLE sessions ADD COLUMN id uuid;
+UPDATE sessions SET id = md5(token_hash)::uuid;
+ALTER TABLE sessions ALTER COLUMN id SET NOT NULL;
+CREATE UNIQUE INDEX sessions_id_idx ON sessions (id);
+
+ALTER TABLE sessions ADD COLUMN last_used_at timestamp;
+UPDATE sessions SET last_used_at = created_at;
+ALTER TABLE sessions ALTER COLUMN last_used_at SET NOT NULL;
+
+ALTER TABLE sessions ADD COLUMN user_agent text not null default '';
+
+-- +goose Down
+ALTER TABLE sessions DROP COLUMN user_agent;
+ALTER TABLE sessions DROP COLUMN last_used_at;
+DROP INDEX sessions_id_idx;
+ALTER TABLE sessions DROP COLUMN id;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- newsletters are private to their owner, others could follow them before
+DELETE FROM feed_follows ff
+USING feeds f
+WHERE ff.feed_id = f.id AND f.url LIKE 'newsletter:%' AND ff.user_id <> f.user_id;
+
+-- +goose Down
+-- the follows can't come back
+SELECT 1;
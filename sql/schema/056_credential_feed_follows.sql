@@ -0,0 +1,11 @@
+-- +goose Up
+-- feeds fetched with credentials are private to their owner, others could
+-- follow them before
+DELETE FROM feed_follows ff
+USING feeds f
+WHERE ff.feed_id = f.id AND ff.user_id <> f.user_id
+    AND EXISTS (SELECT 1 FROM feed_credentials c WHERE c.feed_id = f.id);
+
+-- +goose Down
+-- the follows can't come back
+SELECT 1;
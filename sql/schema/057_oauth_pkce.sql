@@ -0,0 +1,6 @@
+-- +goose Up
+-- the S256 PKCE challenge of the code, empty when the client sent none
+ALTER TABLE oauth_codes ADD COLUMN code_challenge text not null default '';
+
+-- +goose Down
+ALTER TABLE oauth_codes DROP COLUMN code_challenge;
@@ -0,0 +1,8 @@
+// Package schema holds the goose migrations of the database, embedded so the
+// server can apply them itself.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var Migrations embed.FS
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+var errPrivateFeedURL = errors.New("URL points to a private or local address")
+
+// allowPrivateNetworks turns the checks below off, for development setups that
+// serve feeds from localhost. Set from FETCH_ALLOW_PRIVATE_NETWORKS.
+var allowPrivateNetworks = false
+
+var (
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+)
+
+// isPublicAddr reports whether addr is a globally routable unicast address,
+// i.e. not loopback, private, link-local (cloud metadata lives there),
+// multicast or otherwise reserved.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr) &&
+		!thisNetwork.Contains(addr)
+}
+
+// dialControl runs right before every outgoing connection with the resolved
+// address, so it also covers redirects and DNS answers that change between
+// the check at creation time and the fetch.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	if allowPrivateNetworks {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return errPrivateFeedURL
+	}
+
+	return nil
+}
+
+// checkPublicURL resolves the host of rawURL and fails when any of its
+// addresses is not public. It gives a clear error up front, dialControl is
+// what actually enforces it.
+func checkPublicURL(ctx context.Context, rawURL string) error {
+	if allowPrivateNetworks {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return errPrivateFeedURL
+		}
+	}
+
+	return nil
+}
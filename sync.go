@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+const (
+	// syncChangeRetention is how long the change log is kept, older sync
+	// tokens need a full sync.
+	syncChangeRetention = 30 * 24 * time.Hour
+
+	syncDefaultLimit = 500
+	syncMaxLimit     = 1000
+)
+
+var errInvalidSyncToken = errors.New("Invalid sync token")
+
+// syncToken is where a client is in the change log: after the change id of
+// transaction txid. Issued is when the token was handed out, to tell tokens
+// whose changes were pruned.
+type syncToken struct {
+	Txid   int64
+	ID     int64
+	Issued time.Time
+}
+
+func (t syncToken) String() string {
+	raw := fmt.Sprintf("%d|%d|%d", t.Txid, t.ID, t.Issued.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseSyncToken(s string) (syncToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return syncToken{}, errInvalidSyncToken
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return syncToken{}, errInvalidSyncToken
+	}
+	var numbers [3]int64
+	for i, part := range parts {
+		numbers[i], err = strconv.ParseInt(part, 10, 64)
+		if err != nil || numbers[i] < 0 {
+			return syncToken{}, errInvalidSyncToken
+		}
+	}
+
+	return syncToken{Txid: numbers[0], ID: numbers[1], Issued: time.Unix(numbers[2], 0)}, nil
+}
+
+type syncReadState struct {
+	PostID    uuid.UUID `json:"post_id"`
+	Percent   float64   `json:"percent"`
+	Anchor    string    `json:"anchor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type syncResponse struct {
+	Posts            []database.Post       `json:"posts"`
+	DeletedPosts     []uuid.UUID           `json:"deleted_posts"`
+	ReadState        []syncReadState       `json:"read_state"`
+	DeletedReadState []uuid.UUID           `json:"deleted_read_state"`
+	Follows          []database.FeedFollow `json:"follows"`
+	DeletedFollows   []uuid.UUID           `json:"deleted_follows"`
+	Next             string                `json:"next"`
+	HasMore          bool                  `json:"has_more"`
+}
+
+/*
+Endpoint: GET /v1/sync?since=<token>
+
+# This is an authenticated endpoint
+
+Returns what changed since the token for offline clients: new and updated
+posts of GET /v1/posts, read state as stored by PUT
+/v1/posts/{post_id}/progress, and followed feeds, each with the ids of the
+deleted ones. Deleted follows are feed ids.
+
+	{
+		"posts": [...],
+		"deleted_posts": ["..."],
+		"read_state": [{"post_id": "...", "percent": 40, "anchor": "#intro", "updated_at": "..."}],
+		"deleted_read_state": [],
+		"follows": [...],
+		"deleted_follows": [],
+		"next": "MTIzNHw0NTZ8MTcwNDIwNjY0NQ",
+		"has_more": false
+	}
+
+Without since nothing is returned but the token to start from, clients do a
+full sync with the other endpoints first. With has_more the client calls
+again with next right away, at most limit changes (default 500, up to 1000)
+are returned at once. Tokens are good for 30 days, older ones get a 410 and
+the client has to do a full sync again.
+*/
+func getSyncHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		query := r.URL.Query()
+		limit := syncDefaultLimit
+		if s := query.Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > syncMaxLimit {
+				respondWithError(w, 400, "Invalid limit, expected 1 to "+strconv.Itoa(syncMaxLimit))
+				return
+			}
+			limit = n
+		}
+
+		var since *syncToken
+		if s := query.Get("since"); s != "" {
+			token, err := parseSyncToken(s)
+			if err != nil {
+				respondWithError(w, 400, err.Error())
+				return
+			}
+			if time.Since(token.Issued) > syncChangeRetention {
+				respondWithErrorCode(w, 410, "sync_token_expired", "Sync token expired, do a full sync")
+				return
+			}
+			since = &token
+		}
+
+		context := r.Context()
+		// changes of transactions from here on may still be in flight
+		watermark, err := apiConfig.DB.GetSyncWatermark(context)
+		if err != nil {
+			httpLog.Error("Error getting sync watermark", "err", err)
+			respondWithError(w, 500, "Error syncing")
+			return
+		}
+
+		resp := syncResponse{
+			Posts:            []database.Post{},
+			DeletedPosts:     []uuid.UUID{},
+			ReadState:        []syncReadState{},
+			DeletedReadState: []uuid.UUID{},
+			Follows:          []database.FeedFollow{},
+			DeletedFollows:   []uuid.UUID{},
+		}
+		if since == nil {
+			resp.Next = syncToken{Txid: watermark, Issued: time.Now()}.String()
+			respondWithJSON(w, 200, resp)
+			return
+		}
+
+		changes, err := apiConfig.DB.GetSyncChanges(context, database.GetSyncChangesParams{
+			AfterTxid: since.Txid,
+			AfterID:   since.ID,
+			UntilTxid: watermark,
+			UserID:    user.ID,
+			Limit:     int32(limit + 1),
+		})
+		if err != nil {
+			httpLog.Error("Error getting sync changes", "err", err)
+			respondWithError(w, 500, "Error syncing")
+			return
+		}
+
+		next := syncToken{Txid: watermark, Issued: time.Now()}
+		if len(changes) > limit {
+			changes = changes[:limit]
+			last := changes[len(changes)-1]
+			next = syncToken{Txid: last.Txid, ID: last.ID, Issued: time.Now()}
+			resp.HasMore = true
+		}
+		resp.Next = next.String()
+
+		// the last change of an entity wins, a row that is gone by now counts
+		// as deleted whatever the change was
+		changed := map[string][]uuid.UUID{}
+		seen := map[string]map[uuid.UUID]bool{}
+		for i := len(changes) - 1; i >= 0; i-- {
+			change := changes[i]
+			if seen[change.Entity] == nil {
+				seen[change.Entity] = map[uuid.UUID]bool{}
+			}
+			if seen[change.Entity][change.EntityID] {
+				continue
+			}
+			seen[change.Entity][change.EntityID] = true
+			switch {
+			case change.Deleted && change.Entity == "post":
+				resp.DeletedPosts = append(resp.DeletedPosts, change.EntityID)
+			case change.Deleted && change.Entity == "read_state":
+				resp.DeletedReadState = append(resp.DeletedReadState, change.EntityID)
+			case change.Deleted && change.Entity == "follow":
+				resp.DeletedFollows = append(resp.DeletedFollows, change.EntityID)
+			default:
+				changed[change.Entity] = append(changed[change.Entity], change.EntityID)
+			}
+		}
+
+		if ids := changed["post"]; len(ids) > 0 {
+			posts, err := apiConfig.DB.GetPostsByIDs(context, ids)
+			if err != nil {
+				httpLog.Error("Error getting synced posts", "err", err)
+				respondWithError(w, 500, "Error syncing")
+				return
+			}
+			found := make(map[uuid.UUID]bool, len(posts))
+			for _, post := range posts {
+				found[post.ID] = true
+			}
+			resp.Posts = append(resp.Posts, posts...)
+			resp.DeletedPosts = append(resp.DeletedPosts, missingIDs(ids, found)...)
+		}
+
+		if ids := changed["read_state"]; len(ids) > 0 {
+			progress, err := apiConfig.DB.GetReadingProgressByPosts(context, database.GetReadingProgressByPostsParams{
+				UserID:  user.ID,
+				PostIds: ids,
+			})
+			if err != nil {
+				httpLog.Error("Error getting synced reading progress", "err", err)
+				respondWithError(w, 500, "Error syncing")
+				return
+			}
+			found := make(map[uuid.UUID]bool, len(progress))
+			for _, p := range progress {
+				found[p.PostID] = true
+				resp.ReadState = append(resp.ReadState, syncReadState{
+					PostID:    p.PostID,
+					Percent:   p.Percent,
+					Anchor:    p.Anchor,
+					UpdatedAt: p.UpdatedAt,
+				})
+			}
+			resp.DeletedReadState = append(resp.DeletedReadState, missingIDs(ids, found)...)
+		}
+
+		if ids := changed["follow"]; len(ids) > 0 {
+			follows, err := apiConfig.DB.GetFeedFollowsByFeeds(context, database.GetFeedFollowsByFeedsParams{
+				UserID:  user.ID,
+				FeedIds: ids,
+			})
+			if err != nil {
+				httpLog.Error("Error getting synced follows", "err", err)
+				respondWithError(w, 500, "Error syncing")
+				return
+			}
+			found := make(map[uuid.UUID]bool, len(follows))
+			for _, follow := range follows {
+				found[follow.FeedID] = true
+			}
+			resp.Follows = append(resp.Follows, follows...)
+			resp.DeletedFollows = append(resp.DeletedFollows, missingIDs(ids, found)...)
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+// missingIDs returns the ids not in found.
+func missingIDs(ids []uuid.UUID, found map[uuid.UUID]bool) []uuid.UUID {
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/telegram"
+)
+
+/*
+The Telegram bot is on when TELEGRAM_BOT_TOKEN is set. Users link their chat
+by sending the bot a one-time code from POST /v1/telegram/link, then get the
+new posts of the feeds they picked with PUT /v1/telegram/feeds.
+
+Replying /read or /star to a post marks it read or bookmarks it, /list sends
+the latest unread posts. Telegram posts the messages to
+POST /v1/telegram/webhook, which is registered with the bot on start.
+*/
+
+const (
+	telegramCodeTTL = 10 * time.Minute
+	// telegramMessageRetention is how long replies to a post still find it
+	telegramMessageRetention = 30 * 24 * time.Hour
+	// telegramBatchMax is how many new posts of a feed are sent one by one,
+	// more are summed up in a single message
+	telegramBatchMax = 5
+	telegramListMax  = 5
+)
+
+var errTelegramDisabled = errors.New("Telegram is disabled")
+
+const telegramHelp = `Reply to a post with /read to mark it read, or /star to bookmark it.
+/list sends the latest unread posts, /unlink stops the messages.`
+
+// telegramWebhookSecret derives the secret Telegram sends along with updates
+// from the bot token, so it needs no setting of its own.
+func telegramWebhookSecret(token string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("webhook"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// setupTelegram registers the webhook with Telegram and looks up the bot's
+// username.
+func setupTelegram(ctx context.Context, bot *telegram.Bot, baseURL, token string) (string, error) {
+	me, err := bot.GetMe(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = bot.SetWebhook(ctx, baseURL+"/v1/telegram/webhook", telegramWebhookSecret(token))
+	if err != nil {
+		return "", err
+	}
+
+	return me.Username, nil
+}
+
+type telegramResponse struct {
+	Linked   bool        `json:"linked"`
+	LinkedAt *time.Time  `json:"linked_at"`
+	FeedIDs  []uuid.UUID `json:"feed_ids"`
+}
+
+/*
+Endpoint: POST /v1/telegram/link
+
+# This is an authenticated endpoint
+
+Creates a one-time code that links the Telegram chat it's sent from to the
+user, valid for 10 minutes. "link" opens the bot with the code filled in,
+when the bot has a username. The user needs a verified email address,
+otherwise the response is a 403.
+*/
+func postTelegramLinkHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if apiConfig.Telegram == nil {
+			respondWithErrorCode(w, 404, "feature_disabled", errTelegramDisabled.Error())
+			return
+		}
+		if !requireVerifiedEmail(w, user) {
+			return
+		}
+
+		code, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating telegram link code", "err", err)
+			respondWithError(w, 500, "Error creating link code")
+			return
+		}
+		// short enough to type
+		code = code[:12]
+
+		expiresAt := time.Now().Add(telegramCodeTTL)
+		err = apiConfig.DB.CreateTelegramLinkCode(r.Context(), database.CreateTelegramLinkCodeParams{
+			CodeHash:  hashToken(code),
+			UserID:    user.ID,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			httpLog.Error("Error creating telegram link code", "err", err)
+			respondWithError(w, 500, "Error creating link code")
+			return
+		}
+
+		resp := struct {
+			Code      string    `json:"code"`
+			Command   string    `json:"command"`
+			Link      string    `json:"link,omitempty"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}{
+			Code:      code,
+			Command:   "/start " + code,
+			ExpiresAt: expiresAt,
+		}
+		if apiConfig.TelegramUsername != "" {
+			resp.Link = "https://t.me/" + apiConfig.TelegramUsername + "?start=" + code
+		}
+
+		respondWithJSON(w, 201, resp)
+	}
+}
+
+/*
+Endpoint: GET /v1/telegram
+
+# This is an authenticated endpoint
+
+Tells whether a Telegram chat is linked, and the feeds whose posts are sent
+to it.
+*/
+func getTelegramHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if apiConfig.Telegram == nil {
+			respondWithErrorCode(w, 404, "feature_disabled", errTelegramDisabled.Error())
+			return
+		}
+
+		context := r.Context()
+		resp := telegramResponse{FeedIDs: []uuid.UUID{}}
+		chat, err := apiConfig.DB.GetTelegramChat(context, user.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			httpLog.Error("Error getting telegram chat", "err", err)
+			respondWithError(w, 500, "Error getting telegram settings")
+			return
+		}
+		if err == nil {
+			resp.Linked = true
+			resp.LinkedAt = &chat.CreatedAt
+		}
+
+		feedIDs, err := apiConfig.DB.GetTelegramFeeds(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting telegram feeds", "err", err)
+			respondWithError(w, 500, "Error getting telegram settings")
+			return
+		}
+		resp.FeedIDs = append(resp.FeedIDs, feedIDs...)
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: PUT /v1/telegram/feeds
+
+# This is an authenticated endpoint
+
+Sets the feeds whose new posts are sent to the linked chat, as "feed_ids".
+The feeds have to be the user's or followed by them.
+*/
+func putTelegramFeedsHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		if apiConfig.Telegram == nil {
+			respondWithErrorCode(w, 404, "feature_disabled", errTelegramDisabled.Error())
+			return
+		}
+
+		type TelegramFeedsRequest struct {
+			FeedIDs []uuid.UUID `json:"feed_ids"`
+		}
+
+		var req TelegramFeedsRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		feedFollows, err := apiConfig.DB.GetUserFeedFollows(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting feed follows", "err", err)
+			respondWithError(w, 500, "Error saving telegram feeds")
+			return
+		}
+		allowed := map[uuid.UUID]bool{}
+		for _, feedFollow := range feedFollows {
+			allowed[feedFollow.FeedID] = true
+		}
+		for _, feedID := range req.FeedIDs {
+			if allowed[feedID] {
+				continue
+			}
+			feed, err := apiConfig.DB.GetFeedByID(context, feedID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				httpLog.Error("Error getting feed", "err", err)
+				respondWithError(w, 500, "Error saving telegram feeds")
+				return
+			}
+			if err != nil || feed.UserID != user.ID {
+				respondWithErrorCode(w, 404, "feed_not_found", fmt.Sprintf("Feed not found: %s", feedID))
+				return
+			}
+		}
+
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			if err := q.DeleteTelegramFeeds(context, user.ID); err != nil {
+				return err
+			}
+			for _, feedID := range req.FeedIDs {
+				err := q.AddTelegramFeed(context, database.AddTelegramFeedParams{
+					UserID:    user.ID,
+					FeedID:    feedID,
+					CreatedAt: time.Now(),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			httpLog.Error("Error saving telegram feeds", "err", err)
+			respondWithError(w, 500, "Error saving telegram feeds")
+			return
+		}
+
+		feedIDs, err := apiConfig.DB.GetTelegramFeeds(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting telegram feeds", "err", err)
+			respondWithError(w, 500, "Error saving telegram feeds")
+			return
+		}
+
+		respondWithJSON(w, 200, struct {
+			FeedIDs []uuid.UUID `json:"feed_ids"`
+		}{append([]uuid.UUID{}, feedIDs...)})
+	}
+}
+
+/*
+Endpoint: DELETE /v1/telegram
+
+# This is an authenticated endpoint
+
+Unlinks the Telegram chat. The picked feeds are kept for the next link.
+*/
+func deleteTelegramHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		deleted, err := apiConfig.DB.DeleteTelegramChat(r.Context(), user.ID)
+		if err != nil {
+			httpLog.Error("Error unlinking telegram chat", "err", err)
+			respondWithError(w, 500, "Error unlinking telegram chat")
+			return
+		}
+		if deleted == 0 {
+			respondWithError(w, 404, "No telegram chat linked")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: POST /v1/telegram/webhook
+
+Receives the updates of the bot from Telegram, checked by the secret it was
+registered with. Always answers 200 to updates it accepted, Telegram would
+send them again otherwise.
+*/
+func postTelegramWebhookHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if apiConfig.Telegram == nil {
+			respondWithErrorCode(w, 404, "feature_disabled", errTelegramDisabled.Error())
+			return
+		}
+
+		secret := r.Header.Get(telegram.SecretHeader)
+		if !hmac.Equal([]byte(secret), []byte(apiConfig.TelegramSecret)) {
+			respondWithError(w, 401, "Invalid secret")
+			return
+		}
+
+		var update telegram.Update
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&update)
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_body", "Error decoding request")
+			return
+		}
+
+		if update.Message != nil && strings.HasPrefix(update.Message.Text, "/") {
+			reply := handleTelegramCommand(r.Context(), apiConfig, update.Message)
+			if reply != "" {
+				sendTelegram(r.Context(), apiConfig, update.Message.Chat.ID, reply)
+			}
+		}
+
+		respondWithJSON(w, 200, struct{}{})
+	}
+}
+
+// handleTelegramCommand runs the command in the message, returning the
+// reply to send, if any.
+func handleTelegramCommand(ctx context.Context, apiConfig apiConfig, msg *telegram.Message) string {
+	command, arg, _ := strings.Cut(strings.TrimSpace(msg.Text), " ")
+	// in groups commands can name the bot, /read@somebot
+	command, _, _ = strings.Cut(command, "@")
+	arg = strings.TrimSpace(arg)
+
+	if command == "/start" || command == "/link" {
+		if arg == "" {
+			return "Send the code from the app here, as /start <code>, to link this chat."
+		}
+		return linkTelegramChat(ctx, apiConfig, msg.Chat.ID, arg)
+	}
+
+	chat, err := apiConfig.DB.GetTelegramChatByChatID(ctx, msg.Chat.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "This chat isn't linked yet. Send the code from the app as /start <code>."
+	}
+	if err != nil {
+		telegramLog.Error("Error getting telegram chat", "err", err)
+		return "Something went wrong, try again later."
+	}
+
+	switch command {
+	case "/read", "/star":
+		if msg.ReplyToMessage == nil {
+			return "Reply to a post with " + command + "."
+		}
+		postID, err := apiConfig.DB.GetTelegramMessagePost(ctx, database.GetTelegramMessagePostParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: msg.ReplyToMessage.MessageID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return "That message isn't a post, or it's too old."
+		}
+		if err != nil {
+			telegramLog.Error("Error getting telegram message", "err", err)
+			return "Something went wrong, try again later."
+		}
+
+		if command == "/read" {
+			if err := markPostRead(ctx, apiConfig.DB, chat.UserID, postID); err != nil {
+				telegramLog.Error("Error marking post read", "err", err)
+				return "Something went wrong, try again later."
+			}
+			return "Marked as read."
+		}
+
+		post, err := apiConfig.DB.GetPostByID(ctx, postID)
+		if err != nil {
+			telegramLog.Error("Error getting post", "err", err)
+			return "Something went wrong, try again later."
+		}
+		_, err = bookmarkPost(ctx, apiConfig, chat.UserID, post)
+		if errors.Is(err, errAlreadyBookmarked) {
+			return "Already bookmarked."
+		}
+		if err != nil {
+			telegramLog.Error("Error creating bookmark", "err", err)
+			return "Something went wrong, try again later."
+		}
+		return "Bookmarked."
+
+	case "/list":
+		posts, err := apiConfig.DB.GetUnreadTelegramPosts(ctx, database.GetUnreadTelegramPostsParams{
+			UserID: chat.UserID,
+			Limit:  telegramListMax,
+		})
+		if err != nil {
+			telegramLog.Error("Error getting unread posts", "err", err)
+			return "Something went wrong, try again later."
+		}
+		if len(posts) == 0 {
+			return "Nothing unread."
+		}
+		for _, post := range posts {
+			sendTelegramPost(ctx, apiConfig, chat.ChatID, "", post)
+		}
+		return ""
+
+	case "/unlink":
+		if _, err := apiConfig.DB.DeleteTelegramChat(ctx, chat.UserID); err != nil {
+			telegramLog.Error("Error unlinking telegram chat", "err", err)
+			return "Something went wrong, try again later."
+		}
+		return "Unlinked, no more posts will be sent here."
+	}
+
+	return telegramHelp
+}
+
+func linkTelegramChat(ctx context.Context, apiConfig apiConfig, chatID int64, code string) string {
+	var user database.User
+	err := database.InTx(ctx, apiConfig.DB, func(q *database.Queries) error {
+		userID, err := q.ConsumeTelegramLinkCode(ctx, hashToken(code))
+		if err != nil {
+			return err
+		}
+		user, err = q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		// a chat is linked to one user at a time
+		if err := q.DeleteTelegramChatByChatID(ctx, chatID); err != nil {
+			return err
+		}
+		_, err = q.UpsertTelegramChat(ctx, database.UpsertTelegramChatParams{
+			UserID:    userID,
+			ChatID:    chatID,
+			CreatedAt: time.Now(),
+		})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "That code is unknown or expired, get a new one from the app."
+	}
+	if err != nil {
+		telegramLog.Error("Error linking telegram chat", "err", err)
+		return "Something went wrong, try again later."
+	}
+
+	return "Linked to " + user.Name + ". New posts of the feeds you pick in the app will show up here.\n" + telegramHelp
+}
+
+// notifyTelegram sends the new posts of the feed to the chats that picked it.
+// A lot of new posts at once are summed up, /list shows them.
+func notifyTelegram(ctx context.Context, apiConfig apiConfig, feed database.Feed, posts []database.Post) {
+	if apiConfig.Telegram == nil || len(posts) == 0 {
+		return
+	}
+
+	chats, err := apiConfig.DB.GetTelegramChatsForFeed(ctx, feed.ID)
+	if err != nil {
+		telegramLog.Error("Error getting telegram chats", "err", err)
+		return
+	}
+
+	for _, chat := range chats {
+		go func() {
+			ctx := context.Background()
+			if len(posts) > telegramBatchMax {
+				text := fmt.Sprintf("<b>%s</b>\n%d new posts, /list shows the latest unread ones.", html.EscapeString(feed.Name), len(posts))
+				sendTelegram(ctx, apiConfig, chat.ChatID, text)
+				return
+			}
+			for _, post := range posts {
+				sendTelegramPost(ctx, apiConfig, chat.ChatID, feed.Name, post)
+			}
+		}()
+	}
+}
+
+// sendTelegramPost sends a post and remembers the message, for the replies
+// to it.
+func sendTelegramPost(ctx context.Context, apiConfig apiConfig, chatID int64, feedName string, post database.Post) {
+	text := fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(post.Url), html.EscapeString(post.Title))
+	if feedName != "" {
+		text = "<b>" + html.EscapeString(feedName) + "</b>\n" + text
+	}
+
+	msg, ok := sendTelegram(ctx, apiConfig, chatID, text)
+	if !ok {
+		return
+	}
+
+	err := apiConfig.DB.CreateTelegramMessage(ctx, database.CreateTelegramMessageParams{
+		ChatID:    chatID,
+		MessageID: msg.MessageID,
+		PostID:    post.ID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		telegramLog.Error("Error recording telegram message", "err", err)
+	}
+}
+
+// sendTelegram sends a message to the chat, and unlinks chats that blocked
+// the bot.
+func sendTelegram(ctx context.Context, apiConfig apiConfig, chatID int64, text string) (telegram.Message, bool) {
+	msg, err := apiConfig.Telegram.SendMessage(ctx, chatID, text)
+	var apiErr *telegram.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		telegramLog.Info("Unlinking telegram chat", "chat_id", chatID, "err", err)
+		if err := apiConfig.DB.DeleteTelegramChatByChatID(ctx, chatID); err != nil {
+			telegramLog.Error("Error unlinking telegram chat", "err", err)
+		}
+		return msg, false
+	}
+	if err != nil {
+		telegramLog.Error("Error sending telegram message", "err", err)
+		return msg, false
+	}
+
+	return msg, true
+}
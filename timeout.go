@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestTimeoutMiddleware cancels the context of a request after timeout,
+// so its queries stop once nobody waits for them anymore. A timeout of 0
+// turns it off. Streams, the websocket and NDJSON responses like GET
+// /v1/posts/export, run for as long as the client reads them.
+func requestTimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout == 0 || isStreamingRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func isStreamingRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.URL.Path == "/v1/posts/export" || wantsNDJSON(r)
+}
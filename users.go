@@ -0,0 +1,218 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+	// the timezones of PATCH /v1/users/me, images may not have them
+	_ "time/tzdata"
+
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	userNameMinLength = 3
+	userNameMaxLength = 32
+)
+
+var userNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+var (
+	errUserNameTaken = errors.New("Name is already taken")
+	errEmailTaken    = errors.New("Email is already in use")
+)
+
+func validateUserName(name string) error {
+	if len(name) < userNameMinLength || len(name) > userNameMaxLength {
+		return errors.New("Name must be between 3 and 32 characters long")
+	}
+	if !userNamePattern.MatchString(name) {
+		return errors.New("Name may only contain letters, digits, '.', '_' and '-'")
+	}
+
+	return nil
+}
+
+// isUniqueViolation reports whether err is postgres complaining about the given unique constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+/*
+Endpoint: GET /v1/users/check?name=
+
+Tells whether a user name is valid and still free, so clients can check it before signing up.
+Names are unique regardless of case, so "Alice" is taken once "alice" exists.
+*/
+func checkUserNameHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type CheckResponse struct {
+			Name      string `json:"name"`
+			Available bool   `json:"available"`
+			Reason    string `json:"reason,omitempty"`
+		}
+
+		name := r.URL.Query().Get("name")
+		resp := CheckResponse{Name: name}
+
+		err := validateUserName(name)
+		if err != nil {
+			resp.Reason = err.Error()
+			respondWithJSON(w, 200, resp)
+			return
+		}
+
+		context := r.Context()
+		_, err = apiConfig.DB.GetUserByName(context, name)
+		if err == nil {
+			resp.Reason = errUserNameTaken.Error()
+			respondWithJSON(w, 200, resp)
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			httpLog.Error("Error getting user", "err", err)
+			respondWithError(w, 500, "Error getting users")
+			return
+		}
+
+		resp.Available = true
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+var digestFrequencies = []string{"off", "daily", "weekly"}
+
+/*
+Endpoint: PATCH /v1/users/me
+
+# This endpoint requires an API key
+
+Or an access token. Changes the profile and preferences of the user, only the
+fields that are sent:
+
+	{
+		"name": "jane",
+		"email": "jane@example.com",
+		"timezone": "Europe/Berlin",
+		"default_post_limit": 20,
+		"digest_frequency": "daily",
+		"digest_hour": 7
+	}
+
+A new email has to be verified again, a verification link is sent to it. An
+empty email removes it, unless the user logs in with a password.
+default_post_limit is the page size of GET /v1/posts and GET
+/v1/posts/compact when they are paginated without a limit, 0 goes back to the
+default of 50. digest_frequency is off, daily or weekly, digest_hour the hour
+of the day in timezone digests go out. Responds with the user.
+*/
+func patchUserHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type ProfileRequest struct {
+			Name             *string `json:"name"`
+			Email            *string `json:"email"`
+			Timezone         *string `json:"timezone"`
+			DefaultPostLimit *int    `json:"default_post_limit"`
+			DigestFrequency  *string `json:"digest_frequency"`
+			DigestHour       *int    `json:"digest_hour"`
+		}
+
+		var req ProfileRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		params := database.UpdateUserProfileParams{
+			ID:               user.ID,
+			Name:             user.Name,
+			Email:            user.Email,
+			EmailVerifiedAt:  user.EmailVerifiedAt,
+			Timezone:         user.Timezone,
+			DefaultPostLimit: user.DefaultPostLimit,
+			DigestFrequency:  user.DigestFrequency,
+			DigestHour:       user.DigestHour,
+		}
+
+		var v validation
+		if req.Name != nil {
+			v.check("name", validateUserName(*req.Name))
+			params.Name = *req.Name
+		}
+
+		emailChanged := false
+		if req.Email != nil && *req.Email != user.Email.String {
+			if *req.Email == "" && user.PasswordHash.Valid {
+				v.add("email", "The email is needed to log in with the password")
+			}
+			if *req.Email != "" {
+				v.check("email", validateEmail(*req.Email))
+			}
+			params.Email = sql.NullString{String: *req.Email, Valid: *req.Email != ""}
+			params.EmailVerifiedAt = sql.NullTime{}
+			emailChanged = params.Email.Valid
+		}
+
+		if req.Timezone != nil {
+			if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+				v.add("timezone", "Invalid timezone, expected an IANA name like Europe/Berlin")
+			}
+			params.Timezone = *req.Timezone
+		}
+
+		if req.DefaultPostLimit != nil {
+			limit := *req.DefaultPostLimit
+			if limit < 0 || limit > pageMaxLimit {
+				v.add("default_post_limit", "Invalid default_post_limit, expected 1 to "+strconv.Itoa(pageMaxLimit)+", or 0 for the default")
+			}
+			params.DefaultPostLimit = sql.NullInt32{Int32: int32(limit), Valid: limit != 0}
+		}
+
+		if req.DigestFrequency != nil {
+			if !slices.Contains(digestFrequencies, *req.DigestFrequency) {
+				v.add("digest_frequency", "Invalid digest_frequency, expected off, daily or weekly")
+			}
+			params.DigestFrequency = *req.DigestFrequency
+		}
+
+		if req.DigestHour != nil {
+			if *req.DigestHour < 0 || *req.DigestHour > 23 {
+				v.add("digest_hour", "Invalid digest_hour, expected 0 to 23")
+			}
+			params.DigestHour = int16(*req.DigestHour)
+		}
+		if v.respond(w) {
+			return
+		}
+
+		context := r.Context()
+		updated, err := apiConfig.DB.UpdateUserProfile(context, params)
+		if isUniqueViolation(err, "users_name_unique") || isUniqueViolation(err, "users_name_lower_unique") {
+			respondWithErrorCode(w, 409, "user_name_taken", errUserNameTaken.Error())
+			return
+		}
+		if isUniqueViolation(err, "users_email_unique") {
+			respondWithErrorCode(w, 409, "email_taken", errEmailTaken.Error())
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error updating user", "err", err)
+			respondWithError(w, 500, "Error updating user")
+			return
+		}
+
+		if emailChanged {
+			err = sendEmailVerification(context, apiConfig, updated)
+			if err != nil {
+				httpLog.Error("Error sending email verification", "err", err)
+			}
+		}
+
+		respondWithJSON(w, 200, newUserResponse(updated))
+	}
+}
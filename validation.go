@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// fieldError is something wrong with one field of a request.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validation collects what is wrong with the fields of a request, so a client
+// learns about all of it in one response instead of one field at a time:
+//
+//	{
+//		"error": {
+//			"code": "validation_failed",
+//			"message": "Invalid fields: name, url",
+//			"fields": [
+//				{"field": "name", "message": "Name is required"},
+//				{"field": "url", "message": "URL must use http or https"}
+//			]
+//		}
+//	}
+type validation struct {
+	errors []fieldError
+}
+
+// add records a problem with the field.
+func (v *validation) add(field, msg string) {
+	v.errors = append(v.errors, fieldError{Field: field, Message: msg})
+}
+
+// check records err against the field, if there is one.
+func (v *validation) check(field string, err error) {
+	if err != nil {
+		v.add(field, err.Error())
+	}
+}
+
+// require records the field as missing when value is blank, and tells
+// whether it is there.
+func (v *validation) require(field, value, msg string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, msg)
+		return false
+	}
+	return true
+}
+
+// has tells whether the field has a problem already, for checks that only
+// make sense on a valid value.
+func (v *validation) has(field string) bool {
+	for _, e := range v.errors {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// respond answers 422 with everything recorded, if anything was, and tells
+// whether it did.
+func (v *validation) respond(w http.ResponseWriter) bool {
+	if len(v.errors) == 0 {
+		return false
+	}
+
+	fields := make([]string, 0, len(v.errors))
+	for _, e := range v.errors {
+		if !slices.Contains(fields, e.Field) {
+			fields = append(fields, e.Field)
+		}
+	}
+	respondWithJSON(w, 422, apiError{Error: apiErrorDetail{
+		Code:    "validation_failed",
+		Message: "Invalid fields: " + strings.Join(fields, ", "),
+		Fields:  v.errors,
+	}})
+	return true
+}
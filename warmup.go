@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// warmupConfig controls priming the caches before the server reports ready.
+type warmupConfig struct {
+	// OnStart primes the caches at startup. /v1/healthz answers 503 until
+	// it is done, so load balancers hold traffic back meanwhile.
+	OnStart bool
+	// Timeout bounds the warm-up, the server reports ready when it runs out.
+	Timeout time.Duration
+}
+
+// warmupConfigFromEnv reads CACHE_WARMUP and CACHE_WARMUP_TIMEOUT.
+func warmupConfigFromEnv() (warmupConfig, error) {
+	cfg := warmupConfig{OnStart: os.Getenv("CACHE_WARMUP") == "true"}
+
+	var err error
+	if cfg.Timeout, err = envDuration("CACHE_WARMUP_TIMEOUT", time.Minute, time.Second); err != nil {
+		return warmupConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// warmupPaths are the requests made to prime the caches: the first pages of
+// the guest endpoints, which everyone without an account hits, and the list of
+// feeds, to pull the hot tables into the database's buffers.
+func warmupPaths(guest *guestMode) []string {
+	paths := []string{"/v1/feeds"}
+	if guest != nil {
+		paths = append(paths, "/v1/public/feeds", "/v1/public/posts")
+	}
+	return paths
+}
+
+// warmCaches requests each path from handler, one after the other so the
+// warm-up itself doesn't stampede the database, and marks ready once done.
+func warmCaches(ctx context.Context, handler http.Handler, paths []string, cfg warmupConfig, ready *atomic.Bool) {
+	defer ready.Store(true)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, path := range paths {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			httpLog.Error("Error warming", "path", path, "err", err)
+			continue
+		}
+
+		w := &discardResponseWriter{header: http.Header{}}
+		handler.ServeHTTP(w, req)
+		if w.status >= 400 {
+			httpLog.Error("Error warming : status", "path", path, "w_status", w.status)
+		}
+		if ctx.Err() != nil {
+			httpLog.Warn("Cache warm-up timed out", "timeout", cfg.Timeout)
+			return
+		}
+	}
+
+	httpLog.Info("Warmed caches", "duration", time.Since(start).Round(time.Millisecond))
+}
+
+// discardResponseWriter keeps only the status of a warm-up request.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+)
+
+/*
+Webhook deliveries go through a queue in the webhook_deliveries table, so a
+receiver that is down for a while misses nothing and a restart doesn't lose
+what was about to be sent. Every instance works the queue, claiming due
+deliveries with SELECT ... FOR UPDATE SKIP LOCKED.
+
+A failed delivery is tried again after WEBHOOK_RETRY_BASE, doubling every
+time up to WEBHOOK_RETRY_MAX, until WEBHOOK_MAX_ATTEMPTS. Then it's failed
+for good and a copy goes to the dead letters of the webhook, from where its
+owner can send it again.
+*/
+
+// webhookClaimLease is how long claimed deliveries are left alone by other
+// instances. They come back after it if the instance dies on them.
+const webhookClaimLease = webhookTimeout + time.Minute
+
+type webhookQueueConfig struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	RetryBase   time.Duration
+	RetryMax    time.Duration
+}
+
+// webhookQueueConfigFromEnv reads WEBHOOK_QUEUE_INTERVAL,
+// WEBHOOK_QUEUE_BATCH_SIZE, WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_BASE and
+// WEBHOOK_RETRY_MAX.
+func webhookQueueConfigFromEnv() (webhookQueueConfig, error) {
+	cfg := webhookQueueConfig{
+		Interval:    5 * time.Second,
+		BatchSize:   20,
+		MaxAttempts: 8,
+		RetryBase:   30 * time.Second,
+		RetryMax:    6 * time.Hour,
+	}
+
+	var err error
+	if cfg.Interval, err = envDuration("WEBHOOK_QUEUE_INTERVAL", cfg.Interval, 100*time.Millisecond); err != nil {
+		return webhookQueueConfig{}, err
+	}
+	if cfg.BatchSize, err = envInt("WEBHOOK_QUEUE_BATCH_SIZE", cfg.BatchSize, 1); err != nil {
+		return webhookQueueConfig{}, err
+	}
+	if cfg.MaxAttempts, err = envInt("WEBHOOK_MAX_ATTEMPTS", cfg.MaxAttempts, 1); err != nil {
+		return webhookQueueConfig{}, err
+	}
+	if cfg.RetryBase, err = envDuration("WEBHOOK_RETRY_BASE", cfg.RetryBase, time.Second); err != nil {
+		return webhookQueueConfig{}, err
+	}
+	if cfg.RetryMax, err = envDuration("WEBHOOK_RETRY_MAX", cfg.RetryMax, cfg.RetryBase); err != nil {
+		return webhookQueueConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// retryDelay is the wait after the given failed attempt, starting at 1.
+func (cfg webhookQueueConfig) retryDelay(attempt int32) time.Duration {
+	delay := cfg.RetryBase
+	for i := int32(1); i < attempt && delay < cfg.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, cfg.RetryMax)
+}
+
+type webhookQueue struct {
+	cfg  webhookQueueConfig
+	wake chan struct{}
+}
+
+func newWebhookQueue(cfg webhookQueueConfig) *webhookQueue {
+	return &webhookQueue{cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Nudge has the queue look for due deliveries right away instead of at the
+// next interval.
+func (q *webhookQueue) Nudge() {
+	if q == nil {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run works the queue until ctx is done, and returns once the deliveries in
+// flight are finished.
+func (q *webhookQueue) run(ctx context.Context, apiConfig apiConfig) {
+	for {
+		q.drain(ctx, apiConfig)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.cfg.Interval):
+		}
+	}
+}
+
+// drain delivers due deliveries a batch at a time until none are left.
+func (q *webhookQueue) drain(ctx context.Context, apiConfig apiConfig) {
+	for ctx.Err() == nil {
+		deliveries, err := apiConfig.DB.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+			LeasedUntil: time.Now().Add(webhookClaimLease),
+			Limit:       int32(q.cfg.BatchSize),
+		})
+		if err != nil {
+			webhookLog.Error("Error claiming webhook deliveries", "err", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// not ctx, so shutting down lets the deliveries finish
+				q.attempt(context.Background(), apiConfig, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < q.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// attempt sends a claimed delivery, then finishes it, schedules the next
+// attempt or dead-letters it.
+func (q *webhookQueue) attempt(ctx context.Context, apiConfig apiConfig, delivery database.WebhookDelivery) {
+	hook, err := apiConfig.DB.GetWebhookByID(ctx, delivery.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the webhook was deleted since, and its deliveries with it
+		return
+	}
+	if err != nil {
+		webhookLog.Error("Error getting webhook", "delivery_webhook_id", delivery.WebhookID, "err", err)
+		return
+	}
+
+	delivery = attemptWebhook(ctx, hook, delivery)
+	if delivery.Status == deliverySucceeded {
+		finishWebhookDelivery(ctx, apiConfig, delivery)
+		return
+	}
+
+	if int(delivery.Attempts) < q.cfg.MaxAttempts {
+		err := apiConfig.DB.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+			ID:            delivery.ID,
+			ResponseCode:  delivery.ResponseCode,
+			LatencyMs:     delivery.LatencyMs,
+			Error:         delivery.Error,
+			NextAttemptAt: sql.NullTime{Time: time.Now().Add(q.cfg.retryDelay(delivery.Attempts)), Valid: true},
+		})
+		if err != nil {
+			webhookLog.Error("Error scheduling webhook retry", "err", err)
+		}
+		return
+	}
+
+	err = database.InTx(ctx, apiConfig.DB, func(tx *database.Queries) error {
+		err := tx.FinishWebhookDelivery(ctx, database.FinishWebhookDeliveryParams{
+			ID:           delivery.ID,
+			Status:       delivery.Status,
+			ResponseCode: delivery.ResponseCode,
+			LatencyMs:    delivery.LatencyMs,
+			Error:        delivery.Error,
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.CreateWebhookDeadLetter(ctx, database.CreateWebhookDeadLetterParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			WebhookID:   delivery.WebhookID,
+			DeliveryID:  delivery.ID,
+			EventType:   delivery.EventType,
+			Payload:     delivery.Payload,
+			ContentType: delivery.ContentType,
+			Attempts:    delivery.Attempts,
+			Error:       delivery.Error,
+		})
+	})
+	if err != nil {
+		webhookLog.Error("Error dead-lettering webhook delivery", "delivery_id", delivery.ID, "err", err)
+		return
+	}
+	webhookLog.Warn("Gave up on webhook delivery attempts", "delivery_id", delivery.ID, "delivery_attempts", delivery.Attempts)
+}
+
+// enqueueWebhookDelivery queues a delivery of the payload to the webhook.
+func enqueueWebhookDelivery(ctx context.Context, db *database.Queries, params database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	params.ID = uuid.New()
+	params.CreatedAt = time.Now()
+	params.NextAttemptAt = sql.NullTime{Time: params.CreatedAt, Valid: true}
+	return db.CreateWebhookDelivery(ctx, params)
+}
+
+type webhookDeadLetterResponse struct {
+	ID         uuid.UUID       `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	DeliveryID uuid.UUID       `json:"delivery_id"`
+	EventType  string          `json:"event_type"`
+	Attempts   int32           `json:"attempts"`
+	Error      string          `json:"error,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+/*
+Endpoint: GET /v1/webhooks/{webhook_id}/dead_letters
+
+# This is an authenticated endpoint
+
+Lists the deliveries that failed every attempt, newest first, paginated with
+limit and cursor. They stay until they are redelivered or their retention
+runs out, like the delivery log.
+*/
+func getWebhookDeadLettersHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		hook, ok := userWebhook(context, apiConfig, w, r, user)
+		if !ok {
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		deadLetters, err := apiConfig.DB.GetWebhookDeadLetters(context, database.GetWebhookDeadLettersParams{
+			WebhookID:  hook.ID,
+			BeforeTime: page.BeforeTime(),
+			BeforeID:   page.BeforeID(),
+			Limit:      page.QueryLimit(),
+		})
+		if err != nil {
+			httpLog.Error("Error getting webhook dead letters", "err", err)
+			respondWithError(w, 500, "Error getting webhook dead letters")
+			return
+		}
+		deadLetters = finishPage(w, r, page, deadLetters, func(deadLetter database.WebhookDeadLetter) pageCursor {
+			return pageCursor{Time: deadLetter.CreatedAt, ID: deadLetter.ID}
+		})
+
+		resp := []webhookDeadLetterResponse{}
+		for _, deadLetter := range deadLetters {
+			resp = append(resp, webhookDeadLetterResponse{
+				ID:         deadLetter.ID,
+				CreatedAt:  deadLetter.CreatedAt,
+				DeliveryID: deadLetter.DeliveryID,
+				EventType:  deadLetter.EventType,
+				Attempts:   deadLetter.Attempts,
+				Error:      deadLetter.Error.String,
+				Payload:    json.RawMessage(deadLetter.Payload),
+			})
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: POST /v1/webhooks/{webhook_id}/dead_letters/{dead_letter_id}/redeliver
+
+# This is an authenticated endpoint
+
+Queues the payload of a dead letter for delivery again, with a fresh set of
+attempts, and removes the dead letter. Responds 202 with the new delivery,
+which shows up in the delivery log.
+*/
+func redeliverWebhookDeadLetterHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		deadLetterID, err := uuid.Parse(chi.URLParam(r, "dead_letter_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid dead_letter_id")
+			return
+		}
+
+		context := r.Context()
+		hook, ok := userWebhook(context, apiConfig, w, r, user)
+		if !ok {
+			return
+		}
+
+		var delivery database.WebhookDelivery
+		err = database.InTx(context, apiConfig.DB, func(q *database.Queries) error {
+			deadLetter, err := q.GetWebhookDeadLetter(context, database.GetWebhookDeadLetterParams{
+				ID:        deadLetterID,
+				WebhookID: hook.ID,
+			})
+			if err != nil {
+				return err
+			}
+
+			delivery, err = enqueueWebhookDelivery(context, q, database.CreateWebhookDeliveryParams{
+				WebhookID:   hook.ID,
+				EventType:   deadLetter.EventType,
+				Payload:     deadLetter.Payload,
+				Redelivery:  true,
+				ContentType: deadLetter.ContentType,
+			})
+			if err != nil {
+				return err
+			}
+
+			return q.DeleteWebhookDeadLetter(context, deadLetter.ID)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "dead_letter_not_found", "Dead letter not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error redelivering dead letter", "err", err)
+			respondWithError(w, 500, "Error redelivering webhook")
+			return
+		}
+		apiConfig.WebhookQueue.Nudge()
+
+		respondWithJSON(w, 202, newWebhookDeliveryResponse(delivery))
+	}
+}
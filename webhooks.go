@@ -0,0 +1,708 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/database"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/eventbus"
+	"github.com/halfdan87/boot-go-blog-aggregator/internal/webhook"
+)
+
+const (
+	webhookTimeout              = 10 * time.Second
+	webhookDefaultSecretOverlap = 24 * time.Hour
+
+	eventPostCreated    = "post.created"
+	eventFeedFailing    = "feed.failing"
+	eventFeedDead       = "feed.dead"
+	eventFollowCreated  = "follow.created"
+	eventFollowDeleted  = "follow.deleted"
+	eventPostBookmarked = "post.bookmarked"
+
+	deliveryPending   = "pending"
+	deliverySucceeded = "succeeded"
+	deliveryFailed    = "failed"
+)
+
+// webhookClient refuses private addresses like feedClient does, webhook URLs
+// are user input too.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: dialControl,
+		}).DialContext,
+	},
+}
+
+// webhookEventTypes are the events a webhook can subscribe to.
+var webhookEventTypes = []string{
+	eventPostCreated,
+	eventFeedFailing,
+	eventFeedDead,
+	eventFollowCreated,
+	eventFollowDeleted,
+	eventPostBookmarked,
+}
+
+type webhookEvent struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type webhookResponse struct {
+	ID                      uuid.UUID  `json:"id"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
+	URL                     string     `json:"url"`
+	Events                  []string   `json:"events"`
+	Format                  string     `json:"format"`
+	Secret                  string     `json:"secret,omitempty"`
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	EventType     string          `json:"event_type"`
+	Redelivery    bool            `json:"redelivery"`
+	Status        string          `json:"status"`
+	ResponseCode  *int32          `json:"response_code"`
+	LatencyMs     *int32          `json:"latency_ms"`
+	Error         string          `json:"error,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func newWebhookDeliveryResponse(delivery database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:         delivery.ID,
+		CreatedAt:  delivery.CreatedAt,
+		EventType:  delivery.EventType,
+		Redelivery: delivery.Redelivery,
+		Status:     delivery.Status,
+		Error:      delivery.Error.String,
+		Attempts:   delivery.Attempts,
+		Payload:    json.RawMessage(delivery.Payload),
+	}
+	if delivery.ResponseCode.Valid {
+		resp.ResponseCode = &delivery.ResponseCode.Int32
+	}
+	if delivery.LatencyMs.Valid {
+		resp.LatencyMs = &delivery.LatencyMs.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	if delivery.Status == deliveryPending && delivery.NextAttemptAt.Valid {
+		resp.NextAttemptAt = &delivery.NextAttemptAt.Time
+	}
+
+	return resp
+}
+
+// newWebhookResponse hides the secret unless withSecret is set, which only
+// happens right after it was generated.
+func newWebhookResponse(hook database.Webhook, withSecret bool) webhookResponse {
+	resp := webhookResponse{
+		ID:        hook.ID,
+		CreatedAt: hook.CreatedAt,
+		UpdatedAt: hook.UpdatedAt,
+		URL:       hook.Url,
+		Events:    hook.Events,
+		Format:    hook.Format,
+	}
+	if withSecret {
+		resp.Secret = hook.Secret
+	}
+	if hook.PreviousSecretExpiresAt.Valid && hook.PreviousSecretExpiresAt.Time.After(time.Now()) {
+		resp.PreviousSecretExpiresAt = &hook.PreviousSecretExpiresAt.Time
+	}
+
+	return resp
+}
+
+// webhookSecrets are the secrets a delivery is signed with right now: the
+// current one plus the previous one while its rotation overlap lasts.
+func webhookSecrets(hook database.Webhook) []string {
+	secrets := []string{hook.Secret}
+	if hook.PreviousSecret.Valid && hook.PreviousSecretExpiresAt.Valid && hook.PreviousSecretExpiresAt.Time.After(time.Now()) {
+		secrets = append(secrets, hook.PreviousSecret.String)
+	}
+
+	return secrets
+}
+
+// deliverWebhook sends the delivery's payload to the webhook and records the
+// outcome on the delivery.
+func deliverWebhook(ctx context.Context, apiConfig apiConfig, hook database.Webhook, delivery database.WebhookDelivery) database.WebhookDelivery {
+	delivery = attemptWebhook(ctx, hook, delivery)
+	finishWebhookDelivery(ctx, apiConfig, delivery)
+	return delivery
+}
+
+// attemptWebhook sends the delivery's payload to the webhook and fills in the
+// outcome, without recording it.
+func attemptWebhook(ctx context.Context, hook database.Webhook, delivery database.WebhookDelivery) database.WebhookDelivery {
+	body := []byte(delivery.Payload)
+	start := time.Now()
+	code, err := postWebhook(ctx, hook, delivery.ContentType, body)
+	latency := time.Since(start)
+
+	delivery.Status = deliverySucceeded
+	delivery.LatencyMs = sql.NullInt32{Int32: int32(latency.Milliseconds()), Valid: true}
+	delivery.DeliveredAt = sql.NullTime{Time: time.Now(), Valid: true}
+	delivery.ResponseCode = sql.NullInt32{}
+	delivery.Error = sql.NullString{}
+	if code != 0 {
+		delivery.ResponseCode = sql.NullInt32{Int32: int32(code), Valid: true}
+	}
+	if err == nil && (code < 200 || code > 299) {
+		err = fmt.Errorf("unexpected status: %d", code)
+	}
+	if err != nil {
+		webhookLog.Error("Error delivering webhook", "webhook_id", hook.ID, "err", err)
+		delivery.Status = deliveryFailed
+		delivery.Error = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	return delivery
+}
+
+// finishWebhookDelivery records the outcome of the delivery's last attempt.
+func finishWebhookDelivery(ctx context.Context, apiConfig apiConfig, delivery database.WebhookDelivery) {
+	err := apiConfig.DB.FinishWebhookDelivery(ctx, database.FinishWebhookDeliveryParams{
+		ID:           delivery.ID,
+		Status:       delivery.Status,
+		ResponseCode: delivery.ResponseCode,
+		LatencyMs:    delivery.LatencyMs,
+		Error:        delivery.Error,
+	})
+	if err != nil {
+		webhookLog.Error("Error recording webhook delivery", "err", err)
+	}
+}
+
+func postWebhook(ctx context.Context, hook database.Webhook, contentType string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(webhookSecrets(hook), time.Now(), body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+// dispatchFeedEvent notifies the webhooks of everyone following the feed.
+// The change already happened, so its events go out even when ctx, usually
+// the request that made it, is cancelled by now.
+func dispatchFeedEvent(ctx context.Context, apiConfig apiConfig, feedID uuid.UUID, eventType string, data interface{}) {
+	ctx = context.WithoutCancel(ctx)
+	hooks, err := apiConfig.DB.GetWebhooksForFeed(ctx, feedID)
+	if err != nil {
+		webhookLog.Error("Error getting webhooks", "err", err)
+		return
+	}
+
+	dispatchEvent(ctx, apiConfig, hooks, feedID, uuid.Nil, eventType, data)
+}
+
+// dispatchUserEvent notifies the webhooks of a single user, like
+// dispatchFeedEvent even when ctx is cancelled.
+func dispatchUserEvent(ctx context.Context, apiConfig apiConfig, userID uuid.UUID, eventType string, data interface{}) {
+	ctx = context.WithoutCancel(ctx)
+	hooks, err := apiConfig.DB.GetUserWebhooks(ctx, userID)
+	if err != nil {
+		webhookLog.Error("Error getting webhooks", "err", err)
+		return
+	}
+
+	dispatchEvent(ctx, apiConfig, hooks, uuid.Nil, userID, eventType, data)
+}
+
+// dispatchEvent sends the event to the event bus, the subscribed webhooks and
+// the websockets. feedID is the feed the event is about, or uuid.Nil. userID
+// is the user a user event is for, uuid.Nil for feed events.
+func dispatchEvent(ctx context.Context, apiConfig apiConfig, hooks []database.Webhook, feedID, userID uuid.UUID, eventType string, data interface{}) {
+	event := webhookEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		Data:      data,
+	}
+
+	if apiConfig.Events != nil {
+		publishEvent(ctx, apiConfig, feedID, event)
+	}
+	publishLive(ctx, apiConfig, feedID, userID, event)
+
+	hooks = slices.DeleteFunc(hooks, func(hook database.Webhook) bool {
+		return !webhookWants(hook, eventType)
+	})
+
+	for _, hook := range hooks {
+		payload, contentType, err := encodeEvent(apiConfig, hook.Format, event)
+		if err != nil {
+			webhookLog.Error("Error encoding webhook event", "err", err)
+			continue
+		}
+
+		_, err = enqueueWebhookDelivery(ctx, apiConfig.DB, database.CreateWebhookDeliveryParams{
+			WebhookID:   hook.ID,
+			EventType:   eventType,
+			Payload:     string(payload),
+			ContentType: contentType,
+		})
+		if err != nil {
+			webhookLog.Error("Error creating webhook delivery", "err", err)
+			continue
+		}
+	}
+	if len(hooks) > 0 {
+		apiConfig.WebhookQueue.Nudge()
+	}
+}
+
+// publishEvent sends the event to the configured event bus.
+func publishEvent(ctx context.Context, apiConfig apiConfig, feedID uuid.UUID, event webhookEvent) {
+	payload, _, err := encodeEvent(apiConfig, apiConfig.EventsFormat, event)
+	if err != nil {
+		webhookLog.Error("Error encoding event", "err", err)
+		return
+	}
+
+	err = apiConfig.Events.Publish(ctx, eventbus.Message{
+		Type:   event.Type,
+		FeedID: feedID,
+		Data:   payload,
+	})
+	if err != nil {
+		webhookLog.Error("Error publishing event", "event_type", event.Type, "err", err)
+	}
+}
+
+// webhookWants reports whether the webhook subscribed to the event type. A
+// webhook without filters gets every event.
+func webhookWants(hook database.Webhook, eventType string) bool {
+	return len(hook.Events) == 0 || slices.Contains(hook.Events, eventType)
+}
+
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(webhookEventTypes, event) {
+			return fmt.Errorf("Unknown webhook event: %s", event)
+		}
+	}
+
+	return nil
+}
+
+func validateWebhookFormat(format string) error {
+	if !slices.Contains(webhookFormats, format) {
+		return fmt.Errorf("Unknown webhook format: %s", format)
+	}
+
+	return nil
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Invalid webhook URL")
+	}
+
+	return nil
+}
+
+/*
+Endpoint: POST /v1/webhooks
+
+# This is an authenticated endpoint
+
+Registers a URL that receives signed events. "events" limits the webhook to
+the listed event types, leaving it out subscribes to all of them. "format" is
+"json" (default) or "cloudevents" for CloudEvents 1.0 structured JSON. The
+signing secret is only returned here. The user needs a verified email
+address, otherwise the response is a 403.
+*/
+func postWebhookHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type WebhookRequest struct {
+			URL    string   `json:"url"`
+			Events []string `json:"events"`
+			Format string   `json:"format"`
+		}
+
+		if !requireVerifiedEmail(w, user) {
+			return
+		}
+
+		req := WebhookRequest{Format: webhookFormatJSON}
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		var v validation
+		v.check("url", validateWebhookURL(req.URL))
+		v.check("events", validateWebhookEvents(req.Events))
+		v.check("format", validateWebhookFormat(req.Format))
+		if v.respond(w) {
+			return
+		}
+
+		context := r.Context()
+		if err := checkPublicURL(context, req.URL); errors.Is(err, errPrivateFeedURL) {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		if req.Events == nil {
+			// the column is not null, and pq sends a nil slice as NULL
+			req.Events = []string{}
+		}
+
+		secret, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating webhook secret", "err", err)
+			respondWithError(w, 500, "Error creating webhook")
+			return
+		}
+
+		hook, err := apiConfig.DB.CreateWebhook(context, database.CreateWebhookParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			UserID:    user.ID,
+			Url:       req.URL,
+			Secret:    secret,
+			Events:    req.Events,
+			Format:    req.Format,
+		})
+		if err != nil {
+			httpLog.Error("Error creating webhook", "err", err)
+			respondWithError(w, 500, "Error creating webhook")
+			return
+		}
+
+		respondCreated(w, "/v1/webhooks/"+hook.ID.String(), newWebhookResponse(hook, true))
+	}
+}
+
+func getWebhooksHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		hooks, err := apiConfig.DB.GetUserWebhooks(context, user.ID)
+		if err != nil {
+			httpLog.Error("Error getting webhooks", "err", err)
+			respondWithError(w, 500, "Error getting webhooks")
+			return
+		}
+
+		resp := []webhookResponse{}
+		for _, hook := range hooks {
+			resp = append(resp, newWebhookResponse(hook, false))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: PATCH /v1/webhooks/{webhook_id}
+
+# This is an authenticated endpoint
+
+Updates the event filter and/or the format of the webhook. Fields left out
+keep their value, an empty "events" list subscribes to all events.
+*/
+func patchWebhookHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		type PatchWebhookRequest struct {
+			Events []string `json:"events"`
+			Format *string  `json:"format"`
+		}
+
+		var req PatchWebhookRequest
+		if !decodeJSONBody(w, r, &req) {
+			return
+		}
+
+		context := r.Context()
+		hook, ok := userWebhook(context, apiConfig, w, r, user)
+		if !ok {
+			return
+		}
+
+		var v validation
+		if req.Events != nil {
+			v.check("events", validateWebhookEvents(req.Events))
+			hook.Events = req.Events
+		}
+		if req.Format != nil {
+			v.check("format", validateWebhookFormat(*req.Format))
+			hook.Format = *req.Format
+		}
+		if v.respond(w) {
+			return
+		}
+
+		hook, err := apiConfig.DB.UpdateWebhook(context, database.UpdateWebhookParams{
+			ID:     hook.ID,
+			UserID: user.ID,
+			Events: hook.Events,
+			Format: hook.Format,
+		})
+		if err != nil {
+			httpLog.Error("Error updating webhook", "err", err)
+			respondWithError(w, 500, "Error updating webhook")
+			return
+		}
+
+		respondWithJSON(w, 200, newWebhookResponse(hook, false))
+	}
+}
+
+func deleteWebhookHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		webhookID, err := uuid.Parse(chi.URLParam(r, "webhook_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid webhook_id")
+			return
+		}
+
+		context := r.Context()
+		deleted, err := apiConfig.DB.DeleteWebhook(context, database.DeleteWebhookParams{
+			ID:     webhookID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			httpLog.Error("Error deleting webhook", "err", err)
+			respondWithError(w, 500, "Error deleting webhook")
+			return
+		}
+		if deleted == 0 {
+			respondWithErrorCode(w, 404, "webhook_not_found", "Webhook not found")
+			return
+		}
+
+		w.WriteHeader(204)
+	}
+}
+
+/*
+Endpoint: POST /v1/webhooks/{webhook_id}/rotate_secret
+
+# This is an authenticated endpoint
+
+Generates a new signing secret. Until the overlap window ends (24 hours unless
+"overlap_seconds" is given) deliveries are signed with both the old and the
+new secret.
+*/
+func rotateWebhookSecretHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		webhookID, err := uuid.Parse(chi.URLParam(r, "webhook_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid webhook_id")
+			return
+		}
+
+		type RotateRequest struct {
+			OverlapSeconds *int `json:"overlap_seconds"`
+		}
+
+		var req RotateRequest
+		if r.ContentLength != 0 {
+			if !decodeJSONBody(w, r, &req) {
+				return
+			}
+		}
+
+		overlap := webhookDefaultSecretOverlap
+		if req.OverlapSeconds != nil {
+			if *req.OverlapSeconds < 0 {
+				respondWithError(w, 400, "overlap_seconds must not be negative")
+				return
+			}
+			overlap = time.Duration(*req.OverlapSeconds) * time.Second
+		}
+
+		secret, err := generateToken()
+		if err != nil {
+			httpLog.Error("Error generating webhook secret", "err", err)
+			respondWithError(w, 500, "Error rotating webhook secret")
+			return
+		}
+
+		context := r.Context()
+		hook, err := apiConfig.DB.RotateWebhookSecret(context, database.RotateWebhookSecretParams{
+			ID:                      webhookID,
+			UserID:                  user.ID,
+			PreviousSecretExpiresAt: sql.NullTime{Time: time.Now().Add(overlap), Valid: true},
+			Secret:                  secret,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "webhook_not_found", "Webhook not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error rotating webhook secret", "err", err)
+			respondWithError(w, 500, "Error rotating webhook secret")
+			return
+		}
+
+		respondWithJSON(w, 200, newWebhookResponse(hook, true))
+	}
+}
+
+/*
+Endpoint: GET /v1/webhooks/{webhook_id}/deliveries
+
+# This is an authenticated endpoint
+
+Lists the latest deliveries of the webhook with their outcome and the payload
+that was sent, paginated with limit and cursor (see pagination.go).
+*/
+func getWebhookDeliveriesHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		context := r.Context()
+		hook, ok := userWebhook(context, apiConfig, w, r, user)
+		if !ok {
+			return
+		}
+
+		page, err := parsePageRequest(r)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+
+		deliveries, err := apiConfig.DB.GetWebhookDeliveries(context, database.GetWebhookDeliveriesParams{
+			WebhookID:  hook.ID,
+			BeforeTime: page.BeforeTime(),
+			BeforeID:   page.BeforeID(),
+			Limit:      page.QueryLimit(),
+		})
+		if err != nil {
+			httpLog.Error("Error getting webhook deliveries", "err", err)
+			respondWithError(w, 500, "Error getting webhook deliveries")
+			return
+		}
+		deliveries = finishPage(w, r, page, deliveries, func(delivery database.WebhookDelivery) pageCursor {
+			return pageCursor{Time: delivery.CreatedAt, ID: delivery.ID}
+		})
+
+		resp := []webhookDeliveryResponse{}
+		for _, delivery := range deliveries {
+			resp = append(resp, newWebhookDeliveryResponse(delivery))
+		}
+
+		respondWithJSON(w, 200, resp)
+	}
+}
+
+/*
+Endpoint: POST /v1/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver
+
+# This is an authenticated endpoint
+
+Sends the payload of an earlier delivery again, signed with the current
+secrets, and responds with the new delivery once it is done.
+*/
+func redeliverWebhookHandler(apiConfig apiConfig) func(w http.ResponseWriter, r *http.Request, user database.User) {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		deliveryID, err := uuid.Parse(chi.URLParam(r, "delivery_id"))
+		if err != nil {
+			respondWithErrorCode(w, 400, "invalid_id", "Invalid delivery_id")
+			return
+		}
+
+		context := r.Context()
+		hook, ok := userWebhook(context, apiConfig, w, r, user)
+		if !ok {
+			return
+		}
+
+		original, err := apiConfig.DB.GetWebhookDelivery(context, database.GetWebhookDeliveryParams{
+			ID:        deliveryID,
+			WebhookID: hook.ID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithErrorCode(w, 404, "delivery_not_found", "Delivery not found")
+			return
+		}
+		if err != nil {
+			httpLog.Error("Error getting webhook delivery", "err", err)
+			respondWithError(w, 500, "Error redelivering webhook")
+			return
+		}
+
+		delivery, err := apiConfig.DB.CreateWebhookDelivery(context, database.CreateWebhookDeliveryParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			WebhookID:   hook.ID,
+			EventType:   original.EventType,
+			Payload:     original.Payload,
+			Redelivery:  true,
+			ContentType: original.ContentType,
+		})
+		if err != nil {
+			httpLog.Error("Error creating webhook delivery", "err", err)
+			respondWithError(w, 500, "Error redelivering webhook")
+			return
+		}
+
+		delivery = deliverWebhook(r.Context(), apiConfig, hook, delivery)
+
+		respondWithJSON(w, 200, newWebhookDeliveryResponse(delivery))
+	}
+}
+
+// userWebhook loads the webhook named in the url, responding with an error
+// and returning false when it doesn't exist or belongs to someone else.
+func userWebhook(ctx context.Context, apiConfig apiConfig, w http.ResponseWriter, r *http.Request, user database.User) (database.Webhook, bool) {
+	webhookID, err := uuid.Parse(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		respondWithErrorCode(w, 400, "invalid_id", "Invalid webhook_id")
+		return database.Webhook{}, false
+	}
+
+	hook, err := apiConfig.DB.GetWebhook(ctx, database.GetWebhookParams{
+		ID:     webhookID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithErrorCode(w, 404, "webhook_not_found", "Webhook not found")
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		httpLog.Error("Error getting webhook", "err", err)
+		respondWithError(w, 500, "Error getting webhook")
+		return database.Webhook{}, false
+	}
+
+	return hook, true
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+/*
+YouTube has an Atom feed for every channel and playlist, it just doesn't link
+it from all of its pages. Channel and playlist urls are translated to it when
+a feed is added:
+
+	https://www.youtube.com/channel/UC...        videos.xml?channel_id=UC...
+	https://www.youtube.com/user/name           videos.xml?user=name
+	https://www.youtube.com/playlist?list=PL... videos.xml?playlist_id=PL...
+
+Pages of handles (/@name) and custom urls (/c/name) do link the feed, so
+discovery finds it. The Atom feed has the latest 15 videos, with their
+thumbnail but not their duration. With YOUTUBE_API_KEY set the fetcher reads
+the feed through the YouTube Data API instead, which gives both.
+*/
+
+const youtubeFeedBase = "https://www.youtube.com/feeds/videos.xml"
+
+// youtubeAPIBase is the YouTube Data API, youtubeAPIKey its key from
+// YOUTUBE_API_KEY, empty to read the Atom feeds.
+var (
+	youtubeAPIBase = "https://www.googleapis.com/youtube/v3"
+	youtubeAPIKey  string
+)
+
+// youtubeAPIMaxVideos is how many of the latest videos a fetch through the
+// API reads, the most a single page of the API returns.
+const youtubeAPIMaxVideos = 50
+
+func isYouTubeHost(host string) bool {
+	switch strings.TrimPrefix(strings.ToLower(host), "www.") {
+	case "youtube.com", "m.youtube.com", "music.youtube.com":
+		return true
+	}
+	return false
+}
+
+// youtubeFeedURL translates the url of a YouTube channel or playlist to its
+// feed. ok is false for other urls, which are left to discovery.
+func youtubeFeedURL(raw string) (feedURL string, ok bool) {
+	u, err := url.Parse(raw)
+	if err != nil || !isYouTubeHost(u.Host) {
+		return "", false
+	}
+
+	query := url.Values{}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case u.Path == "/feeds/videos.xml":
+		return "", false
+	case u.Query().Get("list") != "":
+		query.Set("playlist_id", u.Query().Get("list"))
+	case len(segments) >= 2 && segments[0] == "channel":
+		query.Set("channel_id", segments[1])
+	case len(segments) >= 2 && segments[0] == "user":
+		query.Set("user", segments[1])
+	default:
+		return "", false
+	}
+
+	return youtubeFeedBase + "?" + query.Encode(), true
+}
+
+// isYouTubeFeed reports whether feedURL is one of the Atom feeds of YouTube.
+func isYouTubeFeed(feedURL string) bool {
+	u, err := url.Parse(feedURL)
+	return err == nil && isYouTubeHost(u.Host) && u.Path == "/feeds/videos.xml"
+}
+
+// youtubeAPIError is an error answer of the Data API. Unlike a missing feed
+// it doesn't mean the channel is gone, it's mostly a used up quota.
+type youtubeAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *youtubeAPIError) Error() string {
+	return fmt.Sprintf("youtube api: %d %s", e.StatusCode, e.Message)
+}
+
+// youtubeAPIGet calls a method of the Data API and decodes its answer into v.
+func youtubeAPIGet(ctx context.Context, method string, params url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, youtubeAPIBase+"/"+method+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// in a header rather than the url, which ends up in errors and logs
+	req.Header.Set("X-Goog-Api-Key", youtubeAPIKey)
+
+	resp, err := feedClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return &youtubeAPIError{StatusCode: resp.StatusCode, Message: apiErr.Error.Message}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type youtubeSnippet struct {
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	PublishedAt  time.Time `json:"publishedAt"`
+	ChannelTitle string    `json:"channelTitle"`
+	Thumbnails   map[string]struct {
+		URL    string `json:"url"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"thumbnails"`
+}
+
+// thumbnail is the url of the largest thumbnail.
+func (s youtubeSnippet) thumbnail() string {
+	for _, size := range []string{"maxres", "standard", "high", "medium", "default"} {
+		if thumbnail, ok := s.Thumbnails[size]; ok && thumbnail.URL != "" {
+			return thumbnail.URL
+		}
+	}
+	return ""
+}
+
+// youtubeAPIFeed reads the channel or playlist of a YouTube feed url through
+// the Data API, into the feed the Atom feed would have been, with durations.
+func youtubeAPIFeed(ctx context.Context, feedURL string) (*gofeed.Feed, error) {
+	u, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+
+	feed := &gofeed.Feed{FeedType: "youtube"}
+	var playlistID string
+	if id := query.Get("playlist_id"); id != "" {
+		var playlists struct {
+			Items []struct {
+				ID      string         `json:"id"`
+				Snippet youtubeSnippet `json:"snippet"`
+			} `json:"items"`
+		}
+		err := youtubeAPIGet(ctx, "playlists", url.Values{"part": {"snippet"}, "id": {id}}, &playlists)
+		if err != nil {
+			return nil, err
+		}
+		if len(playlists.Items) == 0 {
+			return nil, &feedStatusError{StatusCode: http.StatusNotFound}
+		}
+
+		playlistID = playlists.Items[0].ID
+		feed.Title = playlists.Items[0].Snippet.Title
+		feed.Description = playlists.Items[0].Snippet.Description
+		feed.Link = "https://www.youtube.com/playlist?list=" + url.QueryEscape(playlistID)
+	} else {
+		params := url.Values{"part": {"snippet,contentDetails"}}
+		switch {
+		case query.Get("channel_id") != "":
+			params.Set("id", query.Get("channel_id"))
+		case query.Get("user") != "":
+			params.Set("forUsername", query.Get("user"))
+		default:
+			return nil, errors.New("youtube feed url names no channel or playlist")
+		}
+
+		var channels struct {
+			Items []struct {
+				ID             string         `json:"id"`
+				Snippet        youtubeSnippet `json:"snippet"`
+				ContentDetails struct {
+					RelatedPlaylists struct {
+						Uploads string `json:"uploads"`
+					} `json:"relatedPlaylists"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+		err := youtubeAPIGet(ctx, "channels", params, &channels)
+		if err != nil {
+			return nil, err
+		}
+		if len(channels.Items) == 0 {
+			return nil, &feedStatusError{StatusCode: http.StatusNotFound}
+		}
+
+		channel := channels.Items[0]
+		playlistID = channel.ContentDetails.RelatedPlaylists.Uploads
+		feed.Title = channel.Snippet.Title
+		feed.Description = channel.Snippet.Description
+		feed.Link = "https://www.youtube.com/channel/" + channel.ID
+	}
+
+	var playlistItems struct {
+		Items []struct {
+			ContentDetails struct {
+				VideoID string `json:"videoId"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	err = youtubeAPIGet(ctx, "playlistItems", url.Values{
+		"part":       {"contentDetails"},
+		"playlistId": {playlistID},
+		"maxResults": {strconv.Itoa(youtubeAPIMaxVideos)},
+	}, &playlistItems)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, item := range playlistItems.Items {
+		ids = append(ids, item.ContentDetails.VideoID)
+	}
+	if len(ids) == 0 {
+		return feed, nil
+	}
+
+	// private and deleted videos stay in playlists, but videos leaves them out
+	var videos struct {
+		Items []struct {
+			ID             string         `json:"id"`
+			Snippet        youtubeSnippet `json:"snippet"`
+			ContentDetails struct {
+				Duration string `json:"duration"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	err = youtubeAPIGet(ctx, "videos", url.Values{
+		"part": {"snippet,contentDetails"},
+		"id":   {strings.Join(ids, ",")},
+	}, &videos)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, video := range videos.Items {
+		published := video.Snippet.PublishedAt
+		// the guid is the id of the Atom feed, so switching between the two keeps the posts
+		item := &gofeed.Item{
+			GUID:            "yt:video:" + video.ID,
+			Title:           video.Snippet.Title,
+			Description:     video.Snippet.Description, // up to 5000 characters, kept whole
+			Link:            "https://www.youtube.com/watch?v=" + url.QueryEscape(video.ID),
+			Published:       published.Format(time.RFC3339),
+			PublishedParsed: &published,
+			Authors:         []*gofeed.Person{{Name: video.Snippet.ChannelTitle}},
+		}
+
+		content := ext.Extension{Name: "content", Attrs: map[string]string{"url": item.Link, "medium": "video"}}
+		if seconds, ok := parseISODuration(video.ContentDetails.Duration); ok {
+			content.Attrs["duration"] = strconv.Itoa(seconds)
+		}
+		group := ext.Extension{Name: "group", Children: map[string][]ext.Extension{"content": {content}}}
+		if thumbnail := video.Snippet.thumbnail(); thumbnail != "" {
+			group.Children["thumbnail"] = []ext.Extension{{Name: "thumbnail", Attrs: map[string]string{"url": thumbnail}}}
+		}
+		item.Extensions = ext.Extensions{"media": {"group": {group}}}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed, nil
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?T?(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+
+// parseISODuration parses the ISO 8601 durations of the Data API, like
+// PT1H2M3S, into seconds. Live streams have P0D, which is no duration.
+func parseISODuration(duration string) (int, bool) {
+	match := isoDuration.FindStringSubmatch(duration)
+	if match == nil || duration == "P" || duration == "PT" {
+		return 0, false
+	}
+
+	seconds := 0
+	for i, unit := range []int{24 * 60 * 60, 60 * 60, 60, 1} {
+		if match[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return 0, false
+		}
+		seconds += n * unit
+	}
+
+	return seconds, seconds > 0
+}